@@ -0,0 +1,4 @@
+// Package walletv1 - gRPC API сервиса, сгенерированный из wallet.proto
+package walletv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative wallet/v1/wallet.proto
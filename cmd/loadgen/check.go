@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+)
+
+// invariants - итог сверки балансов на сервере с ожидаемыми
+type invariants struct {
+	WalletsChecked int `json:"wallets_checked"`
+	// UnknownOutcomes - операции без ответа, повторенные с тем же ключом
+	// идемпотентности; Unresolved из них так и не получили ответа
+	UnknownOutcomes  int        `json:"unknown_outcomes"`
+	Unresolved       int        `json:"unresolved"`
+	ExpectedTotal    int64      `json:"expected_total"`
+	ActualTotal      int64      `json:"actual_total"`
+	NegativeBalances int        `json:"negative_balances"`
+	Mismatches       []mismatch `json:"mismatches"`
+	// Reconciliation - сверка сервера по всем кошелькам, если задан -admin-token
+	Reconciliation *reconciliationCheck `json:"reconciliation,omitempty"`
+}
+
+type mismatch struct {
+	WalletID uuid.UUID `json:"wallet_id"`
+	Expected int64     `json:"expected"`
+	Actual   int64     `json:"actual"`
+}
+
+type reconciliationCheck struct {
+	RunID          uuid.UUID `json:"run_id"`
+	WalletsChecked int64     `json:"wallets_checked"`
+	Discrepancies  int64     `json:"discrepancies"`
+}
+
+// Passed - балансы сошлись и проверены все кошельки
+func (i *invariants) Passed() bool {
+	return len(i.Mismatches) == 0 && i.NegativeBalances == 0 && i.Unresolved == 0 &&
+		(i.Reconciliation == nil || i.Reconciliation.Discrepancies == 0)
+}
+
+// check выясняет исход операций без ответа и сравнивает баланс каждого
+// кошелька с суммой проведенных операций и комиссий
+func (r *runner) check(ctx context.Context) (invariants, error) {
+	inv := invariants{Mismatches: []mismatch{}}
+	var mu sync.Mutex
+	err := parallel(ctx, len(r.wallets), r.opts.concurrency, func(ctx context.Context, i int) error {
+		w := r.wallets[i]
+		c, err := r.opts.newClient(r.slow, client.WithToken(w.c.Token()))
+		if err != nil {
+			return err
+		}
+		unknown, unresolved := len(w.unknown), r.resolve(ctx, c, w)
+		current, err := c.GetWallet(ctx, w.id)
+		if err != nil {
+			return fmt.Errorf("get wallet %s: %w", w.id, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		inv.WalletsChecked++
+		inv.UnknownOutcomes += unknown
+		inv.Unresolved += unresolved
+		if current.Balance < 0 {
+			inv.NegativeBalances++
+		}
+		if unresolved > 0 {
+			return nil
+		}
+		inv.ExpectedTotal += w.expected
+		inv.ActualTotal += current.Balance
+		if current.Balance != w.expected {
+			inv.Mismatches = append(inv.Mismatches, mismatch{WalletID: w.id, Expected: w.expected, Actual: current.Balance})
+		}
+		return nil
+	})
+	if err != nil {
+		return inv, err
+	}
+	sort.Slice(inv.Mismatches, func(i, j int) bool {
+		return inv.Mismatches[i].WalletID.String() < inv.Mismatches[j].WalletID.String()
+	})
+
+	if r.opts.adminToken != "" {
+		admin, err := r.opts.newClient(r.slow, client.WithToken(r.opts.adminToken))
+		if err != nil {
+			return inv, err
+		}
+		report, err := admin.RunReconciliation(ctx)
+		if err != nil {
+			return inv, fmt.Errorf("reconciliation: %w", err)
+		}
+		inv.Reconciliation = &reconciliationCheck{
+			RunID:          report.Run.ID,
+			WalletsChecked: report.Run.WalletsChecked,
+			Discrepancies:  report.Run.Discrepancies,
+		}
+	}
+	return inv, nil
+}
+
+// resolve повторяет операции без ответа с их ключами идемпотентности:
+// сервер вернет первый результат или проведет операцию сейчас, и ожидаемый
+// баланс снова точен. Возвращает число операций, оставшихся без ответа.
+func (r *runner) resolve(ctx context.Context, c *client.Client, w *wallet) int {
+	unresolved := 0
+	for _, op := range w.unknown {
+		var (
+			result *client.OperationResult
+			err    error
+		)
+		for attempt := 0; attempt < 5; attempt++ {
+			if result, err = c.ProcessOperation(ctx, op); err == nil || !uncertain(err) {
+				break
+			}
+			time.Sleep(time.Duration(attempt+1) * 200 * time.Millisecond)
+		}
+		switch {
+		case err == nil:
+			w.apply(op, result)
+		case uncertain(err):
+			unresolved++
+		}
+	}
+	return unresolved
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mailSeparator - начало письма в файле FileMailer
+const mailSeparator = "----- mail -----\r\n"
+
+var tokenPattern = regexp.MustCompile(`[?&]token=([^\s&]+)`)
+
+// waitForTokens ждет, пока в файле писем появятся ссылки подтверждения для
+// всех кошельков, и возвращает токены по адресам. Письма отправляются
+// асинхронно, поэтому файл перечитывается до timeout.
+func waitForTokens(ctx context.Context, path string, wallets []*wallet, timeout time.Duration) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		tokens, err := readTokens(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		missing := 0
+		for _, w := range wallets {
+			if tokens[w.email] == "" {
+				missing++
+			}
+		}
+		if missing == 0 {
+			return tokens, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no verification email for %d of %d users in %s; is the API running with MAIL_DRIVER=file and MAIL_FILE_PATH=%s?",
+				missing, len(wallets), path, path)
+		case <-ticker.C:
+		}
+	}
+}
+
+// readTokens разбирает файл писем; для адреса берется последняя ссылка
+func readTokens(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]string)
+	for _, msg := range strings.Split(string(data), mailSeparator) {
+		header, body, _ := strings.Cut(msg, "\r\n\r\n")
+		var to string
+		for _, line := range strings.Split(header, "\r\n") {
+			if v, ok := strings.CutPrefix(line, "To: "); ok {
+				to = strings.ToLower(strings.TrimSpace(v))
+			}
+		}
+		m := tokenPattern.FindStringSubmatch(body)
+		if to == "" || m == nil || !strings.Contains(body, "/verify-email?") {
+			continue
+		}
+		if token, err := url.QueryUnescape(m[1]); err == nil {
+			tokens[to] = token
+		}
+	}
+	return tokens, nil
+}
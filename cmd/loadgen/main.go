@@ -0,0 +1,177 @@
+// Command loadgen - генератор нагрузки на HTTP API: создает пользователей и
+// кошельки, подает смесь пополнений, списаний и чтений с заданной частотой
+// и перекосом на "горячие" кошельки, затем печатает перцентили задержек,
+// разбивку ошибок и сверяет балансы на сервере с ожидаемыми.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"walletapitest/pkg/client"
+)
+
+const usage = `Usage: loadgen [flags]
+
+Creates -users users with one wallet each, funds them, then sends -rate
+requests per second for -duration and checks every wallet's balance.
+
+Withdrawals need verified emails: run the API with MAIL_DRIVER=file and pass
+the same file as -mail-file, or use a mix without withdrawals.
+
+Flags:
+`
+
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitViolation = 3
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// Первый Ctrl-C останавливает нагрузку, но не проверку; второй - процесс
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// options - параметры прогона
+type options struct {
+	url         string
+	users       int
+	currency    string
+	fund        int64
+	rate        int
+	duration    time.Duration
+	concurrency int
+	mix         mix
+	hotWallets  int
+	hotShare    float64
+	minAmount   int64
+	maxAmount   int64
+	timeout     time.Duration
+	retries     int
+	mailFile    string
+	adminToken  string
+	seed        int64
+	json        bool
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.url, "url", envOr("LOADGEN_URL", "http://localhost:8080"), "API base URL (LOADGEN_URL)")
+	fs.IntVar(&opts.users, "users", 100, "users to create, one wallet each")
+	fs.StringVar(&opts.currency, "currency", "USD", "wallet currency")
+	fs.Int64Var(&opts.fund, "fund", 1_000_000, "initial deposit per wallet in minor units")
+	fs.IntVar(&opts.rate, "rate", 500, "target requests per second")
+	fs.DurationVar(&opts.duration, "duration", 30*time.Second, "load duration")
+	fs.IntVar(&opts.concurrency, "concurrency", 64, "requests in flight at most; ticks are dropped when all are busy")
+	mixRaw := fs.String("mix", "deposit=40,withdraw=40,read=20", "relative weights of deposit, withdraw and read")
+	fs.IntVar(&opts.hotWallets, "hot-wallets", 5, "wallets that receive -hot-share of the traffic")
+	fs.Float64Var(&opts.hotShare, "hot-share", 0.5, "share of requests sent to the hot wallets, 0..1")
+	fs.Int64Var(&opts.minAmount, "min-amount", 1, "smallest operation amount")
+	fs.Int64Var(&opts.maxAmount, "max-amount", 1000, "largest operation amount; keep it below MFA_WITHDRAWAL_THRESHOLD")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout of a single request")
+	fs.IntVar(&opts.retries, "retries", 1, "attempts per request including the first, see pkg/client")
+	fs.StringVar(&opts.mailFile, "mail-file", os.Getenv("MAIL_FILE_PATH"), "MAIL_DRIVER=file output to read verification links from (MAIL_FILE_PATH)")
+	fs.StringVar(&opts.adminToken, "admin-token", os.Getenv("LOADGEN_ADMIN_TOKEN"), "admin access token; if set, a reconciliation run follows the check (LOADGEN_ADMIN_TOKEN)")
+	fs.Int64Var(&opts.seed, "seed", 0, "random seed of the operation sequence; 0 - current time")
+	output := fs.String("o", "table", "report format: table or json")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	var err error
+	if opts.mix, err = parseMix(*mixRaw); err == nil {
+		err = opts.validate(*output)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "loadgen: %v\n", err)
+		return exitUsage
+	}
+	opts.json = *output == "json"
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	report, err := newRunner(opts, stderr).run(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "loadgen: %v\n", err)
+		return exitError
+	}
+	if err := report.write(stdout, opts.json); err != nil {
+		fmt.Fprintf(stderr, "loadgen: %v\n", err)
+		return exitError
+	}
+	if !report.Invariants.Passed() {
+		return exitViolation
+	}
+	return exitOK
+}
+
+func (o *options) validate(output string) error {
+	switch {
+	case output != "table" && output != "json":
+		return fmt.Errorf("unknown output format %q", output)
+	case o.users < 1:
+		return errors.New("-users must be positive")
+	case o.rate < 1 || o.concurrency < 1:
+		return errors.New("-rate and -concurrency must be positive")
+	case o.duration <= 0:
+		return errors.New("-duration must be positive")
+	case o.hotWallets < 0 || o.hotWallets > o.users:
+		return errors.New("-hot-wallets must be between 0 and -users")
+	case o.hotShare < 0 || o.hotShare > 1:
+		return errors.New("-hot-share must be between 0 and 1")
+	case o.minAmount < 1 || o.maxAmount < o.minAmount:
+		return errors.New("amounts must satisfy 1 <= -min-amount <= -max-amount")
+	case o.fund < 0:
+		return errors.New("-fund must not be negative")
+	case o.mix[opWithdraw] > 0 && o.mailFile == "":
+		return errors.New("withdrawals need verified emails: pass -mail-file or drop withdraw from -mix")
+	}
+	return nil
+}
+
+// newClient - клиент с общим пулом соединений и политикой повторов прогона
+func (o *options) newClient(hc *http.Client, opts ...client.Option) (*client.Client, error) {
+	opts = append([]client.Option{
+		client.WithHTTPClient(hc),
+		client.WithRetry(client.RetryPolicy{MaxAttempts: o.retries, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second}),
+	}, opts...)
+	return client.New(o.url, opts...)
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+type report struct {
+	Seed       int64       `json:"seed"`
+	Setup      setupReport `json:"setup"`
+	Load       loadReport  `json:"load"`
+	Invariants invariants  `json:"invariants"`
+}
+
+type setupReport struct {
+	Users    int    `json:"users"`
+	Duration millis `json:"duration_ms"`
+}
+
+type loadReport struct {
+	TargetRate int     `json:"target_rate"`
+	Achieved   float64 `json:"achieved_rate"`
+	Duration   millis  `json:"duration_ms"`
+	// Scheduled - тики за прогон; Dropped из них не отправлены, потому что
+	// все воркеры были заняты
+	Scheduled int          `json:"scheduled"`
+	Dropped   int          `json:"dropped"`
+	Ops       []opReport   `json:"operations"`
+	Errors    []errorCount `json:"errors"`
+}
+
+// opReport - запросы одного вида; задержки от запланированного момента
+type opReport struct {
+	Op     string `json:"op"`
+	Count  int    `json:"count"`
+	OK     int    `json:"ok"`
+	Errors int    `json:"errors"`
+	P50    millis `json:"p50_ms"`
+	P90    millis `json:"p90_ms"`
+	P99    millis `json:"p99_ms"`
+	Max    millis `json:"max_ms"`
+}
+
+type errorCount struct {
+	Op    string `json:"op"`
+	Class string `json:"class"`
+	Count int    `json:"count"`
+}
+
+// millis - длительность, которая в JSON выводится в миллисекундах
+type millis time.Duration
+
+func (m millis) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, float64(m)/float64(time.Millisecond), 'f', 3, 64), nil
+}
+
+func (r *report) write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Setup: %d users and wallets in %s (seed %d)\n", r.Setup.Users, time.Duration(r.Setup.Duration).Round(time.Millisecond), r.Seed)
+	fmt.Fprintf(tw, "Load: %s at %d rps target, %.1f rps achieved, %d scheduled, %d dropped\n\n",
+		time.Duration(r.Load.Duration).Round(time.Millisecond), r.Load.TargetRate, r.Load.Achieved, r.Load.Scheduled, r.Load.Dropped)
+
+	fmt.Fprintln(tw, "OP\tCOUNT\tOK\tERRORS\tP50\tP90\tP99\tMAX")
+	for _, op := range r.Load.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n", op.Op, op.Count, op.OK, op.Errors,
+			latency(op.P50), latency(op.P90), latency(op.P99), latency(op.Max))
+	}
+	if len(r.Load.Errors) > 0 {
+		fmt.Fprintln(tw, "\nOP\tERROR\tCOUNT")
+		for _, e := range r.Load.Errors {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", e.Op, e.Class, e.Count)
+		}
+	}
+
+	inv := r.Invariants
+	fmt.Fprintf(tw, "\nWallets checked\t%d\n", inv.WalletsChecked)
+	fmt.Fprintf(tw, "Unknown outcomes\t%d (unresolved %d)\n", inv.UnknownOutcomes, inv.Unresolved)
+	fmt.Fprintf(tw, "Expected total\t%d\n", inv.ExpectedTotal)
+	fmt.Fprintf(tw, "Actual total\t%d\n", inv.ActualTotal)
+	fmt.Fprintf(tw, "Negative balances\t%d\n", inv.NegativeBalances)
+	fmt.Fprintf(tw, "Balance mismatches\t%d\n", len(inv.Mismatches))
+	if rec := inv.Reconciliation; rec != nil {
+		fmt.Fprintf(tw, "Reconciliation\trun %s, %d wallets, %d discrepancies\n", rec.RunID, rec.WalletsChecked, rec.Discrepancies)
+	}
+	for _, m := range inv.Mismatches {
+		fmt.Fprintf(tw, "  %s\texpected %d, actual %d\n", m.WalletID, m.Expected, m.Actual)
+	}
+	result := "PASS"
+	if !inv.Passed() {
+		result = "FAIL"
+	}
+	fmt.Fprintf(tw, "Result\t%s\n", result)
+	return tw.Flush()
+}
+
+func latency(m millis) string {
+	if m == 0 {
+		return "-"
+	}
+	return time.Duration(m).Round(10 * time.Microsecond).String()
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+)
+
+type opKind int
+
+const (
+	opDeposit opKind = iota
+	opWithdraw
+	opRead
+	opKinds
+)
+
+var opNames = [opKinds]string{"deposit", "withdraw", "read"}
+
+// mix - относительные веса видов запросов
+type mix [opKinds]int
+
+func parseMix(raw string) (mix, error) {
+	var m mix
+	total := 0
+	for _, part := range strings.Split(raw, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		w, err := strconv.Atoi(weight)
+		if !ok || err != nil || w < 0 {
+			return m, fmt.Errorf("invalid -mix entry %q, want name=weight", part)
+		}
+		kind := -1
+		for i, n := range opNames {
+			if n == name {
+				kind = i
+			}
+		}
+		if kind < 0 {
+			return m, fmt.Errorf("unknown operation %q in -mix", name)
+		}
+		m[kind] = w
+		total += w
+	}
+	if total == 0 {
+		return m, errors.New("-mix has no positive weights")
+	}
+	return m, nil
+}
+
+func (m mix) pick(rng *rand.Rand) opKind {
+	total := 0
+	for _, w := range m {
+		total += w
+	}
+	n := rng.Intn(total)
+	for kind, w := range m {
+		if n < w {
+			return opKind(kind)
+		}
+		n -= w
+	}
+	return opRead
+}
+
+// wallet - кошелек прогона и баланс, который должен быть на сервере
+type wallet struct {
+	id    uuid.UUID
+	email string
+	c     *client.Client
+
+	mu       sync.Mutex
+	expected int64
+	// unknown - операции без ответа: сервер мог провести их или нет
+	unknown []client.OperationRequest
+}
+
+// apply учитывает проведенную операцию вместе с комиссией
+func (w *wallet) apply(op client.OperationRequest, result *client.OperationResult) {
+	delta := op.Amount
+	if op.Type == client.Withdraw {
+		delta = -delta
+	}
+	if result.Fee != nil {
+		delta -= result.Fee.Amount
+	}
+	w.mu.Lock()
+	w.expected += delta
+	w.mu.Unlock()
+}
+
+func (w *wallet) remember(op client.OperationRequest) {
+	w.mu.Lock()
+	w.unknown = append(w.unknown, op)
+	w.mu.Unlock()
+}
+
+// uncertain - по ошибке нельзя сказать, проведена ли операция: ответа нет
+// или это 5xx
+func uncertain(err error) bool {
+	var apiErr *client.APIError
+	return !errors.As(err, &apiErr) || errors.Is(err, client.ErrServer) || errors.Is(err, client.ErrUnavailable)
+}
+
+type runner struct {
+	opts options
+	log  io.Writer
+	// hc - соединения нагрузки с таймаутом -timeout; slow - для подготовки и
+	// проверки, которым короткий таймаут мешал бы
+	hc   *http.Client
+	slow *http.Client
+	// anon - клиент без токена для регистрации и подтверждения email
+	anon    *client.Client
+	wallets []*wallet
+}
+
+func newRunner(opts options, log io.Writer) *runner {
+	return &runner{
+		opts: opts,
+		log:  log,
+		hc: &http.Client{
+			Timeout: opts.timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        opts.concurrency,
+				MaxIdleConnsPerHost: opts.concurrency,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		slow: &http.Client{Timeout: max(opts.timeout, 30*time.Second)},
+	}
+}
+
+func (r *runner) run(ctx context.Context) (*report, error) {
+	var err error
+	if r.anon, err = r.opts.newClient(r.slow); err != nil {
+		return nil, err
+	}
+	rep := &report{Seed: r.opts.seed}
+
+	started := time.Now()
+	fmt.Fprintf(r.log, "loadgen: creating %d users and wallets\n", r.opts.users)
+	if err := r.setup(ctx); err != nil {
+		return nil, fmt.Errorf("setup: %w", err)
+	}
+	rep.Setup = setupReport{Users: r.opts.users, Duration: millis(time.Since(started))}
+
+	fmt.Fprintf(r.log, "loadgen: sending %d rps for %s\n", r.opts.rate, r.opts.duration)
+	rep.Load = r.load(ctx)
+
+	// Проверка идет и после Ctrl-C: прерванный прогон тоже должен сойтись
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+	defer cancel()
+	fmt.Fprintln(r.log, "loadgen: checking balances")
+	if rep.Invariants, err = r.check(checkCtx); err != nil {
+		return nil, fmt.Errorf("check: %w", err)
+	}
+	return rep, nil
+}
+
+func (r *runner) setup(ctx context.Context) error {
+	runID := uuid.NewString()[:8]
+	r.wallets = make([]*wallet, r.opts.users)
+	err := parallel(ctx, r.opts.users, r.opts.concurrency, func(ctx context.Context, i int) error {
+		w, err := r.createWallet(ctx, runID, i)
+		r.wallets[i] = w
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if r.opts.mix[opWithdraw] > 0 {
+		return r.verifyEmails(ctx)
+	}
+	return nil
+}
+
+// createWallet регистрирует пользователя, входит от его имени, создает
+// кошелек и пополняет его на -fund
+func (r *runner) createWallet(ctx context.Context, runID string, i int) (*wallet, error) {
+	email := fmt.Sprintf("loadgen-%s-%d@example.com", runID, i)
+	password := "loadgen-" + runID
+	user, err := r.anon.CreateUser(ctx, email, fmt.Sprintf("loadgen_%s_%d", runID, i), password)
+	if err != nil {
+		return nil, fmt.Errorf("create user %s: %w", email, err)
+	}
+	c, err := r.opts.newClient(r.slow)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Login(ctx, email, password, ""); err != nil {
+		return nil, fmt.Errorf("login %s: %w", email, err)
+	}
+	created, err := c.CreateWallet(ctx, user.ID, r.opts.currency)
+	if err != nil {
+		return nil, fmt.Errorf("create wallet for %s: %w", email, err)
+	}
+
+	load, err := r.opts.newClient(r.hc, client.WithToken(c.Token()))
+	if err != nil {
+		return nil, err
+	}
+	w := &wallet{id: created.ID, email: email, c: load}
+	if r.opts.fund > 0 {
+		op := client.OperationRequest{WalletID: w.id, Type: client.Deposit, Amount: r.opts.fund}
+		result, err := c.ProcessOperation(ctx, op)
+		if err != nil {
+			return nil, fmt.Errorf("fund wallet %s: %w", w.id, err)
+		}
+		w.apply(op, result)
+	}
+	return w, nil
+}
+
+// verifyEmails подтверждает email всех пользователей ссылками из файла,
+// куда пишет письма сервер с MAIL_DRIVER=file
+func (r *runner) verifyEmails(ctx context.Context) error {
+	tokens, err := waitForTokens(ctx, r.opts.mailFile, r.wallets, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	return parallel(ctx, len(r.wallets), r.opts.concurrency, func(ctx context.Context, i int) error {
+		if _, err := r.anon.VerifyEmail(ctx, tokens[r.wallets[i].email]); err != nil {
+			return fmt.Errorf("verify %s: %w", r.wallets[i].email, err)
+		}
+		return nil
+	})
+}
+
+// job - запрос, запланированный на момент due
+type job struct {
+	due    time.Time
+	kind   opKind
+	wallet *wallet
+	amount int64
+}
+
+// load подает запросы с постоянной частотой, не дожидаясь ответов.
+// Задержка считается от запланированного момента, поэтому ожидание
+// свободного воркера в нее входит; если заняты все, тик пропускается.
+func (r *runner) load(ctx context.Context) loadReport {
+	loadCtx, cancel := context.WithTimeout(ctx, r.opts.duration)
+	defer cancel()
+
+	jobs := make(chan job, r.opts.concurrency)
+	workers := make([]*stats, r.opts.concurrency)
+	var wg sync.WaitGroup
+	for i := range workers {
+		workers[i] = newStats()
+		wg.Add(1)
+		go func(s *stats) {
+			defer wg.Done()
+			for j := range jobs {
+				r.execute(ctx, j, s)
+			}
+		}(workers[i])
+	}
+
+	rng := rand.New(rand.NewSource(r.opts.seed))
+	interval := time.Second / time.Duration(r.opts.rate)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var scheduled, dropped int
+	started := time.Now()
+	next := started
+pace:
+	for {
+		now := time.Now()
+		for !next.After(now) {
+			select {
+			case jobs <- r.nextJob(rng, next):
+			default:
+				dropped++
+			}
+			scheduled++
+			next = next.Add(interval)
+		}
+		timer.Reset(next.Sub(now))
+		select {
+		case <-loadCtx.Done():
+			break pace
+		case <-timer.C:
+		}
+	}
+	elapsed := time.Since(started)
+	close(jobs)
+	wg.Wait()
+
+	total := newStats()
+	for _, s := range workers {
+		total.merge(s)
+	}
+	return total.report(r.opts.rate, elapsed, scheduled, dropped)
+}
+
+func (r *runner) nextJob(rng *rand.Rand, due time.Time) job {
+	j := job{due: due, kind: r.opts.mix.pick(rng), wallet: r.pickWallet(rng)}
+	if j.kind != opRead {
+		j.amount = r.opts.minAmount + rng.Int63n(r.opts.maxAmount-r.opts.minAmount+1)
+	}
+	return j
+}
+
+// pickWallet отдает горячим кошелькам долю -hot-share запросов
+func (r *runner) pickWallet(rng *rand.Rand) *wallet {
+	hot := r.opts.hotWallets
+	if hot > 0 && (hot == len(r.wallets) || rng.Float64() < r.opts.hotShare) {
+		return r.wallets[rng.Intn(hot)]
+	}
+	return r.wallets[hot+rng.Intn(len(r.wallets)-hot)]
+}
+
+func (r *runner) execute(ctx context.Context, j job, s *stats) {
+	w := j.wallet
+	var err error
+	if j.kind == opRead {
+		_, err = w.c.GetWallet(ctx, w.id)
+	} else {
+		op := client.OperationRequest{WalletID: w.id, Type: client.Deposit, Amount: j.amount, IdempotencyKey: uuid.New()}
+		if j.kind == opWithdraw {
+			op.Type = client.Withdraw
+		}
+		var result *client.OperationResult
+		result, err = w.c.ProcessOperation(ctx, op)
+		switch {
+		case err == nil:
+			w.apply(op, result)
+		case uncertain(err):
+			w.remember(op)
+		}
+	}
+	s.record(j.kind, time.Since(j.due), err)
+}
+
+// parallel вызывает fn для 0..n-1 не более чем в workers горутинах и
+// останавливается на первой ошибке
+func parallel(ctx context.Context, n, workers int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	indexes := make(chan int)
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						first = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"walletapitest/pkg/client"
+)
+
+// stats - результаты запросов одного воркера; сливаются после прогона
+type stats struct {
+	latencies [opKinds][]time.Duration
+	ok        [opKinds]int
+	errors    [opKinds]map[string]int
+}
+
+func newStats() *stats {
+	s := &stats{}
+	for i := range s.errors {
+		s.errors[i] = make(map[string]int)
+	}
+	return s
+}
+
+func (s *stats) record(kind opKind, latency time.Duration, err error) {
+	s.latencies[kind] = append(s.latencies[kind], latency)
+	if err == nil {
+		s.ok[kind]++
+		return
+	}
+	s.errors[kind][classify(err)]++
+}
+
+func (s *stats) merge(other *stats) {
+	for kind := range s.latencies {
+		s.latencies[kind] = append(s.latencies[kind], other.latencies[kind]...)
+		s.ok[kind] += other.ok[kind]
+		for class, n := range other.errors[kind] {
+			s.errors[kind][class] += n
+		}
+	}
+}
+
+// classify - класс ошибки для разбивки в отчете
+func classify(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, client.ErrInsufficientFunds):
+		return "insufficient_funds"
+	case errors.Is(err, client.ErrWalletFrozen):
+		return "wallet_frozen"
+	case errors.Is(err, client.ErrEmailNotVerified):
+		return "email_not_verified"
+	case errors.Is(err, client.ErrStepUpRequired):
+		return "step_up_required"
+	case errors.Is(err, client.ErrRateLimited):
+		return "rate_limited"
+	}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("http_%d", apiErr.StatusCode)
+	}
+	return "transport"
+}
+
+func (s *stats) report(rate int, elapsed time.Duration, scheduled, dropped int) loadReport {
+	rep := loadReport{
+		TargetRate: rate,
+		Duration:   millis(elapsed),
+		Scheduled:  scheduled,
+		Dropped:    dropped,
+		Errors:     []errorCount{},
+	}
+	var all []time.Duration
+	total := opReport{Op: "total"}
+	for kind, latencies := range s.latencies {
+		op := opReport{Op: opNames[kind], Count: len(latencies), OK: s.ok[kind]}
+		op.Errors = op.Count - op.OK
+		op.setLatencies(latencies)
+		rep.Ops = append(rep.Ops, op)
+
+		total.Count += op.Count
+		total.OK += op.OK
+		total.Errors += op.Errors
+		all = append(all, latencies...)
+		for class, n := range s.errors[kind] {
+			rep.Errors = append(rep.Errors, errorCount{Op: opNames[kind], Class: class, Count: n})
+		}
+	}
+	total.setLatencies(all)
+	rep.Ops = append(rep.Ops, total)
+	if elapsed > 0 {
+		rep.Achieved = float64(total.Count) / elapsed.Seconds()
+	}
+	sort.Slice(rep.Errors, func(i, j int) bool {
+		if rep.Errors[i].Op != rep.Errors[j].Op {
+			return rep.Errors[i].Op < rep.Errors[j].Op
+		}
+		return rep.Errors[i].Count > rep.Errors[j].Count
+	})
+	return rep
+}
+
+func (o *opReport) setLatencies(latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	o.P50 = millis(percentile(sorted, 0.50))
+	o.P90 = millis(percentile(sorted, 0.90))
+	o.P99 = millis(percentile(sorted, 0.99))
+	o.Max = millis(sorted[len(sorted)-1])
+}
+
+// percentile - наименьшее значение, которого не превышает доля p выборки
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(float64(len(sorted))*p)) - 1
+	return sorted[max(i, 0)]
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+)
+
+// apiBackend выполняет команды через HTTP API с правами токена или ключа
+type apiBackend struct {
+	c *client.Client
+}
+
+func newAPIBackend(baseURL, token, apiKey string) (*apiBackend, error) {
+	if token == "" && apiKey == "" {
+		return nil, errors.New("api mode needs -token or -api-key (WALLETCTL_TOKEN, WALLETCTL_API_KEY)")
+	}
+	c, err := client.New(baseURL, client.WithToken(token), client.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return &apiBackend{c: c}, nil
+}
+
+// GetUser дополняет ответ GET /users/:id ролью, тарифом и статусом email
+// из списка пользователей, если у вызывающего есть users:read_all
+func (b *apiBackend) GetUser(ctx context.Context, id uuid.UUID) (*client.User, error) {
+	user, err := b.c.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	full, err := b.FindUserByEmail(ctx, user.Email)
+	if err != nil || full.ID != user.ID {
+		return user, nil
+	}
+	return full, nil
+}
+
+func (b *apiBackend) FindUserByEmail(ctx context.Context, email string) (*client.User, error) {
+	page, err := b.c.ListUsers(ctx, client.UserFilter{Email: email})
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Users {
+		if strings.EqualFold(page.Users[i].Email, email) {
+			return &page.Users[i], nil
+		}
+	}
+	return nil, errUserNotFound
+}
+
+func (b *apiBackend) ListUsers(ctx context.Context, filter client.UserFilter) (*client.UserPage, error) {
+	return b.c.ListUsers(ctx, filter)
+}
+
+func (b *apiBackend) UserWallets(ctx context.Context, userID uuid.UUID) ([]client.Wallet, error) {
+	balances, err := b.c.UserBalances(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	wallets := make([]client.Wallet, 0, len(balances.Wallets))
+	for _, balance := range balances.Wallets {
+		wallet, err := b.c.GetWallet(ctx, balance.WalletID)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *wallet)
+	}
+	return wallets, nil
+}
+
+func (b *apiBackend) GetWallet(ctx context.Context, id uuid.UUID) (*client.Wallet, error) {
+	return b.c.GetWallet(ctx, id)
+}
+
+func (b *apiBackend) Operations(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]client.Operation, error) {
+	return b.c.ListOperations(ctx, walletID, limit, offset)
+}
+
+func (b *apiBackend) SetFrozen(ctx context.Context, walletID uuid.UUID, frozen bool) error {
+	if frozen {
+		return b.c.FreezeWallet(ctx, walletID)
+	}
+	return b.c.UnfreezeWallet(ctx, walletID)
+}
+
+func (b *apiBackend) Adjust(ctx context.Context, walletID uuid.UUID, amount int64, reason string) (*client.Operation, error) {
+	return b.c.AdjustWallet(ctx, walletID, amount, reason)
+}
+
+func (b *apiBackend) Reconcile(ctx context.Context) (*client.ReconciliationReport, error) {
+	return b.c.RunReconciliation(ctx)
+}
+
+func (b *apiBackend) Statement(ctx context.Context, walletID uuid.UUID, from, to time.Time, format client.StatementFormat, w io.Writer) error {
+	return b.c.Statement(ctx, walletID, from, to, format, w)
+}
+
+func (b *apiBackend) Close() error {
+	return nil
+}
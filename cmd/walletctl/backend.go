@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+// backend - источник данных команд: HTTP API или база данных напрямую.
+// Оба отдают сущности в том виде, в каком их возвращает API, поэтому
+// вывод команд не зависит от режима.
+type backend interface {
+	GetUser(ctx context.Context, id uuid.UUID) (*client.User, error)
+	FindUserByEmail(ctx context.Context, email string) (*client.User, error)
+	ListUsers(ctx context.Context, filter client.UserFilter) (*client.UserPage, error)
+	UserWallets(ctx context.Context, userID uuid.UUID) ([]client.Wallet, error)
+
+	GetWallet(ctx context.Context, id uuid.UUID) (*client.Wallet, error)
+	Operations(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]client.Operation, error)
+	SetFrozen(ctx context.Context, walletID uuid.UUID, frozen bool) error
+	Adjust(ctx context.Context, walletID uuid.UUID, amount int64, reason string) (*client.Operation, error)
+
+	Reconcile(ctx context.Context) (*client.ReconciliationReport, error)
+	Statement(ctx context.Context, walletID uuid.UUID, from, to time.Time, format client.StatementFormat, w io.Writer) error
+
+	Close() error
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"walletapitest/internal/infrastructure/database/migrations"
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+)
+
+// command выполняет команду и возвращает код выхода
+type command func(c *cli, ctx context.Context, b backend, args []string) (int, error)
+
+var commands = map[string]command{
+	"user get":          (*cli).userGet,
+	"user list":         (*cli).userList,
+	"user wallets":      (*cli).userWallets,
+	"wallet get":        (*cli).walletGet,
+	"wallet operations": (*cli).walletOperations,
+	"wallet freeze":     (*cli).walletFreeze,
+	"wallet unfreeze":   (*cli).walletUnfreeze,
+	"wallet adjust":     (*cli).walletAdjust,
+	"reconcile":         (*cli).reconcile,
+	"statement":         (*cli).statement,
+}
+
+func (c *cli) userGet(ctx context.Context, b backend, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+	var (
+		user *client.User
+		err  error
+	)
+	if id, parseErr := uuid.Parse(args[0]); parseErr == nil {
+		user, err = b.GetUser(ctx, id)
+	} else {
+		user, err = b.FindUserByEmail(ctx, args[0])
+	}
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, c.printer.users(*user)
+}
+
+func (c *cli) userList(ctx context.Context, b backend, args []string) (int, error) {
+	fs := newFlagSet("user list")
+	var filter client.UserFilter
+	fs.StringVar(&filter.Email, "email", "", "email prefix")
+	fs.StringVar(&filter.Username, "username", "", "username prefix")
+	fs.IntVar(&filter.Limit, "limit", 0, "page size")
+	fs.IntVar(&filter.Offset, "offset", 0, "users to skip")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil || len(rest) != 0 {
+		return exitUsage, errUsage
+	}
+
+	page, err := b.ListUsers(ctx, filter)
+	if err != nil {
+		return exitError, err
+	}
+	if c.printer.json {
+		return exitOK, c.printer.print(page, nil)
+	}
+	if err := c.printer.users(page.Users...); err != nil {
+		return exitError, err
+	}
+	fmt.Fprintf(c.out, "\n%d of %d users\n", len(page.Users), page.Total)
+	return exitOK, nil
+}
+
+func (c *cli) userWallets(ctx context.Context, b backend, args []string) (int, error) {
+	id, err := singleID(args)
+	if err != nil {
+		return exitUsage, err
+	}
+	wallets, err := b.UserWallets(ctx, id)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, c.printer.wallets(wallets...)
+}
+
+func (c *cli) walletGet(ctx context.Context, b backend, args []string) (int, error) {
+	id, err := singleID(args)
+	if err != nil {
+		return exitUsage, err
+	}
+	wallet, err := b.GetWallet(ctx, id)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, c.printer.wallets(*wallet)
+}
+
+func (c *cli) walletOperations(ctx context.Context, b backend, args []string) (int, error) {
+	fs := newFlagSet("wallet operations")
+	limit := fs.Int("limit", 0, "page size")
+	offset := fs.Int("offset", 0, "operations to skip")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage, errUsage
+	}
+	id, err := singleID(rest)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	operations, err := b.Operations(ctx, id, *limit, *offset)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, c.printer.operations(operations...)
+}
+
+func (c *cli) walletFreeze(ctx context.Context, b backend, args []string) (int, error) {
+	return c.setFrozen(ctx, b, args, true)
+}
+
+func (c *cli) walletUnfreeze(ctx context.Context, b backend, args []string) (int, error) {
+	return c.setFrozen(ctx, b, args, false)
+}
+
+func (c *cli) setFrozen(ctx context.Context, b backend, args []string, frozen bool) (int, error) {
+	id, err := singleID(args)
+	if err != nil {
+		return exitUsage, err
+	}
+	if err := b.SetFrozen(ctx, id, frozen); err != nil {
+		return exitError, err
+	}
+	wallet, err := b.GetWallet(ctx, id)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, c.printer.wallets(*wallet)
+}
+
+func (c *cli) walletAdjust(ctx context.Context, b backend, args []string) (int, error) {
+	fs := newFlagSet("wallet adjust")
+	amount := fs.Int64("amount", 0, "amount in minor units: positive credits, negative debits")
+	reason := fs.String("reason", "", "reason recorded with the operation and in the audit log")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage, errUsage
+	}
+	id, err := singleID(rest)
+	if err != nil {
+		return exitUsage, err
+	}
+	if *amount == 0 || strings.TrimSpace(*reason) == "" {
+		return exitUsage, fmt.Errorf("%w: wallet adjust needs a non-zero -amount and a -reason", errUsage)
+	}
+
+	operation, err := b.Adjust(ctx, id, *amount, *reason)
+	if err != nil {
+		return exitError, err
+	}
+	return exitOK, c.printer.operations(*operation)
+}
+
+func (c *cli) reconcile(ctx context.Context, b backend, args []string) (int, error) {
+	if len(args) != 0 {
+		return exitUsage, errUsage
+	}
+	report, err := b.Reconcile(ctx)
+	if err != nil {
+		return exitError, err
+	}
+	if err := c.printer.reconciliation(report); err != nil {
+		return exitError, err
+	}
+	if report.Run.Discrepancies > 0 {
+		return exitDiscrepancies, nil
+	}
+	return exitOK, nil
+}
+
+func (c *cli) statement(ctx context.Context, b backend, args []string) (int, error) {
+	fs := newFlagSet("statement")
+	fromRaw := fs.String("from", "", "period start: YYYY-MM-DD or RFC 3339")
+	toRaw := fs.String("to", "", "period end: YYYY-MM-DD (whole day) or RFC 3339")
+	format := fs.String("format", string(client.StatementCSV), "csv, ndjson or pdf")
+	out := fs.String("out", "", "output file (default stdout)")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage, errUsage
+	}
+	id, err := singleID(rest)
+	if err != nil {
+		return exitUsage, err
+	}
+	from, err := parseDate(*fromRaw, false)
+	if err != nil {
+		return exitUsage, fmt.Errorf("%w: -from: %v", errUsage, err)
+	}
+	to, err := parseDate(*toRaw, true)
+	if err != nil {
+		return exitUsage, fmt.Errorf("%w: -to: %v", errUsage, err)
+	}
+
+	var w io.Writer = c.out
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return exitError, err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := b.Statement(ctx, id, from, to, client.StatementFormat(*format), w); err != nil {
+		if *out != "" {
+			os.Remove(*out)
+		}
+		return exitError, err
+	}
+	return exitOK, nil
+}
+
+// migrate применяет миграции напрямую в базе в любом режиме: API не
+// запустится на устаревшей схеме
+func (c *cli) migrate(ctx context.Context, args []string) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "status") {
+		return errUsage
+	}
+	dsn, err := c.databaseDSN()
+	if err != nil {
+		return err
+	}
+	db, err := openDB(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	status := &migrationStatus{Latest: migrations.Latest(), Applied: []appliedMigration{}}
+	if len(args) == 0 {
+		applied, err := migrations.Up(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, m := range applied {
+			status.Applied = append(status.Applied, appliedMigration{Version: m.Version, Name: m.Name})
+		}
+	}
+	if status.Version, err = migrations.CurrentVersion(ctx, db); err != nil {
+		return err
+	}
+	return c.printer.migrations(status)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseInterspersed разбирает флаги команды, стоящие и до, и после
+// позиционных аргументов: "wallet adjust <id> -amount 100"
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func singleID(args []string) (uuid.UUID, error) {
+	if len(args) != 1 {
+		return uuid.Nil, errUsage
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid id %q", errUsage, args[0])
+	}
+	return id, nil
+}
+
+// parseDate - RFC 3339 или дата YYYY-MM-DD по UTC; для конца периода дата
+// означает конец дня, как в API выписок
+func parseDate(raw string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	d, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want YYYY-MM-DD or RFC 3339, got %q", raw)
+	}
+	if endOfDay {
+		d = d.Add(24*time.Hour - time.Microsecond)
+	}
+	return d, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/migrations"
+	postgres "walletapitest/internal/infrastructure/database/postgres"
+	"walletapitest/internal/infrastructure/statement"
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// apiTimeLayout - формат дат в ответах API о пользователях и кошельках
+const apiTimeLayout = "2006-01-02 15:04:05"
+
+// dbBackend выполняет команды напрямую в базе через доменные сервисы, без
+// запущенного API. Изменения пишутся в журнал аудита от имени system.
+type dbBackend struct {
+	db             *sqlx.DB
+	userRepo       repositories.UserRepository
+	users          *services.UserService
+	wallets        *services.WalletService
+	reconciliation *services.ReconciliationService
+}
+
+func openDB(ctx context.Context, dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.ConnectContext(ctx, "postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	db.SetMaxOpenConns(4)
+	return db, nil
+}
+
+func newDBBackend(ctx context.Context, dsn string) (*dbBackend, error) {
+	db, err := openDB(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Старая схема не совпадает с запросами репозиториев
+	version, err := migrations.CurrentVersion(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+	if latest := migrations.Latest(); version < latest {
+		db.Close()
+		return nil, fmt.Errorf("database schema is at version %d, expected %d: run walletctl migrate", version, latest)
+	}
+
+	userRepo := postgres.NewUserRepository(db)
+	audit := services.NewAuditService(postgres.NewAuditRepository(db))
+	return &dbBackend{
+		db:             db,
+		userRepo:       userRepo,
+		users:          services.NewUserService(userRepo, postgres.NewLoginRepository(db), nil, services.LoginPolicy{}, audit),
+		wallets:        services.NewWalletService(postgres.NewWalletRepository(db), audit, nil, nil),
+		reconciliation: services.NewReconciliationService(postgres.NewReconciliationRepository(db)),
+	}, nil
+}
+
+func (b *dbBackend) GetUser(ctx context.Context, id uuid.UUID) (*client.User, error) {
+	user, err := b.users.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return userView(user), nil
+}
+
+func (b *dbBackend) FindUserByEmail(ctx context.Context, email string) (*client.User, error) {
+	user, err := b.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errUserNotFound
+	}
+	return userView(user), nil
+}
+
+func (b *dbBackend) ListUsers(ctx context.Context, filter client.UserFilter) (*client.UserPage, error) {
+	users, total, err := b.users.ListUsers(ctx, repositories.UserFilter{
+		EmailPrefix:    filter.Email,
+		UsernamePrefix: filter.Username,
+		SortBy:         "created_at",
+		SortDesc:       true,
+		Limit:          filter.Limit,
+		Offset:         filter.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &client.UserPage{Users: make([]client.User, 0, len(users)), Total: total, Limit: filter.Limit, Offset: filter.Offset}
+	for _, user := range users {
+		page.Users = append(page.Users, *userView(user))
+	}
+	return page, nil
+}
+
+func (b *dbBackend) UserWallets(ctx context.Context, userID uuid.UUID) ([]client.Wallet, error) {
+	if _, err := b.users.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	wallets, err := b.wallets.GetUserWallets(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]client.Wallet, 0, len(wallets))
+	for _, wallet := range wallets {
+		views = append(views, *walletView(wallet))
+	}
+	return views, nil
+}
+
+func (b *dbBackend) GetWallet(ctx context.Context, id uuid.UUID) (*client.Wallet, error) {
+	wallet, err := b.wallets.GetWallet(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return walletView(wallet), nil
+}
+
+func (b *dbBackend) Operations(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]client.Operation, error) {
+	if _, err := b.wallets.GetWallet(ctx, walletID); err != nil {
+		return nil, err
+	}
+	operations, err := b.wallets.GetOperations(ctx, walletID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]client.Operation, 0, len(operations))
+	for _, operation := range operations {
+		views = append(views, operationView(operation))
+	}
+	return views, nil
+}
+
+func (b *dbBackend) SetFrozen(ctx context.Context, walletID uuid.UUID, frozen bool) error {
+	status := entities.WalletStatusActive
+	if frozen {
+		status = entities.WalletStatusFrozen
+	}
+	return b.wallets.SetStatus(ctx, walletID, status)
+}
+
+func (b *dbBackend) Adjust(ctx context.Context, walletID uuid.UUID, amount int64, reason string) (*client.Operation, error) {
+	operation, err := b.wallets.Adjust(ctx, walletID, amount, reason)
+	if err != nil {
+		return nil, err
+	}
+	view := operationView(operation)
+	return &view, nil
+}
+
+func (b *dbBackend) Reconcile(ctx context.Context) (*client.ReconciliationReport, error) {
+	run, discrepancies, err := b.reconciliation.Reconcile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &client.ReconciliationReport{
+		Run: client.ReconciliationRun{
+			ID:             run.ID,
+			StartedAt:      run.StartedAt,
+			FinishedAt:     run.FinishedAt,
+			WalletsChecked: run.WalletsChecked,
+			Discrepancies:  run.Discrepancies,
+		},
+		Discrepancies: make([]client.Discrepancy, 0, len(discrepancies)),
+	}
+	for _, d := range discrepancies {
+		report.Discrepancies = append(report.Discrepancies, client.Discrepancy{
+			WalletID:         d.WalletID,
+			Balance:          d.Balance,
+			LastBalanceAfter: d.LastBalanceAfter,
+			OperationsSum:    d.OperationsSum,
+			OperationsCount:  d.OperationsCount,
+			LastBalanceDrift: d.LastBalanceDrift,
+			SumDrift:         d.SumDrift,
+		})
+	}
+	return report, nil
+}
+
+func (b *dbBackend) Statement(ctx context.Context, walletID uuid.UUID, from, to time.Time, format client.StatementFormat, w io.Writer) error {
+	writer, err := statement.NewWriter(statement.Format(format), w)
+	if err != nil {
+		return err
+	}
+	return b.wallets.WriteStatement(ctx, walletID, from, to, writer)
+}
+
+func (b *dbBackend) Close() error {
+	return b.db.Close()
+}
+
+func userView(user *entities.User) *client.User {
+	return &client.User{
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		Role:          string(user.Role),
+		Tier:          user.Tier,
+		EmailVerified: user.EmailVerified(),
+		CreatedAt:     user.CreatedAt.Format(apiTimeLayout),
+	}
+}
+
+func walletView(wallet *entities.Wallet) *client.Wallet {
+	return &client.Wallet{
+		ID:        wallet.ID,
+		UserID:    wallet.UserID,
+		Balance:   wallet.Balance,
+		Currency:  wallet.Currency,
+		Status:    string(wallet.Status),
+		CreatedAt: wallet.CreatedAt.Format(apiTimeLayout),
+		UpdatedAt: wallet.UpdatedAt.Format(apiTimeLayout),
+	}
+}
+
+func operationView(operation *entities.Operation) client.Operation {
+	return client.Operation{
+		ID:            operation.ID,
+		WalletID:      operation.WalletID,
+		OperationType: client.OperationType(operation.OperationType),
+		Amount:        operation.Amount,
+		BalanceAfter:  operation.BalanceAfter,
+		Reason:        operation.Reason,
+		CreatedAt:     operation.CreatedAt,
+		TransferID:    operation.TransferID,
+		RequestID:     operation.RequestID,
+	}
+}
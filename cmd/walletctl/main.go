@@ -0,0 +1,188 @@
+// Command walletctl - инструмент администратора: поиск пользователей и
+// кошельков, заморозка, ручные корректировки, сверка, выписки и миграции.
+// Работает через HTTP API (по умолчанию) или напрямую с базой (-mode db),
+// когда API недоступен.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"walletapitest/internal/config"
+)
+
+const usage = `Usage: walletctl [flags] <command> [args]
+
+Commands:
+  user get <id|email>                   Show a user
+  user list [-email p] [-username p] [-limit n] [-offset n]
+                                        Search users by email or username prefix
+  user wallets <user-id>                List the user's wallets
+  wallet get <id>                       Show a wallet
+  wallet operations <id> [-limit n] [-offset n]
+                                        Operation history, newest first
+  wallet freeze <id>                    Refuse deposits and withdrawals
+  wallet unfreeze <id>                  Allow operations again
+  wallet adjust <id> -amount n -reason text
+                                        Credit (positive) or debit (negative) a wallet
+  reconcile                             Run balance reconciliation; exit code 3 on discrepancies
+  statement <wallet-id> -from date -to date [-format csv|ndjson|pdf] [-out file]
+                                        Export a statement (dates: YYYY-MM-DD or RFC 3339)
+  migrate [status]                      Apply pending migrations, or show the schema version
+
+Flags:
+`
+
+const (
+	exitOK            = 0
+	exitError         = 1
+	exitUsage         = 2
+	exitDiscrepancies = 3
+)
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// cli - разобранные глобальные флаги
+type cli struct {
+	mode    string
+	url     string
+	token   string
+	apiKey  string
+	dsn     string
+	timeout time.Duration
+	out     io.Writer
+	printer *printer
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c := &cli{out: stdout}
+	fs := flag.NewFlagSet("walletctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.mode, "mode", envOr("WALLETCTL_MODE", "api"), "api: call the HTTP API; db: work on the database directly (WALLETCTL_MODE)")
+	fs.StringVar(&c.url, "url", envOr("WALLETCTL_URL", "http://localhost:8080"), "API base URL (WALLETCTL_URL)")
+	fs.StringVar(&c.token, "token", os.Getenv("WALLETCTL_TOKEN"), "access token of an admin session (WALLETCTL_TOKEN)")
+	fs.StringVar(&c.apiKey, "api-key", os.Getenv("WALLETCTL_API_KEY"), "API key, if no token is given (WALLETCTL_API_KEY)")
+	fs.StringVar(&c.dsn, "dsn", os.Getenv("WALLETCTL_DSN"), "Postgres DSN for db mode and migrate; default from DB_* settings (WALLETCTL_DSN)")
+	output := fs.String("o", envOr("WALLETCTL_OUTPUT", "table"), "output format: table or json (WALLETCTL_OUTPUT)")
+	fs.DurationVar(&c.timeout, "timeout", time.Minute, "timeout of the whole command")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	switch *output {
+	case "table", "json":
+		c.printer = &printer{w: stdout, json: *output == "json"}
+	default:
+		fmt.Fprintf(stderr, "walletctl: unknown output format %q\n", *output)
+		return exitUsage
+	}
+	if c.mode != "api" && c.mode != "db" {
+		fmt.Fprintf(stderr, "walletctl: unknown mode %q\n", c.mode)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if fs.Arg(0) == "help" {
+		fs.Usage()
+		return exitOK
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	code, err := c.dispatch(ctx, fs.Arg(0), fs.Args()[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintf(stderr, "walletctl: %v (see walletctl help)\n", err)
+			return exitUsage
+		}
+		fs.Usage()
+		return exitUsage
+	case err != nil:
+		fmt.Fprintf(stderr, "walletctl: %v\n", err)
+		return exitError
+	}
+	return code
+}
+
+func (c *cli) dispatch(ctx context.Context, name string, args []string) (int, error) {
+	if name == "migrate" {
+		return exitOK, c.migrate(ctx, args)
+	}
+
+	var handler command
+	switch name {
+	case "user", "wallet":
+		if len(args) == 0 {
+			return exitUsage, errUsage
+		}
+		handler = commands[name+" "+args[0]]
+		args = args[1:]
+	default:
+		handler = commands[name]
+	}
+	if handler == nil {
+		return exitUsage, errUsage
+	}
+
+	b, err := c.backend(ctx)
+	if err != nil {
+		return exitError, err
+	}
+	defer b.Close()
+	return handler(c, ctx, b, args)
+}
+
+func (c *cli) backend(ctx context.Context) (backend, error) {
+	if c.mode == "api" {
+		return newAPIBackend(c.url, c.token, c.apiKey)
+	}
+	dsn, err := c.databaseDSN()
+	if err != nil {
+		return nil, err
+	}
+	return newDBBackend(ctx, dsn)
+}
+
+// databaseDSN - -dsn или строка из настроек сервиса (DB_HOST, DB_USER, ...)
+func (c *cli) databaseDSN() (string, error) {
+	if c.dsn != "" {
+		return c.dsn, nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("load config: %w", err)
+	}
+	return cfg.Database.DSN(), nil
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"walletapitest/pkg/client"
+)
+
+// printer выводит результат команды таблицей или JSON
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// print выводит v как JSON или вызывает render для табличного вида
+func (p *printer) print(v interface{}, render func(t *table)) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	t := &table{tw: tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)}
+	render(t)
+	return t.tw.Flush()
+}
+
+type table struct {
+	tw *tabwriter.Writer
+}
+
+func (t *table) row(cells ...string) {
+	fmt.Fprintln(t.tw, strings.Join(cells, "\t"))
+}
+
+func (p *printer) users(users ...client.User) error {
+	return p.print(users, func(t *table) {
+		t.row("ID", "EMAIL", "USERNAME", "ROLE", "TIER", "VERIFIED", "CREATED")
+		for _, u := range users {
+			t.row(u.ID.String(), u.Email, u.Username, dash(u.Role), dash(u.Tier), strconv.FormatBool(u.EmailVerified), u.CreatedAt)
+		}
+	})
+}
+
+func (p *printer) wallets(wallets ...client.Wallet) error {
+	return p.print(wallets, func(t *table) {
+		t.row("ID", "USER", "BALANCE", "CURRENCY", "STATUS", "UPDATED")
+		for _, w := range wallets {
+			t.row(w.ID.String(), w.UserID.String(), strconv.FormatInt(w.Balance, 10), w.Currency, w.Status, w.UpdatedAt)
+		}
+	})
+}
+
+func (p *printer) operations(operations ...client.Operation) error {
+	return p.print(operations, func(t *table) {
+		t.row("ID", "TYPE", "AMOUNT", "BALANCE AFTER", "CREATED", "REASON")
+		for _, op := range operations {
+			reason := "-"
+			if op.Reason != nil {
+				reason = *op.Reason
+			}
+			t.row(op.ID.String(), string(op.OperationType), strconv.FormatInt(op.Amount, 10),
+				strconv.FormatInt(op.BalanceAfter, 10), op.CreatedAt.UTC().Format(time.RFC3339), reason)
+		}
+	})
+}
+
+func (p *printer) reconciliation(report *client.ReconciliationReport) error {
+	return p.print(report, func(t *table) {
+		t.row("RUN", report.Run.ID.String())
+		t.row("FINISHED", report.Run.FinishedAt.UTC().Format(time.RFC3339))
+		t.row("WALLETS CHECKED", strconv.FormatInt(report.Run.WalletsChecked, 10))
+		t.row("DISCREPANCIES", strconv.FormatInt(report.Run.Discrepancies, 10))
+		if len(report.Discrepancies) == 0 {
+			return
+		}
+		t.row()
+		t.row("WALLET", "BALANCE", "LAST BALANCE AFTER", "OPERATIONS SUM", "LAST DRIFT", "SUM DRIFT")
+		for _, d := range report.Discrepancies {
+			last := "-"
+			if d.LastBalanceAfter != nil {
+				last = strconv.FormatInt(*d.LastBalanceAfter, 10)
+			}
+			t.row(d.WalletID.String(), strconv.FormatInt(d.Balance, 10), last, strconv.FormatInt(d.OperationsSum, 10),
+				strconv.FormatInt(d.LastBalanceDrift, 10), strconv.FormatInt(d.SumDrift, 10))
+		}
+	})
+}
+
+// migrationStatus - версия схемы и миграции, примененные командой
+type migrationStatus struct {
+	Version int                `json:"version"`
+	Latest  int                `json:"latest"`
+	Applied []appliedMigration `json:"applied"`
+}
+
+type appliedMigration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+func (p *printer) migrations(status *migrationStatus) error {
+	return p.print(status, func(t *table) {
+		for _, m := range status.Applied {
+			t.row("APPLIED", fmt.Sprintf("%03d_%s", m.Version, m.Name))
+		}
+		t.row("VERSION", strconv.Itoa(status.Version))
+		t.row("LATEST", strconv.Itoa(status.Latest))
+	})
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,19 +12,32 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 
 	"walletapitest/internal/config"
-	"walletapitest/internal/domain/services"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/infrastructure/cache"
+	"walletapitest/internal/infrastructure/database/migrations"
 	postgres "walletapitest/internal/infrastructure/database/postgres"
+	"walletapitest/internal/infrastructure/grpcapi"
 	"walletapitest/internal/infrastructure/http/handlers"
+	"walletapitest/internal/infrastructure/http/middlewares"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/health"
 	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
 )
 
 type App struct {
-	cfg    *config.Config
-	logger logger.Logger
-	router *gin.Engine
-	db     *sqlx.DB
+	cfg      *config.Config
+	logger   logger.Logger
+	router   *gin.Engine
+	db       *sqlx.DB
+	redis    *redis.Client
+	health   *health.Service
+	tokens   *auth.TokenManager
+	denylist auth.Denylist
+	apiKeys  auth.APIKeyVerifier
 }
 
 func New(cfg *config.Config, logger logger.Logger) *App {
@@ -34,6 +48,25 @@ func New(cfg *config.Config, logger logger.Logger) *App {
 }
 
 func (a *App) Run() error {
+	// Инициализация трассировки
+	shutdownTracing, err := tracing.Init(context.Background(), a.cfg.Tracing)
+	if err != nil {
+		a.logger.Error("Failed to initialize tracing", "error", err)
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			a.logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	if a.cfg.JWT.SecretKey == "" {
+		return errors.New("jwt secret key is not configured (JWT_SECRET_KEY)")
+	}
+	a.tokens = auth.NewTokenManager(a.cfg.JWT.SecretKey, time.Duration(a.cfg.JWT.ExpiresIn)*time.Second)
+
 	// Инициализация базы данных
 	db, err := a.initDB()
 	if err != nil {
@@ -46,27 +79,60 @@ func (a *App) Run() error {
 	}
 
 	// Инициализация зависимостей
-	var userService *services.UserService
-	var walletService *services.WalletService
-	if a.db != nil {
-		// Инициализация репозиториев
-		userRepo := postgres.NewUserRepository(db)
-		walletRepo := postgres.NewWalletRepository(db)
-
-		// Инициализация сервисов
-		userService = services.NewUserService(userRepo)
-		walletService = services.NewWalletService(walletRepo)
-	} else {
+	if a.db == nil {
 		// База данных обязательна для работы приложения
 		return err
 	}
 
-	// Инициализация хендлеров
-	userHandler := handlers.NewUserHandler(userService)
-	walletHandler := handlers.NewWalletHandler(walletService)
+	// Redis опционален: при недоступности отчет о здоровье будет degraded
+	a.redis = a.initRedis()
+	if a.redis != nil {
+		defer a.redis.Close()
+		a.denylist = cache.NewSessionDenylist(a.redis)
+	} else {
+		a.denylist = postgres.NewSessionDenylist(db)
+	}
 
-	// Инициализация роутера
-	a.router = a.initRouter(userHandler, walletHandler)
+	// Инициализация репозиториев
+	balanceListener := postgres.NewBalanceListener(a.dsn())
+	st := postgresStorage(db)
+	st.balances = balanceListener
+
+	// Инициализация сервисов
+	svc, err := a.initServices(st)
+	if err != nil {
+		return err
+	}
+
+	a.health = a.initHealth()
+
+	// Фоновые задачи останавливаются вместе с сервером
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	if a.cfg.Reconciliation.Enabled {
+		interval := time.Duration(a.cfg.Reconciliation.Interval) * time.Second
+		worker := a.health.RegisterWorker("reconciliation", interval+2*reconciliationRetry)
+		go a.runReconciliation(workersCtx, svc.reconciliation, interval, worker)
+	}
+	if a.cfg.Schedules.Enabled {
+		interval := time.Duration(a.cfg.Schedules.PollInterval) * time.Second
+		worker := a.health.RegisterWorker("schedules", 3*interval)
+		go a.runSchedules(workersCtx, svc.schedule, postgres.NewLeaderLock(db, schedulesLockKey), interval, worker)
+	}
+	// Пакеты обрабатываются всегда: небольшие, прерванные вместе с запросом,
+	// тоже дообрабатывает воркер. Один большой пакет может занять до batchStaleAfter.
+	batchInterval := time.Duration(a.cfg.Batch.PollInterval) * time.Second
+	batchStaleAfter := time.Duration(a.cfg.Batch.StaleAfter) * time.Second
+	batchWorker := a.health.RegisterWorker("batches", 3*batchInterval+batchStaleAfter)
+	go a.runBatches(workersCtx, svc.batch, batchInterval, batchWorker)
+	operationsInterval := time.Duration(a.cfg.Operations.PollInterval) * time.Second
+	operationsStaleAfter := time.Duration(a.cfg.Operations.StaleAfter) * time.Second
+	operationsWorker := a.health.RegisterWorker("operations", 3*operationsInterval+operationsStaleAfter)
+	a.runOperationQueue(workersCtx, svc.operationQueue, a.cfg.Operations.Workers, operationsInterval, operationsWorker)
+	go a.runBalanceListener(workersCtx, balanceListener)
+
+	// Инициализация хендлеров и роутера
+	a.router = a.newRouter(svc)
 
 	// Запуск сервера
 	srv := &http.Server{
@@ -86,15 +152,45 @@ func (a *App) Run() error {
 
 	a.logger.Info("Server started on port " + a.cfg.Server.Port)
 
+	var grpcServer *grpcapi.Server
+	if a.cfg.GRPC.Enabled {
+		grpcServer, err = a.serveGRPC(grpcapi.Deps{
+			UserService:    svc.user,
+			AccountService: svc.account,
+			WalletService:  svc.wallet,
+			AccessService:  svc.access,
+			Tokens:         a.tokens,
+			Denylist:       a.denylist,
+			APIKeys:        a.apiKeys,
+		})
+		if err != nil {
+			a.logger.Error("Failed to start gRPC server", "error", err)
+			return err
+		}
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	a.logger.Info("Shutting down server...")
 
+	// Сначала проваливаем readiness и даем балансировщику снять трафик
+	a.health.SetShuttingDown()
+	stopWorkers()
+	if drain := time.Duration(a.cfg.Server.DrainDelay) * time.Second; drain > 0 {
+		a.logger.Info("Waiting for load balancer to drain traffic", "delay", drain)
+		time.Sleep(drain)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			a.logger.Error("gRPC server forced to shutdown", "error", err)
+		}
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		a.logger.Error("Server forced to shutdown", "error", err)
 		return err
@@ -104,40 +200,163 @@ func (a *App) Run() error {
 	return nil
 }
 
-func (a *App) initRouter(userHandler *handlers.UserHandler, walletHandler *handlers.WalletHandler) *gin.Engine {
-	router := gin.Default()
+func (a *App) initRouter(
+	userHandler *handlers.UserHandler,
+	walletHandler *handlers.WalletHandler,
+	sessionHandler *handlers.SessionHandler,
+	mfaHandler *handlers.MFAHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	accountHandler *handlers.AccountHandler,
+	auditHandler *handlers.AuditHandler,
+	reconciliationHandler *handlers.ReconciliationHandler,
+	scheduleHandler *handlers.ScheduleHandler,
+	batchHandler *handlers.BatchHandler,
+	healthHandler *handlers.HealthHandler,
+	docsHandler *handlers.DocsHandler,
+) *gin.Engine {
+	router := gin.New()
+	// IP клиента используется для ограничения попыток входа, поэтому
+	// X-Forwarded-For принимается только от известных прокси
+	if err := router.SetTrustedProxies(a.cfg.Server.TrustedProxies); err != nil {
+		a.logger.Error("Invalid trusted proxies, ignoring X-Forwarded-For", "error", err)
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(
+		gin.Recovery(),
+		middlewares.RequestID(),
+		middlewares.Tracing(),
+		middlewares.Logging(a.logger),
+	)
 
 	// Public routes
 	router.POST("/api/v1/users", userHandler.CreateUser)
 	router.POST("/api/v1/login", userHandler.Login)
-	router.GET("/api/v1/users/:id", userHandler.GetUser)
-
-	// Wallet routes
-	router.POST("/api/v1/wallet", walletHandler.ProcessOperation)
-	router.GET("/api/v1/wallet/:walletId", walletHandler.GetWallet)
-	router.POST("/api/v1/wallet/create", walletHandler.CreateWallet)
-
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		if a.db != nil {
-			// Проверяем соединение с БД
-			if err := a.db.Ping(); err != nil {
-				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": err.Error()})
-				return
-			}
-		}
-		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-	})
+	router.POST("/api/v1/token/refresh", sessionHandler.Refresh)
+	router.POST("/api/v1/email/verify", accountHandler.VerifyEmail)
+	router.POST("/api/v1/password/forgot", accountHandler.ForgotPassword)
+	router.POST("/api/v1/password/reset", accountHandler.ResetPassword)
+
+	// Authenticated routes
+	authorized := router.Group("/api/v1", middlewares.AuthMiddleware(a.tokens, a.denylist, a.apiKeys))
+	authorized.POST("/logout", sessionHandler.Logout)
+	authorized.POST("/logout/all", sessionHandler.LogoutAll)
+	authorized.GET("/users/:id", userHandler.GetUser)
+	authorized.PATCH("/users/:id", userHandler.UpdateUser)
+	authorized.DELETE("/users/:id", userHandler.DeleteUser)
+	authorized.POST("/mfa/totp/enroll", mfaHandler.Enroll)
+	authorized.POST("/mfa/totp/confirm", mfaHandler.Confirm)
+	authorized.DELETE("/mfa/totp", mfaHandler.Disable)
+	authorized.GET("/me/sign-ins", userHandler.RecentSignIns)
+	authorized.POST("/email/verify/resend", accountHandler.ResendVerification)
+
+	authorized.GET("/users", middlewares.RequirePermission(entities.PermUsersReadAll), userHandler.ListUsers)
+	authorized.PUT("/users/:id/role", middlewares.RequirePermission(entities.PermUsersManage), userHandler.SetRole)
+	authorized.PUT("/users/:id/tier", middlewares.RequirePermission(entities.PermUsersManage), userHandler.SetTier)
+
+	// Wallet routes: владение кошельком проверяет хендлер
+	authorized.POST("/wallet", middlewares.RequirePermission(entities.PermWalletsOperate), walletHandler.ProcessOperation)
+	authorized.POST("/wallet/create", middlewares.RequirePermission(entities.PermWalletsOperate), walletHandler.CreateWallet)
+	// Пакеты: доступ к каждому кошельку пакета проверяет хендлер
+	authorized.POST("/wallet/batch", middlewares.RequirePermission(entities.PermWalletsOperate), batchHandler.Submit)
+	authorized.GET("/wallet/batch/:jobId", batchHandler.Get)
+	authorized.GET("/operations/:id",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermHistoryReadAll),
+		walletHandler.GetOperation)
+	authorized.GET("/wallet/:walletId",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermWalletsReadAll),
+		walletHandler.GetWallet)
+	authorized.GET("/wallet/:walletId/operations",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermHistoryReadAll),
+		walletHandler.ListOperations)
+	authorized.GET("/wallet/:walletId/balance",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermWalletsReadAll, entities.PermReportsRead),
+		walletHandler.BalanceAt)
+	authorized.GET("/users/:id/balances",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermWalletsReadAll, entities.PermReportsRead),
+		walletHandler.UserBalancesAt)
+	authorized.GET("/wallet/:walletId/statement",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermHistoryReadAll, entities.PermReportsRead),
+		walletHandler.Statement)
+	authorized.GET("/wallet/:walletId/operations/verify",
+		middlewares.RequirePermission(entities.PermHistoryReadAll),
+		walletHandler.VerifyChain)
+
+	// Scheduled transfers: доступ к кошельку списания проверяет хендлер
+	authorized.POST("/wallet/:walletId/schedules", middlewares.RequirePermission(entities.PermWalletsOperate), scheduleHandler.Create)
+	authorized.GET("/wallet/:walletId/schedules",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermWalletsReadAll),
+		scheduleHandler.List)
+	authorized.GET("/schedules/:id",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermWalletsReadAll),
+		scheduleHandler.Get)
+	authorized.GET("/schedules/:id/runs",
+		middlewares.RequirePermission(entities.PermWalletsRead, entities.PermWalletsReadAll, entities.PermHistoryReadAll),
+		scheduleHandler.Runs)
+	authorized.POST("/schedules/:id/pause", middlewares.RequirePermission(entities.PermWalletsOperate), scheduleHandler.Pause)
+	authorized.POST("/schedules/:id/resume", middlewares.RequirePermission(entities.PermWalletsOperate), scheduleHandler.Resume)
+	authorized.DELETE("/schedules/:id", middlewares.RequirePermission(entities.PermWalletsOperate), scheduleHandler.Cancel)
+
+	// Admin routes
+	admin := authorized.Group("/admin")
+	admin.POST("/wallets/:walletId/freeze", middlewares.RequirePermission(entities.PermWalletsFreeze), walletHandler.Freeze)
+	admin.POST("/wallets/:walletId/unfreeze", middlewares.RequirePermission(entities.PermWalletsFreeze), walletHandler.Unfreeze)
+	admin.POST("/wallets/:walletId/adjust", middlewares.RequirePermission(entities.PermWalletsAdjust), walletHandler.Adjust)
+	admin.POST("/reconciliation/run", middlewares.RequirePermission(entities.PermWalletsAdjust), reconciliationHandler.Run)
+
+	apiKeys := admin.Group("/api-keys", middlewares.RequirePermission(entities.PermAPIKeysManage))
+	apiKeys.POST("", apiKeyHandler.Create)
+	apiKeys.GET("", apiKeyHandler.List)
+	apiKeys.POST("/:id/rotate", apiKeyHandler.Rotate)
+	apiKeys.DELETE("/:id", apiKeyHandler.Revoke)
+
+	// Audit log
+	audit := authorized.Group("/audit", middlewares.RequirePermission(entities.PermAuditRead))
+	audit.GET("", auditHandler.List)
+	audit.GET("/verify", auditHandler.Verify)
+
+	// Reconciliation reports
+	reconciliation := authorized.Group("/reconciliation", middlewares.RequirePermission(entities.PermReportsRead))
+	reconciliation.GET("/runs", reconciliationHandler.ListRuns)
+	reconciliation.GET("/runs/:id", reconciliationHandler.Report)
+
+	// Health checks
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+	router.GET("/health", healthHandler.Health)
+
+	// API documentation
+	router.GET("/openapi.json", docsHandler.Spec)
+	router.GET("/docs/*filepath", docsHandler.UI)
 
 	return router
 }
 
-func (a *App) initDB() (*sqlx.DB, error) {
-	connStr := "postgres://" + a.cfg.Database.User + ":" + a.cfg.Database.Password +
-		"@" + a.cfg.Database.Host + ":" + a.cfg.Database.Port + "/" + a.cfg.Database.Name +
-		"?sslmode=" + a.cfg.Database.SSLMode
+// postgresStorage - репозитории на Postgres; подписку на балансы Run
+// добавляет сам, потому что ей нужно отдельное соединение
+func postgresStorage(db *sqlx.DB) *storage {
+	return &storage{
+		users:             postgres.NewUserRepository(db),
+		wallets:           postgres.NewWalletRepository(db),
+		sessions:          postgres.NewSessionRepository(db),
+		mfa:               postgres.NewMFARepository(db),
+		apiKeys:           postgres.NewAPIKeyRepository(db),
+		logins:            postgres.NewLoginRepository(db),
+		userTokens:        postgres.NewUserTokenRepository(db),
+		audit:             postgres.NewAuditRepository(db),
+		reconciliation:    postgres.NewReconciliationRepository(db),
+		schedules:         postgres.NewScheduleRepository(db),
+		batches:           postgres.NewBatchRepository(db),
+		operationRequests: postgres.NewOperationRequestRepository(db),
+	}
+}
 
-	db, err := sqlx.Connect("postgres", connStr)
+// dsn - строка подключения к Postgres
+func (a *App) dsn() string {
+	return a.cfg.Database.DSN()
+}
+
+func (a *App) initDB() (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", a.dsn())
 	if err != nil {
 		return nil, err
 	}
@@ -151,54 +370,16 @@ func (a *App) initDB() (*sqlx.DB, error) {
 		return nil, err
 	}
 
-	// Создание таблицы users если её нет
-	if err := a.createUsersTable(db); err != nil {
-		a.logger.Error("Failed to create users table", "error", err)
+	// Применение миграций схемы
+	applied, err := migrations.Up(context.Background(), db)
+	if err != nil {
+		a.logger.Error("Failed to apply migrations", "error", err)
 		return nil, err
 	}
-
-	// Создание таблицы wallets если её нет
-	if err := a.createWalletsTable(db); err != nil {
-		a.logger.Error("Failed to create wallets table", "error", err)
-		return nil, err
+	for _, m := range applied {
+		a.logger.Info("Migration applied", "version", m.Version, "name", m.Name)
 	}
 
 	a.logger.Info("Database connection established")
 	return db, nil
 }
-
-func (a *App) createUsersTable(db *sqlx.DB) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS users (
-			id UUID PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			username VARCHAR(255) NOT NULL,
-			password VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-	`
-
-	_, err := db.Exec(query)
-	return err
-}
-
-func (a *App) createWalletsTable(db *sqlx.DB) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS wallets (
-			id UUID PRIMARY KEY,
-			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			balance BIGINT NOT NULL DEFAULT 0,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_wallets_user_id ON wallets(user_id);
-		CREATE INDEX IF NOT EXISTS idx_wallets_id ON wallets(id);
-	`
-
-	_, err := db.Exec(query)
-	return err
-}
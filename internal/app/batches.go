@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/health"
+	"walletapitest/internal/pkg/logger"
+)
+
+// runBatches раз в interval обрабатывает пакеты операций из очереди и
+// брошенные другими экземплярами. Работает на каждом экземпляре: задания
+// разбираются через SKIP LOCKED, поэтому одно задание достается одному.
+func (a *App) runBatches(ctx context.Context, svc *services.BatchService, interval time.Duration, worker *health.Worker) {
+	log := a.logger.With("worker", "batches")
+	ctx = logger.WithContext(ctx, log)
+
+	for {
+		processed, err := svc.RunPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to process batches", "error", err, "processed", processed)
+			worker.Fail(err)
+		} else {
+			if processed > 0 {
+				log.Info("processed batches", "processed", processed)
+			}
+			worker.Beat()
+		}
+
+		if !sleep(ctx, interval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"walletapitest/internal/config"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+// newFeeSchedule собирает и проверяет правила комиссий из конфигурации;
+// nil - комиссии не настроены
+func newFeeSchedule(cfg config.FeesConfig) (*entities.FeeSchedule, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, nil
+	}
+
+	fees := &entities.FeeSchedule{Wallets: make(map[string]uuid.UUID, len(cfg.Wallets))}
+	for currency, raw := range cfg.Wallets {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("fee wallet for %s: %w", currency, err)
+		}
+		// viper приводит ключи к нижнему регистру
+		fees.Wallets[strings.ToUpper(currency)] = id
+	}
+	for _, rule := range cfg.Rules {
+		r := entities.FeeRule{
+			Name:      rule.Name,
+			Operation: strings.ToUpper(rule.Operation),
+			Currency:  strings.ToUpper(rule.Currency),
+			UserTier:  rule.UserTier,
+			Fixed:     rule.Fixed,
+			RateBps:   rule.RateBps,
+			Min:       rule.Min,
+			Max:       rule.Max,
+		}
+		for _, tier := range rule.Tiers {
+			r.Tiers = append(r.Tiers, entities.FeeTier{UpTo: tier.UpTo, Fixed: tier.Fixed, RateBps: tier.RateBps})
+		}
+		fees.Rules = append(fees.Rules, r)
+	}
+
+	if err := fees.Validate(); err != nil {
+		return nil, err
+	}
+	return fees, nil
+}
+
+// checkFeeWallets проверяет, что кошельки комиссий существуют и их валюта
+// совпадает с той, для которой они настроены
+func checkFeeWallets(ctx context.Context, walletRepo repositories.WalletRepository, fees *entities.FeeSchedule) error {
+	if fees == nil {
+		return nil
+	}
+	for currency, id := range fees.Wallets {
+		wallet, err := walletRepo.FindByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("fee wallet %s for %s: %w", id, currency, err)
+		}
+		if wallet == nil {
+			return fmt.Errorf("fee wallet %s for %s: %w", id, currency, repositories.ErrWalletNotFound)
+		}
+		if wallet.Currency != currency {
+			return fmt.Errorf("fee wallet %s for %s holds %s", id, currency, wallet.Currency)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"net"
+
+	"walletapitest/internal/infrastructure/database/postgres"
+	"walletapitest/internal/infrastructure/grpcapi"
+	"walletapitest/internal/pkg/logger"
+)
+
+// runBalanceListener раздает изменения балансов подписчикам WatchBalance до
+// отмены ctx; без него потоки балансов завершаются с Unavailable
+func (a *App) runBalanceListener(ctx context.Context, listener *postgres.BalanceListener) {
+	log := a.logger.With("worker", "balances")
+	if err := listener.Run(logger.WithContext(ctx, log)); err != nil {
+		log.Error("balance listener stopped", "error", err)
+	}
+}
+
+// serveGRPC запускает gRPC API на порту GRPC_PORT
+func (a *App) serveGRPC(deps grpcapi.Deps) (*grpcapi.Server, error) {
+	lis, err := net.Listen("tcp", ":"+a.cfg.GRPC.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := grpcapi.NewServer(deps, a.logger)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			a.logger.Error("Failed to serve gRPC", "error", err)
+		}
+	}()
+
+	a.logger.Info("gRPC server started on port " + a.cfg.GRPC.Port)
+	return srv, nil
+}
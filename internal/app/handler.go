@@ -0,0 +1,63 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/infrastructure/database/postgres"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/health"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MemoryHandler собирает HTTP API на хранилище в памяти, без Postgres,
+// Redis и фоновых воркеров. Предназначен для тестов клиентов и локальных
+// прогонов: пользователи, сессии, MFA, ключи API, аудит, синхронные
+// операции с кошельками и пакеты работают как в Run. Пакеты из очереди
+// обрабатывает только BatchService.RunPending: воркеров нет. Расписания,
+// асинхронные операции, сверка и подписка на балансы хранилищем не
+// поддерживаются - такие запросы завершаются ошибкой 500.
+func (a *App) MemoryHandler(store *memory.Store) (http.Handler, error) {
+	a.denylist = memory.NewSessionDenylist(store)
+	return a.handler(&storage{
+		users:      memory.NewUserRepository(store),
+		wallets:    memory.NewWalletRepository(store),
+		sessions:   memory.NewSessionRepository(store),
+		mfa:        memory.NewMFARepository(store),
+		apiKeys:    memory.NewAPIKeyRepository(store),
+		logins:     memory.NewLoginRepository(store),
+		userTokens: memory.NewUserTokenRepository(store),
+		audit:      memory.NewAuditRepository(store),
+		batches:    memory.NewBatchRepository(store),
+	})
+}
+
+// PostgresHandler собирает HTTP API на Postgres с уже примененными
+// миграциями, но без Redis и фоновых воркеров: те же тесты клиентов, что
+// идут на MemoryHandler, проверяют им настоящие репозитории. Подписка на
+// балансы не поддерживается.
+func (a *App) PostgresHandler(db *sqlx.DB) (http.Handler, error) {
+	a.denylist = postgres.NewSessionDenylist(db)
+	return a.handler(postgresStorage(db))
+}
+
+// handler собирает роутер на st без фоновых воркеров; a.denylist должен быть
+// уже задан
+func (a *App) handler(st *storage) (http.Handler, error) {
+	if a.cfg.JWT.SecretKey == "" {
+		return nil, errors.New("jwt secret key is not configured (JWT_SECRET_KEY)")
+	}
+	a.tokens = auth.NewTokenManager(a.cfg.JWT.SecretKey, time.Duration(a.cfg.JWT.ExpiresIn)*time.Second)
+
+	svc, err := a.initServices(st)
+	if err != nil {
+		return nil, err
+	}
+
+	a.health = health.New(2 * time.Second)
+	a.router = a.newRouter(svc)
+	return a.router, nil
+}
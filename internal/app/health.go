@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"walletapitest/internal/infrastructure/database/migrations"
+	"walletapitest/internal/pkg/health"
+)
+
+func (a *App) initHealth() *health.Service {
+	h := health.New(2 * time.Second)
+
+	h.AddCheck(health.Check{
+		Name:     "postgres",
+		Critical: true,
+		Run: func(ctx context.Context) (string, error) {
+			if err := a.db.PingContext(ctx); err != nil {
+				return "", err
+			}
+			stats := a.db.Stats()
+			return fmt.Sprintf("open=%d in_use=%d idle=%d", stats.OpenConnections, stats.InUse, stats.Idle), nil
+		},
+	})
+
+	h.AddCheck(health.Check{
+		Name:     "migrations",
+		Critical: true,
+		Run: func(ctx context.Context) (string, error) {
+			current, err := migrations.CurrentVersion(ctx, a.db)
+			if err != nil {
+				return "", err
+			}
+			latest := migrations.Latest()
+			details := fmt.Sprintf("version=%d latest=%d", current, latest)
+			if current < latest {
+				return details, fmt.Errorf("schema is behind: version %d, expected %d", current, latest)
+			}
+			return details, nil
+		},
+	})
+
+	if a.redis != nil {
+		h.AddCheck(health.Check{
+			Name: "redis",
+			Run: func(ctx context.Context) (string, error) {
+				return "", a.redis.Ping(ctx).Err()
+			},
+		})
+	}
+
+	return h
+}
+
+func (a *App) initRedis() *redis.Client {
+	if a.cfg.Redis.Host == "" {
+		return nil
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:     a.cfg.Redis.Host + ":" + a.cfg.Redis.Port,
+		Password: a.cfg.Redis.Password,
+		DB:       a.cfg.Redis.DB,
+	})
+}
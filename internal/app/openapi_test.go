@@ -0,0 +1,142 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"walletapitest/internal/config"
+	"walletapitest/internal/infrastructure/http/handlers"
+	"walletapitest/internal/infrastructure/http/openapi"
+	"walletapitest/internal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testRouter - роутер приложения с пустыми хендлерами: для сверки маршрутов
+// зависимости не нужны
+func testRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	a := New(&config.Config{}, logger.New("error"))
+	return a.initRouter(
+		&handlers.UserHandler{},
+		&handlers.WalletHandler{},
+		&handlers.SessionHandler{},
+		&handlers.MFAHandler{},
+		&handlers.APIKeyHandler{},
+		&handlers.AccountHandler{},
+		&handlers.AuditHandler{},
+		&handlers.ReconciliationHandler{},
+		&handlers.ScheduleHandler{},
+		&handlers.BatchHandler{},
+		&handlers.HealthHandler{},
+		handlers.NewDocsHandler(openapi.JSON()),
+	)
+}
+
+// TestOpenAPICoversRouter - у каждого маршрута gin есть операция в
+// спецификации с тем же хендлером, и наоборот
+func TestOpenAPICoversRouter(t *testing.T) {
+	routes := make(map[string]string)
+	for _, r := range testRouter(t).Routes() {
+		routes[r.Method+" "+r.Path] = strings.TrimSuffix(r.Handler, "-fm")
+	}
+
+	described := make(map[string]bool)
+	for _, e := range openapi.Endpoints() {
+		key := e.Method + " " + e.Path
+		if described[key] {
+			t.Errorf("%s is described twice", key)
+		}
+		described[key] = true
+
+		handler, ok := routes[key]
+		switch {
+		case !ok:
+			t.Errorf("%s is in the spec but not registered in initRouter", key)
+		case handler != e.Handler:
+			t.Errorf("%s is served by %s, but the spec describes %s", key, handler, e.Handler)
+		}
+	}
+	for key := range routes {
+		if !described[key] {
+			t.Errorf("%s is registered in initRouter but missing from the spec catalog", key)
+		}
+	}
+}
+
+// TestOpenAPIDocumentUpToDate - openapi.json собран из текущих структур
+// запросов и ответов и содержит все маршруты
+func TestOpenAPIDocumentUpToDate(t *testing.T) {
+	want, err := openapi.Render()
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !bytes.Equal(openapi.JSON(), want) {
+		t.Fatal("openapi.json is out of date with the handler structs or the route catalog; " +
+			"run go generate ./internal/infrastructure/http/openapi")
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(openapi.JSON(), &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi = %q, want %q", doc.OpenAPI, openapi.Version)
+	}
+	for _, r := range testRouter(t).Routes() {
+		item, ok := doc.Paths[openapi.SpecPath(r.Path)]
+		if !ok || operation(item, r.Method) == nil {
+			t.Errorf("openapi.json has no operation for %s %s", r.Method, r.Path)
+		}
+	}
+}
+
+func TestDocsRoutes(t *testing.T) {
+	router := testRouter(t)
+
+	cases := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/openapi.json", "application/json", `"openapi": "3.1.0"`},
+		{"/docs/", "text/html", "swagger-ui"},
+		{"/docs/swagger-initializer.js", "application/javascript", `url: "/openapi.json"`},
+		{"/docs/swagger-ui.css", "text/css", ".swagger-ui"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200", tc.path, rec.Code)
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tc.contentType) {
+			t.Errorf("GET %s: Content-Type %q, want %s", tc.path, ct, tc.contentType)
+		}
+		if !strings.Contains(rec.Body.String(), tc.contains) {
+			t.Errorf("GET %s: body does not contain %q", tc.path, tc.contains)
+		}
+	}
+}
+
+func operation(item *openapi.PathItem, method string) *openapi.Operation {
+	switch method {
+	case http.MethodGet:
+		return item.Get
+	case http.MethodPut:
+		return item.Put
+	case http.MethodPost:
+		return item.Post
+	case http.MethodDelete:
+		return item.Delete
+	case http.MethodPatch:
+		return item.Patch
+	}
+	return nil
+}
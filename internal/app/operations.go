@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/health"
+	"walletapitest/internal/pkg/logger"
+)
+
+// runOperationQueue запускает workers воркеров отложенных операций. Воркер
+// берет операции одну за другой, пока очередь не опустеет, затем ждет
+// interval. Работают на каждом экземпляре: запросы разбираются через SKIP
+// LOCKED, поэтому один запрос достается одному воркеру.
+func (a *App) runOperationQueue(ctx context.Context, svc *services.OperationQueueService, workers int, interval time.Duration, worker *health.Worker) {
+	log := a.logger.With("worker", "operations")
+	ctx = logger.WithContext(ctx, log)
+
+	for i := 0; i < max(workers, 1); i++ {
+		go func() {
+			for {
+				processed, err := svc.ProcessNext(ctx)
+				switch {
+				case err != nil && ctx.Err() == nil:
+					log.Error("failed to process queued operation", "error", err)
+					worker.Fail(err)
+				case processed:
+					worker.Beat()
+					continue
+				default:
+					worker.Beat()
+				}
+				if !sleep(ctx, interval) {
+					return
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/health"
+	"walletapitest/internal/pkg/logger"
+)
+
+// reconciliationRetry - пауза перед повтором после неудачной сверки
+const reconciliationRetry = 5 * time.Minute
+
+// runReconciliation запускает сверку раз в interval до отмены ctx. Срок
+// следующей сверки считается от последнего прохода в базе, поэтому перезапуски
+// и несколько экземпляров сервиса не сдвигают расписание и не дублируют проход.
+//
+// Состояние воркера в отчете о здоровье повторяет последний проход: пока
+// в нем есть расхождения, проверка воркера не проходит.
+func (a *App) runReconciliation(ctx context.Context, svc *services.ReconciliationService, interval time.Duration, worker *health.Worker) {
+	log := a.logger.With("worker", "reconciliation")
+	ctx = logger.WithContext(ctx, log)
+
+	for {
+		latest, err := svc.LatestRun(ctx)
+		if err != nil {
+			log.Error("failed to load last reconciliation", "error", err)
+			worker.Fail(err)
+			if !sleep(ctx, reconciliationRetry) {
+				return
+			}
+			continue
+		}
+
+		var wait time.Duration
+		if latest != nil {
+			if latest.Discrepancies > 0 {
+				worker.Fail(fmt.Errorf("%d wallets do not match their operations (run %s)", latest.Discrepancies, latest.ID))
+			} else {
+				worker.Beat()
+			}
+			wait = time.Until(latest.StartedAt.Add(interval))
+		}
+		if wait > 0 {
+			log.Debug("next reconciliation scheduled", "in", wait.Round(time.Second))
+			if !sleep(ctx, wait) {
+				return
+			}
+			continue
+		}
+
+		_, _, err = svc.Reconcile(ctx)
+		switch {
+		case errors.Is(err, services.ErrReconciliationRunning):
+			// Проход другого экземпляра появится в базе, когда он закончит
+			log.Info("reconciliation is running on another instance")
+		case err != nil:
+			log.Error("reconciliation failed", "error", err)
+			worker.Fail(err)
+		default:
+			continue
+		}
+		if !sleep(ctx, reconciliationRetry) {
+			return
+		}
+	}
+}
+
+// sleep ждет d и возвращает false, если ctx отменен раньше
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/postgres"
+	"walletapitest/internal/pkg/health"
+	"walletapitest/internal/pkg/logger"
+)
+
+// schedulesLockKey - ключ advisory-блокировки ведущего исполнителя расписаний
+const schedulesLockKey = 7318462053
+
+// runSchedules раз в interval выполняет наступившие регулярные переводы.
+// Запуски выполняет только экземпляр, удерживающий блокировку leader;
+// остальные ждут и подхватывают ее, если ведущий пропадет.
+func (a *App) runSchedules(ctx context.Context, svc *services.ScheduleService, leader *postgres.LeaderLock, interval time.Duration, worker *health.Worker) {
+	log := a.logger.With("worker", "schedules")
+	ctx = logger.WithContext(ctx, log)
+	defer func() {
+		// ctx уже отменен - снимаем блокировку с отдельным таймаутом
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := leader.Release(releaseCtx); err != nil {
+			log.Error("failed to release schedules leadership", "error", err)
+		}
+	}()
+
+	wasLeader := false
+	for {
+		isLeader, err := leader.Acquire(ctx)
+		switch {
+		case err != nil:
+			log.Error("failed to acquire schedules leadership", "error", err)
+			worker.Fail(err)
+		case !isLeader:
+			if wasLeader {
+				log.Info("lost schedules leadership")
+			}
+			worker.Beat()
+		default:
+			if !wasLeader {
+				log.Info("acquired schedules leadership")
+			}
+			executed, err := svc.RunDue(ctx, time.Now())
+			if err != nil {
+				log.Error("failed to run due schedules", "error", err, "executed", executed)
+				worker.Fail(err)
+			} else {
+				if executed > 0 {
+					log.Info("executed due schedules", "executed", executed)
+				}
+				worker.Beat()
+			}
+		}
+		wasLeader = err == nil && isLeader
+
+		if !sleep(ctx, interval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/http/handlers"
+	"walletapitest/internal/infrastructure/http/openapi"
+	"walletapitest/internal/pkg/mailer"
+	"walletapitest/internal/pkg/totp"
+)
+
+// storage - репозитории, на которых собираются сервисы: Postgres в Run,
+// память в MemoryHandler
+type storage struct {
+	users             repositories.UserRepository
+	wallets           repositories.WalletRepository
+	sessions          repositories.SessionRepository
+	mfa               repositories.MFARepository
+	apiKeys           repositories.APIKeyRepository
+	logins            repositories.LoginRepository
+	userTokens        repositories.UserTokenRepository
+	audit             repositories.AuditRepository
+	reconciliation    repositories.ReconciliationRepository
+	schedules         repositories.ScheduleRepository
+	batches           repositories.BatchRepository
+	operationRequests repositories.OperationRequestRepository
+	balances          repositories.BalanceFeed
+}
+
+// appServices - сервисы, общие для HTTP, gRPC и фоновых воркеров
+type appServices struct {
+	audit          *services.AuditService
+	user           *services.UserService
+	wallet         *services.WalletService
+	session        *services.SessionService
+	mfa            *services.MFAService
+	apiKey         *services.APIKeyService
+	account        *services.AccountService
+	reconciliation *services.ReconciliationService
+	schedule       *services.ScheduleService
+	operationQueue *services.OperationQueueService
+	batch          *services.BatchService
+	access         *services.AccessService
+}
+
+// initServices собирает сервисы на репозиториях st; a.tokens и a.denylist
+// должны быть уже заданы
+func (a *App) initServices(st *storage) (*appServices, error) {
+	s := &appServices{}
+	s.audit = services.NewAuditService(st.audit)
+	s.session = services.NewSessionService(
+		st.sessions,
+		st.users,
+		a.tokens,
+		a.denylist,
+		time.Duration(a.cfg.JWT.RefreshExpiresIn)*time.Second,
+	)
+	s.user = services.NewUserService(st.users, st.logins, s.session, services.LoginPolicy{
+		MaxFailedAttempts: a.cfg.Login.MaxFailedAttempts,
+		LockoutBase:       time.Duration(a.cfg.Login.LockoutBase) * time.Second,
+		LockoutMax:        time.Duration(a.cfg.Login.LockoutMax) * time.Second,
+		IPMaxFailures:     a.cfg.Login.IPMaxFailures,
+		IPWindow:          time.Duration(a.cfg.Login.IPWindow) * time.Second,
+	}, s.audit)
+	fees, err := newFeeSchedule(a.cfg.Fees)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFeeWallets(context.Background(), st.wallets, fees); err != nil {
+		return nil, err
+	}
+	s.wallet = services.NewWalletService(st.wallets, s.audit, fees, st.balances)
+
+	totpCipher, err := totp.NewCipher(a.cfg.MFA.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	s.mfa = services.NewMFAService(st.mfa, st.users, totpCipher, a.cfg.MFA.Issuer, s.audit, s.user)
+	s.apiKey = services.NewAPIKeyService(st.apiKeys, s.wallet, s.audit)
+	a.apiKeys = s.apiKey
+
+	mail, err := mailer.New(a.cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+	s.account = services.NewAccountService(st.users, st.userTokens, st.logins, s.session, mail, services.AccountPolicy{
+		BaseURL:         strings.TrimRight(a.cfg.Account.BaseURL, "/"),
+		VerificationTTL: time.Duration(a.cfg.Account.VerificationTTL) * time.Second,
+		ResetTTL:        time.Duration(a.cfg.Account.ResetTTL) * time.Second,
+	}, s.audit)
+
+	s.reconciliation = services.NewReconciliationService(st.reconciliation)
+	s.schedule = services.NewScheduleService(st.schedules, s.wallet, services.SchedulePolicy{
+		MaxAttempts:          a.cfg.Schedules.MaxAttempts,
+		RetryBackoff:         time.Duration(a.cfg.Schedules.RetryBackoff) * time.Second,
+		MaxInsufficientFunds: a.cfg.Schedules.MaxInsufficientFunds,
+	})
+	s.operationQueue = services.NewOperationQueueService(st.operationRequests, s.wallet, services.OperationQueuePolicy{
+		MaxAttempts:  a.cfg.Operations.MaxAttempts,
+		RetryBackoff: time.Duration(a.cfg.Operations.RetryBackoff) * time.Second,
+		StaleAfter:   time.Duration(a.cfg.Operations.StaleAfter) * time.Second,
+	})
+	s.access = services.NewAccessService(s.user, s.session, s.mfa, s.account, s.wallet, a.cfg.MFA.WithdrawalThreshold)
+	s.batch = services.NewBatchService(st.batches, s.wallet, services.BatchPolicy{
+		MaxItems:   a.cfg.Batch.MaxItems,
+		SyncLimit:  a.cfg.Batch.SyncLimit,
+		Workers:    a.cfg.Batch.Workers,
+		StaleAfter: time.Duration(a.cfg.Batch.StaleAfter) * time.Second,
+	})
+	return s, nil
+}
+
+// newRouter создает хендлеры поверх сервисов и роутер с ними; a.health
+// должен быть уже задан
+func (a *App) newRouter(s *appServices) *gin.Engine {
+	userHandler := handlers.NewUserHandler(s.user, s.account, s.access)
+	walletHandler := handlers.NewWalletHandler(s.wallet, s.operationQueue, s.access)
+	sessionHandler := handlers.NewSessionHandler(s.session)
+	mfaHandler := handlers.NewMFAHandler(s.mfa)
+	apiKeyHandler := handlers.NewAPIKeyHandler(s.apiKey)
+	accountHandler := handlers.NewAccountHandler(s.account, s.user)
+	auditHandler := handlers.NewAuditHandler(s.audit)
+	reconciliationHandler := handlers.NewReconciliationHandler(s.reconciliation)
+	scheduleHandler := handlers.NewScheduleHandler(s.schedule, walletHandler)
+	batchHandler := handlers.NewBatchHandler(s.batch, walletHandler, a.cfg.Batch.MaxItems)
+	healthHandler := handlers.NewHealthHandler(a.health)
+	docsHandler := handlers.NewDocsHandler(openapi.JSON())
+
+	return a.initRouter(userHandler, walletHandler, sessionHandler, mfaHandler, apiKeyHandler, accountHandler, auditHandler, reconciliationHandler, scheduleHandler, batchHandler, healthHandler, docsHandler)
+}
@@ -1,15 +1,30 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	LogLevel string
+	Server         ServerConfig
+	GRPC           GRPCConfig
+	Database       DatabaseConfig
+	Redis          RedisConfig
+	JWT            JWTConfig
+	Tracing        TracingConfig
+	MFA            MFAConfig
+	Login          LoginConfig
+	Mail           MailConfig
+	Account        AccountConfig
+	Reconciliation ReconciliationConfig
+	Schedules      SchedulesConfig
+	Fees           FeesConfig
+	Batch          BatchConfig
+	Operations     OperationsConfig
+	LogLevel       string
 }
 
 type ServerConfig struct {
@@ -17,6 +32,16 @@ type ServerConfig struct {
 	ReadTimeout  int
 	WriteTimeout int
 	IdleTimeout  int
+	DrainDelay   int // секунд между провалом readiness и остановкой сервера
+	// TrustedProxies - адреса/сети прокси, чьим X-Forwarded-For можно верить
+	// при определении IP клиента; пусто - используется адрес соединения
+	TrustedProxies []string
+}
+
+// GRPCConfig - gRPC API на отдельном порту
+type GRPCConfig struct {
+	Enabled bool
+	Port    string
 }
 
 type DatabaseConfig struct {
@@ -28,6 +53,13 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// DSN - строка подключения к Postgres
+func (c DatabaseConfig) DSN() string {
+	return "postgres://" + c.User + ":" + c.Password +
+		"@" + c.Host + ":" + c.Port + "/" + c.Name +
+		"?sslmode=" + c.SSLMode
+}
+
 type RedisConfig struct {
 	Host     string
 	Port     string
@@ -36,8 +68,109 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey string
-	ExpiresIn int
+	SecretKey        string
+	ExpiresIn        int // секунд жизни access-токена
+	RefreshExpiresIn int // секунд жизни refresh-токена
+}
+
+type TracingConfig struct {
+	Enabled     bool
+	Exporter    string // otlp или stdout
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
+}
+
+type MFAConfig struct {
+	Issuer              string
+	EncryptionKey       string // ключ шифрования TOTP-секретов; по умолчанию JWT-секрет
+	WithdrawalThreshold int64  // сумма списания, выше которой нужен второй фактор (0 - выключено)
+}
+
+type LoginConfig struct {
+	MaxFailedAttempts int // неудач подряд до блокировки аккаунта (0 - выключено)
+	LockoutBase       int // секунд первой блокировки; каждая следующая вдвое длиннее
+	LockoutMax        int // секунд, предел блокировки
+	IPMaxFailures     int // неудач с одного IP за IPWindow (0 - выключено)
+	IPWindow          int // секунд
+}
+
+type MailConfig struct {
+	Driver       string // smtp, file или stdout
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	FilePath     string // для драйвера file
+}
+
+type AccountConfig struct {
+	// BaseURL - адрес фронтенда для ссылок в письмах; токен передается в параметре token
+	BaseURL         string
+	VerificationTTL int // секунд жизни ссылки подтверждения email
+	ResetTTL        int // секунд жизни ссылки сброса пароля
+}
+
+type ReconciliationConfig struct {
+	Enabled  bool
+	Interval int // секунд между сверками; по умолчанию раз в сутки
+}
+
+type SchedulesConfig struct {
+	Enabled      bool
+	PollInterval int // секунд между проверками наступивших расписаний
+	// MaxAttempts - попыток одного запуска при временных ошибках
+	MaxAttempts  int
+	RetryBackoff int // секунд до первого повтора, дальше удваивается
+	// MaxInsufficientFunds - подряд запусков без средств до автоматической паузы
+	MaxInsufficientFunds int
+}
+
+type BatchConfig struct {
+	MaxItems int // операций в одном пакете
+	// SyncLimit - пакеты не больше этого размера проводятся в запросе,
+	// большие ставятся в очередь
+	SyncLimit    int
+	Workers      int // параллельных операций при обработке пакета
+	PollInterval int // секунд между проверками очереди пакетов
+	StaleAfter   int // секунд без отметки исполнителя, после которых пакет забирает другой
+}
+
+// OperationsConfig - отложенные (async) операции
+type OperationsConfig struct {
+	Workers      int // воркеров на экземпляр
+	PollInterval int // секунд между проверками пустой очереди
+	MaxAttempts  int // попыток при временных ошибках
+	RetryBackoff int // секунд до первого повтора, дальше удваивается
+	StaleAfter   int // секунд, после которых операцию, взятую воркером, забирает другой
+}
+
+// FeesConfig - комиссии. В переменных окружения FEES_WALLETS и FEES_RULES
+// задаются в JSON с теми же ключами, что и в файле конфигурации.
+type FeesConfig struct {
+	// Wallets - кошелек комиссий для каждой валюты: {"USD": "<wallet id>"}
+	Wallets map[string]string
+	Rules   []FeeRuleConfig
+}
+
+type FeeRuleConfig struct {
+	Name      string
+	Operation string // DEPOSIT, WITHDRAW или TRANSFER
+	Currency  string // пусто - любая валюта
+	UserTier  string // пусто - любой тариф
+	Fixed     int64
+	RateBps   int64 // ставка в базисных пунктах: 150 = 1,5%
+	Tiers     []FeeTierConfig
+	Min       int64
+	Max       int64 // 0 - без ограничения
+}
+
+type FeeTierConfig struct {
+	UpTo    int64 // 0 - без верхней границы
+	Fixed   int64
+	RateBps int64
 }
 
 func Load() (*Config, error) {
@@ -62,11 +195,120 @@ func Load() (*Config, error) {
 	viper.BindEnv("redis.password", "REDIS_PASSWORD")
 	viper.BindEnv("redis.db", "REDIS_DB")
 
+	viper.BindEnv("jwt.secretKey", "JWT_SECRET_KEY")
+	viper.BindEnv("jwt.expiresIn", "JWT_EXPIRES_IN")
+	viper.BindEnv("jwt.refreshExpiresIn", "JWT_REFRESH_EXPIRES_IN")
+
 	viper.BindEnv("server.port", "SERVER_PORT")
+	viper.BindEnv("server.drainDelay", "SERVER_DRAIN_DELAY")
+	viper.BindEnv("server.trustedProxies", "SERVER_TRUSTED_PROXIES")
 	viper.BindEnv("logLevel", "LOG_LEVEL")
 
+	viper.BindEnv("grpc.enabled", "GRPC_ENABLED")
+	viper.BindEnv("grpc.port", "GRPC_PORT")
+
+	viper.BindEnv("tracing.enabled", "TRACING_ENABLED")
+	viper.BindEnv("tracing.exporter", "TRACING_EXPORTER")
+	viper.BindEnv("tracing.endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT")
+	viper.BindEnv("tracing.insecure", "OTEL_EXPORTER_OTLP_INSECURE")
+	viper.BindEnv("tracing.serviceName", "OTEL_SERVICE_NAME")
+	viper.BindEnv("tracing.sampleRatio", "TRACING_SAMPLE_RATIO")
+
+	viper.BindEnv("mfa.issuer", "MFA_ISSUER")
+	viper.BindEnv("mfa.encryptionKey", "MFA_ENCRYPTION_KEY")
+	viper.BindEnv("mfa.withdrawalThreshold", "MFA_WITHDRAWAL_THRESHOLD")
+
+	viper.BindEnv("login.maxFailedAttempts", "LOGIN_MAX_FAILED_ATTEMPTS")
+	viper.BindEnv("login.lockoutBase", "LOGIN_LOCKOUT_BASE")
+	viper.BindEnv("login.lockoutMax", "LOGIN_LOCKOUT_MAX")
+	viper.BindEnv("login.ipMaxFailures", "LOGIN_IP_MAX_FAILURES")
+	viper.BindEnv("login.ipWindow", "LOGIN_IP_WINDOW")
+
+	viper.BindEnv("mail.driver", "MAIL_DRIVER")
+	viper.BindEnv("mail.from", "MAIL_FROM")
+	viper.BindEnv("mail.smtpHost", "MAIL_SMTP_HOST")
+	viper.BindEnv("mail.smtpPort", "MAIL_SMTP_PORT")
+	viper.BindEnv("mail.smtpUsername", "MAIL_SMTP_USERNAME")
+	viper.BindEnv("mail.smtpPassword", "MAIL_SMTP_PASSWORD")
+	viper.BindEnv("mail.filePath", "MAIL_FILE_PATH")
+
+	viper.BindEnv("account.baseURL", "ACCOUNT_BASE_URL")
+	viper.BindEnv("account.verificationTTL", "ACCOUNT_VERIFICATION_TTL")
+	viper.BindEnv("account.resetTTL", "ACCOUNT_RESET_TTL")
+
+	viper.BindEnv("reconciliation.enabled", "RECONCILIATION_ENABLED")
+	viper.BindEnv("reconciliation.interval", "RECONCILIATION_INTERVAL")
+
+	viper.BindEnv("schedules.enabled", "SCHEDULES_ENABLED")
+	viper.BindEnv("schedules.pollInterval", "SCHEDULES_POLL_INTERVAL")
+	viper.BindEnv("schedules.maxAttempts", "SCHEDULES_MAX_ATTEMPTS")
+	viper.BindEnv("schedules.retryBackoff", "SCHEDULES_RETRY_BACKOFF")
+	viper.BindEnv("schedules.maxInsufficientFunds", "SCHEDULES_MAX_INSUFFICIENT_FUNDS")
+
+	viper.BindEnv("batch.maxItems", "BATCH_MAX_ITEMS")
+	viper.BindEnv("batch.syncLimit", "BATCH_SYNC_LIMIT")
+	viper.BindEnv("batch.workers", "BATCH_WORKERS")
+	viper.BindEnv("batch.pollInterval", "BATCH_POLL_INTERVAL")
+	viper.BindEnv("batch.staleAfter", "BATCH_STALE_AFTER")
+
+	viper.BindEnv("operations.workers", "OPERATIONS_WORKERS")
+	viper.BindEnv("operations.pollInterval", "OPERATIONS_POLL_INTERVAL")
+	viper.BindEnv("operations.maxAttempts", "OPERATIONS_MAX_ATTEMPTS")
+	viper.BindEnv("operations.retryBackoff", "OPERATIONS_RETRY_BACKOFF")
+	viper.BindEnv("operations.staleAfter", "OPERATIONS_STALE_AFTER")
+
 	viper.SetDefault("server.port", "8080")
+	viper.SetDefault("server.drainDelay", 5)
 	viper.SetDefault("logLevel", "info")
+	viper.SetDefault("grpc.enabled", true)
+	viper.SetDefault("grpc.port", "9090")
+	viper.SetDefault("jwt.expiresIn", 3600)
+	viper.SetDefault("jwt.refreshExpiresIn", 30*24*3600)
+
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.exporter", "otlp")
+	viper.SetDefault("tracing.endpoint", "localhost:4318")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.serviceName", "wallet-api")
+	viper.SetDefault("tracing.sampleRatio", 1.0)
+
+	viper.SetDefault("mfa.issuer", "Wallet API")
+	viper.SetDefault("mfa.withdrawalThreshold", 0)
+
+	viper.SetDefault("login.maxFailedAttempts", 5)
+	viper.SetDefault("login.lockoutBase", 60)
+	viper.SetDefault("login.lockoutMax", 3600)
+	viper.SetDefault("login.ipMaxFailures", 20)
+	viper.SetDefault("login.ipWindow", 900)
+
+	viper.SetDefault("mail.driver", "stdout")
+	viper.SetDefault("mail.from", "Wallet API <no-reply@localhost>")
+	viper.SetDefault("mail.smtpPort", "587")
+
+	viper.SetDefault("account.baseURL", "http://localhost:8080")
+	viper.SetDefault("account.verificationTTL", 48*3600)
+	viper.SetDefault("account.resetTTL", 3600)
+
+	viper.SetDefault("reconciliation.enabled", true)
+	viper.SetDefault("reconciliation.interval", 24*3600)
+
+	viper.SetDefault("schedules.enabled", true)
+	viper.SetDefault("schedules.pollInterval", 30)
+	viper.SetDefault("schedules.maxAttempts", 5)
+	viper.SetDefault("schedules.retryBackoff", 60)
+	viper.SetDefault("schedules.maxInsufficientFunds", 3)
+
+	viper.SetDefault("batch.maxItems", 10000)
+	viper.SetDefault("batch.syncLimit", 100)
+	viper.SetDefault("batch.workers", 8)
+	viper.SetDefault("batch.pollInterval", 5)
+	viper.SetDefault("batch.staleAfter", 60)
+
+	viper.SetDefault("operations.workers", 4)
+	viper.SetDefault("operations.pollInterval", 1)
+	viper.SetDefault("operations.maxAttempts", 5)
+	viper.SetDefault("operations.retryBackoff", 5)
+	viper.SetDefault("operations.staleAfter", 60)
 
 	// Read config file (optional - will use defaults/env vars if file doesn't exist)
 	viper.ReadInConfig() // Ignore error - config file is optional
@@ -76,5 +318,21 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if raw := os.Getenv("FEES_WALLETS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Fees.Wallets); err != nil {
+			return nil, fmt.Errorf("FEES_WALLETS: %w", err)
+		}
+	}
+	if raw := os.Getenv("FEES_RULES"); raw != "" {
+		cfg.Fees.Rules = nil
+		if err := json.Unmarshal([]byte(raw), &cfg.Fees.Rules); err != nil {
+			return nil, fmt.Errorf("FEES_RULES: %w", err)
+		}
+	}
+
+	if cfg.MFA.EncryptionKey == "" {
+		cfg.MFA.EncryptionKey = cfg.JWT.SecretKey
+	}
+
 	return &cfg, nil
 }
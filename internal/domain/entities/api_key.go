@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey - ключ для межсервисных интеграций. Хранится только хеш секрета;
+// префикс - открытая часть ключа, по которой он ищется.
+type APIKey struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	Name        string       `json:"name" db:"name"`
+	Prefix      string       `json:"prefix" db:"prefix"`
+	SecretHash  string       `json:"-" db:"secret_hash"`
+	Permissions []Permission `json:"permissions" db:"permissions"`
+	// WalletIDs ограничивает ключ конкретными кошельками; без списка ключ
+	// действует только через разрешения на чужие кошельки (_all, freeze и т.п.)
+	WalletIDs  []uuid.UUID `json:"wallet_ids" db:"wallet_ids"`
+	CreatedBy  *uuid.UUID  `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+func NewAPIKey(name, prefix, secretHash string, permissions []Permission, walletIDs []uuid.UUID) *APIKey {
+	return &APIKey{
+		ID:          uuid.New(),
+		Name:        name,
+		Prefix:      prefix,
+		SecretHash:  secretHash,
+		Permissions: permissions,
+		WalletIDs:   walletIDs,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Active - ключ не отозван и не истек
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
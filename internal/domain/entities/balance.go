@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WalletBalance - баланс кошелька на момент времени, восстановленный по
+// balance_after последней операции до этого момента
+type WalletBalance struct {
+	WalletID uuid.UUID `json:"wallet_id" db:"wallet_id"`
+	Balance  int64     `json:"balance" db:"balance"`
+	At       time.Time `json:"at" db:"-"`
+	// OperationID - операция, по которой определен баланс; nil - операций еще не было
+	OperationID *uuid.UUID `json:"operation_id,omitempty" db:"operation_id"`
+}
+
+// BalanceUpdate - новый баланс кошелька после изменения
+type BalanceUpdate struct {
+	WalletID  uuid.UUID `json:"wallet_id"`
+	Balance   int64     `json:"balance"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
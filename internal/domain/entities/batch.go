@@ -0,0 +1,105 @@
+package entities
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BatchStatus string
+
+const (
+	BatchStatusQueued    BatchStatus = "queued"
+	BatchStatusRunning   BatchStatus = "running"
+	BatchStatusCompleted BatchStatus = "completed"
+	// BatchStatusFailed - задание не удалось довести до конца (ошибка базы);
+	// отказы отдельных операций задание не проваливают
+	BatchStatusFailed BatchStatus = "failed"
+)
+
+// BatchJob - пакет операций. Atomic - все или ничего в одной транзакции;
+// иначе каждая операция проводится отдельно.
+type BatchJob struct {
+	ID     uuid.UUID `json:"id" db:"id"`
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	// APIKeyID - ключ, которым отправлен пакет; nil - сессия пользователя UserID
+	APIKeyID    *uuid.UUID  `json:"api_key_id,omitempty" db:"api_key_id"`
+	Atomic      bool        `json:"atomic" db:"atomic"`
+	Status      BatchStatus `json:"status" db:"status"`
+	Total       int         `json:"total" db:"total"`
+	Succeeded   int         `json:"succeeded" db:"succeeded"`
+	Failed      int         `json:"failed" db:"failed"`
+	Skipped     int         `json:"skipped" db:"skipped"`
+	Error       *string     `json:"error,omitempty" db:"error"`
+	HeartbeatAt *time.Time  `json:"-" db:"heartbeat_at"`
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
+	StartedAt   *time.Time  `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+func NewBatchJob(userID uuid.UUID, atomic bool, total int) *BatchJob {
+	return &BatchJob{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Atomic:    atomic,
+		Status:    BatchStatusQueued,
+		Total:     total,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Done - задание завершено, результаты операций окончательные
+func (j *BatchJob) Done() bool {
+	return j.Status == BatchStatusCompleted || j.Status == BatchStatusFailed
+}
+
+type BatchItemStatus string
+
+const (
+	BatchItemPending   BatchItemStatus = "pending"
+	BatchItemSucceeded BatchItemStatus = "succeeded"
+	BatchItemFailed    BatchItemStatus = "failed"
+	// BatchItemSkipped - операция atomic-пакета не проведена из-за отказа другой
+	BatchItemSkipped BatchItemStatus = "skipped"
+)
+
+// BatchItem - операция пакета и ее результат
+type BatchItem struct {
+	JobID         uuid.UUID       `json:"-" db:"job_id"`
+	Index         int             `json:"index" db:"item_index"`
+	WalletID      uuid.UUID       `json:"wallet_id" db:"wallet_id"`
+	OperationType OperationType   `json:"operation_type" db:"operation_type"`
+	Amount        int64           `json:"amount" db:"amount"`
+	Reference     *string         `json:"reference,omitempty" db:"reference"`
+	Status        BatchItemStatus `json:"status" db:"status"`
+	Error         *string         `json:"error,omitempty" db:"error"`
+	OperationID   *uuid.UUID      `json:"operation_id,omitempty" db:"operation_id"`
+	Fee           *int64          `json:"fee,omitempty" db:"fee"`
+	BalanceAfter  *int64          `json:"balance_after,omitempty" db:"balance_after"`
+}
+
+// RequestID - ключ идемпотентности операции: при повторной обработке
+// задания (после сбоя исполнителя) операция не проводится второй раз
+func (i *BatchItem) RequestID() uuid.UUID {
+	return uuid.NewSHA1(i.JobID, []byte("batch-item:"+strconv.Itoa(i.Index)))
+}
+
+// Succeed записывает результат проведенной операции
+func (i *BatchItem) Succeed(result *OperationResult) {
+	i.Status = BatchItemSucceeded
+	i.Error = nil
+	i.OperationID = &result.Operation.ID
+	if result.Fee != nil {
+		fee := result.Fee.Amount
+		i.Fee = &fee
+	}
+	balance := result.BalanceAfter()
+	i.BalanceAfter = &balance
+}
+
+// Fail записывает отказ; status - BatchItemFailed или BatchItemSkipped
+func (i *BatchItem) Fail(status BatchItemStatus, reason string) {
+	i.Status = status
+	i.Error = &reason
+}
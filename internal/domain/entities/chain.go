@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// GenesisHash - prev_hash первой записи хеш-цепочки
+var GenesisHash = strings.Repeat("0", 64)
+
+// chainHash - SHA-256 полей записи цепочки. Длина перед каждым значением
+// исключает неоднозначность склейки полей.
+func chainHash(fields ...string) string {
+	h := sha256.New()
+	for _, f := range fields {
+		fmt.Fprintf(h, "%d:%s|", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ChainVerification - результат проверки хеш-цепочки
+type ChainVerification struct {
+	Valid   bool  `json:"valid"`
+	Checked int64 `json:"checked"`
+	LastSeq int64 `json:"last_seq"`
+	// BrokenAt - номер первой записи, на которой цепочка нарушена
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Legacy - записи, созданные до появления цепочки и не покрытые ею
+	Legacy int64 `json:"legacy,omitempty"`
+}
@@ -0,0 +1,201 @@
+package entities
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// FeeOperationTransfer - ключ правил комиссии для переводов между кошельками;
+// для разовых операций ключ - их тип (DEPOSIT, WITHDRAW)
+const FeeOperationTransfer = "TRANSFER"
+
+// feeRateDenominator - ставки задаются в базисных пунктах: 150 = 1,5%
+const feeRateDenominator = 10000
+
+// FeeTier - ступень тарифа: действует для сумм не больше UpTo (0 - без верхней границы)
+type FeeTier struct {
+	UpTo    int64 `json:"up_to"`
+	Fixed   int64 `json:"fixed"`
+	RateBps int64 `json:"rate_bps"`
+}
+
+// FeeRule - комиссия для типа операции. Пустые Currency и UserTier
+// подходят к любой валюте и любому тарифу. Если заданы Tiers, фиксированная
+// часть и ставка берутся из первой подходящей ступени.
+type FeeRule struct {
+	Name      string    `json:"name"`
+	Operation string    `json:"operation"`
+	Currency  string    `json:"currency,omitempty"`
+	UserTier  string    `json:"user_tier,omitempty"`
+	Fixed     int64     `json:"fixed"`
+	RateBps   int64     `json:"rate_bps"`
+	Tiers     []FeeTier `json:"tiers,omitempty"`
+	Min       int64     `json:"min"`
+	Max       int64     `json:"max"` // 0 - без ограничения сверху
+}
+
+// FeeSchedule - правила комиссий и системные кошельки, на которые они
+// зачисляются (по одному на валюту)
+type FeeSchedule struct {
+	Rules   []FeeRule
+	Wallets map[string]uuid.UUID
+}
+
+// Fee - расчет комиссии за операцию. Debit и Credit - ее проводки:
+// списание с кошелька плательщика и зачисление на кошелек комиссий.
+type Fee struct {
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Rule        string    `json:"rule"`
+	Fixed       int64     `json:"fixed"`
+	RateBps     int64     `json:"rate_bps"`
+	Percentage  int64     `json:"percentage"`
+	TierUpTo    *int64    `json:"tier_up_to,omitempty"`
+	MinApplied  bool      `json:"min_applied,omitempty"`
+	MaxApplied  bool      `json:"max_applied,omitempty"`
+	FeeWalletID uuid.UUID `json:"fee_wallet_id"`
+	TransferID  uuid.UUID `json:"transfer_id"`
+
+	Debit  *Operation `json:"-"`
+	Credit *Operation `json:"-"`
+}
+
+// FeeTransferID - идентификатор проводок комиссии за операцию или перевод
+// sourceID: повтор перевода не может списать комиссию второй раз
+func FeeTransferID(sourceID uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(sourceID, []byte("fee"))
+}
+
+// Rule возвращает самое точное правило для операции: совпадение валюты и
+// тарифа важнее пустого значения; при равенстве побеждает правило выше в списке
+func (s *FeeSchedule) Rule(operation, currency, userTier string) *FeeRule {
+	if s == nil {
+		return nil
+	}
+	var best *FeeRule
+	bestScore := -1
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.Operation != operation {
+			continue
+		}
+		score := 0
+		switch rule.Currency {
+		case currency:
+			score += 2
+		case "":
+		default:
+			continue
+		}
+		switch rule.UserTier {
+		case userTier:
+			score++
+		case "":
+		default:
+			continue
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best
+}
+
+// Quote считает комиссию за операцию на сумму amount; nil - комиссии нет.
+// Комиссия за пополнение не больше самого пополнения.
+func (s *FeeSchedule) Quote(operation, currency, userTier string, amount int64) *Fee {
+	rule := s.Rule(operation, currency, userTier)
+	if rule == nil {
+		return nil
+	}
+
+	fee := &Fee{
+		Currency: currency,
+		Rule:     rule.Name,
+		Fixed:    rule.Fixed,
+		RateBps:  rule.RateBps,
+	}
+	for i := range rule.Tiers {
+		tier := &rule.Tiers[i]
+		if tier.UpTo == 0 || amount <= tier.UpTo {
+			fee.Fixed, fee.RateBps = tier.Fixed, tier.RateBps
+			if tier.UpTo != 0 {
+				upTo := tier.UpTo
+				fee.TierUpTo = &upTo
+			}
+			break
+		}
+	}
+	fee.Percentage = applyRate(amount, fee.RateBps)
+	fee.Amount = fee.Fixed + fee.Percentage
+
+	if fee.Amount < rule.Min {
+		fee.Amount = rule.Min
+		fee.MinApplied = true
+	}
+	if rule.Max > 0 && fee.Amount > rule.Max {
+		fee.Amount = rule.Max
+		fee.MaxApplied = true
+	}
+	if operation == string(OperationTypeDeposit) && fee.Amount > amount {
+		fee.Amount = amount
+		fee.MaxApplied = true
+	}
+	if fee.Amount <= 0 {
+		return nil
+	}
+	return fee
+}
+
+// applyRate - amount * rateBps / 10000 с округлением половины вверх, без
+// переполнения на больших суммах
+func applyRate(amount, rateBps int64) int64 {
+	whole := amount / feeRateDenominator * rateBps
+	rest := (amount%feeRateDenominator*rateBps + feeRateDenominator/2) / feeRateDenominator
+	return whole + rest
+}
+
+// Validate проверяет правила: известный тип операции, ставки 0-100%,
+// ступени по возрастанию, min <= max и кошелек комиссий для каждой валюты правила
+func (s *FeeSchedule) Validate() error {
+	for i, rule := range s.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		switch rule.Operation {
+		case string(OperationTypeDeposit), string(OperationTypeWithdraw), FeeOperationTransfer:
+		default:
+			return fmt.Errorf("fee rule %s: unknown operation %q", name, rule.Operation)
+		}
+		if rule.Fixed < 0 || rule.Min < 0 || rule.Max < 0 {
+			return fmt.Errorf("fee rule %s: amounts must not be negative", name)
+		}
+		if rule.RateBps < 0 || rule.RateBps > feeRateDenominator {
+			return fmt.Errorf("fee rule %s: rate_bps must be between 0 and %d", name, feeRateDenominator)
+		}
+		if rule.Max > 0 && rule.Min > rule.Max {
+			return fmt.Errorf("fee rule %s: min is greater than max", name)
+		}
+		var prev int64
+		for j, tier := range rule.Tiers {
+			if tier.Fixed < 0 || tier.RateBps < 0 || tier.RateBps > feeRateDenominator {
+				return fmt.Errorf("fee rule %s: invalid tier %d", name, j+1)
+			}
+			last := j == len(rule.Tiers)-1
+			if tier.UpTo == 0 && !last || tier.UpTo != 0 && tier.UpTo <= prev {
+				return fmt.Errorf("fee rule %s: tiers must be in ascending order of up_to, only the last may be unbounded", name)
+			}
+			prev = tier.UpTo
+		}
+		if rule.Currency != "" {
+			if _, ok := s.Wallets[rule.Currency]; !ok {
+				return fmt.Errorf("fee rule %s: no fee wallet for %s", name, rule.Currency)
+			}
+		} else if len(s.Wallets) == 0 {
+			return fmt.Errorf("fee rule %s: no fee wallets configured", name)
+		}
+	}
+	return nil
+}
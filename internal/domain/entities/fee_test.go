@@ -0,0 +1,111 @@
+package entities
+
+import "testing"
+
+func TestApplyRateRounding(t *testing.T) {
+	tests := []struct {
+		amount, rateBps, want int64
+	}{
+		{10000, 150, 150},
+		{1, 5000, 1},  // 0,5 округляется вверх
+		{1, 4999, 0},  // 0,4999 - вниз
+		{3, 1666, 0},  // 0,4998
+		{333, 150, 5}, // 4,995
+		{0, 150, 0},
+		{1000, 0, 0},
+		// Без переполнения: amount * rateBps не помещается в int64
+		{9_000_000_000_000_000_000, 10000, 9_000_000_000_000_000_000},
+		{9_000_000_000_000_000_001, 5000, 4_500_000_000_000_000_001},
+	}
+	for _, tt := range tests {
+		if got := applyRate(tt.amount, tt.rateBps); got != tt.want {
+			t.Errorf("applyRate(%d, %d) = %d, want %d", tt.amount, tt.rateBps, got, tt.want)
+		}
+	}
+}
+
+func TestFeeScheduleQuote(t *testing.T) {
+	schedule := &FeeSchedule{Rules: []FeeRule{
+		{Name: "withdraw", Operation: "WITHDRAW", Fixed: 30, RateBps: 100, Min: 50, Max: 500},
+		{Name: "withdraw-eur", Operation: "WITHDRAW", Currency: "EUR", Fixed: 10},
+		{Name: "withdraw-vip", Operation: "WITHDRAW", UserTier: "vip", RateBps: 10},
+		{Name: "withdraw-eur-vip", Operation: "WITHDRAW", Currency: "EUR", UserTier: "vip", Fixed: 1},
+		{Name: "deposit", Operation: "DEPOSIT", Fixed: 100},
+		{Name: "transfer", Operation: FeeOperationTransfer, Tiers: []FeeTier{
+			{UpTo: 1000, Fixed: 10},
+			{UpTo: 10000, RateBps: 50},
+			{Fixed: 5, RateBps: 20},
+		}},
+	}}
+
+	tests := []struct {
+		name                string
+		operation, currency string
+		tier                string
+		amount              int64
+		want                *Fee // nil - комиссии нет
+		wantTierUpTo        int64
+	}{
+		{"fixed plus rate", "WITHDRAW", "USD", "", 10000,
+			&Fee{Amount: 130, Rule: "withdraw", Fixed: 30, RateBps: 100, Percentage: 100}, 0},
+		{"min applied", "WITHDRAW", "USD", "", 100,
+			&Fee{Amount: 50, Rule: "withdraw", Fixed: 30, RateBps: 100, Percentage: 1, MinApplied: true}, 0},
+		{"max applied", "WITHDRAW", "USD", "", 1_000_000,
+			&Fee{Amount: 500, Rule: "withdraw", Fixed: 30, RateBps: 100, Percentage: 10000, MaxApplied: true}, 0},
+		{"rate rounded", "WITHDRAW", "USD", "vip", 15,
+			nil, 0}, // 0,015 округляется до нуля - комиссии нет
+		{"tier rule", "WITHDRAW", "USD", "vip", 10000,
+			&Fee{Amount: 10, Rule: "withdraw-vip", RateBps: 10, Percentage: 10}, 0},
+		{"currency rule", "WITHDRAW", "EUR", "", 10000,
+			&Fee{Amount: 10, Rule: "withdraw-eur", Fixed: 10}, 0},
+		// Валюта важнее тарифа, совпадение обоих - важнее всего
+		{"currency over tier", "WITHDRAW", "EUR", "gold", 10000,
+			&Fee{Amount: 10, Rule: "withdraw-eur", Fixed: 10}, 0},
+		{"currency and tier", "WITHDRAW", "EUR", "vip", 10000,
+			&Fee{Amount: 1, Rule: "withdraw-eur-vip", Fixed: 1}, 0},
+		{"deposit capped by amount", "DEPOSIT", "USD", "", 40,
+			&Fee{Amount: 40, Rule: "deposit", Fixed: 100, MaxApplied: true}, 0},
+		{"first tier", FeeOperationTransfer, "USD", "", 1000,
+			&Fee{Amount: 10, Rule: "transfer", Fixed: 10}, 1000},
+		{"second tier", FeeOperationTransfer, "USD", "", 1001,
+			&Fee{Amount: 5, Rule: "transfer", RateBps: 50, Percentage: 5}, 10000},
+		{"unbounded tier", FeeOperationTransfer, "USD", "", 100000,
+			&Fee{Amount: 205, Rule: "transfer", Fixed: 5, RateBps: 20, Percentage: 200}, 0},
+		{"no rule", "ADJUSTMENT", "USD", "", 1000, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schedule.Quote(tt.operation, tt.currency, tt.tier, tt.amount)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Quote = %+v, want no fee", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Quote = nil, want a fee")
+			}
+			switch {
+			case tt.wantTierUpTo == 0 && got.TierUpTo != nil:
+				t.Errorf("TierUpTo = %d, want none", *got.TierUpTo)
+			case tt.wantTierUpTo != 0 && (got.TierUpTo == nil || *got.TierUpTo != tt.wantTierUpTo):
+				t.Errorf("TierUpTo = %v, want %d", got.TierUpTo, tt.wantTierUpTo)
+			}
+			got.TierUpTo = nil
+			tt.want.Currency = tt.currency
+			if *got != *tt.want {
+				t.Errorf("Quote = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestFeeScheduleQuoteWithoutRules(t *testing.T) {
+	var schedule *FeeSchedule
+	if fee := schedule.Quote("WITHDRAW", "USD", "", 1000); fee != nil {
+		t.Errorf("nil schedule quoted %+v", fee)
+	}
+	if fee := (&FeeSchedule{}).Quote("WITHDRAW", "USD", "", 1000); fee != nil {
+		t.Errorf("empty schedule quoted %+v", fee)
+	}
+}
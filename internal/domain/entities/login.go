@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Причины неудачного входа
+const (
+	LoginFailureUnknownEmail    = "unknown_email"
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureInvalidOTP      = "invalid_otp"
+	LoginFailureAccountLocked   = "account_locked"
+	LoginFailureIPThrottled     = "ip_throttled"
+	// LoginFailureInvalidStepUp - неверный код второго фактора вне входа
+	// (подтверждение списания, отключение TOTP)
+	LoginFailureInvalidStepUp = "invalid_step_up_otp"
+)
+
+// LoginEvent - попытка входа. Для неизвестного email UserID пустой.
+type LoginEvent struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	UserID        *uuid.UUID `json:"-" db:"user_id"`
+	Email         string     `json:"-" db:"email"`
+	IP            string     `json:"ip" db:"ip"`
+	UserAgent     string     `json:"user_agent" db:"user_agent"`
+	Success       bool       `json:"success" db:"success"`
+	FailureReason *string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	// NewIP - успешный вход с адреса, с которого пользователь раньше не входил
+	NewIP     bool      `json:"new_ip" db:"new_ip"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func NewLoginEvent(userID *uuid.UUID, email, ip, userAgent string) *LoginEvent {
+	return &LoginEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Email:     email,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+}
+
+// LoginLockout - счетчики неудачных входов пользователя
+type LoginLockout struct {
+	UserID uuid.UUID `db:"user_id"`
+	// FailedCount - неудачи подряд с последнего успешного входа или блокировки
+	FailedCount int `db:"failed_count"`
+	// LockoutCount - сколько раз подряд аккаунт блокировался; задает длительность следующей блокировки
+	LockoutCount int        `db:"lockout_count"`
+	LockedUntil  *time.Time `db:"locked_until"`
+	LastFailedAt *time.Time `db:"last_failed_at"`
+}
+
+// Locked - аккаунт заблокирован в момент now
+func (l *LoginLockout) Locked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
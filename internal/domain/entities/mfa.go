@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA - настройки TOTP пользователя. Secret хранится зашифрованным.
+type UserMFA struct {
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	Enabled      bool       `json:"enabled" db:"enabled"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+}
+
+func NewUserMFA(userID uuid.UUID, encryptedSecret string) *UserMFA {
+	return &UserMFA{
+		UserID:    userID,
+		Secret:    encryptedSecret,
+		CreatedAt: time.Now(),
+	}
+}
@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,10 +17,38 @@ const (
 type Operation struct {
 	ID            uuid.UUID     `json:"id" db:"id"`
 	WalletID      uuid.UUID     `json:"wallet_id" db:"wallet_id"`
+	UserID        uuid.UUID     `json:"user_id" db:"user_id"`
 	OperationType OperationType `json:"operation_type" db:"operation_type"`
 	Amount        int64         `json:"amount" db:"amount"`
 	BalanceAfter  int64         `json:"balance_after" db:"balance_after"`
+	Reason        *string       `json:"reason,omitempty" db:"reason"` // причина ручной корректировки
 	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	// Seq, PrevHash и Hash - звено хеш-цепочки кошелька; пусты у операций,
+	// созданных до ее появления
+	Seq      *int64  `json:"seq,omitempty" db:"seq"`
+	PrevHash *string `json:"prev_hash,omitempty" db:"prev_hash"`
+	Hash     *string `json:"hash,omitempty" db:"hash"`
+	// TransferID связывает списание и зачисление одного перевода между кошельками
+	TransferID *uuid.UUID `json:"transfer_id,omitempty" db:"transfer_id"`
+	// RequestID - ключ идемпотентности разовой операции
+	RequestID *uuid.UUID `json:"request_id,omitempty" db:"request_id"`
+}
+
+// OperationResult - итог разовой операции: сама операция, комиссия за нее
+// (nil - без комиссии) и признак того, что операция с тем же ключом
+// идемпотентности уже была проведена раньше
+type OperationResult struct {
+	Operation *Operation
+	Fee       *Fee
+	Replayed  bool
+}
+
+// BalanceAfter - баланс кошелька после операции и комиссии за нее
+func (r *OperationResult) BalanceAfter() int64 {
+	if r.Fee != nil {
+		return r.Fee.Debit.BalanceAfter
+	}
+	return r.Operation.BalanceAfter
 }
 
 func NewOperation(walletID uuid.UUID, operationType OperationType, amount, balanceAfter int64) *Operation {
@@ -29,6 +58,58 @@ func NewOperation(walletID uuid.UUID, operationType OperationType, amount, balan
 		OperationType: operationType,
 		Amount:        amount,
 		BalanceAfter:  balanceAfter,
-		CreatedAt:     time.Now(),
+		// Postgres хранит микросекунды: округляем заранее, чтобы хеш сошелся при проверке
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+	}
+}
+
+// Chain связывает операцию с предыдущей в кошельке и вычисляет ее хеш
+func (o *Operation) Chain(seq int64, prevHash string) {
+	o.Seq = &seq
+	o.PrevHash = &prevHash
+	hash := o.ComputeHash()
+	o.Hash = &hash
+}
+
+// ComputeHash считает SHA-256 содержимого операции вместе с хешем предыдущей
+func (o *Operation) ComputeHash() string {
+	var seq int64
+	if o.Seq != nil {
+		seq = *o.Seq
 	}
-}
\ No newline at end of file
+	prevHash, reason := "", ""
+	if o.PrevHash != nil {
+		prevHash = *o.PrevHash
+	}
+	if o.Reason != nil {
+		reason = *o.Reason
+	}
+	fields := []string{
+		prevHash,
+		strconv.FormatInt(seq, 10),
+		o.ID.String(),
+		o.WalletID.String(),
+		o.UserID.String(),
+		string(o.OperationType),
+		strconv.FormatInt(o.Amount, 10),
+		strconv.FormatInt(o.BalanceAfter, 10),
+		reason,
+		o.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	// Поле добавлено позже: у операций без перевода хеш остается прежним
+	if o.TransferID != nil {
+		fields = append(fields, o.TransferID.String())
+	}
+	if o.RequestID != nil {
+		fields = append(fields, "request:"+o.RequestID.String())
+	}
+	return chainHash(fields...)
+}
+
+// SignedAmount - сумма со знаком: зачисления положительные, списания отрицательные
+func (o *Operation) SignedAmount() int64 {
+	if o.OperationType == OperationTypeWithdraw {
+		return -o.Amount
+	}
+	return o.Amount
+}
@@ -0,0 +1,103 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OperationRequestStatus string
+
+const (
+	OperationRequestPending    OperationRequestStatus = "pending"
+	OperationRequestProcessing OperationRequestStatus = "processing"
+	OperationRequestCompleted  OperationRequestStatus = "completed"
+	// OperationRequestRejected - операция не проведена; причина в Reason
+	OperationRequestRejected OperationRequestStatus = "rejected"
+)
+
+// OperationRequest - отложенное пополнение или списание: принимается сразу,
+// проводится воркером. ID - ключ идемпотентности операции.
+type OperationRequest struct {
+	ID     uuid.UUID `json:"id" db:"id"`
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	// APIKeyID - ключ, которым принят запрос; nil - сессия пользователя UserID
+	APIKeyID      *uuid.UUID             `json:"api_key_id,omitempty" db:"api_key_id"`
+	WalletID      uuid.UUID              `json:"wallet_id" db:"wallet_id"`
+	OperationType OperationType          `json:"operation_type" db:"operation_type"`
+	Amount        int64                  `json:"amount" db:"amount"`
+	Status        OperationRequestStatus `json:"status" db:"status"`
+	Reason        *string                `json:"reason,omitempty" db:"reason"`
+	OperationID   *uuid.UUID             `json:"operation_id,omitempty" db:"operation_id"`
+	Fee           *int64                 `json:"fee,omitempty" db:"fee"`
+	BalanceAfter  *int64                 `json:"balance_after,omitempty" db:"balance_after"`
+	Attempts      int                    `json:"attempts" db:"attempts"`
+	AvailableAt   time.Time              `json:"-" db:"available_at"`
+	ClaimedAt     *time.Time             `json:"-" db:"claimed_at"`
+	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at" db:"updated_at"`
+	CompletedAt   *time.Time             `json:"completed_at,omitempty" db:"completed_at"`
+	// Replayed - запрос с этим ID уже был принят раньше и возвращен из очереди
+	Replayed bool `json:"-" db:"-"`
+}
+
+func NewOperationRequest(userID, walletID uuid.UUID, operationType OperationType, amount int64) *OperationRequest {
+	now := time.Now()
+	return &OperationRequest{
+		ID:            uuid.New(),
+		UserID:        userID,
+		WalletID:      walletID,
+		OperationType: operationType,
+		Amount:        amount,
+		Status:        OperationRequestPending,
+		AvailableAt:   now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// SameAuthor - запросы приняты от одного пользователя или одного API-ключа
+func (r *OperationRequest) SameAuthor(other *OperationRequest) bool {
+	if r.APIKeyID == nil || other.APIKeyID == nil {
+		return r.APIKeyID == nil && other.APIKeyID == nil && r.UserID == other.UserID
+	}
+	return *r.APIKeyID == *other.APIKeyID
+}
+
+// Done - результат окончательный
+func (r *OperationRequest) Done() bool {
+	return r.Status == OperationRequestCompleted || r.Status == OperationRequestRejected
+}
+
+// Complete записывает результат проведенной операции
+func (r *OperationRequest) Complete(result *OperationResult) {
+	r.finish(OperationRequestCompleted)
+	r.OperationID = &result.Operation.ID
+	if result.Fee != nil {
+		fee := result.Fee.Amount
+		r.Fee = &fee
+	}
+	balance := result.BalanceAfter()
+	r.BalanceAfter = &balance
+}
+
+// Reject записывает отказ в операции
+func (r *OperationRequest) Reject(reason string) {
+	r.finish(OperationRequestRejected)
+	r.Reason = &reason
+}
+
+// Retry возвращает запрос в очередь после временной ошибки
+func (r *OperationRequest) Retry(at time.Time) {
+	r.Status = OperationRequestPending
+	r.AvailableAt = at
+	r.ClaimedAt = nil
+	r.UpdatedAt = time.Now()
+}
+
+func (r *OperationRequest) finish(status OperationRequestStatus) {
+	now := time.Now()
+	r.Status = status
+	r.UpdatedAt = now
+	r.CompletedAt = &now
+}
@@ -0,0 +1,41 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReconciliationRun - один проход сверки балансов всех кошельков
+type ReconciliationRun struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	StartedAt      time.Time `json:"started_at" db:"started_at"`
+	FinishedAt     time.Time `json:"finished_at" db:"finished_at"`
+	WalletsChecked int64     `json:"wallets_checked" db:"wallets_checked"`
+	Discrepancies  int64     `json:"discrepancies" db:"discrepancies"`
+}
+
+func NewReconciliationRun() *ReconciliationRun {
+	now := time.Now().Truncate(time.Microsecond)
+	return &ReconciliationRun{
+		ID:         uuid.New(),
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+}
+
+// Discrepancy - кошелек, баланс которого не сходится с историей операций.
+// Такое возможно после изменения баланса в обход операций.
+type Discrepancy struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	RunID    uuid.UUID `json:"run_id" db:"run_id"`
+	WalletID uuid.UUID `json:"wallet_id" db:"wallet_id"`
+	Balance  int64     `json:"balance" db:"balance"`
+	// LastBalanceAfter - balance_after последней операции; nil - операций не было
+	LastBalanceAfter *int64 `json:"last_balance_after" db:"last_balance_after"`
+	OperationsSum    int64  `json:"operations_sum" db:"operations_sum"`
+	OperationsCount  int64  `json:"operations_count" db:"operations_count"`
+	// Расхождения со знаком: баланс минус ожидаемое значение
+	LastBalanceDrift int64 `json:"last_balance_drift" db:"last_balance_drift"`
+	SumDrift         int64 `json:"sum_drift" db:"sum_drift"`
+}
@@ -0,0 +1,108 @@
+package entities
+
+// Role - роль пользователя; определяет набор разрешений
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleSupport Role = "support"
+	RoleAdmin   Role = "admin"
+	RoleAuditor Role = "auditor"
+)
+
+// Permission - право на действие. Разрешения с суффиксом _all действуют на
+// чужие ресурсы, без него - только на собственные.
+type Permission string
+
+const (
+	PermUsersReadAll   Permission = "users:read_all"
+	PermUsersManage    Permission = "users:manage"
+	PermWalletsRead    Permission = "wallets:read"
+	PermWalletsOperate Permission = "wallets:operate"
+	PermWalletsReadAll Permission = "wallets:read_all"
+	PermWalletsFreeze  Permission = "wallets:freeze"
+	PermWalletsAdjust  Permission = "wallets:adjust"
+	PermHistoryReadAll Permission = "history:read_all"
+	PermReportsRead    Permission = "reports:read"
+	PermAPIKeysManage  Permission = "api_keys:manage"
+	PermAuditRead      Permission = "audit:read"
+	// PermPayoutsCreate - пакетные пополнения чужих кошельков (выплаты)
+	PermPayoutsCreate Permission = "payouts:create"
+)
+
+var knownPermissions = map[Permission]bool{
+	PermUsersReadAll:   true,
+	PermUsersManage:    true,
+	PermWalletsRead:    true,
+	PermWalletsOperate: true,
+	PermWalletsReadAll: true,
+	PermWalletsFreeze:  true,
+	PermWalletsAdjust:  true,
+	PermHistoryReadAll: true,
+	PermReportsRead:    true,
+	PermAPIKeysManage:  true,
+	PermAuditRead:      true,
+	PermPayoutsCreate:  true,
+}
+
+// Valid - разрешение известно системе
+func (p Permission) Valid() bool {
+	return knownPermissions[p]
+}
+
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {
+		PermWalletsRead,
+		PermWalletsOperate,
+	},
+	RoleSupport: {
+		PermUsersReadAll,
+		PermWalletsRead,
+		PermWalletsReadAll,
+		PermHistoryReadAll,
+	},
+	RoleAdmin: {
+		PermUsersReadAll,
+		PermUsersManage,
+		PermWalletsRead,
+		PermWalletsOperate,
+		PermWalletsReadAll,
+		PermWalletsFreeze,
+		PermWalletsAdjust,
+		PermHistoryReadAll,
+		PermReportsRead,
+		PermAPIKeysManage,
+		PermAuditRead,
+		PermPayoutsCreate,
+	},
+	RoleAuditor: {
+		PermHistoryReadAll,
+		PermReportsRead,
+		PermAuditRead,
+	},
+}
+
+// Valid - роль известна системе
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Permissions возвращает разрешения роли
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// PermissionNames возвращает разрешения роли строками (для токенов)
+func (r Role) PermissionNames() []string {
+	return PermissionStrings(rolePermissions[r])
+}
+
+// PermissionStrings возвращает разрешения строками
+func PermissionStrings(perms []Permission) []string {
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = string(p)
+	}
+	return names
+}
@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleStatusActive    ScheduleStatus = "active"
+	ScheduleStatusPaused    ScheduleStatus = "paused"
+	ScheduleStatusCancelled ScheduleStatus = "cancelled"
+)
+
+// Schedule - регулярный перевод между кошельками по cron-выражению (UTC)
+// или с фиксированным интервалом
+type Schedule struct {
+	ID              uuid.UUID      `json:"id" db:"id"`
+	UserID          uuid.UUID      `json:"user_id" db:"user_id"`
+	FromWalletID    uuid.UUID      `json:"from_wallet_id" db:"from_wallet_id"`
+	ToWalletID      uuid.UUID      `json:"to_wallet_id" db:"to_wallet_id"`
+	Amount          int64          `json:"amount" db:"amount"`
+	Description     string         `json:"description" db:"description"`
+	Cron            *string        `json:"cron,omitempty" db:"cron"`
+	IntervalSeconds *int64         `json:"interval_seconds,omitempty" db:"interval_seconds"`
+	Status          ScheduleStatus `json:"status" db:"status"`
+	PausedReason    *string        `json:"paused_reason,omitempty" db:"paused_reason"`
+	// NextRunAt - срок текущего запуска; RetryAt - повтор этого запуска после временной ошибки
+	NextRunAt              time.Time  `json:"next_run_at" db:"next_run_at"`
+	RetryAt                *time.Time `json:"retry_at,omitempty" db:"retry_at"`
+	Attempts               int        `json:"attempts" db:"attempts"`
+	InsufficientFundsCount int        `json:"insufficient_funds_count" db:"insufficient_funds_count"`
+	LastRunAt              *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	CreatedAt              time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func NewSchedule(userID, fromWalletID, toWalletID uuid.UUID, amount int64, description string, cronExpr *string, intervalSeconds *int64) *Schedule {
+	now := time.Now()
+	return &Schedule{
+		ID:              uuid.New(),
+		UserID:          userID,
+		FromWalletID:    fromWalletID,
+		ToWalletID:      toWalletID,
+		Amount:          amount,
+		Description:     description,
+		Cron:            cronExpr,
+		IntervalSeconds: intervalSeconds,
+		Status:          ScheduleStatusActive,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
+
+// TransferID - ключ идемпотентности перевода для текущего срока: повторы
+// одного запуска получают тот же ключ и не проводят перевод дважды
+func (s *Schedule) TransferID() uuid.UUID {
+	return uuid.NewSHA1(s.ID, []byte(s.NextRunAt.UTC().Format(time.RFC3339Nano)))
+}
+
+type ScheduleRunStatus string
+
+const (
+	ScheduleRunSucceeded ScheduleRunStatus = "succeeded"
+	ScheduleRunFailed    ScheduleRunStatus = "failed"
+	// ScheduleRunRetrying - попытка не удалась из-за временной ошибки, назначен повтор
+	ScheduleRunRetrying ScheduleRunStatus = "retrying"
+)
+
+// ScheduleRun - результат одной попытки выполнить расписание
+type ScheduleRun struct {
+	ID           uuid.UUID         `json:"id" db:"id"`
+	ScheduleID   uuid.UUID         `json:"schedule_id" db:"schedule_id"`
+	ScheduledFor time.Time         `json:"scheduled_for" db:"scheduled_for"`
+	Attempt      int               `json:"attempt" db:"attempt"`
+	Status       ScheduleRunStatus `json:"status" db:"status"`
+	Error        *string           `json:"error,omitempty" db:"error"`
+	TransferID   uuid.UUID         `json:"transfer_id" db:"transfer_id"`
+	ExecutedAt   time.Time         `json:"executed_at" db:"executed_at"`
+}
+
+func NewScheduleRun(s *Schedule) *ScheduleRun {
+	return &ScheduleRun{
+		ID:           uuid.New(),
+		ScheduleID:   s.ID,
+		ScheduledFor: s.NextRunAt,
+		Attempt:      s.Attempts + 1,
+		TransferID:   s.TransferID(),
+		ExecutedAt:   time.Now(),
+	}
+}
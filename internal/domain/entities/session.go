@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	UserAgent    string     `json:"user_agent" db:"user_agent"`
+	IP           string     `json:"ip" db:"ip"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt   time.Time  `json:"last_used_at" db:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokeReason *string    `json:"revoke_reason,omitempty" db:"revoke_reason"`
+}
+
+func NewSession(userID uuid.UUID, userAgent, ip string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+}
+
+func (s *Session) Revoked() bool {
+	return s.RevokedAt != nil
+}
+
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	SessionID uuid.UUID  `json:"session_id" db:"session_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+func NewRefreshToken(sessionID uuid.UUID, tokenHash string, ttl time.Duration) *RefreshToken {
+	now := time.Now()
+	return &RefreshToken{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
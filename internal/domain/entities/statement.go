@@ -0,0 +1,68 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statement - выписка по кошельку за период [From, To]. Итоговые поля
+// заполняются по мере вывода строк и окончательны только к концу выписки.
+type Statement struct {
+	WalletID       uuid.UUID `json:"wallet_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	GeneratedAt    time.Time `json:"generated_at"`
+	OpeningBalance int64     `json:"opening_balance"`
+	ClosingBalance int64     `json:"closing_balance"`
+	TotalCredits   int64     `json:"total_credits"`
+	TotalDebits    int64     `json:"total_debits"`
+	Count          int64     `json:"count"`
+}
+
+// StatementLine - операция выписки с балансом после нее
+type StatementLine struct {
+	OperationID    uuid.UUID     `json:"operation_id"`
+	CreatedAt      time.Time     `json:"created_at"`
+	OperationType  OperationType `json:"operation_type"`
+	Amount         int64         `json:"amount"`
+	RunningBalance int64         `json:"running_balance"`
+	Reason         string        `json:"reason,omitempty"`
+}
+
+func NewStatement(wallet *Wallet, from, to time.Time, openingBalance int64) *Statement {
+	return &Statement{
+		WalletID:       wallet.ID,
+		UserID:         wallet.UserID,
+		From:           from,
+		To:             to,
+		GeneratedAt:    time.Now().UTC(),
+		OpeningBalance: openingBalance,
+		ClosingBalance: openingBalance,
+	}
+}
+
+// Add учитывает операцию в итогах выписки и возвращает ее строку.
+// Баланс берется из balance_after, а не суммируется: так выписка сходится с BalanceAt.
+func (s *Statement) Add(op *Operation) *StatementLine {
+	if op.SignedAmount() < 0 {
+		s.TotalDebits += op.Amount
+	} else {
+		s.TotalCredits += op.Amount
+	}
+	s.ClosingBalance = op.BalanceAfter
+	s.Count++
+
+	line := &StatementLine{
+		OperationID:    op.ID,
+		CreatedAt:      op.CreatedAt,
+		OperationType:  op.OperationType,
+		Amount:         op.Amount,
+		RunningBalance: op.BalanceAfter,
+	}
+	if op.Reason != nil {
+		line.Reason = *op.Reason
+	}
+	return line
+}
@@ -0,0 +1,28 @@
+package entities
+
+import "github.com/google/uuid"
+
+// Transfer - перевод между кошельками: списание с FromWalletID и зачисление
+// на ToWalletID в одной транзакции. ID - ключ идемпотентности: повтор с тем
+// же ID не проводит перевод второй раз.
+type Transfer struct {
+	ID           uuid.UUID  `json:"id"`
+	FromWalletID uuid.UUID  `json:"from_wallet_id"`
+	ToWalletID   uuid.UUID  `json:"to_wallet_id"`
+	Amount       int64      `json:"amount"`
+	Debit        *Operation `json:"debit"`
+	Credit       *Operation `json:"credit"`
+	// Fee - комиссия, списанная с отправителя; nil - без комиссии
+	Fee *Fee `json:"fee,omitempty"`
+	// Replayed - перевод с этим ID уже был проведен раньше, операции взяты из истории
+	Replayed bool `json:"replayed"`
+}
+
+func NewTransfer(id, fromWalletID, toWalletID uuid.UUID, amount int64) *Transfer {
+	return &Transfer{
+		ID:           id,
+		FromWalletID: fromWalletID,
+		ToWalletID:   toWalletID,
+		Amount:       amount,
+	}
+}
@@ -2,26 +2,40 @@ package entities
 
 import (
 	"time"
-	
+
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Username  string    `json:"username" db:"username"`
-	Password  string    `json:"-" db:"password"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID       uuid.UUID `json:"id" db:"id"`
+	Email    string    `json:"email" db:"email"`
+	Username string    `json:"username" db:"username"`
+	Password string    `json:"-" db:"password"`
+	Role     Role      `json:"role" db:"role"`
+	// Tier - тариф пользователя, по нему выбираются комиссии
+	Tier string `json:"tier" db:"tier"`
+	// EmailVerifiedAt - когда пользователь подтвердил email; nil - не подтвержден
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// DefaultUserTier - тариф новых пользователей
+const DefaultUserTier = "standard"
+
 func NewUser(email, username, password string) *User {
 	return &User{
 		ID:        uuid.New(),
 		Email:     email,
 		Username:  username,
 		Password:  password, // В реальном приложении хешировать!
+		Role:      RoleUser,
+		Tier:      DefaultUserTier,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-}
\ No newline at end of file
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserTokenPurpose string
+
+const (
+	TokenPurposeEmailVerification UserTokenPurpose = "email_verification"
+	TokenPurposePasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken - одноразовый токен, отправляемый пользователю по email.
+// Хранится только хеш; использованный токен остается в таблице.
+type UserToken struct {
+	ID        uuid.UUID        `json:"id" db:"id"`
+	UserID    uuid.UUID        `json:"user_id" db:"user_id"`
+	Purpose   UserTokenPurpose `json:"purpose" db:"purpose"`
+	TokenHash string           `json:"-" db:"token_hash"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time       `json:"used_at,omitempty" db:"used_at"`
+}
+
+func NewUserToken(userID uuid.UUID, purpose UserTokenPurpose, tokenHash string, ttl time.Duration) *UserToken {
+	now := time.Now()
+	return &UserToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
@@ -6,21 +6,39 @@ import (
 	"github.com/google/uuid"
 )
 
+type WalletStatus string
+
+const (
+	WalletStatusActive WalletStatus = "active"
+	WalletStatusFrozen WalletStatus = "frozen"
+)
+
+// DefaultCurrency - валюта кошелька, если при создании она не указана
+const DefaultCurrency = "USD"
+
 type Wallet struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
 	Balance   int64       `json:"balance" db:"balance"`
+	Currency  string    `json:"currency" db:"currency"`
+	Status    WalletStatus `json:"status" db:"status"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
-func NewWallet(userID uuid.UUID) *Wallet {
+func NewWallet(userID uuid.UUID, currency string) *Wallet {
 	return &Wallet{
 		ID:        uuid.New(),
 		UserID:    userID,
 		Balance:   0,
+		Currency:  currency,
+		Status:    WalletStatusActive,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 }
 
+// Frozen - кошелек заморожен и не принимает операций
+func (w *Wallet) Frozen() bool {
+	return w.Status == WalletStatusFrozen
+}
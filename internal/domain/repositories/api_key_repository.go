@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository interface {
+	// Create сохраняет ключ; event (если не nil) пишется в журнал аудита в
+	// той же транзакции
+	Create(ctx context.Context, key *entities.APIKey, event *entities.AuditEvent) error
+	FindByID(ctx context.Context, id uuid.UUID) (*entities.APIKey, error)
+	FindByPrefix(ctx context.Context, prefix string) (*entities.APIKey, error)
+	List(ctx context.Context) ([]*entities.APIKey, error)
+	// Revoke отзывает ключ; event (если не nil) пишется в журнал аудита в
+	// той же транзакции
+	Revoke(ctx context.Context, id uuid.UUID, event *entities.AuditEvent) error
+	// Rotate сохраняет новый ключ и в той же транзакции ограничивает срок
+	// действия старого моментом oldExpiresAt и пишет event (если не nil) в
+	// журнал аудита
+	Rotate(ctx context.Context, oldID uuid.UUID, oldExpiresAt time.Time, next *entities.APIKey, event *entities.AuditEvent) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
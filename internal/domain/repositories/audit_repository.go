@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+// AuditFilter - параметры поиска по журналу аудита; пустые поля не фильтруют
+type AuditFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditFunc строит запись аудита по проведенной операции. Репозиторий пишет
+// ее в транзакции операции: без записи операция не сохраняется. nil-функция
+// или nil-запись - без аудита.
+type AuditFunc func(operation *entities.Operation) (*entities.AuditEvent, error)
+
+type AuditRepository interface {
+	// Append добавляет запись в конец цепочки: назначает Seq, PrevHash и Hash.
+	// Добавления сериализуются, чтобы цепочка оставалась линейной.
+	Append(ctx context.Context, event *entities.AuditEvent) error
+	// Search возвращает записи по фильтру, новые первыми, и общее число совпадений
+	Search(ctx context.Context, filter AuditFilter) ([]*entities.AuditEvent, int, error)
+	// ListAfter возвращает до limit записей с seq > afterSeq по возрастанию seq
+	ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*entities.AuditEvent, error)
+}
@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"errors"
+
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+var ErrBalanceFeedClosed = errors.New("balance updates are unavailable")
+
+// BalanceFeed - изменения балансов кошельков, сделанные любым экземпляром
+type BalanceFeed interface {
+	// Subscribe возвращает канал изменений баланса кошелька и функцию отписки.
+	// Канал хранит только последнее недоставленное изменение; nil в канале -
+	// изменения могли быть потеряны, баланс нужно перечитать. Канал
+	// закрывается, когда лента останавливается.
+	Subscribe(walletID uuid.UUID) (<-chan *entities.BalanceUpdate, func())
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+var ErrBatchJobNotFound = errors.New("batch job not found")
+
+type BatchRepository interface {
+	// Create сохраняет задание и его операции в одной транзакции
+	Create(ctx context.Context, job *entities.BatchJob, items []*entities.BatchItem) error
+	// FindByID возвращает задание с числом операций по статусам
+	FindByID(ctx context.Context, id uuid.UUID) (*entities.BatchJob, error)
+	// ListItems возвращает операции задания по порядку и их общее число;
+	// status (nil - любой) фильтрует по статусу
+	ListItems(ctx context.Context, jobID uuid.UUID, status *entities.BatchItemStatus, limit, offset int) ([]*entities.BatchItem, int, error)
+	// PendingItems возвращает еще не обработанные операции задания по порядку
+	PendingItems(ctx context.Context, jobID uuid.UUID) ([]*entities.BatchItem, error)
+	// UpdateItems сохраняет результаты операций в одной транзакции. Результат
+	// уже обработанной операции не перезаписывается.
+	UpdateItems(ctx context.Context, items []*entities.BatchItem) error
+	// Claim переводит в running самое старое задание в очереди или брошенное
+	// исполнителем (отметка старше staleBefore) и возвращает его; nil - таких нет
+	Claim(ctx context.Context, staleBefore time.Time) (*entities.BatchJob, error)
+	// Heartbeat обновляет отметку исполнителя задания
+	Heartbeat(ctx context.Context, jobID uuid.UUID) error
+	// Finish сохраняет итоговый статус и ошибку задания
+	Finish(ctx context.Context, job *entities.BatchJob) error
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+type LoginRepository interface {
+	RecordEvent(ctx context.Context, event *entities.LoginEvent) error
+	RecentEvents(ctx context.Context, userID uuid.UUID, limit int) ([]*entities.LoginEvent, error)
+	CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, error)
+	// KnownIP - с этого адреса уже был успешный вход пользователя
+	KnownIP(ctx context.Context, userID uuid.UUID, ip string) (bool, error)
+
+	FindLockout(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error)
+	// RegisterFailure атомарно увеличивает счетчик неудач и возвращает новое состояние
+	RegisterFailure(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error)
+	// Lock блокирует аккаунт до until, если счетчик неудач все еще не меньше
+	// threshold (параллельная неудача могла заблокировать раньше). Возвращает,
+	// была ли выполнена блокировка; только тогда event (если не nil) пишется
+	// в журнал аудита в той же транзакции.
+	Lock(ctx context.Context, userID uuid.UUID, threshold int, until time.Time, event *entities.AuditEvent) (bool, error)
+	// ResetFailures обнуляет счетчики после успешного входа
+	ResetFailures(ctx context.Context, userID uuid.UUID) error
+}
@@ -0,0 +1,23 @@
+package repositories
+
+import (
+	"context"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository interface {
+	Find(ctx context.Context, userID uuid.UUID) (*entities.UserMFA, error)
+	// Save создает или перезаписывает настройки TOTP пользователя; event
+	// (если не nil) пишется в журнал аудита в той же транзакции
+	Save(ctx context.Context, mfa *entities.UserMFA, event *entities.AuditEvent) error
+	// Delete удаляет TOTP и коды восстановления; event (если не nil) пишется
+	// в журнал аудита в той же транзакции
+	Delete(ctx context.Context, userID uuid.UUID, event *entities.AuditEvent) error
+	// UseStep атомарно сдвигает last_used_step; false - код этого интервала уже использован
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode помечает код использованным; false - кода нет или он уже использован
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOperationRequestNotFound = errors.New("operation not found")
+	// ErrOperationRequestClaimLost - Save не записал исход: запрос уже забрал
+	// другой воркер или он завершен
+	ErrOperationRequestClaimLost = errors.New("operation request is no longer held by this attempt")
+)
+
+type OperationRequestRepository interface {
+	// Create сохраняет новый запрос; ErrDuplicate - запрос с таким ID уже есть
+	Create(ctx context.Context, request *entities.OperationRequest) error
+	FindByID(ctx context.Context, id uuid.UUID) (*entities.OperationRequest, error)
+	// Claim переводит в processing самый старый запрос, срок которого наступил
+	// к now, или брошенный воркером (взят раньше staleBefore), увеличивает
+	// число попыток и возвращает его; nil - таких нет
+	Claim(ctx context.Context, now, staleBefore time.Time) (*entities.OperationRequest, error)
+	// Save сохраняет исход попытки. Запрос, который уже забрал другой воркер
+	// или который уже завершен, не меняется: ErrOperationRequestClaimLost.
+	Save(ctx context.Context, request *entities.OperationRequest) error
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReconciliationRunning  = errors.New("reconciliation is already running")
+	ErrReconciliationNotFound = errors.New("reconciliation run not found")
+)
+
+type ReconciliationRepository interface {
+	// Reconcile сверяет все кошельки на одном снимке данных и сохраняет
+	// проход вместе с найденными расхождениями. Одновременно выполняется
+	// только один проход: иначе ErrReconciliationRunning.
+	Reconcile(ctx context.Context, run *entities.ReconciliationRun) ([]*entities.Discrepancy, error)
+	// LatestRun возвращает последний проход; nil, если сверок еще не было
+	LatestRun(ctx context.Context) (*entities.ReconciliationRun, error)
+	FindRun(ctx context.Context, id uuid.UUID) (*entities.ReconciliationRun, error)
+	// ListRuns возвращает проходы, новые первыми, и их общее число
+	ListRuns(ctx context.Context, limit, offset int) ([]*entities.ReconciliationRun, int, error)
+	ListDiscrepancies(ctx context.Context, runID uuid.UUID) ([]*entities.Discrepancy, error)
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *entities.Schedule) error
+	FindByID(ctx context.Context, id uuid.UUID) (*entities.Schedule, error)
+	// ListByWallet возвращает расписания, списывающие с кошелька, кроме отмененных
+	ListByWallet(ctx context.Context, walletID uuid.UUID) ([]*entities.Schedule, error)
+	// UpdateState сохраняет статус и срок запуска после действия пользователя
+	UpdateState(ctx context.Context, schedule *entities.Schedule) error
+	// ListDue возвращает до limit активных расписаний, срок которых (или
+	// повтора) наступил к now, самые просроченные первыми
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.Schedule, error)
+	// RecordRun сохраняет попытку и новое состояние расписания в одной
+	// транзакции. Статус меняется только с active на paused: пауза или отмена
+	// пользователем во время выполнения не отменяются.
+	RecordRun(ctx context.Context, schedule *entities.Schedule, run *entities.ScheduleRun) error
+	// ListRuns возвращает попытки расписания, новые первыми, и их общее число
+	ListRuns(ctx context.Context, scheduleID uuid.UUID, limit, offset int) ([]*entities.ScheduleRun, int, error)
+}
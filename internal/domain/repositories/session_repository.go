@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+// ErrTokenAlreadyUsed - refresh-токен уже был обменян (в том числе параллельным запросом)
+var ErrTokenAlreadyUsed = errors.New("refresh token already used")
+
+type SessionRepository interface {
+	// Create сохраняет сессию и ее первый refresh-токен
+	Create(ctx context.Context, session *entities.Session, token *entities.RefreshToken) error
+	FindByID(ctx context.Context, id uuid.UUID) (*entities.Session, error)
+	FindRefreshToken(ctx context.Context, tokenHash string) (*entities.RefreshToken, error)
+	// Rotate помечает старый токен использованным и сохраняет новый;
+	// если старый уже использован, возвращает ErrTokenAlreadyUsed
+	Rotate(ctx context.Context, old, next *entities.RefreshToken) error
+	Revoke(ctx context.Context, id uuid.UUID, reason string) error
+	// RevokeAllForUser отзывает все активные сессии и возвращает их идентификаторы
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, reason string) ([]uuid.UUID, error)
+}
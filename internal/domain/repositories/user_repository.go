@@ -2,16 +2,42 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"walletapitest/internal/domain/entities"
 	
 	"github.com/google/uuid"
 )
 
+var (
+	ErrUserHasFunds = errors.New("user has wallets with non-zero balance")
+	ErrDuplicate    = errors.New("duplicate key value")
+)
+
+// UserFilter - параметры постраничного списка пользователей
+type UserFilter struct {
+	EmailPrefix    string
+	UsernamePrefix string
+	SortBy         string // created_at, email или username
+	SortDesc       bool
+	Limit          int
+	Offset         int
+}
+
 type UserRepository interface {
 	Create(ctx context.Context, user *entities.User) error
 	FindByID(ctx context.Context, id uuid.UUID) (*entities.User, error)
 	FindByEmail(ctx context.Context, email string) (*entities.User, error)
-	Update(ctx context.Context, user *entities.User) error
+	// Update сохраняет пользователя; event (если не nil) пишется в журнал
+	// аудита в той же транзакции. При смене email неиспользованные токены
+	// пользователя удаляются: ссылки из писем на старый адрес перестают
+	// действовать.
+	Update(ctx context.Context, user *entities.User, event *entities.AuditEvent) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	List(ctx context.Context, limit, offset int) ([]*entities.User, error)
+	FindByUsername(ctx context.Context, username string) (*entities.User, error)
+	Search(ctx context.Context, filter UserFilter) ([]*entities.User, int, error)
+	// DeleteWithWallets удаляет пользователя вместе с кошельками в одной транзакции;
+	// если на каком-либо кошельке ненулевой баланс, возвращает ErrUserHasFunds.
+	// event (если не nil) пишется в журнал аудита в той же транзакции.
+	DeleteWithWallets(ctx context.Context, id uuid.UUID, event *entities.AuditEvent) error
 }
\ No newline at end of file
@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"context"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+type UserTokenRepository interface {
+	// Create сохраняет токен, погашая ранее выданные неиспользованные токены
+	// того же назначения: действует только последняя ссылка из письма
+	Create(ctx context.Context, token *entities.UserToken) error
+	// Consume атомарно помечает токен использованным. Возвращает nil, если
+	// токен не найден, уже использован или истек.
+	Consume(ctx context.Context, tokenHash string, purpose entities.UserTokenPurpose) (*entities.UserToken, error)
+	// Latest - последний выданный пользователю токен; nil - не выдавался
+	Latest(ctx context.Context, userID uuid.UUID, purpose entities.UserTokenPurpose) (*entities.UserToken, error)
+}
@@ -2,16 +2,48 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 	"walletapitest/internal/domain/entities"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+var (
+	ErrWalletNotFound    = errors.New("wallet not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrWalletFrozen      = errors.New("wallet is frozen")
+	ErrInvalidOperation  = errors.New("invalid operation type")
+	ErrTransferMismatch  = errors.New("transfer id was already used for a different transfer")
+	ErrCurrencyMismatch  = errors.New("wallets have different currencies")
+	// ErrFeeWalletNotConfigured - комиссия положена, но кошелька комиссий для валюты нет
+	ErrFeeWalletNotConfigured = errors.New("fee wallet is not configured")
+	// ErrRequestMismatch - ключ идемпотентности уже использован для другой операции
+	ErrRequestMismatch = errors.New("request id was already used for a different operation")
+)
+
+// BatchItemError - отказ операции index atomic-пакета; весь пакет откачен
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
 type WalletRepository interface {
 	Create(ctx context.Context, wallet *entities.Wallet) error
 	FindByID(ctx context.Context, id uuid.UUID) (*entities.Wallet, error)
 	FindByUserID(ctx context.Context, userID uuid.UUID) ([]*entities.Wallet, error)
+	// FindByIDs возвращает найденные кошельки из ids; отсутствующих в ответе нет
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*entities.Wallet, error)
 	Update(ctx context.Context, wallet *entities.Wallet) error
 	UpdateBalance(ctx context.Context, walletID uuid.UUID, amount int64) error
 	Delete(ctx context.Context, id uuid.UUID) error
@@ -21,5 +53,47 @@ type WalletRepository interface {
 	UpdateBalanceWithTx(ctx context.Context, tx *sqlx.Tx, walletID uuid.UUID, amount int64) error
 	FindByIDWithTx(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (*entities.Wallet, error)
 
-	ProcessOperationAtomic(ctx context.Context, walletID uuid.UUID, operationType entities.OperationType, amount int64) error
+	// ProcessOperationAtomic проводит пополнение или списание; комиссию по fees
+	// (nil - без комиссий) списывает на кошелек комиссий в той же транзакции.
+	// Операция с уже использованным requestID не проводится повторно: результат
+	// берется из истории с Replayed; другая операция с тем же ключом - ErrRequestMismatch.
+	ProcessOperationAtomic(ctx context.Context, walletID uuid.UUID, operationType entities.OperationType, amount int64, requestID *uuid.UUID, fees *entities.FeeSchedule) (*entities.OperationResult, error)
+	// FindRequest возвращает с Replayed результат операции, уже проведенной
+	// с requestID; nil - такой операции не было, другая операция с тем же
+	// ключом - ErrRequestMismatch
+	FindRequest(ctx context.Context, walletID uuid.UUID, operationType entities.OperationType, amount int64, requestID uuid.UUID) (*entities.OperationResult, error)
+	// ProcessBatchAtomic проводит операции пакета в одной транзакции и
+	// записывает в items их результаты. Отказ одной операции откатывает все
+	// и возвращается как *BatchItemError. Пакет, уже проведенный ранее
+	// (по RequestID операций), не проводится повторно.
+	ProcessBatchAtomic(ctx context.Context, items []*entities.BatchItem, fees *entities.FeeSchedule) error
+	// AdjustBalanceAtomic - ручная корректировка баланса со знаком, не смотрит на статус кошелька.
+	// Запись аудита, построенная audit по операции, пишется в той же транзакции.
+	AdjustBalanceAtomic(ctx context.Context, walletID uuid.UUID, amount int64, reason string, audit AuditFunc) (*entities.Operation, error)
+	// TransferAtomic проводит перевод: списание и зачисление в одной транзакции.
+	// Если перевод с transfer.ID уже проведен, заполняет его операции из истории
+	// и выставляет Replayed, не меняя балансы. Комиссия по fees списывается
+	// с кошелька отправителя в той же транзакции.
+	TransferAtomic(ctx context.Context, transfer *entities.Transfer, reason *string, fees *entities.FeeSchedule) error
+	// SetStatus возвращает ErrWalletNotFound, если кошелька нет; event (если
+	// не nil) пишется в журнал аудита в той же транзакции
+	SetStatus(ctx context.Context, walletID uuid.UUID, status entities.WalletStatus, event *entities.AuditEvent) error
+
+	// ListChainedOperations возвращает до limit операций хеш-цепочки кошелька
+	// с seq > afterSeq по возрастанию seq
+	ListChainedOperations(ctx context.Context, walletID uuid.UUID, afterSeq int64, limit int) ([]*entities.Operation, error)
+	// CountLegacyOperations - число операций кошелька, созданных до появления цепочки
+	CountLegacyOperations(ctx context.Context, walletID uuid.UUID) (int64, error)
+
+	// BalanceAt возвращает баланс кошелька на момент at; ErrWalletNotFound, если кошелька нет
+	BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (*entities.WalletBalance, error)
+	// BalancesAtByUser возвращает балансы всех кошельков пользователя, созданных не позже at
+	BalancesAtByUser(ctx context.Context, userID uuid.UUID, at time.Time) ([]*entities.WalletBalance, error)
+
+	// ListOperationsBetween возвращает до limit операций кошелька с created_at
+	// в [from, to] в порядке их применения, начиная после операции after (nil - с начала)
+	ListOperationsBetween(ctx context.Context, walletID uuid.UUID, from, to time.Time, after *entities.Operation, limit int) ([]*entities.Operation, error)
+
+	GetOperationsHistory(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entities.Operation, error)
+	GetOperationsHistoryByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*entities.Operation, error)
 }
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// ErrAPIKeyScope - кошелек вне области API-ключа
+var ErrAPIKeyScope = errors.New("api key is not allowed for this wallet")
+
+// StepUpError - списание выше Threshold без верного второго фактора; Err -
+// ErrMFARequired, ErrInvalidOTP или ErrMFAEnrollmentRequired
+type StepUpError struct {
+	Err       error
+	Threshold int64
+}
+
+func (e *StepUpError) Error() string {
+	return fmt.Sprintf("%s (step-up threshold %d)", e.Err, e.Threshold)
+}
+
+func (e *StepUpError) Unwrap() error {
+	return e.Err
+}
+
+// AccessService - проверки вызывающего, общие для REST и gRPC: вход со
+// вторым фактором, повторная аутентификация для смены email, доступ к
+// кошельку, подтвержденный email и step-up для списаний
+type AccessService struct {
+	userService    *UserService
+	sessionService *SessionService
+	mfaService     *MFAService
+	accountService *AccountService
+	walletService  *WalletService
+	// stepUpThreshold - сумма списания, выше которой нужен второй фактор (0 - выключено)
+	stepUpThreshold int64
+}
+
+func NewAccessService(
+	userService *UserService,
+	sessionService *SessionService,
+	mfaService *MFAService,
+	accountService *AccountService,
+	walletService *WalletService,
+	stepUpThreshold int64,
+) *AccessService {
+	return &AccessService{
+		userService:     userService,
+		sessionService:  sessionService,
+		mfaService:      mfaService,
+		accountService:  accountService,
+		walletService:   walletService,
+		stepUpThreshold: stepUpThreshold,
+	}
+}
+
+// Login проверяет пароль и второй фактор, учитывает исход попытки и
+// открывает сессию. Неверный пароль - ErrUserNotFound или
+// ErrInvalidPassword, нет кода - ErrMFARequired, неверный код -
+// ErrInvalidOTP, блокировка - ThrottleError.
+func (s *AccessService) Login(ctx context.Context, email, password, otp string, meta SessionMeta) (*entities.User, *TokenPair, error) {
+	user, err := s.userService.Authenticate(ctx, email, password, meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = logger.WithFields(ctx, "user_id", user.ID)
+
+	mfaEnabled, err := s.mfaService.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("check two-factor status: %w", err)
+	}
+	if mfaEnabled {
+		if otp == "" {
+			return nil, nil, ErrMFARequired
+		}
+		if err := s.mfaService.Verify(ctx, user.ID, otp); err != nil {
+			if err != ErrInvalidOTP && err != ErrMFARequired {
+				return nil, nil, fmt.Errorf("verify two-factor code: %w", err)
+			}
+			// Перебор кодов учитывается наравне с перебором паролей
+			if err := s.userService.RecordLoginFailure(ctx, user, meta, entities.LoginFailureInvalidOTP); err != nil {
+				logger.FromContext(ctx).Error("failed to record login failure", "error", err)
+			}
+			return nil, nil, err
+		}
+	}
+
+	if err := s.userService.RecordLoginSuccess(ctx, user, meta); err != nil {
+		logger.FromContext(ctx).Error("failed to record login success", "error", err)
+	}
+
+	tokens, err := s.sessionService.Start(ctx, user, meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("start session: %w", err)
+	}
+	return user, tokens, nil
+}
+
+// Reauthentication - подтверждение личности для смены email: текущий пароль
+// или код второго фактора
+type Reauthentication struct {
+	Password string
+	OTP      string
+	Meta     SessionMeta
+}
+
+// UpdateUser - UserService.UpdateUser, который при смене email сначала
+// проверяет пароль или код второго фактора вызывающего. Неудачи учитываются
+// в блокировке входа: иначе украденный access-токен позволял бы перебирать
+// пароль и увести аккаунт через сброс пароля на новый адрес.
+func (s *AccessService) UpdateUser(ctx context.Context, id uuid.UUID, update UserUpdate, reauth Reauthentication) (*entities.User, error) {
+	if !auth.CanActOn(ctx, id, string(entities.PermUsersManage)) {
+		return nil, ErrForbidden
+	}
+	if update.Email != nil {
+		user, err := s.userService.GetUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(*update.Email, user.Email) {
+			if err := s.reauthenticate(ctx, reauth); err != nil {
+				return nil, err
+			}
+			update.reauthenticated = true
+		}
+	}
+	return s.userService.UpdateUser(ctx, id, update)
+}
+
+// reauthenticate проверяет пароль, а без него - код второго фактора
+// вызывающего
+func (s *AccessService) reauthenticate(ctx context.Context, reauth Reauthentication) error {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		return ErrForbidden
+	}
+	switch {
+	case reauth.Password != "":
+		return s.userService.VerifyPassword(ctx, claims.UserID, reauth.Password, reauth.Meta)
+	case reauth.OTP != "":
+		return s.mfaService.VerifyStepUp(ctx, claims.UserID, reauth.OTP, reauth.Meta)
+	default:
+		return ErrReauthRequired
+	}
+}
+
+// Wallet загружает кошелек и проверяет доступ к нему вызывающего из ctx
+// (см. AuthorizeWallet)
+func (s *AccessService) Wallet(ctx context.Context, walletID uuid.UUID, perms ...entities.Permission) (*entities.Wallet, error) {
+	wallet, err := s.walletService.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := AuthorizeWallet(ctx, wallet, perms...); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// ReplayedOperation возвращает результат операции, уже проведенной с
+// ключом requestID, если кошелек доступен вызывающему; nil - ключа нет или
+// операции с ним еще не было. Повтор не проходит проверки списания заново:
+// они пройдены при проведении, а использованный код второго фактора
+// повторно не принимается.
+func (s *AccessService) ReplayedOperation(
+	ctx context.Context,
+	walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	requestID *uuid.UUID,
+) (*entities.OperationResult, error) {
+	if requestID == nil {
+		return nil, nil
+	}
+	if _, err := s.Wallet(ctx, walletID); err != nil {
+		return nil, err
+	}
+	return s.walletService.Replay(ctx, walletID, operationType, amount, *requestID)
+}
+
+// Operation проверяет пополнение или списание amount с кошелька walletID
+// так же, как POST /api/v1/wallet: владелец, а для списаний еще
+// AuthorizeWithdrawal
+func (s *AccessService) Operation(
+	ctx context.Context,
+	walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	otp string,
+	meta SessionMeta,
+) (*entities.Wallet, error) {
+	wallet, err := s.Wallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if operationType == entities.OperationTypeWithdraw {
+		if err := s.AuthorizeWithdrawal(ctx, wallet, amount, otp, meta); err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
+
+// AuthorizeWithdrawal пропускает списание amount с кошелька, только если
+// владелец подтвердил email, а выше порога - еще и второй фактор владельца
+func (s *AccessService) AuthorizeWithdrawal(ctx context.Context, wallet *entities.Wallet, amount int64, otp string, meta SessionMeta) error {
+	if err := s.accountService.RequireVerifiedEmail(ctx, wallet.UserID); err != nil {
+		return err
+	}
+	if s.StepUpRequired(amount) {
+		return s.VerifyStepUp(ctx, wallet.UserID, otp, meta)
+	}
+	return nil
+}
+
+// RequireVerifiedEmail - ErrEmailNotVerified, если владелец кошелька не
+// подтвердил email
+func (s *AccessService) RequireVerifiedEmail(ctx context.Context, ownerID uuid.UUID) error {
+	return s.accountService.RequireVerifiedEmail(ctx, ownerID)
+}
+
+func (s *AccessService) StepUpRequired(amount int64) bool {
+	return s.stepUpThreshold > 0 && amount > s.stepUpThreshold
+}
+
+// VerifyStepUp проверяет второй фактор userID. Step-up - защита
+// пользовательских сессий; у API-ключей второго фактора нет.
+func (s *AccessService) VerifyStepUp(ctx context.Context, userID uuid.UUID, otp string, meta SessionMeta) error {
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && claims.IsAPIKey() {
+		return nil
+	}
+	err := s.mfaService.VerifyStepUp(ctx, userID, otp, meta)
+	switch err {
+	case ErrMFARequired, ErrInvalidOTP, ErrMFAEnrollmentRequired:
+		return &StepUpError{Err: err, Threshold: s.stepUpThreshold}
+	}
+	return err
+}
+
+// AuthorizeWallet пропускает владельца кошелька или обладателя любого из
+// разрешений perms на чужие кошельки; иначе ErrForbidden. API-ключ
+// владельцем не бывает: он действует на кошельки из своей области, а без
+// области - только с perms (ErrAPIKeyScope).
+func AuthorizeWallet(ctx context.Context, wallet *entities.Wallet, perms ...entities.Permission) error {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if ok && claims.IsAPIKey() {
+		return AuthorizeAPIKeyScope(ctx, wallet.ID, perms...)
+	}
+	if ok {
+		if claims.UserID == wallet.UserID {
+			return nil
+		}
+		for _, perm := range perms {
+			if claims.HasPermission(string(perm)) {
+				return nil
+			}
+		}
+	}
+	return ErrForbidden
+}
+
+// AuthorizeAPIKeyScope - ErrAPIKeyScope, если кошелек вне области API-ключа,
+// а у ключа без области нет ни одного из разрешений all. Пользовательских
+// токенов не касается.
+func AuthorizeAPIKeyScope(ctx context.Context, walletID uuid.UUID, all ...entities.Permission) error {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok || claims.CoversWallet(walletID, entities.PermissionStrings(all)...) {
+		return nil
+	}
+	return ErrAPIKeyScope
+}
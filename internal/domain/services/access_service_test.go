@@ -0,0 +1,105 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+func TestAuthorizeWallet(t *testing.T) {
+	owner, other, keyID := uuid.New(), uuid.New(), uuid.New()
+	wallet := &entities.Wallet{ID: uuid.New(), UserID: owner}
+	readAll := string(entities.PermWalletsReadAll)
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		perms  []entities.Permission
+		want   error
+	}{
+		{"owner", &auth.Claims{UserID: owner}, nil, nil},
+		{"other user", &auth.Claims{UserID: other}, nil, services.ErrForbidden},
+		{"other user with permission", &auth.Claims{UserID: other, Permissions: []string{readAll}},
+			[]entities.Permission{entities.PermWalletsReadAll}, nil},
+		{"permission not accepted here", &auth.Claims{UserID: other, Permissions: []string{readAll}}, nil, services.ErrForbidden},
+		{"no claims", nil, nil, services.ErrForbidden},
+		// Ключ владельцем не бывает, даже если выпущен владельцем
+		{"api key of owner without scope", &auth.Claims{UserID: owner, APIKeyID: keyID}, nil, services.ErrAPIKeyScope},
+		{"api key scoped to wallet", &auth.Claims{UserID: other, APIKeyID: keyID, WalletIDs: []uuid.UUID{wallet.ID}}, nil, nil},
+		{"api key scoped elsewhere", &auth.Claims{UserID: owner, APIKeyID: keyID, WalletIDs: []uuid.UUID{uuid.New()}},
+			[]entities.Permission{entities.PermWalletsReadAll}, services.ErrAPIKeyScope},
+		{"api key without scope with permission", &auth.Claims{APIKeyID: keyID, Permissions: []string{readAll}},
+			[]entities.Permission{entities.PermWalletsReadAll}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = auth.WithClaims(ctx, tt.claims)
+			}
+			if err := services.AuthorizeWallet(ctx, wallet, tt.perms...); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserEmailRequiresReauthentication(t *testing.T) {
+	const maxFailures = 2
+	f := newMFAFixture(t, maxFailures)
+	access := services.NewAccessService(f.users, nil, f.mfa, nil, nil, 0)
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{UserID: f.user.ID})
+	tokens := memory.NewUserTokenRepository(f.store)
+	reset := entities.NewUserToken(f.user.ID, entities.TokenPurposePasswordReset, "reset-hash", time.Hour)
+	if err := tokens.Create(ctx, reset); err != nil {
+		t.Fatal(err)
+	}
+
+	email := "new-" + f.user.Email
+	update := services.UserUpdate{Email: &email}
+
+	// Без подтверждения и в обход AccessService email не меняется
+	if _, err := access.UpdateUser(ctx, f.user.ID, update, services.Reauthentication{}); !errors.Is(err, services.ErrReauthRequired) {
+		t.Fatalf("no credentials: got %v, want %v", err, services.ErrReauthRequired)
+	}
+	if _, err := f.users.UpdateUser(ctx, f.user.ID, update); !errors.Is(err, services.ErrReauthRequired) {
+		t.Fatalf("UserService.UpdateUser: got %v, want %v", err, services.ErrReauthRequired)
+	}
+	// Смена только username подтверждения не требует
+	username := "renamed-" + f.user.ID.String()
+	if _, err := access.UpdateUser(ctx, f.user.ID, services.UserUpdate{Username: &username}, services.Reauthentication{}); err != nil {
+		t.Fatalf("username only: %v", err)
+	}
+
+	if _, err := access.UpdateUser(ctx, f.user.ID, update, services.Reauthentication{Password: "wrong"}); !errors.Is(err, services.ErrInvalidPassword) {
+		t.Fatalf("wrong password: got %v, want %v", err, services.ErrInvalidPassword)
+	}
+	user, err := access.UpdateUser(ctx, f.user.ID, update, services.Reauthentication{OTP: code(t, f.secret, 1)})
+	if err != nil {
+		t.Fatalf("valid otp: %v", err)
+	}
+	if user.Email != email || user.EmailVerified() {
+		t.Errorf("email = %q verified = %v, want %q unverified", user.Email, user.EmailVerified(), email)
+	}
+	if latest, err := tokens.Latest(ctx, f.user.ID, entities.TokenPurposePasswordReset); err != nil || latest != nil {
+		t.Errorf("reset token after email change = %v, %v; want deleted", latest, err)
+	}
+
+	// Неверные пароли учитываются в блокировке входа
+	other := "other-" + f.user.Email
+	update.Email = &other
+	access.UpdateUser(ctx, f.user.ID, update, services.Reauthentication{Password: "wrong"})
+	_, err = access.UpdateUser(ctx, f.user.ID, update, services.Reauthentication{Password: "secret"})
+	var throttle *services.ThrottleError
+	if !errors.As(err, &throttle) || !errors.Is(err, services.ErrAccountLocked) {
+		t.Fatalf("after %d wrong passwords: got %v, want %v", maxFailures, err, services.ErrAccountLocked)
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/mailer"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrMailCooldown         = errors.New("an email was sent recently")
+)
+
+const (
+	// mailCooldown - минимальный интервал между письмами одного назначения
+	mailCooldown = time.Minute
+	mailTimeout  = time.Minute
+)
+
+// AccountPolicy - параметры ссылок в письмах
+type AccountPolicy struct {
+	BaseURL         string
+	VerificationTTL time.Duration
+	ResetTTL        time.Duration
+}
+
+// AccountService - подтверждение email и сброс пароля по одноразовым ссылкам
+type AccountService struct {
+	userRepo  repositories.UserRepository
+	tokenRepo repositories.UserTokenRepository
+	loginRepo repositories.LoginRepository
+	sessions  *SessionService
+	mailer    mailer.Mailer
+	policy    AccountPolicy
+	audit     *AuditService
+}
+
+func NewAccountService(
+	userRepo repositories.UserRepository,
+	tokenRepo repositories.UserTokenRepository,
+	loginRepo repositories.LoginRepository,
+	sessions *SessionService,
+	mailer mailer.Mailer,
+	policy AccountPolicy,
+	audit *AuditService,
+) *AccountService {
+	return &AccountService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		loginRepo: loginRepo,
+		sessions:  sessions,
+		mailer:    mailer,
+		policy:    policy,
+		audit:     audit,
+	}
+}
+
+// SendVerification отправляет ссылку подтверждения email. Повторная отправка
+// раньше mailCooldown отклоняется с ThrottleError.
+func (s *AccountService) SendVerification(ctx context.Context, user *entities.User) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.SendVerification", attribute.String("user.id", user.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if user.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+	if err = s.checkCooldown(ctx, user.ID, entities.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	token, err := s.issueToken(ctx, user.ID, entities.TokenPurposeEmailVerification, s.policy.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.deliver(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nconfirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, s.link("/verify-email", token), s.policy.VerificationTTL),
+	})
+	return nil
+}
+
+// VerifyEmail гасит токен подтверждения и отмечает email пользователя подтвержденным
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.consumeToken(ctx, token, entities.TokenPurposeEmailVerification)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerified() {
+		return user, nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	user.UpdatedAt = now
+	event, err := s.audit.EventFor(ctx, user.ID, entities.AuditUserEmailVerified, entities.AuditTargetUser, user.ID.String(),
+		nil, map[string]string{"email": user.Email})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.userRepo.Update(ctx, user, event); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("email verified", "user_id", user.ID)
+	return user, nil
+}
+
+// RequestPasswordReset отправляет ссылку сброса пароля. Для неизвестного
+// email ничего не делает и не сообщает об этом, чтобы по ответу нельзя было
+// проверить, зарегистрирован ли адрес.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.RequestPasswordReset")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	log := logger.FromContext(ctx)
+	if user == nil {
+		log.Info("password reset requested for unknown email")
+		return nil
+	}
+
+	if err = s.checkCooldown(ctx, user.ID, entities.TokenPurposePasswordReset); err != nil {
+		if errors.Is(err, ErrMailCooldown) {
+			log.Info("password reset email suppressed by cooldown", "user_id", user.ID)
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.issueToken(ctx, user.ID, entities.TokenPurposePasswordReset, s.policy.ResetTTL)
+	if err != nil {
+		return err
+	}
+
+	s.deliver(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nsomeone requested a password reset for your account. To choose a new password open the link below:\n\n%s\n\nThe link expires in %s. If you did not request a reset, ignore this email.\n",
+			user.Username, s.link("/reset-password", token), s.policy.ResetTTL),
+	})
+
+	log.Info("password reset requested", "user_id", user.ID)
+	return nil
+}
+
+// ResetPassword гасит токен сброса и меняет пароль. Блокировка входа
+// снимается: владелец почты подтвердил, что он владелец аккаунта. Все сессии
+// пользователя отзываются: токены, выданные по старому паролю, мог получить
+// тот, из-за кого пароль сбрасывают.
+func (s *AccountService) ResetPassword(ctx context.Context, token, password string) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.consumeToken(ctx, token, entities.TokenPurposePasswordReset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.Password = password // В реальном приложении хешировать!
+	// Ссылка пришла на email, значит адрес подтвержден
+	if !user.EmailVerified() {
+		user.EmailVerifiedAt = &now
+	}
+	user.UpdatedAt = now
+	event, err := s.audit.EventFor(ctx, user.ID, entities.AuditUserPasswordReset, entities.AuditTargetUser, user.ID.String(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.userRepo.Update(ctx, user, event); err != nil {
+		return nil, err
+	}
+	if _, err = s.sessions.RevokeAll(ctx, user.ID, RevokeReasonPasswordReset); err != nil {
+		return nil, err
+	}
+	if err = s.loginRepo.ResetFailures(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Warn("password reset", "user_id", user.ID)
+	return user, nil
+}
+
+// RequireVerifiedEmail возвращает ErrEmailNotVerified, если пользователь
+// не подтвердил email
+func (s *AccountService) RequireVerifiedEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if !user.EmailVerified() {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+func (s *AccountService) checkCooldown(ctx context.Context, userID uuid.UUID, purpose entities.UserTokenPurpose) error {
+	latest, err := s.tokenRepo.Latest(ctx, userID, purpose)
+	if err != nil {
+		return err
+	}
+	if latest == nil {
+		return nil
+	}
+	if wait := mailCooldown - time.Since(latest.CreatedAt); wait > 0 {
+		return &ThrottleError{Err: ErrMailCooldown, RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *AccountService) issueToken(ctx context.Context, userID uuid.UUID, purpose entities.UserTokenPurpose, ttl time.Duration) (string, error) {
+	token, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokenRepo.Create(ctx, entities.NewUserToken(userID, purpose, hash, ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *AccountService) consumeToken(ctx context.Context, token string, purpose entities.UserTokenPurpose) (*entities.User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	consumed, err := s.tokenRepo.Consume(ctx, auth.HashToken(token), purpose)
+	if err != nil {
+		return nil, err
+	}
+	if consumed == nil {
+		logger.FromContext(ctx).Warn("invalid account token", "purpose", purpose)
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, consumed.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.policy.BaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// deliver отправляет письмо в фоне: ответ не ждет почтовый сервер, а время
+// ответа не выдает, существует ли адрес
+func (s *AccountService) deliver(ctx context.Context, msg mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mailTimeout)
+	go func() {
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			logger.FromContext(ctx).Error("failed to send email", "subject", msg.Subject, "error", err)
+		}
+	}()
+}
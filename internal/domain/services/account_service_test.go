@@ -0,0 +1,100 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/mailer"
+)
+
+// capturedMail принимает письма, которые сервис отправляет в фоне
+type capturedMail chan mailer.Message
+
+func (m capturedMail) Send(ctx context.Context, msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`token=(\S+)`)
+
+// token ждет письмо и достает токен из ссылки в нем
+func (m capturedMail) token(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-m:
+		match := linkToken.FindStringSubmatch(msg.Body)
+		if match == nil {
+			t.Fatalf("no link in %q", msg.Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email sent")
+		return ""
+	}
+}
+
+func TestResetPasswordRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	user := createUser(t, store)
+	denylist := newFakeDenylist()
+	sessions := services.NewSessionService(memory.NewSessionRepository(store), memory.NewUserRepository(store),
+		auth.NewTokenManager("test secret", time.Minute), denylist, time.Hour)
+	mail := make(capturedMail, 1)
+	account := services.NewAccountService(memory.NewUserRepository(store), memory.NewUserTokenRepository(store),
+		memory.NewLoginRepository(store), sessions, mail, services.AccountPolicy{ResetTTL: time.Hour}, nil)
+
+	// Сессии на двух устройствах, одна из них могла попасть к злоумышленнику
+	var pairs []*services.TokenPair
+	for _, ip := range []string{"192.0.2.1", "203.0.113.7"} {
+		pair, err := sessions.Start(ctx, user, services.SessionMeta{IP: ip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if err := account.RequestPasswordReset(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := account.ResetPassword(ctx, mail.token(t), "new secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range pairs {
+		// access-токен отклоняется сразу, refresh-токен больше не обменять
+		if denied, _ := denylist.Contains(ctx, pair.SessionID); !denied {
+			t.Errorf("session %s is not in the denylist", pair.SessionID)
+		}
+		if _, err := sessions.Refresh(ctx, pair.RefreshToken); !errors.Is(err, services.ErrSessionRevoked) {
+			t.Errorf("refresh: got %v, want %v", err, services.ErrSessionRevoked)
+		}
+		session, err := memory.NewSessionRepository(store).FindByID(ctx, pair.SessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.RevokeReason == nil || *session.RevokeReason != services.RevokeReasonPasswordReset {
+			t.Errorf("revoke reason = %v, want %s", session.RevokeReason, services.RevokeReasonPasswordReset)
+		}
+	}
+
+	// Новая сессия после сброса работает
+	pair, err := sessions.Start(ctx, user, services.SessionMeta{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.Refresh(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("refresh after reset: %v", err)
+	}
+}
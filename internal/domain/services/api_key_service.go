@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrAPIKeyNotFound         = errors.New("api key not found")
+	ErrAPIKeyRevoked          = errors.New("api key is revoked or expired")
+	ErrInvalidPermission      = errors.New("unknown permission")
+	ErrPermissionNotGrantable = errors.New("permission cannot be granted to an api key")
+	ErrWalletScopeRequired    = errors.New("wallets:read and wallets:operate require wallet_ids")
+)
+
+// apiKeyTouchInterval - как часто обновлять last_used_at, чтобы не писать
+// в БД на каждый запрос
+const apiKeyTouchInterval = time.Minute
+
+// nonGrantable - разрешения, которые нельзя выдать ключу: ключ не должен
+// управлять пользователями и выпускать другие ключи
+var nonGrantable = map[entities.Permission]bool{
+	entities.PermUsersManage:   true,
+	entities.PermAPIKeysManage: true,
+}
+
+// ownWalletPermissions - у пользователя действуют только на свои кошельки.
+// Владельца у ключа нет, поэтому такие разрешения выдаются только вместе со
+// списком кошельков, доступных вызывающему: своих или чужих по разрешениям
+// из значения.
+var ownWalletPermissions = map[entities.Permission][]entities.Permission{
+	entities.PermWalletsRead:    {entities.PermWalletsReadAll},
+	entities.PermWalletsOperate: nil,
+}
+
+// APIKeySpec - параметры нового ключа
+type APIKeySpec struct {
+	Name        string
+	Permissions []entities.Permission
+	WalletIDs   []uuid.UUID
+	ExpiresAt   *time.Time
+}
+
+type APIKeyService struct {
+	apiKeyRepo    repositories.APIKeyRepository
+	walletService *WalletService
+	audit         *AuditService
+}
+
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepository, walletService *WalletService, audit *AuditService) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:    apiKeyRepo,
+		walletService: walletService,
+		audit:         audit,
+	}
+}
+
+// Create выпускает ключ. Вызывающий может выдать только те разрешения,
+// которые есть у него самого. Секрет возвращается один раз.
+func (s *APIKeyService) Create(ctx context.Context, spec APIKeySpec) (_ *entities.APIKey, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Create")
+	defer func() { tracing.End(span, err) }()
+
+	if err = s.checkGrantable(ctx, spec.Permissions, spec.WalletIDs); err != nil {
+		return nil, "", err
+	}
+
+	plain, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := entities.NewAPIKey(spec.Name, prefix, hash, spec.Permissions, spec.WalletIDs)
+	key.ExpiresAt = spec.ExpiresAt
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && !claims.IsAPIKey() {
+		key.CreatedBy = &claims.UserID
+	}
+
+	event, err := s.audit.Event(ctx, entities.AuditAPIKeyCreated, entities.AuditTargetAPIKey, key.ID.String(), nil, key)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = s.apiKeyRepo.Create(ctx, key, event); err != nil {
+		return nil, "", err
+	}
+
+	logger.FromContext(ctx).Info("api key created", "api_key_id", key.ID, "name", key.Name)
+	return key, plain, nil
+}
+
+// checkGrantable проверяет, что вызывающий может выдать ключу perms на
+// кошельки walletIDs. Ключ с разрешением на свои кошельки действует на
+// кошельки из списка как их владелец, поэтому каждый из них должен быть
+// доступен вызывающему: ErrWalletNotFound или ErrForbidden.
+func (s *APIKeyService) checkGrantable(ctx context.Context, perms []entities.Permission, walletIDs []uuid.UUID) error {
+	scoped := false
+	for _, perm := range perms {
+		if !perm.Valid() {
+			return ErrInvalidPermission
+		}
+		if nonGrantable[perm] || !auth.HasPermission(ctx, string(perm)) {
+			return ErrPermissionNotGrantable
+		}
+		if _, ok := ownWalletPermissions[perm]; ok {
+			if len(walletIDs) == 0 {
+				return ErrWalletScopeRequired
+			}
+			scoped = true
+		}
+	}
+	if !scoped {
+		return nil
+	}
+
+	wallets, err := s.walletService.GetWallets(ctx, walletIDs)
+	if err != nil {
+		return err
+	}
+	for _, id := range walletIDs {
+		wallet, ok := wallets[id]
+		if !ok {
+			return ErrWalletNotFound
+		}
+		for _, perm := range perms {
+			if all, ok := ownWalletPermissions[perm]; ok {
+				if err := AuthorizeWallet(ctx, wallet, all...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *APIKeyService) List(ctx context.Context) (_ []*entities.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.List")
+	defer func() { tracing.End(span, err) }()
+
+	return s.apiKeyRepo.List(ctx)
+}
+
+// Rotate выпускает замену ключа с теми же правами. Старый ключ продолжает
+// работать grace (0 - отключается сразу), чтобы клиенты успели переключиться.
+func (s *APIKeyService) Rotate(ctx context.Context, id uuid.UUID, grace time.Duration) (_ *entities.APIKey, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Rotate", attribute.String("api_key.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	old, err := s.apiKeyRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if old == nil {
+		return nil, "", ErrAPIKeyNotFound
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return nil, "", ErrAPIKeyRevoked
+	}
+	// Замена - новый секрет, поэтому выдать ее может только тот, кто мог бы
+	// выпустить ключ с такими правами
+	if err = s.checkGrantable(ctx, old.Permissions, old.WalletIDs); err != nil {
+		return nil, "", err
+	}
+
+	plain, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := entities.NewAPIKey(old.Name, prefix, hash, old.Permissions, old.WalletIDs)
+	next.ExpiresAt = old.ExpiresAt
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && !claims.IsAPIKey() {
+		next.CreatedBy = &claims.UserID
+	}
+
+	event, err := s.audit.Event(ctx, entities.AuditAPIKeyRotated, entities.AuditTargetAPIKey, old.ID.String(), old, next)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = s.apiKeyRepo.Rotate(ctx, old.ID, now.Add(grace), next, event); err != nil {
+		return nil, "", err
+	}
+
+	logger.FromContext(ctx).Info("api key rotated", "api_key_id", old.ID, "new_api_key_id", next.ID, "grace", grace)
+	return next, plain, nil
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Revoke", attribute.String("api_key.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	key, err := s.apiKeyRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotFound
+	}
+
+	event, err := s.audit.Event(ctx, entities.AuditAPIKeyRevoked, entities.AuditTargetAPIKey, id.String(), key, nil)
+	if err != nil {
+		return err
+	}
+	if err = s.apiKeyRepo.Revoke(ctx, id, event); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("api key revoked", "api_key_id", id)
+	return nil
+}
+
+// VerifyAPIKey реализует auth.APIKeyVerifier
+func (s *APIKeyService) VerifyAPIKey(ctx context.Context, token string) (*auth.Claims, error) {
+	prefix, secret, ok := auth.ParseAPIKey(token)
+	if !ok {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.FindByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			// Не отказываем в доступе из-за метки использования
+			logger.FromContext(ctx).Warn("failed to update api key last use", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	return &auth.Claims{
+		APIKeyID:    key.ID,
+		Role:        "api_key",
+		Permissions: entities.PermissionStrings(key.Permissions),
+		WalletIDs:   key.WalletIDs,
+	}, nil
+}
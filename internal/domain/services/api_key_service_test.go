@@ -0,0 +1,310 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// apiKeyFixture - сервис ключей и кошельки в одном хранилище в памяти
+type apiKeyFixture struct {
+	store   *memory.Store
+	keys    *services.APIKeyService
+	wallets *services.WalletService
+}
+
+func newAPIKeyFixture() *apiKeyFixture {
+	store := memory.NewStore()
+	wallets := services.NewWalletService(memory.NewWalletRepository(store), nil, nil, nil)
+	return &apiKeyFixture{
+		store:   store,
+		keys:    services.NewAPIKeyService(memory.NewAPIKeyRepository(store), wallets, services.NewAuditService(memory.NewAuditRepository(store))),
+		wallets: wallets,
+	}
+}
+
+// admin создает пользователя и возвращает контекст с его разрешениями perms
+func (f *apiKeyFixture) admin(t *testing.T, perms ...entities.Permission) (context.Context, uuid.UUID) {
+	t.Helper()
+	user := createUser(t, f.store)
+	return adminContext(user.ID, perms...), user.ID
+}
+
+// adminContext - администратор userID с разрешениями perms
+func adminContext(userID uuid.UUID, perms ...entities.Permission) context.Context {
+	return auth.WithClaims(context.Background(), &auth.Claims{
+		UserID:      userID,
+		Permissions: entities.PermissionStrings(append(perms, entities.PermAPIKeysManage)),
+	})
+}
+
+// createUser создает пользователя с уникальными email и username
+func createUser(t *testing.T, store *memory.Store) *entities.User {
+	t.Helper()
+	name := "user-" + uuid.NewString()
+	user := entities.NewUser(name+"@example.com", name, "secret")
+	if err := memory.NewUserRepository(store).Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createWallet создает кошелек ownerID в валюте по умолчанию
+func createWallet(t *testing.T, wallets *services.WalletService, ownerID uuid.UUID) uuid.UUID {
+	t.Helper()
+	wallet, err := wallets.CreateWallet(context.Background(), ownerID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet.ID
+}
+
+func TestRotateRequiresGrantablePermissions(t *testing.T) {
+	f := newAPIKeyFixture()
+	issuer, _ := f.admin(t, entities.PermWalletsReadAll, entities.PermHistoryReadAll)
+	key, _, err := f.keys.Create(issuer, services.APIKeySpec{
+		Name:        "reports",
+		Permissions: []entities.Permission{entities.PermWalletsReadAll, entities.PermHistoryReadAll},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ротация выдает новый секрет, поэтому права проверяются как при выпуске
+	weaker, _ := f.admin(t, entities.PermWalletsReadAll)
+	if _, _, err := f.keys.Rotate(weaker, key.ID, 0); !errors.Is(err, services.ErrPermissionNotGrantable) {
+		t.Fatalf("rotate without history:read_all: got %v, want %v", err, services.ErrPermissionNotGrantable)
+	}
+	if _, _, err := f.keys.Rotate(context.Background(), key.ID, 0); !errors.Is(err, services.ErrPermissionNotGrantable) {
+		t.Fatalf("rotate without claims: got %v, want %v", err, services.ErrPermissionNotGrantable)
+	}
+
+	next, _, err := f.keys.Rotate(issuer, key.ID, 0)
+	if err != nil {
+		t.Fatalf("rotate by issuer: %v", err)
+	}
+	if len(next.Permissions) != len(key.Permissions) {
+		t.Fatalf("permissions = %v, want %v", next.Permissions, key.Permissions)
+	}
+}
+
+func TestRotateRequiresWalletAccess(t *testing.T) {
+	f := newAPIKeyFixture()
+	owner, ownerID := f.admin(t, entities.PermWalletsOperate)
+	key, _, err := f.keys.Create(owner, services.APIKeySpec{
+		Name:        "payouts",
+		Permissions: []entities.Permission{entities.PermWalletsOperate},
+		WalletIDs:   []uuid.UUID{createWallet(t, f.wallets, ownerID)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// У другого администратора те же разрешения, но кошелек ему не принадлежит
+	other, _ := f.admin(t, entities.PermWalletsOperate, entities.PermWalletsReadAll)
+	if _, _, err := f.keys.Rotate(other, key.ID, 0); !errors.Is(err, services.ErrForbidden) {
+		t.Fatalf("rotate by another admin: got %v, want %v", err, services.ErrForbidden)
+	}
+	if _, _, err := f.keys.Rotate(owner, key.ID, 0); err != nil {
+		t.Fatalf("rotate by wallet owner: %v", err)
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx, adminID := f.admin(t, entities.PermWalletsOperate)
+	walletID := createWallet(t, f.wallets, adminID)
+	key, plain, err := f.keys.Create(ctx, services.APIKeySpec{
+		Name:        "payouts",
+		Permissions: []entities.Permission{entities.PermWalletsOperate},
+		WalletIDs:   []uuid.UUID{walletID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret, _ := auth.ParseAPIKey(plain)
+	// Настоящий секрет другого ключа с префиксом выданного
+	another, _, _, err := auth.NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, wrongSecret, _ := auth.ParseAPIKey(another)
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"issued key", plain, true},
+		{"correct prefix, wrong secret", auth.APIKeyPrefix + key.Prefix + "_" + wrongSecret, false},
+		{"correct prefix, empty secret", auth.APIKeyPrefix + key.Prefix + "_", false},
+		{"unknown prefix, issued secret", auth.APIKeyPrefix + "0000000000000000_" + secret, false},
+		{"not an api key", "Bearer " + plain, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := f.keys.VerifyAPIKey(context.Background(), tt.token)
+			if !tt.ok {
+				if !errors.Is(err, auth.ErrInvalidAPIKey) {
+					t.Fatalf("got %v, want %v", err, auth.ErrInvalidAPIKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.APIKeyID != key.ID || !claims.IsAPIKey() || len(claims.WalletIDs) != 1 || claims.WalletIDs[0] != walletID {
+				t.Fatalf("claims = %+v, want key %s scoped to %s", claims, key.ID, walletID)
+			}
+		})
+	}
+
+	if err := f.keys.Revoke(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.keys.VerifyAPIKey(context.Background(), plain); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Fatalf("revoked key: got %v, want %v", err, auth.ErrInvalidAPIKey)
+	}
+}
+
+func TestAPIKeyChangesAudited(t *testing.T) {
+	f := newAPIKeyFixture()
+	ctx, adminID := f.admin(t, entities.PermWalletsReadAll)
+	key, _, err := f.keys.Create(ctx, services.APIKeySpec{
+		Name:        "reports",
+		Permissions: []entities.Permission{entities.PermWalletsReadAll},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _, err := f.keys.Rotate(ctx, key.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.keys.Revoke(ctx, next.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Записи пишет репозиторий вместе с изменением ключа
+	audit := memory.NewAuditRepository(f.store)
+	for _, tt := range []struct {
+		action string
+		target uuid.UUID
+	}{
+		{entities.AuditAPIKeyCreated, key.ID},
+		{entities.AuditAPIKeyRotated, key.ID},
+		{entities.AuditAPIKeyRevoked, next.ID},
+	} {
+		events, total, err := audit.Search(context.Background(), repositories.AuditFilter{Action: tt.action, TargetID: tt.target.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || events[0].ActorID == nil || *events[0].ActorID != adminID {
+			t.Fatalf("%s: %d events, want one by %s", tt.action, total, adminID)
+		}
+	}
+}
+
+func TestCreateAPIKeyPermissions(t *testing.T) {
+	const (
+		noScope = iota
+		ownWallet
+		foreignWallet
+		ownAndForeign
+		unknownWallet
+	)
+	operate := []entities.Permission{entities.PermWalletsOperate}
+	read := []entities.Permission{entities.PermWalletsRead}
+
+	tests := []struct {
+		name  string
+		holds []entities.Permission
+		perms []entities.Permission
+		scope int
+		want  error
+	}{
+		{"held permission", []entities.Permission{entities.PermWalletsReadAll}, []entities.Permission{entities.PermWalletsReadAll}, noScope, nil},
+		{"permission not held", nil, []entities.Permission{entities.PermWalletsReadAll}, noScope, services.ErrPermissionNotGrantable},
+		{"unknown permission", nil, []entities.Permission{"wallets:everything"}, noScope, services.ErrInvalidPermission},
+		{"users:manage", []entities.Permission{entities.PermUsersManage}, []entities.Permission{entities.PermUsersManage}, noScope, services.ErrPermissionNotGrantable},
+		{"api_keys:manage", nil, []entities.Permission{entities.PermAPIKeysManage}, noScope, services.ErrPermissionNotGrantable},
+		// У ключа нет владельца, поэтому "свои" кошельки задаются списком
+		{"own-wallet permission without scope", operate, operate, noScope, services.ErrWalletScopeRequired},
+		{"own-wallet permission with own wallet", operate, operate, ownWallet, nil},
+		// Иначе администратор списывал бы с чужих кошельков без step-up
+		{"wallets:operate on another user's wallet", operate, operate, foreignWallet, services.ErrForbidden},
+		{"wallets:operate on another user's wallet with read_all",
+			[]entities.Permission{entities.PermWalletsOperate, entities.PermWalletsReadAll}, operate, foreignWallet, services.ErrForbidden},
+		{"wallets:operate on own and another user's wallet", operate, operate, ownAndForeign, services.ErrForbidden},
+		{"wallets:operate on unknown wallet", operate, operate, unknownWallet, services.ErrWalletNotFound},
+		{"wallets:read on another user's wallet", read, read, foreignWallet, services.ErrForbidden},
+		{"wallets:read on another user's wallet with read_all",
+			[]entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll}, read, foreignWallet, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAPIKeyFixture()
+			ctx, adminID := f.admin(t, tt.holds...)
+			own := createWallet(t, f.wallets, adminID)
+			foreign := createWallet(t, f.wallets, createUser(t, f.store).ID)
+			walletIDs := map[int][]uuid.UUID{
+				ownWallet:     {own},
+				foreignWallet: {foreign},
+				ownAndForeign: {own, foreign},
+				unknownWallet: {uuid.New()},
+			}[tt.scope]
+
+			_, _, err := f.keys.Create(ctx, services.APIKeySpec{
+				Name:        tt.name,
+				Permissions: tt.perms,
+				WalletIDs:   walletIDs,
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAPIKeyScope(t *testing.T) {
+	walletID, keyID := uuid.New(), uuid.New()
+	operate := string(entities.PermWalletsOperate)
+	readAll := string(entities.PermWalletsReadAll)
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		all    []entities.Permission
+		want   error
+	}{
+		{"no claims", nil, nil, nil},
+		{"user token", &auth.Claims{UserID: uuid.New()}, nil, nil},
+		{"scoped key", &auth.Claims{APIKeyID: keyID, Permissions: []string{operate}, WalletIDs: []uuid.UUID{walletID}}, nil, nil},
+		{"scoped key, other wallet", &auth.Claims{APIKeyID: keyID, Permissions: []string{operate}, WalletIDs: []uuid.UUID{uuid.New()}},
+			nil, services.ErrAPIKeyScope},
+		// wallets:operate относится к своим кошелькам и без области ни на что не действует
+		{"own-wallet permission without scope", &auth.Claims{APIKeyID: keyID, Permissions: []string{operate}},
+			[]entities.Permission{entities.PermWalletsReadAll}, services.ErrAPIKeyScope},
+		{"unscoped key with read_all", &auth.Claims{APIKeyID: keyID, Permissions: []string{readAll}},
+			[]entities.Permission{entities.PermWalletsReadAll}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = auth.WithClaims(ctx, tt.claims)
+			}
+			if err := services.AuthorizeAPIKeyScope(ctx, walletID, tt.all...); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
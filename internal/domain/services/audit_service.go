@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/requestid"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 500
+
+	auditVerifyBatch = 1000
+)
+
+// AuditService ведет журнал административных действий и событий безопасности
+type AuditService struct {
+	auditRepo repositories.AuditRepository
+}
+
+func NewAuditService(auditRepo repositories.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record пишет событие от имени вызывающего из контекста (пользователь или
+// API-ключ; без аутентификации - system). before и after - снимки состояния
+// цели до и после действия, любой из них может быть nil.
+//
+// Record - для действий вне транзакций репозиториев: вызывающий должен
+// вернуть ошибку, чтобы действие без записи аудита не считалось успешным.
+// Изменения, которые репозиторий проводит в транзакции, передают туда
+// запись из Event, и она сохраняется вместе с изменением.
+func (s *AuditService) Record(ctx context.Context, action, targetType, targetID string, before, after interface{}) error {
+	event, err := s.Event(ctx, action, targetType, targetID, before, after)
+	if err != nil {
+		return err
+	}
+	return s.append(ctx, event)
+}
+
+// RecordFor пишет событие от имени пользователя, не имеющего сессии, но
+// подтвердившего личность иначе (например, токеном из письма)
+func (s *AuditService) RecordFor(ctx context.Context, userID uuid.UUID, action, targetType, targetID string, before, after interface{}) error {
+	event, err := s.EventFor(ctx, userID, action, targetType, targetID, before, after)
+	if err != nil {
+		return err
+	}
+	return s.append(ctx, event)
+}
+
+// Event готовит запись от имени вызывающего из контекста, как Record, но не
+// пишет ее: запись добавляет репозиторий в транзакции изменения.
+// Сервисы, собранные без журнала (утилиты, фоновые задачи), получают nil.
+func (s *AuditService) Event(ctx context.Context, action, targetType, targetID string, before, after interface{}) (*entities.AuditEvent, error) {
+	actorType, actorID := entities.AuditActorSystem, (*uuid.UUID)(nil)
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		if claims.IsAPIKey() {
+			actorType, actorID = entities.AuditActorAPIKey, &claims.APIKeyID
+		} else {
+			actorType, actorID = entities.AuditActorUser, &claims.UserID
+		}
+	}
+	return s.newEvent(ctx, actorType, actorID, action, targetType, targetID, before, after)
+}
+
+// EventFor готовит запись от имени пользователя без сессии, как RecordFor
+func (s *AuditService) EventFor(ctx context.Context, userID uuid.UUID, action, targetType, targetID string, before, after interface{}) (*entities.AuditEvent, error) {
+	return s.newEvent(ctx, entities.AuditActorUser, &userID, action, targetType, targetID, before, after)
+}
+
+func (s *AuditService) newEvent(
+	ctx context.Context,
+	actorType entities.AuditActorType,
+	actorID *uuid.UUID,
+	action, targetType, targetID string,
+	before, after interface{},
+) (*entities.AuditEvent, error) {
+	if s == nil {
+		return nil, nil
+	}
+	event, err := entities.NewAuditEvent(action, targetType, targetID, before, after)
+	if err != nil {
+		return nil, err
+	}
+	event.ActorType = actorType
+	event.ActorID = actorID
+	event.RequestID = requestid.FromContext(ctx)
+	return event, nil
+}
+
+func (s *AuditService) append(ctx context.Context, event *entities.AuditEvent) (err error) {
+	if event == nil {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "AuditService.Record", attribute.String("audit.action", event.Action))
+	defer func() { tracing.End(span, err) }()
+
+	return s.auditRepo.Append(ctx, event)
+}
+
+// Search возвращает страницу журнала и общее число совпадений
+func (s *AuditService) Search(ctx context.Context, filter repositories.AuditFilter) (_ []*entities.AuditEvent, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.Search")
+	defer func() { tracing.End(span, err) }()
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditPageSize
+	}
+	if filter.Limit > MaxAuditPageSize {
+		filter.Limit = MaxAuditPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return s.auditRepo.Search(ctx, filter)
+}
+
+// Verify проходит цепочку от начала и сообщает о первой записи, у которой
+// не совпадает хеш, ссылка на предыдущую запись или пропущен номер
+func (s *AuditService) Verify(ctx context.Context) (_ *entities.ChainVerification, err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.Verify")
+	defer func() { tracing.End(span, err) }()
+
+	result := &entities.ChainVerification{Valid: true}
+	prevHash := entities.GenesisHash
+	for {
+		events, err := s.auditRepo.ListAfter(ctx, result.LastSeq, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			reason := ""
+			switch {
+			case event.Seq != result.LastSeq+1:
+				reason = "missing records before this entry"
+			case event.PrevHash != prevHash:
+				reason = "previous hash does not match"
+			case event.ComputeHash() != event.Hash:
+				reason = "content hash does not match"
+			}
+			if reason != "" {
+				seq := event.Seq
+				result.Valid = false
+				result.BrokenAt = &seq
+				result.Reason = reason
+				logger.FromContext(ctx).Error("audit chain broken", "seq", seq, "reason", reason)
+				return result, nil
+			}
+			prevHash = event.Hash
+			result.LastSeq = event.Seq
+			result.Checked++
+		}
+		if len(events) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// auditUser - снимок пользователя для журнала (без пароля)
+type auditUser struct {
+	Email         string        `json:"email"`
+	Username      string        `json:"username"`
+	Role          entities.Role `json:"role"`
+	EmailVerified bool          `json:"email_verified"`
+}
+
+func newAuditUser(user *entities.User) auditUser {
+	return auditUser{
+		Email:         user.Email,
+		Username:      user.Username,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified(),
+	}
+}
@@ -0,0 +1,103 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+)
+
+// auditRows отдает Verify записи как есть, в том числе испорченные в обход Append
+type auditRows struct {
+	repositories.AuditRepository
+	rows []*entities.AuditEvent
+}
+
+func (r *auditRows) ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*entities.AuditEvent, error) {
+	events := []*entities.AuditEvent{}
+	for _, e := range r.rows {
+		if len(events) == limit {
+			break
+		}
+		if e.Seq > afterSeq {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// auditChain пишет n записей через AuditService и возвращает их по возрастанию seq
+func auditChain(t *testing.T, n int) []*entities.AuditEvent {
+	t.Helper()
+	ctx := context.Background()
+	repo := memory.NewAuditRepository(memory.NewStore())
+	audit := services.NewAuditService(repo)
+	for i := 0; i < n; i++ {
+		if err := audit.Record(ctx, entities.AuditUserLocked, entities.AuditTargetUser, "user", nil, map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := repo.ListAfter(ctx, 0, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestAuditVerify(t *testing.T) {
+	const size = 5
+	tests := []struct {
+		name   string
+		tamper func(rows []*entities.AuditEvent) []*entities.AuditEvent
+		// brokenAt - 0, если цепочка цела
+		brokenAt int64
+		reason   string
+	}{
+		{"intact", func(rows []*entities.AuditEvent) []*entities.AuditEvent { return rows }, 0, ""},
+		{"field changed", func(rows []*entities.AuditEvent) []*entities.AuditEvent {
+			rows[2].TargetID = "someone-else"
+			return rows
+		}, 3, "content hash does not match"},
+		// Пересчитанный хеш измененной записи не сходится со ссылкой следующей
+		{"field changed and hash recomputed", func(rows []*entities.AuditEvent) []*entities.AuditEvent {
+			rows[2].TargetID = "someone-else"
+			rows[2].Hash = rows[2].ComputeHash()
+			return rows
+		}, 4, "previous hash does not match"},
+		{"row deleted", func(rows []*entities.AuditEvent) []*entities.AuditEvent {
+			return append(rows[:2:2], rows[3:]...)
+		}, 4, "missing records before this entry"},
+		{"first row deleted", func(rows []*entities.AuditEvent) []*entities.AuditEvent {
+			return rows[1:]
+		}, 2, "missing records before this entry"},
+		// Записи переставлены вместе с номерами, чтобы не было пропуска
+		{"rows reordered", func(rows []*entities.AuditEvent) []*entities.AuditEvent {
+			rows[1], rows[2] = rows[2], rows[1]
+			rows[1].Seq, rows[2].Seq = 2, 3
+			return rows
+		}, 2, "previous hash does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := tt.tamper(auditChain(t, size))
+			audit := services.NewAuditService(&auditRows{rows: rows})
+
+			result, err := audit.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.brokenAt == 0 {
+				if !result.Valid || result.BrokenAt != nil || result.Checked != size || result.LastSeq != size {
+					t.Fatalf("result = %+v, want valid chain of %d", result, size)
+				}
+				return
+			}
+			if result.Valid || result.BrokenAt == nil || *result.BrokenAt != tt.brokenAt || result.Reason != tt.reason {
+				t.Fatalf("result = %+v, want broken at %d: %s", result, tt.brokenAt, tt.reason)
+			}
+		})
+	}
+}
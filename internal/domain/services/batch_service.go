@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrBatchJobNotFound = repositories.ErrBatchJobNotFound
+	ErrBatchEmpty       = errors.New("batch has no items")
+	ErrBatchTooLarge    = errors.New("batch has too many items")
+)
+
+const (
+	DefaultBatchItemsPageSize = 100
+	MaxBatchItemsPageSize     = 1000
+)
+
+// BatchPolicy - размеры пакетов и параллельность их обработки
+type BatchPolicy struct {
+	MaxItems int
+	// SyncLimit - пакеты не больше этого размера проводятся сразу, в запросе
+	SyncLimit int
+	// Workers - одновременно проводимых операций пакета (не atomic)
+	Workers int
+	// StaleAfter - без отметки исполнителя дольше этого задание считается брошенным
+	StaleAfter time.Duration
+}
+
+// BatchService проводит пакеты операций (выплаты). Каждый пакет хранится как
+// задание; операции проводятся с ключом идемпотентности, поэтому задание,
+// брошенное исполнителем, можно безопасно дообработать на другом экземпляре.
+type BatchService struct {
+	batchRepo     repositories.BatchRepository
+	walletService *WalletService
+	policy        BatchPolicy
+}
+
+func NewBatchService(batchRepo repositories.BatchRepository, walletService *WalletService, policy BatchPolicy) *BatchService {
+	return &BatchService{
+		batchRepo:     batchRepo,
+		walletService: walletService,
+		policy:        policy,
+	}
+}
+
+// Submit сохраняет пакет операций пользователя userID; пакет, отправленный
+// API-ключом, записывается за ключом из ctx. Небольшой пакет
+// (не async) проводится сразу и возвращается с результатами; большой ставится
+// в очередь и возвращается в статусе queued. Ошибка в операции пакета -
+// *repositories.BatchItemError.
+func (s *BatchService) Submit(
+	ctx context.Context,
+	userID uuid.UUID,
+	atomic, async bool,
+	items []*entities.BatchItem,
+) (_ *entities.BatchJob, err error) {
+	ctx, span := tracing.Start(ctx, "BatchService.Submit",
+		attribute.Int("batch.items", len(items)),
+		attribute.Bool("batch.atomic", atomic),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if len(items) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(items) > s.policy.MaxItems {
+		return nil, ErrBatchTooLarge
+	}
+
+	job := entities.NewBatchJob(userID, atomic, len(items))
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && claims.IsAPIKey() {
+		job.APIKeyID = &claims.APIKeyID
+	}
+	for i, item := range items {
+		if item.OperationType != entities.OperationTypeDeposit && item.OperationType != entities.OperationTypeWithdraw {
+			return nil, &repositories.BatchItemError{Index: i, Err: ErrInvalidOperation}
+		}
+		if item.Amount <= 0 {
+			return nil, &repositories.BatchItemError{Index: i, Err: ErrInvalidAmount}
+		}
+		item.JobID = job.ID
+		item.Index = i
+		item.Status = entities.BatchItemPending
+	}
+
+	inline := !async && len(items) <= s.policy.SyncLimit
+	if inline {
+		// Задание сразу принадлежит этому запросу; воркеры заберут его,
+		// только если запрос оборвется, не закончив обработку
+		now := time.Now()
+		job.Status = entities.BatchStatusRunning
+		job.StartedAt = &now
+		job.HeartbeatAt = &now
+	}
+	if err = s.batchRepo.Create(ctx, job, items); err != nil {
+		return nil, err
+	}
+
+	log := logger.FromContext(ctx).With("batch_id", job.ID)
+	log.Info("batch submitted", "items", len(items), "atomic", atomic, "inline", inline)
+	if !inline {
+		return job, nil
+	}
+
+	if err := s.process(logger.WithContext(ctx, log), job); err != nil {
+		// Задание осталось running и будет дообработано воркером
+		log.Error("batch processing interrupted", "error", err)
+	}
+	return s.batchRepo.FindByID(ctx, job.ID)
+}
+
+func (s *BatchService) Get(ctx context.Context, id uuid.UUID) (_ *entities.BatchJob, err error) {
+	ctx, span := tracing.Start(ctx, "BatchService.Get", attribute.String("batch.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return s.batchRepo.FindByID(ctx, id)
+}
+
+// ListItems - операции задания по порядку и их общее число; status (nil - любой)
+func (s *BatchService) ListItems(
+	ctx context.Context,
+	jobID uuid.UUID,
+	status *entities.BatchItemStatus,
+	limit, offset int,
+) (_ []*entities.BatchItem, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "BatchService.ListItems", attribute.String("batch.id", jobID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return s.batchRepo.ListItems(ctx, jobID, status, limit, offset)
+}
+
+// RunPending обрабатывает задания из очереди и брошенные другими
+// исполнителями, пока они есть; возвращает число обработанных
+func (s *BatchService) RunPending(ctx context.Context) (processed int, err error) {
+	ctx, span := tracing.Start(ctx, "BatchService.RunPending")
+	defer func() { tracing.End(span, err) }()
+
+	for {
+		job, err := s.batchRepo.Claim(ctx, time.Now().Add(-s.policy.StaleAfter))
+		if err != nil || job == nil {
+			return processed, err
+		}
+		log := logger.FromContext(ctx).With("batch_id", job.ID)
+		log.Info("batch claimed", "items", job.Total, "atomic", job.Atomic)
+		if err = s.process(logger.WithContext(ctx, log), job); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+}
+
+// process проводит необработанные операции задания и завершает его. Ошибка
+// базы оставляет задание running: после StaleAfter его заберет воркер.
+func (s *BatchService) process(ctx context.Context, job *entities.BatchJob) (err error) {
+	ctx, span := tracing.Start(ctx, "BatchService.process",
+		attribute.String("batch.id", job.ID.String()),
+		attribute.Bool("batch.atomic", job.Atomic),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.heartbeat(ctx, job.ID)
+
+	items, err := s.batchRepo.PendingItems(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if job.Atomic {
+		err = s.processAtomic(ctx, items)
+	} else {
+		err = s.processEach(ctx, items)
+	}
+
+	switch {
+	case err == nil:
+		job.Status = entities.BatchStatusCompleted
+	case errors.Is(err, ErrFeeWalletNotConfigured), errors.Is(err, ErrRequestMismatch):
+		// Повтор не поможет: задание проваливается, необработанные операции
+		// остаются pending
+		reason := err.Error()
+		job.Status = entities.BatchStatusFailed
+		job.Error = &reason
+		logger.FromContext(ctx).Error("batch failed", "error", err)
+	default:
+		return err
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	if err = s.batchRepo.Finish(ctx, job); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("batch finished", "status", job.Status)
+	return nil
+}
+
+// processAtomic проводит все операции в одной транзакции. Отказ операции
+// откатывает пакет: она помечается failed, остальные - skipped.
+func (s *BatchService) processAtomic(ctx context.Context, items []*entities.BatchItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	err := s.walletService.ProcessBatch(ctx, items)
+	var itemErr *repositories.BatchItemError
+	switch {
+	case err == nil:
+	case errors.As(err, &itemErr) && isOperationRejection(itemErr.Err):
+		for _, item := range items {
+			if item.Index == itemErr.Index {
+				item.Fail(entities.BatchItemFailed, itemErr.Err.Error())
+			} else {
+				item.Fail(entities.BatchItemSkipped, fmt.Sprintf("batch rolled back: item %d failed", itemErr.Index))
+			}
+		}
+		logger.FromContext(ctx).Warn("atomic batch rolled back", "item", itemErr.Index, "error", itemErr.Err)
+	default:
+		return err
+	}
+	return s.batchRepo.UpdateItems(ctx, items)
+}
+
+// processEach проводит операции по отдельности не более чем в policy.Workers
+// потоков. Отказ операции записывается в ее результат; ошибка базы
+// останавливает обработку, оставляя непроведенные операции pending.
+func (s *BatchService) processEach(ctx context.Context, items []*entities.BatchItem) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan *entities.BatchItem)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	abort := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < max(s.policy.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				if err := s.processItem(ctx, item); err != nil {
+					abort(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case queue <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (s *BatchService) processItem(ctx context.Context, item *entities.BatchItem) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	requestID := item.RequestID()
+	result, err := s.walletService.ProcessOperation(ctx, item.WalletID, item.OperationType, item.Amount, &requestID)
+	switch {
+	case err == nil:
+		item.Succeed(result)
+	case isOperationRejection(err):
+		item.Fail(entities.BatchItemFailed, err.Error())
+	default:
+		return err
+	}
+	return s.batchRepo.UpdateItems(ctx, []*entities.BatchItem{item})
+}
+
+// heartbeat обновляет отметку исполнителя, пока задание обрабатывается
+func (s *BatchService) heartbeat(ctx context.Context, jobID uuid.UUID) {
+	ticker := time.NewTicker(max(s.policy.StaleAfter/3, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.batchRepo.Heartbeat(ctx, jobID); err != nil && ctx.Err() == nil {
+				logger.FromContext(ctx).Warn("failed to update batch heartbeat", "error", err)
+			}
+		}
+	}
+}
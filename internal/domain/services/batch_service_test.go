@@ -0,0 +1,289 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+
+	"github.com/google/uuid"
+)
+
+// batchFixture - сервис пакетов и кошельки в одном хранилище в памяти
+type batchFixture struct {
+	store   *memory.Store
+	jobs    repositories.BatchRepository
+	wallets *services.WalletService
+	batches *services.BatchService
+	owner   uuid.UUID
+}
+
+func newBatchFixture(t *testing.T, policy services.BatchPolicy) *batchFixture {
+	t.Helper()
+	store := memory.NewStore()
+	f := &batchFixture{
+		store:   store,
+		jobs:    memory.NewBatchRepository(store),
+		wallets: services.NewWalletService(memory.NewWalletRepository(store), nil, nil, nil),
+		owner:   createUser(t, store).ID,
+	}
+	f.batches = services.NewBatchService(f.jobs, f.wallets, policy)
+	return f
+}
+
+// wallet создает кошелек владельца фикстуры с балансом balance
+func (f *batchFixture) wallet(t *testing.T, balance int64) uuid.UUID {
+	t.Helper()
+	id := createWallet(t, f.wallets, f.owner)
+	if balance > 0 {
+		if _, err := f.wallets.ProcessOperation(context.Background(), id, entities.OperationTypeDeposit, balance, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+func (f *batchFixture) balance(t *testing.T, id uuid.UUID) int64 {
+	t.Helper()
+	wallet, err := f.wallets.GetWallet(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet.Balance
+}
+
+func (f *batchFixture) statuses(t *testing.T, jobID uuid.UUID) []entities.BatchItemStatus {
+	t.Helper()
+	items, _, err := f.batches.ListItems(context.Background(), jobID, nil, services.MaxBatchItemsPageSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make([]entities.BatchItemStatus, len(items))
+	for i, item := range items {
+		statuses[i] = item.Status
+	}
+	return statuses
+}
+
+func batchItem(walletID uuid.UUID, operationType entities.OperationType, amount int64) *entities.BatchItem {
+	return &entities.BatchItem{WalletID: walletID, OperationType: operationType, Amount: amount}
+}
+
+func equalStatuses(got, want []entities.BatchItemStatus) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBatchAtomicAndBestEffort(t *testing.T) {
+	const (
+		ok        = entities.BatchItemSucceeded
+		failed    = entities.BatchItemFailed
+		skipped   = entities.BatchItemSkipped
+		funded    = 100
+		overdrawn = 500
+	)
+	tests := []struct {
+		name   string
+		atomic bool
+		want   []entities.BatchItemStatus
+		// payee и payer - балансы кошельков после пакета
+		payee, payer int64
+	}{
+		// Отказ одной операции откатывает весь пакет
+		{"atomic", true, []entities.BatchItemStatus{skipped, skipped, failed, skipped}, 0, funded},
+		// Остальные операции проводятся независимо от отказа
+		{"best effort", false, []entities.BatchItemStatus{ok, ok, failed, ok}, 80, funded - 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newBatchFixture(t, services.BatchPolicy{MaxItems: 10, SyncLimit: 10, Workers: 2})
+			payee, payer := f.wallet(t, 0), f.wallet(t, funded)
+
+			job, err := f.batches.Submit(context.Background(), f.owner, tt.atomic, false, []*entities.BatchItem{
+				batchItem(payee, entities.OperationTypeDeposit, 50),
+				batchItem(payer, entities.OperationTypeWithdraw, 30),
+				batchItem(payer, entities.OperationTypeWithdraw, overdrawn),
+				batchItem(payee, entities.OperationTypeDeposit, 30),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != entities.BatchStatusCompleted {
+				t.Fatalf("status = %s, want %s", job.Status, entities.BatchStatusCompleted)
+			}
+			if got := f.statuses(t, job.ID); !equalStatuses(got, tt.want) {
+				t.Fatalf("item statuses = %v, want %v", got, tt.want)
+			}
+			if got := f.balance(t, payee); got != tt.payee {
+				t.Errorf("payee balance = %d, want %d", got, tt.payee)
+			}
+			if got := f.balance(t, payer); got != tt.payer {
+				t.Errorf("payer balance = %d, want %d", got, tt.payer)
+			}
+		})
+	}
+}
+
+func TestBatchSubmitValidation(t *testing.T) {
+	f := newBatchFixture(t, services.BatchPolicy{MaxItems: 2, SyncLimit: 2})
+	walletID := uuid.New()
+	tests := []struct {
+		name  string
+		items []*entities.BatchItem
+		want  error
+		index int
+	}{
+		{"empty", nil, services.ErrBatchEmpty, -1},
+		{"too large", []*entities.BatchItem{
+			batchItem(walletID, entities.OperationTypeDeposit, 1),
+			batchItem(walletID, entities.OperationTypeDeposit, 1),
+			batchItem(walletID, entities.OperationTypeDeposit, 1),
+		}, services.ErrBatchTooLarge, -1},
+		{"unknown operation", []*entities.BatchItem{
+			batchItem(walletID, entities.OperationTypeDeposit, 1),
+			batchItem(walletID, "TRANSFER", 1),
+		}, services.ErrInvalidOperation, 1},
+		{"zero amount", []*entities.BatchItem{batchItem(walletID, entities.OperationTypeDeposit, 0)}, services.ErrInvalidAmount, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.batches.Submit(context.Background(), f.owner, false, false, tt.items)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			var itemErr *repositories.BatchItemError
+			if tt.index >= 0 && (!errors.As(err, &itemErr) || itemErr.Index != tt.index) {
+				t.Fatalf("got %v, want error in item %d", err, tt.index)
+			}
+		})
+	}
+}
+
+func TestBatchAsyncLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newBatchFixture(t, services.BatchPolicy{MaxItems: 10, SyncLimit: 10, Workers: 2, StaleAfter: time.Minute})
+	walletID := f.wallet(t, 0)
+
+	// async ставит в очередь даже небольшой пакет
+	job, err := f.batches.Submit(ctx, f.owner, false, true, []*entities.BatchItem{
+		batchItem(walletID, entities.OperationTypeDeposit, 10),
+		batchItem(walletID, entities.OperationTypeDeposit, 20),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != entities.BatchStatusQueued || job.Done() {
+		t.Fatalf("status = %s, want %s", job.Status, entities.BatchStatusQueued)
+	}
+	pending := entities.BatchItemPending
+	if got := f.statuses(t, job.ID); !equalStatuses(got, []entities.BatchItemStatus{pending, pending}) {
+		t.Fatalf("item statuses before processing = %v", got)
+	}
+
+	processed, err := f.batches.RunPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != 1 {
+		t.Fatalf("processed = %d, want 1", processed)
+	}
+	job, err = f.batches.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != entities.BatchStatusCompleted || job.Succeeded != 2 || job.StartedAt == nil || job.FinishedAt == nil {
+		t.Fatalf("job = %+v, want completed with 2 succeeded", job)
+	}
+	if got := f.balance(t, walletID); got != 30 {
+		t.Fatalf("balance = %d, want 30", got)
+	}
+
+	// Очередь пуста: повторный запуск ничего не делает
+	if processed, err := f.batches.RunPending(ctx); err != nil || processed != 0 {
+		t.Fatalf("second run: processed %d, %v", processed, err)
+	}
+}
+
+func TestBatchReclaimsStaleJob(t *testing.T) {
+	ctx := context.Background()
+	const staleAfter = time.Minute
+	f := newBatchFixture(t, services.BatchPolicy{MaxItems: 10, SyncLimit: 10, Workers: 1, StaleAfter: staleAfter})
+	walletID := f.wallet(t, 0)
+
+	// running-задание, исполнитель которого пропал давно и недавно
+	runningJob := func(heartbeat time.Time) (*entities.BatchJob, []*entities.BatchItem) {
+		job := entities.NewBatchJob(f.owner, false, 3)
+		job.Status = entities.BatchStatusRunning
+		job.StartedAt, job.HeartbeatAt = &heartbeat, &heartbeat
+		items := []*entities.BatchItem{
+			batchItem(walletID, entities.OperationTypeDeposit, 1),
+			batchItem(walletID, entities.OperationTypeDeposit, 10),
+			batchItem(walletID, entities.OperationTypeDeposit, 100),
+		}
+		for i, item := range items {
+			item.JobID, item.Index, item.Status = job.ID, i, entities.BatchItemPending
+		}
+		if err := f.jobs.Create(ctx, job, items); err != nil {
+			t.Fatal(err)
+		}
+		return job, items
+	}
+	stale, items := runningJob(time.Now().Add(-2 * staleAfter))
+	alive, _ := runningJob(time.Now())
+
+	// Исполнитель успел записать первую операцию, а вторую провел, но не
+	// записал: ее результат возьмется по ключу идемпотентности
+	requestID := items[0].RequestID()
+	result, err := f.wallets.ProcessOperation(ctx, walletID, entities.OperationTypeDeposit, 1, &requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items[0].Succeed(result)
+	if err := f.jobs.UpdateItems(ctx, items[:1]); err != nil {
+		t.Fatal(err)
+	}
+	requestID = items[1].RequestID()
+	if _, err := f.wallets.ProcessOperation(ctx, walletID, entities.OperationTypeDeposit, 10, &requestID); err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := f.batches.RunPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != 1 {
+		t.Fatalf("processed = %d, want only the stale job", processed)
+	}
+	job, err := f.batches.Get(ctx, stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != entities.BatchStatusCompleted || job.Succeeded != 3 {
+		t.Fatalf("stale job = %+v, want completed with 3 succeeded", job)
+	}
+	// Ни одна операция не проведена дважды
+	if got := f.balance(t, walletID); got != 111 {
+		t.Fatalf("balance = %d, want 111", got)
+	}
+
+	// Задание с живым исполнителем не перехватывается
+	job, err = f.batches.Get(ctx, alive.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != entities.BatchStatusRunning || job.Succeeded != 0 {
+		t.Fatalf("live job = %+v, want untouched", job)
+	}
+}
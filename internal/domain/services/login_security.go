@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account temporarily locked due to failed sign-in attempts")
+	ErrTooManyAttempts = errors.New("too many failed sign-in attempts from this address")
+)
+
+const (
+	DefaultSignInsPageSize = 20
+	MaxSignInsPageSize     = 100
+)
+
+// LoginPolicy - пороги защиты от перебора паролей
+type LoginPolicy struct {
+	// MaxFailedAttempts - неудач подряд до блокировки аккаунта (0 - без блокировки)
+	MaxFailedAttempts int
+	// LockoutBase - длительность первой блокировки; каждая следующая вдвое длиннее
+	LockoutBase time.Duration
+	LockoutMax  time.Duration
+	// IPMaxFailures - неудач с одного IP за IPWindow до отказа (0 - без ограничения)
+	IPMaxFailures int
+	IPWindow      time.Duration
+}
+
+// lockoutDuration - экспоненциальная задержка: base * 2^n, но не больше max
+func (p LoginPolicy) lockoutDuration(previousLockouts int) time.Duration {
+	d := p.LockoutBase
+	for i := 0; i < previousLockouts && i < 30 && (p.LockoutMax <= 0 || d < p.LockoutMax); i++ {
+		d *= 2
+	}
+	if p.LockoutMax > 0 && d > p.LockoutMax {
+		d = p.LockoutMax
+	}
+	return d
+}
+
+// ThrottleError - отказ до истечения RetryAfter
+type ThrottleError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+func (s *UserService) checkIPThrottle(ctx context.Context, email string, meta SessionMeta) error {
+	policy := s.loginPolicy
+	if policy.IPMaxFailures <= 0 || meta.IP == "" {
+		return nil
+	}
+
+	failures, err := s.loginRepo.CountFailuresByIP(ctx, meta.IP, time.Now().Add(-policy.IPWindow))
+	if err != nil {
+		return err
+	}
+	if failures < policy.IPMaxFailures {
+		return nil
+	}
+
+	logger.FromContext(ctx).Warn("sign-in throttled by ip", "ip", meta.IP, "failures", failures)
+	s.recordEvent(ctx, entities.NewLoginEvent(nil, email, meta.IP, meta.UserAgent), entities.LoginFailureIPThrottled)
+	return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: policy.IPWindow}
+}
+
+func (s *UserService) checkAccountLock(ctx context.Context, user *entities.User, meta SessionMeta) error {
+	lockout, err := s.loginRepo.FindLockout(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if lockout == nil || !lockout.Locked(now) {
+		return nil
+	}
+
+	logger.FromContext(ctx).Warn("sign-in to locked account", "user_id", user.ID, "locked_until", lockout.LockedUntil)
+	s.recordEvent(ctx, entities.NewLoginEvent(&user.ID, user.Email, meta.IP, meta.UserAgent), entities.LoginFailureAccountLocked)
+	return &ThrottleError{Err: ErrAccountLocked, RetryAfter: lockout.LockedUntil.Sub(now)}
+}
+
+// RecordLoginFailure учитывает неудачную попытку входа известного пользователя
+// (неверный пароль или второй фактор) и блокирует аккаунт при достижении порога
+func (s *UserService) RecordLoginFailure(ctx context.Context, user *entities.User, meta SessionMeta, reason string) error {
+	s.recordEvent(ctx, entities.NewLoginEvent(&user.ID, user.Email, meta.IP, meta.UserAgent), reason)
+
+	policy := s.loginPolicy
+	if policy.MaxFailedAttempts <= 0 {
+		return nil
+	}
+
+	lockout, err := s.loginRepo.RegisterFailure(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if lockout.FailedCount < policy.MaxFailedAttempts {
+		return nil
+	}
+
+	duration := policy.lockoutDuration(lockout.LockoutCount)
+	// Запись аудита сохраняется, только если блокировку поставил этот вызов
+	event, err := s.audit.Event(ctx, entities.AuditUserLocked, entities.AuditTargetUser, user.ID.String(), nil, map[string]interface{}{
+		"duration_seconds": int64(duration.Seconds()),
+		"lockouts":         lockout.LockoutCount + 1,
+		"ip":               meta.IP,
+	})
+	if err != nil {
+		return err
+	}
+	locked, err := s.loginRepo.Lock(ctx, user.ID, policy.MaxFailedAttempts, time.Now().Add(duration), event)
+	if err != nil {
+		return err
+	}
+	if locked {
+		logger.FromContext(ctx).Warn("account locked",
+			"user_id", user.ID,
+			"duration", duration,
+			"lockouts", lockout.LockoutCount+1,
+		)
+	}
+	return nil
+}
+
+// RecordLoginSuccess фиксирует успешный вход после проверки всех факторов:
+// сбрасывает счетчики неудач и отмечает вход с нового адреса
+func (s *UserService) RecordLoginSuccess(ctx context.Context, user *entities.User, meta SessionMeta) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RecordLoginSuccess", attribute.String("user.id", user.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if err = s.loginRepo.ResetFailures(ctx, user.ID); err != nil {
+		return err
+	}
+
+	known, err := s.loginRepo.KnownIP(ctx, user.ID, meta.IP)
+	if err != nil {
+		return err
+	}
+
+	event := entities.NewLoginEvent(&user.ID, user.Email, meta.IP, meta.UserAgent)
+	event.Success = true
+	event.NewIP = !known
+	if err = s.loginRepo.RecordEvent(ctx, event); err != nil {
+		return err
+	}
+
+	if event.NewIP {
+		logger.FromContext(ctx).Warn("sign-in from new ip", "user_id", user.ID, "ip", meta.IP, "user_agent", meta.UserAgent)
+	}
+	return nil
+}
+
+// RecentSignIns возвращает последние попытки входа в аккаунт, новые первыми
+func (s *UserService) RecentSignIns(ctx context.Context, userID uuid.UUID, limit int) (_ []*entities.LoginEvent, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RecentSignIns", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if limit <= 0 {
+		limit = DefaultSignInsPageSize
+	}
+	if limit > MaxSignInsPageSize {
+		limit = MaxSignInsPageSize
+	}
+
+	return s.loginRepo.RecentEvents(ctx, userID, limit)
+}
+
+// recordEvent пишет неудачную попытку. Ошибка записи не должна менять ответ
+// на попытку входа, поэтому только логируется.
+func (s *UserService) recordEvent(ctx context.Context, event *entities.LoginEvent, reason string) {
+	event.FailureReason = &reason
+	if err := s.loginRepo.RecordEvent(ctx, event); err != nil {
+		logger.FromContext(ctx).Error("failed to record login event", "error", err)
+	}
+}
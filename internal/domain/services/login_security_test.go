@@ -0,0 +1,189 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+
+	"github.com/google/uuid"
+)
+
+// shiftedLoginRepository хранит время сдвинутым на offset вперед: для сервиса,
+// который смотрит на time.Now, это выглядит как прошедшее время
+type shiftedLoginRepository struct {
+	repositories.LoginRepository
+	offset time.Duration
+}
+
+func (r *shiftedLoginRepository) advance(d time.Duration) {
+	r.offset += d
+}
+
+func (r *shiftedLoginRepository) RecordEvent(ctx context.Context, event *entities.LoginEvent) error {
+	e := *event
+	e.CreatedAt = e.CreatedAt.Add(r.offset)
+	return r.LoginRepository.RecordEvent(ctx, &e)
+}
+
+func (r *shiftedLoginRepository) CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, error) {
+	return r.LoginRepository.CountFailuresByIP(ctx, ip, since.Add(r.offset))
+}
+
+func (r *shiftedLoginRepository) FindLockout(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error) {
+	lockout, err := r.LoginRepository.FindLockout(ctx, userID)
+	if lockout != nil && lockout.LockedUntil != nil {
+		until := lockout.LockedUntil.Add(-r.offset)
+		lockout.LockedUntil = &until
+	}
+	return lockout, err
+}
+
+func (r *shiftedLoginRepository) Lock(ctx context.Context, userID uuid.UUID, threshold int, until time.Time, event *entities.AuditEvent) (bool, error) {
+	return r.LoginRepository.Lock(ctx, userID, threshold, until.Add(r.offset), event)
+}
+
+type loginFixture struct {
+	users  *services.UserService
+	logins *shiftedLoginRepository
+}
+
+func newLoginFixture(t *testing.T, policy services.LoginPolicy) *loginFixture {
+	t.Helper()
+	store := memory.NewStore()
+	logins := &shiftedLoginRepository{LoginRepository: memory.NewLoginRepository(store)}
+	users := services.NewUserService(memory.NewUserRepository(store), logins, nil, policy, services.NewAuditService(memory.NewAuditRepository(store)))
+	return &loginFixture{users: users, logins: logins}
+}
+
+func (f *loginFixture) createUser(t *testing.T) *entities.User {
+	t.Helper()
+	name := "login-" + uuid.NewString()
+	user, err := f.users.CreateUser(context.Background(), name+"@example.com", name, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// signIn - успешный вход целиком: пароль и фиксация успеха
+func (f *loginFixture) signIn(user *entities.User, password string, meta services.SessionMeta) error {
+	ctx := context.Background()
+	authenticated, err := f.users.Authenticate(ctx, user.Email, password, meta)
+	if err != nil {
+		return err
+	}
+	return f.users.RecordLoginSuccess(ctx, authenticated, meta)
+}
+
+func (f *loginFixture) fail(t *testing.T, user *entities.User, n int, meta services.SessionMeta) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := f.signIn(user, "wrong", meta); !errors.Is(err, services.ErrInvalidPassword) {
+			t.Fatalf("failure %d: got %v, want %v", i+1, err, services.ErrInvalidPassword)
+		}
+	}
+}
+
+// assertLocked проверяет, что вход отклонен блокировкой примерно на want
+func assertLocked(t *testing.T, err error, target error, want time.Duration) {
+	t.Helper()
+	var throttle *services.ThrottleError
+	if !errors.Is(err, target) || !errors.As(err, &throttle) {
+		t.Fatalf("got %v, want %v", err, target)
+	}
+	if throttle.RetryAfter > want || throttle.RetryAfter < want-time.Second {
+		t.Fatalf("retry after %s, want %s", throttle.RetryAfter, want)
+	}
+}
+
+func TestAccountLockoutDoublesAndResets(t *testing.T) {
+	const threshold = 3
+	f := newLoginFixture(t, services.LoginPolicy{
+		MaxFailedAttempts: threshold,
+		LockoutBase:       time.Minute,
+		LockoutMax:        5 * time.Minute,
+	})
+	user := f.createUser(t)
+	meta := services.SessionMeta{IP: "198.51.100.1"}
+
+	// До порога аккаунт открыт
+	f.fail(t, user, threshold-1, meta)
+	if err := f.signIn(user, "secret", meta); err != nil {
+		t.Fatalf("below threshold: %v", err)
+	}
+	// Успешный вход обнулил счетчик: еще threshold-1 неудач не блокируют
+	f.fail(t, user, threshold-1, meta)
+	if err := f.signIn(user, "secret", meta); err != nil {
+		t.Fatalf("after reset: %v", err)
+	}
+
+	// Каждая следующая блокировка вдвое длиннее, но не больше LockoutMax
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		f.fail(t, user, threshold, meta)
+		// Верный пароль и другой адрес блокировку не снимают
+		err := f.signIn(user, "secret", services.SessionMeta{IP: "203.0.113.7"})
+		assertLocked(t, err, services.ErrAccountLocked, want)
+
+		f.logins.advance(want - time.Second)
+		assertLocked(t, f.signIn(user, "secret", meta), services.ErrAccountLocked, time.Second)
+		f.logins.advance(time.Second)
+	}
+
+	// Блокировка истекла: вход проходит и сбрасывает счет блокировок
+	if err := f.signIn(user, "secret", meta); err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+	lockout, err := f.logins.FindLockout(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockout != nil {
+		t.Fatalf("lockout after successful sign-in = %+v, want none", lockout)
+	}
+	f.fail(t, user, threshold, meta)
+	assertLocked(t, f.signIn(user, "secret", meta), services.ErrAccountLocked, time.Minute)
+}
+
+func TestIPThrottle(t *testing.T) {
+	const (
+		threshold = 3
+		window    = 10 * time.Minute
+	)
+	f := newLoginFixture(t, services.LoginPolicy{
+		IPMaxFailures: threshold,
+		IPWindow:      window,
+	})
+	user := f.createUser(t)
+	attacker := services.SessionMeta{IP: "198.51.100.66"}
+	other := services.SessionMeta{IP: "203.0.113.7"}
+	ctx := context.Background()
+
+	// Считаются неудачи по любым аккаунтам, в том числе несуществующим:
+	// здесь их threshold-1
+	f.fail(t, f.createUser(t), 1, attacker)
+	if _, err := f.users.Authenticate(ctx, "nobody@example.com", "secret", attacker); !errors.Is(err, services.ErrUserNotFound) {
+		t.Fatalf("unknown email: got %v, want %v", err, services.ErrUserNotFound)
+	}
+	if err := f.signIn(user, "secret", attacker); err != nil {
+		t.Fatalf("below threshold: %v", err)
+	}
+
+	f.fail(t, user, 1, attacker)
+	assertLocked(t, f.signIn(user, "secret", attacker), services.ErrTooManyAttempts, window)
+	// Лимит касается только адреса, а не аккаунта
+	if err := f.signIn(user, "secret", other); err != nil {
+		t.Fatalf("other address: %v", err)
+	}
+
+	// Неудачи выходят из окна
+	f.logins.advance(window + time.Second)
+	if err := f.signIn(user, "secret", attacker); err != nil {
+		t.Fatalf("after window: %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/totp"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrMFARequired           = errors.New("two-factor code required")
+	ErrInvalidOTP            = errors.New("invalid two-factor code")
+	ErrMFANotEnrolled        = errors.New("two-factor authentication is not enrolled")
+	ErrMFAAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrMFAEnrollmentRequired = errors.New("two-factor enrollment required for this operation")
+)
+
+const recoveryCodesCount = 10
+
+// TOTPEnrollment - данные для добавления аккаунта в приложение-аутентификатор
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+type MFAService struct {
+	mfaRepo  repositories.MFARepository
+	userRepo repositories.UserRepository
+	cipher   *totp.Cipher
+	issuer   string
+	audit    *AuditService
+	// logins учитывает неверные коды вне входа наравне с неудачными входами;
+	// nil - без ограничения попыток
+	logins *UserService
+}
+
+func NewMFAService(
+	mfaRepo repositories.MFARepository,
+	userRepo repositories.UserRepository,
+	cipher *totp.Cipher,
+	issuer string,
+	audit *AuditService,
+	logins *UserService,
+) *MFAService {
+	return &MFAService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		cipher:   cipher,
+		issuer:   issuer,
+		audit:    audit,
+		logins:   logins,
+	}
+}
+
+// Enroll генерирует новый TOTP-секрет. Второй фактор включается только после
+// подтверждения кодом в Confirm.
+func (s *MFAService) Enroll(ctx context.Context, userID uuid.UUID) (_ *TOTPEnrollment, err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Enroll", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	existing, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.cipher.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.mfaRepo.Save(ctx, entities.NewUserMFA(userID, sealed), nil); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm проверяет первый код, включает второй фактор и возвращает
+// одноразовые коды восстановления (показываются пользователю один раз)
+func (s *MFAService) Confirm(ctx context.Context, userID uuid.UUID, code string) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Confirm", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	mfa, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok, err := s.validateTOTP(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidOTP
+	}
+
+	now := time.Now()
+	mfa.Enabled = true
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+	event, err := s.audit.Event(ctx, entities.AuditMFAEnabled, entities.AuditTargetUser, userID.String(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.mfaRepo.Save(ctx, mfa, event); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.regenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("two-factor authentication enabled")
+	return codes, nil
+}
+
+// Disable выключает второй фактор; требует действующий код
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, code string, meta SessionMeta) (err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Disable", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if err = s.verifyLimited(ctx, userID, code, meta); err != nil {
+		return err
+	}
+	event, err := s.audit.Event(ctx, entities.AuditMFADisabled, entities.AuditTargetUser, userID.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = s.mfaRepo.Delete(ctx, userID, event); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("two-factor authentication disabled")
+	return nil
+}
+
+// Enabled сообщает, включен ли у пользователя второй фактор
+func (s *MFAService) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	mfa, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.Enabled, nil
+}
+
+// Verify проверяет TOTP-код или код восстановления. Каждый TOTP-код
+// принимается только один раз, коды восстановления одноразовые.
+func (s *MFAService) Verify(ctx context.Context, userID uuid.UUID, code string) (err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Verify", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	mfa, err := s.mfaRepo.Find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrMFARequired
+	}
+
+	log := logger.FromContext(ctx)
+
+	if len(code) == totp.Digits {
+		step, ok, err := s.validateTOTP(mfa, code)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fresh, err := s.mfaRepo.UseStep(ctx, userID, step)
+			if err != nil {
+				return err
+			}
+			if fresh {
+				return nil
+			}
+			log.Warn("two-factor code replay rejected")
+		}
+		return ErrInvalidOTP
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, userID, auth.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidOTP
+	}
+
+	log.Warn("recovery code used")
+	return nil
+}
+
+// VerifyStepUp требует второй фактор для чувствительной операции.
+// Пользователь без TOTP не может выполнить такую операцию. Неверные коды
+// ведут к блокировке аккаунта, как при входе (*ThrottleError).
+func (s *MFAService) VerifyStepUp(ctx context.Context, userID uuid.UUID, code string, meta SessionMeta) error {
+	err := s.verifyLimited(ctx, userID, code, meta)
+	if err == ErrMFANotEnrolled {
+		return ErrMFAEnrollmentRequired
+	}
+	return err
+}
+
+// verifyLimited - Verify для уже вошедшего пользователя. Без ограничения
+// украденный access-токен позволял бы перебирать коды, поэтому неверный код
+// учитывается как неудачный вход, а у заблокированного аккаунта код не
+// проверяется. Verify при входе учитывает неудачи сам вызывающий.
+func (s *MFAService) verifyLimited(ctx context.Context, userID uuid.UUID, code string, meta SessionMeta) error {
+	if s.logins == nil {
+		return s.Verify(ctx, userID, code)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err = s.logins.checkAccountLock(ctx, user, meta); err != nil {
+		return err
+	}
+
+	err = s.Verify(ctx, userID, code)
+	if err == ErrInvalidOTP {
+		if ferr := s.logins.RecordLoginFailure(ctx, user, meta, entities.LoginFailureInvalidStepUp); ferr != nil {
+			return ferr
+		}
+	}
+	return err
+}
+
+func (s *MFAService) validateTOTP(mfa *entities.UserMFA, code string) (int64, bool, error) {
+	secret, err := s.cipher.Open(mfa.Secret)
+	if err != nil {
+		return 0, false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if ok && step <= mfa.LastUsedStep {
+		return 0, false, nil
+	}
+	return step, ok, nil
+}
+
+func (s *MFAService) regenerateRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = auth.HashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Алфавит без похожих символов (0/O, 1/I/L)
+const recoveryAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// newRecoveryCode генерирует код вида XXXXX-XXXXX
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, b := range buf {
+		if i == 5 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,153 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/pkg/totp"
+
+	"github.com/google/uuid"
+)
+
+type mfaFixture struct {
+	store  *memory.Store
+	mfa    *services.MFAService
+	users  *services.UserService
+	user   *entities.User
+	secret string
+}
+
+// newMFAFixture создает пользователя с включенным TOTP; maxFailures - порог
+// блокировки аккаунта
+func newMFAFixture(t *testing.T, maxFailures int) *mfaFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepository(store)
+	audit := services.NewAuditService(memory.NewAuditRepository(store))
+	users := services.NewUserService(userRepo, memory.NewLoginRepository(store), nil, services.LoginPolicy{
+		MaxFailedAttempts: maxFailures,
+		LockoutBase:       time.Minute,
+		LockoutMax:        time.Hour,
+	}, audit)
+	cipher, err := totp.NewCipher("test key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa := services.NewMFAService(memory.NewMFARepository(store), userRepo, cipher, "Wallet", audit, users)
+
+	user := entities.NewUser("mfa-"+uuid.NewString()+"@example.com", "mfa-"+uuid.NewString(), "secret")
+	if err := userRepo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := mfa.Enroll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if _, err := mfa.Confirm(ctx, user.ID, code(t, enrollment.Secret, 0)); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	return &mfaFixture{store: store, mfa: mfa, users: users, user: user, secret: enrollment.Secret}
+}
+
+// code - TOTP-код интервала, отстоящего от текущего на offset
+func code(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	c, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// wrongCode - шестизначный код, не совпадающий ни с одним из окна проверки
+func wrongCode(t *testing.T, secret string) string {
+	t.Helper()
+	valid := map[string]bool{}
+	for offset := int64(-totp.Skew - 1); offset <= totp.Skew+1; offset++ {
+		valid[code(t, secret, offset)] = true
+	}
+	for _, c := range []string{"000000", "111111", "222222", "333333", "444444", "555555"} {
+		if !valid[c] {
+			return c
+		}
+	}
+	t.Fatal("no invalid code found")
+	return ""
+}
+
+func TestMFAVerifyRejectsReplayedCode(t *testing.T) {
+	f := newMFAFixture(t, 0)
+	ctx := context.Background()
+
+	// Код текущего интервала уже использован в Confirm
+	if err := f.mfa.Verify(ctx, f.user.ID, code(t, f.secret, 0)); !errors.Is(err, services.ErrInvalidOTP) {
+		t.Fatalf("code used by Confirm: got %v, want %v", err, services.ErrInvalidOTP)
+	}
+
+	next := code(t, f.secret, 1)
+	if err := f.mfa.Verify(ctx, f.user.ID, next); err != nil {
+		t.Fatalf("fresh code: %v", err)
+	}
+	if err := f.mfa.Verify(ctx, f.user.ID, next); !errors.Is(err, services.ErrInvalidOTP) {
+		t.Fatalf("replayed code: got %v, want %v", err, services.ErrInvalidOTP)
+	}
+	// Код более раннего интервала после более позднего тоже не принимается
+	if err := f.mfa.Verify(ctx, f.user.ID, code(t, f.secret, -1)); !errors.Is(err, services.ErrInvalidOTP) {
+		t.Fatalf("older code: got %v, want %v", err, services.ErrInvalidOTP)
+	}
+}
+
+func TestMFAStepUpLocksAccountAfterFailures(t *testing.T) {
+	const maxFailures = 3
+	f := newMFAFixture(t, maxFailures)
+	ctx := context.Background()
+	wrong := wrongCode(t, f.secret)
+
+	for i := 1; i < maxFailures; i++ {
+		if err := f.mfa.VerifyStepUp(ctx, f.user.ID, wrong, services.SessionMeta{IP: "192.0.2.1"}); !errors.Is(err, services.ErrInvalidOTP) {
+			t.Fatalf("failure %d: got %v, want %v", i, err, services.ErrInvalidOTP)
+		}
+	}
+	if err := f.mfa.VerifyStepUp(ctx, f.user.ID, wrong, services.SessionMeta{}); !errors.Is(err, services.ErrInvalidOTP) {
+		t.Fatalf("failure %d: got %v, want %v", maxFailures, err, services.ErrInvalidOTP)
+	}
+
+	// Аккаунт заблокирован: даже верный код не проверяется
+	err := f.mfa.VerifyStepUp(ctx, f.user.ID, code(t, f.secret, 1), services.SessionMeta{})
+	var throttle *services.ThrottleError
+	if !errors.As(err, &throttle) || !errors.Is(err, services.ErrAccountLocked) {
+		t.Fatalf("step-up on locked account: got %v, want %v", err, services.ErrAccountLocked)
+	}
+	if throttle.RetryAfter <= 0 || throttle.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want (0, 1m]", throttle.RetryAfter)
+	}
+
+	// Блокировка общая со входом
+	if err := f.mfa.Disable(ctx, f.user.ID, code(t, f.secret, 1), services.SessionMeta{}); !errors.Is(err, services.ErrAccountLocked) {
+		t.Fatalf("disable on locked account: got %v, want %v", err, services.ErrAccountLocked)
+	}
+	if _, err := f.users.Authenticate(ctx, f.user.Email, "secret", services.SessionMeta{}); !errors.Is(err, services.ErrAccountLocked) {
+		t.Fatalf("login on locked account: got %v, want %v", err, services.ErrAccountLocked)
+	}
+}
+
+func TestMFAStepUpMissingCodeIsNotAFailure(t *testing.T) {
+	f := newMFAFixture(t, 1)
+	ctx := context.Background()
+
+	// Пустой код - запрос второго фактора, а не попытка подбора
+	for i := 0; i < 3; i++ {
+		if err := f.mfa.VerifyStepUp(ctx, f.user.ID, "", services.SessionMeta{}); !errors.Is(err, services.ErrMFARequired) {
+			t.Fatalf("empty code: got %v, want %v", err, services.ErrMFARequired)
+		}
+	}
+	if err := f.mfa.VerifyStepUp(ctx, f.user.ID, code(t, f.secret, 1), services.SessionMeta{}); err != nil {
+		t.Fatalf("valid code after empty ones: %v", err)
+	}
+}
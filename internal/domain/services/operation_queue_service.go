@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var ErrOperationRequestNotFound = repositories.ErrOperationRequestNotFound
+
+// operationFailedReason - причина отказа, если операцию не удалось провести
+// из-за сбоя; подробности только в логе
+const operationFailedReason = "operation could not be processed"
+
+// OperationQueuePolicy - повторы отложенных операций
+type OperationQueuePolicy struct {
+	// MaxAttempts - попыток при временных ошибках, включая первую
+	MaxAttempts int
+	// RetryBackoff - пауза перед первым повтором; каждая следующая вдвое длиннее
+	RetryBackoff time.Duration
+	// StaleAfter - запрос, взятый воркером раньше, считается брошенным
+	StaleAfter time.Duration
+}
+
+// OperationQueueService принимает пополнения и списания в очередь и проводит
+// их воркерами через WalletService. id запроса - ключ идемпотентности
+// операции, поэтому повтор после сбоя воркера не проводит ее второй раз.
+type OperationQueueService struct {
+	requestRepo   repositories.OperationRequestRepository
+	walletService *WalletService
+	policy        OperationQueuePolicy
+}
+
+func NewOperationQueueService(
+	requestRepo repositories.OperationRequestRepository,
+	walletService *WalletService,
+	policy OperationQueuePolicy,
+) *OperationQueueService {
+	return &OperationQueueService{
+		requestRepo:   requestRepo,
+		walletService: walletService,
+		policy:        policy,
+	}
+}
+
+// Submit ставит операцию userID над кошельком в очередь; запрос,
+// отправленный API-ключом, записывается за ключом из ctx. requestID - ключ
+// идемпотентности клиента, он становится ID запроса: повтор тем же автором
+// возвращает уже принятый запрос с Replayed, другой автор или другие
+// параметры с тем же ключом - ErrRequestMismatch. nil - ID генерируется.
+func (s *OperationQueueService) Submit(
+	ctx context.Context,
+	userID, walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	requestID *uuid.UUID,
+) (_ *entities.OperationRequest, err error) {
+	ctx, span := tracing.Start(ctx, "OperationQueueService.Submit",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("operation.type", string(operationType)),
+		attribute.Int64("operation.amount", amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if operationType != entities.OperationTypeDeposit && operationType != entities.OperationTypeWithdraw {
+		return nil, ErrInvalidOperation
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	request := entities.NewOperationRequest(userID, walletID, operationType, amount)
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && claims.IsAPIKey() {
+		request.APIKeyID = &claims.APIKeyID
+	}
+	if requestID != nil {
+		request.ID = *requestID
+	}
+	err = s.requestRepo.Create(ctx, request)
+	if requestID != nil && errors.Is(err, repositories.ErrDuplicate) {
+		return s.replay(ctx, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("wallet operation queued", "operation_request_id", request.ID, "operation_type", operationType)
+	return request, nil
+}
+
+// replay возвращает ранее принятый запрос с ID request, если он совпадает с
+// request по параметрам
+func (s *OperationQueueService) replay(ctx context.Context, request *entities.OperationRequest) (*entities.OperationRequest, error) {
+	existing, err := s.requestRepo.FindByID(ctx, request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !existing.SameAuthor(request) || existing.WalletID != request.WalletID ||
+		existing.OperationType != request.OperationType || existing.Amount != request.Amount {
+		return nil, ErrRequestMismatch
+	}
+	existing.Replayed = true
+	logger.FromContext(ctx).Info("queued wallet operation replayed", "operation_request_id", existing.ID)
+	return existing, nil
+}
+
+func (s *OperationQueueService) Get(ctx context.Context, id uuid.UUID) (_ *entities.OperationRequest, err error) {
+	ctx, span := tracing.Start(ctx, "OperationQueueService.Get", attribute.String("operation_request.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return s.requestRepo.FindByID(ctx, id)
+}
+
+// ProcessNext проводит один запрос из очереди; false - очередь пуста.
+// Отказы и временные ошибки операции записываются в запрос, ошибка
+// возвращается только при сбое самой очереди.
+func (s *OperationQueueService) ProcessNext(ctx context.Context) (processed bool, err error) {
+	now := time.Now()
+	request, err := s.requestRepo.Claim(ctx, now, now.Add(-s.policy.StaleAfter))
+	if err != nil || request == nil {
+		return false, err
+	}
+
+	ctx, span := tracing.Start(ctx, "OperationQueueService.ProcessNext",
+		attribute.String("operation_request.id", request.ID.String()),
+		attribute.Int("operation_request.attempt", request.Attempts),
+	)
+	defer func() { tracing.End(span, err) }()
+	log := logger.FromContext(ctx).With("operation_request_id", request.ID, "attempt", request.Attempts)
+
+	result, err := s.walletService.ProcessOperation(ctx, request.WalletID, request.OperationType, request.Amount, &request.ID)
+	switch {
+	case err == nil:
+		request.Complete(result)
+		log.Info("queued operation completed", "operation_id", result.Operation.ID)
+	case isOperationRejection(err):
+		request.Reject(err.Error())
+		log.Info("queued operation rejected", "reason", err)
+	case errors.Is(err, ErrFeeWalletNotConfigured), errors.Is(err, ErrRequestMismatch),
+		request.Attempts >= s.policy.MaxAttempts:
+		request.Reject(operationFailedReason)
+		log.Error("queued operation failed", "error", err)
+	default:
+		backoff := min(s.policy.RetryBackoff<<(request.Attempts-1), maxRetryBackoff)
+		request.Retry(time.Now().Add(backoff))
+		log.Warn("queued operation will be retried", "error", err, "backoff", backoff)
+	}
+
+	err = s.requestRepo.Save(ctx, request)
+	if errors.Is(err, repositories.ErrOperationRequestClaimLost) {
+		// Попытка длилась дольше StaleAfter, и запрос забрал другой воркер.
+		// Операция идемпотентна по ID запроса, поэтому исход запишет он.
+		log.Warn("queued operation outcome discarded, request was claimed again", "status", request.Status)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
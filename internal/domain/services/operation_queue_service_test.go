@@ -0,0 +1,157 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// claimedRequests отдает один запрос в Claim и возвращает saveErr из Save
+type claimedRequests struct {
+	repositories.OperationRequestRepository
+	request *entities.OperationRequest
+	saveErr error
+	saved   []entities.OperationRequestStatus
+}
+
+func (r *claimedRequests) Claim(ctx context.Context, now, staleBefore time.Time) (*entities.OperationRequest, error) {
+	request := r.request
+	r.request = nil
+	return request, nil
+}
+
+func (r *claimedRequests) Save(ctx context.Context, request *entities.OperationRequest) error {
+	r.saved = append(r.saved, request.Status)
+	return r.saveErr
+}
+
+func TestProcessNextSaveResult(t *testing.T) {
+	queueErr := errors.New("connection reset")
+	tests := []struct {
+		name    string
+		saveErr error
+		want    error
+	}{
+		{"saved", nil, nil},
+		// Запрос забрал другой воркер: исход запишет он, это не сбой очереди
+		{"claim lost", repositories.ErrOperationRequestClaimLost, nil},
+		{"queue failure", queueErr, queueErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.NewStore()
+			owner := entities.NewUser("queue-"+uuid.NewString()+"@example.com", "queue-"+uuid.NewString(), "secret")
+			if err := memory.NewUserRepository(store).Create(ctx, owner); err != nil {
+				t.Fatal(err)
+			}
+			wallets := services.NewWalletService(memory.NewWalletRepository(store), nil, nil, nil)
+			wallet, err := wallets.CreateWallet(ctx, owner.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := entities.NewOperationRequest(owner.ID, wallet.ID, entities.OperationTypeDeposit, 100)
+			request.Status = entities.OperationRequestProcessing
+			request.Attempts = 1
+			repo := &claimedRequests{request: request, saveErr: tt.saveErr}
+			queue := services.NewOperationQueueService(repo, wallets, services.OperationQueuePolicy{MaxAttempts: 3, RetryBackoff: time.Second})
+
+			processed, err := queue.ProcessNext(ctx)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if processed != (tt.want == nil) {
+				t.Fatalf("processed = %v", processed)
+			}
+			if len(repo.saved) != 1 || repo.saved[0] != entities.OperationRequestCompleted {
+				t.Fatalf("saved statuses = %v, want one completed", repo.saved)
+			}
+			got, err := wallets.GetWallet(ctx, wallet.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Balance != 100 {
+				t.Fatalf("balance = %d, want 100", got.Balance)
+			}
+		})
+	}
+}
+
+// queuedRequests хранит принятые запросы по ID, как таблица operation_requests
+type queuedRequests struct {
+	repositories.OperationRequestRepository
+	byID map[uuid.UUID]*entities.OperationRequest
+}
+
+func (r *queuedRequests) Create(ctx context.Context, request *entities.OperationRequest) error {
+	if _, ok := r.byID[request.ID]; ok {
+		return repositories.ErrDuplicate
+	}
+	c := *request
+	r.byID[request.ID] = &c
+	return nil
+}
+
+func (r *queuedRequests) FindByID(ctx context.Context, id uuid.UUID) (*entities.OperationRequest, error) {
+	request, ok := r.byID[id]
+	if !ok {
+		return nil, repositories.ErrOperationRequestNotFound
+	}
+	c := *request
+	return &c, nil
+}
+
+func TestSubmitReplayRequiresSameAuthor(t *testing.T) {
+	walletID, userID := uuid.New(), uuid.New()
+	keyA, keyB := uuid.New(), uuid.New()
+	withKey := func(id uuid.UUID) context.Context {
+		return auth.WithClaims(context.Background(), &auth.Claims{APIKeyID: id, WalletIDs: []uuid.UUID{walletID}})
+	}
+	withUser := auth.WithClaims(context.Background(), &auth.Claims{UserID: userID})
+
+	tests := []struct {
+		name   string
+		first  context.Context
+		userID uuid.UUID
+		again  context.Context
+		want   error
+	}{
+		{"same user", withUser, userID, withUser, nil},
+		{"same api key", withKey(keyA), uuid.Nil, withKey(keyA), nil},
+		// У всех ключей пользователь нулевой: совпадения user_id недостаточно
+		{"other api key", withKey(keyA), uuid.Nil, withKey(keyB), services.ErrRequestMismatch},
+		{"api key replays user's request", withUser, userID, withKey(keyA), services.ErrRequestMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &queuedRequests{byID: map[uuid.UUID]*entities.OperationRequest{}}
+			queue := services.NewOperationQueueService(repo, nil, services.OperationQueuePolicy{})
+			requestID := uuid.New()
+
+			if _, err := queue.Submit(tt.first, tt.userID, walletID, entities.OperationTypeWithdraw, 100, &requestID); err != nil {
+				t.Fatal(err)
+			}
+			againUser := uuid.Nil
+			if claims, _ := auth.ClaimsFromContext(tt.again); !claims.IsAPIKey() {
+				againUser = claims.UserID
+			}
+			request, err := queue.Submit(tt.again, againUser, walletID, entities.OperationTypeWithdraw, 100, &requestID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err == nil && !request.Replayed {
+				t.Fatal("request was not replayed")
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrReconciliationRunning  = repositories.ErrReconciliationRunning
+	ErrReconciliationNotFound = repositories.ErrReconciliationNotFound
+)
+
+const (
+	DefaultReconciliationPageSize = 20
+	MaxReconciliationPageSize     = 100
+)
+
+// ReconciliationService сверяет балансы кошельков с историей операций.
+// UpdateBalance и Update меняют баланс без записи операции, и расхождение
+// иначе заметно только по выпискам.
+type ReconciliationService struct {
+	reconciliationRepo repositories.ReconciliationRepository
+}
+
+func NewReconciliationService(reconciliationRepo repositories.ReconciliationRepository) *ReconciliationService {
+	return &ReconciliationService{
+		reconciliationRepo: reconciliationRepo,
+	}
+}
+
+// Reconcile выполняет сверку и пишет в лог ошибку по каждому кошельку с
+// расхождением. ErrReconciliationRunning - сверку уже выполняет другой экземпляр.
+func (s *ReconciliationService) Reconcile(ctx context.Context) (_ *entities.ReconciliationRun, _ []*entities.Discrepancy, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Reconcile")
+	defer func() { tracing.End(span, err) }()
+
+	run := entities.NewReconciliationRun()
+	discrepancies, err := s.reconciliationRepo.Reconcile(ctx, run)
+	if err != nil {
+		return nil, nil, err
+	}
+	span.SetAttributes(
+		attribute.Int64("reconciliation.wallets_checked", run.WalletsChecked),
+		attribute.Int64("reconciliation.discrepancies", run.Discrepancies),
+	)
+
+	log := logger.FromContext(ctx)
+	for _, d := range discrepancies {
+		log.Error("wallet balance does not match operations",
+			"run_id", run.ID,
+			"wallet_id", d.WalletID,
+			"balance", d.Balance,
+			"last_balance_after", d.LastBalanceAfter,
+			"operations_sum", d.OperationsSum,
+			"last_balance_drift", d.LastBalanceDrift,
+			"sum_drift", d.SumDrift,
+		)
+	}
+	log.Info("reconciliation finished",
+		"run_id", run.ID,
+		"wallets_checked", run.WalletsChecked,
+		"discrepancies", run.Discrepancies,
+		"duration", run.FinishedAt.Sub(run.StartedAt),
+	)
+	return run, discrepancies, nil
+}
+
+// LatestRun возвращает последний проход сверки; nil, если сверок не было
+func (s *ReconciliationService) LatestRun(ctx context.Context) (_ *entities.ReconciliationRun, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.LatestRun")
+	defer func() { tracing.End(span, err) }()
+
+	return s.reconciliationRepo.LatestRun(ctx)
+}
+
+func (s *ReconciliationService) ListRuns(ctx context.Context, limit, offset int) (_ []*entities.ReconciliationRun, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.ListRuns")
+	defer func() { tracing.End(span, err) }()
+
+	if limit <= 0 {
+		limit = DefaultReconciliationPageSize
+	}
+	if limit > MaxReconciliationPageSize {
+		limit = MaxReconciliationPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.reconciliationRepo.ListRuns(ctx, limit, offset)
+}
+
+// GetRun возвращает проход сверки со всеми найденными расхождениями
+func (s *ReconciliationService) GetRun(ctx context.Context, id uuid.UUID) (_ *entities.ReconciliationRun, _ []*entities.Discrepancy, err error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.GetRun", attribute.String("reconciliation.run_id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	run, err := s.reconciliationRepo.FindRun(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	discrepancies, err := s.reconciliationRepo.ListDiscrepancies(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return run, discrepancies, nil
+}
@@ -0,0 +1,77 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	postgres "walletapitest/internal/infrastructure/database/postgres"
+
+	"github.com/google/uuid"
+)
+
+// Сверка - запросы Postgres, поэтому тест идет только с WALLET_TEST_DSN
+func TestReconcileFindsBalanceChangedWithoutOperation(t *testing.T) {
+	db := testDB(t)
+	if db == nil {
+		t.Skipf("%s is not set", envTestDSN)
+	}
+	ctx := context.Background()
+	walletRepo := postgres.NewWalletRepository(db)
+	wallets := services.NewWalletService(walletRepo, nil, nil, nil)
+	reconciliation := services.NewReconciliationService(postgres.NewReconciliationRepository(db))
+
+	owner := entities.NewUser("reconcile-"+uuid.NewString()+"@example.com", "reconcile-"+uuid.NewString(), "secret")
+	if err := postgres.NewUserRepository(db).Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	clean, err := wallets.CreateWallet(ctx, owner.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drifted, err := wallets.CreateWallet(ctx, owner.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, walletID := range []uuid.UUID{clean.ID, drifted.ID} {
+		if _, err := wallets.ProcessOperation(ctx, walletID, entities.OperationTypeDeposit, 1000, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Баланс меняется в обход операций
+	const drift = 250
+	if err := walletRepo.UpdateBalance(ctx, drifted.ID, drift); err != nil {
+		t.Fatal(err)
+	}
+
+	run, found, err := reconciliation.Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.WalletsChecked < 2 || run.Discrepancies != int64(len(found)) {
+		t.Fatalf("run = %+v with %d discrepancies", run, len(found))
+	}
+
+	// Другие тесты той же базы могли оставить свои расхождения: смотрим только свои
+	_, stored, err := reconciliation.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *entities.Discrepancy
+	for _, d := range stored {
+		switch d.WalletID {
+		case clean.ID:
+			t.Fatalf("discrepancy for consistent wallet: %+v", d)
+		case drifted.ID:
+			got = d
+		}
+	}
+	if got == nil {
+		t.Fatalf("no stored discrepancy for wallet %s in run %s", drifted.ID, run.ID)
+	}
+	if got.RunID != run.ID || got.Balance != 1000+drift || got.OperationsSum != 1000 || got.OperationsCount != 1 ||
+		got.LastBalanceAfter == nil || *got.LastBalanceAfter != 1000 || got.LastBalanceDrift != drift || got.SumDrift != drift {
+		t.Fatalf("discrepancy = %+v, want drift %d over balance 1000", got, drift)
+	}
+}
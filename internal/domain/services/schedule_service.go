@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/cron"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrScheduleNotFound     = repositories.ErrScheduleNotFound
+	ErrScheduleCancelled    = errors.New("schedule is cancelled")
+	ErrScheduleRuleRequired = errors.New("exactly one of cron or interval_seconds is required")
+	ErrScheduleInterval     = errors.New("interval_seconds must be at least 60")
+	ErrScheduleNeverFires   = errors.New("schedule has no upcoming runs")
+)
+
+const (
+	DefaultScheduleRunsPageSize = 20
+	MaxScheduleRunsPageSize     = 100
+
+	// minScheduleInterval - самый частый допустимый интервал регулярного перевода
+	minScheduleInterval = time.Minute
+	// scheduleDueBatch - расписаний за один проход воркера
+	scheduleDueBatch = 100
+	maxRetryBackoff  = time.Hour
+)
+
+// SchedulePolicy - повторы и автоматическая пауза регулярных переводов
+type SchedulePolicy struct {
+	// MaxAttempts - попыток одного запуска при временных ошибках, включая первую
+	MaxAttempts int
+	// RetryBackoff - пауза перед первым повтором; каждая следующая вдвое длиннее
+	RetryBackoff time.Duration
+	// MaxInsufficientFunds - неудач подряд из-за нехватки средств до паузы (0 - не ставить на паузу)
+	MaxInsufficientFunds int
+}
+
+// ScheduleService ведет регулярные переводы и выполняет наступившие запуски
+// через WalletService
+type ScheduleService struct {
+	scheduleRepo  repositories.ScheduleRepository
+	walletService *WalletService
+	policy        SchedulePolicy
+}
+
+func NewScheduleService(scheduleRepo repositories.ScheduleRepository, walletService *WalletService, policy SchedulePolicy) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo:  scheduleRepo,
+		walletService: walletService,
+		policy:        policy,
+	}
+}
+
+// Create заводит расписание для кошелька from; первый запуск - не раньше startAt
+func (s *ScheduleService) Create(
+	ctx context.Context,
+	from *entities.Wallet,
+	toWalletID uuid.UUID,
+	amount int64,
+	description string,
+	cronExpr *string,
+	intervalSeconds *int64,
+	startAt time.Time,
+) (_ *entities.Schedule, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.Create",
+		attribute.String("wallet.id", from.ID.String()),
+		attribute.Int64("schedule.amount", amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if from.ID == toWalletID {
+		return nil, ErrSameWallet
+	}
+	to, err := s.walletService.GetWallet(ctx, toWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if to.Currency != from.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	schedule := entities.NewSchedule(from.UserID, from.ID, toWalletID, amount, strings.TrimSpace(description), cronExpr, intervalSeconds)
+	if err = validateScheduleRule(schedule); err != nil {
+		return nil, err
+	}
+	if schedule.IntervalSeconds != nil {
+		schedule.NextRunAt = startAt.Truncate(time.Microsecond)
+	} else {
+		// Срабатывание cron ровно в startAt тоже подходит
+		schedule.NextRunAt = nextScheduleRun(schedule, startAt.Add(-time.Microsecond))
+	}
+	if schedule.NextRunAt.IsZero() {
+		return nil, ErrScheduleNeverFires
+	}
+
+	if err = s.scheduleRepo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("schedule created", "schedule_id", schedule.ID, "next_run_at", schedule.NextRunAt)
+	return schedule, nil
+}
+
+func (s *ScheduleService) Get(ctx context.Context, id uuid.UUID) (_ *entities.Schedule, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.Get", attribute.String("schedule.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return s.scheduleRepo.FindByID(ctx, id)
+}
+
+func (s *ScheduleService) ListByWallet(ctx context.Context, walletID uuid.UUID) (_ []*entities.Schedule, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.ListByWallet", attribute.String("wallet.id", walletID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return s.scheduleRepo.ListByWallet(ctx, walletID)
+}
+
+// Pause приостанавливает расписание; пропущенные за паузу сроки не выполняются
+func (s *ScheduleService) Pause(ctx context.Context, schedule *entities.Schedule) (err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.Pause", attribute.String("schedule.id", schedule.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	switch schedule.Status {
+	case entities.ScheduleStatusCancelled:
+		return ErrScheduleCancelled
+	case entities.ScheduleStatusPaused:
+		return nil
+	}
+
+	reason := "paused by user"
+	schedule.Status = entities.ScheduleStatusPaused
+	schedule.PausedReason = &reason
+	schedule.UpdatedAt = time.Now()
+	return s.scheduleRepo.UpdateState(ctx, schedule)
+}
+
+// Resume возобновляет расписание со следующего срока после текущего момента
+// и сбрасывает счетчики неудач
+func (s *ScheduleService) Resume(ctx context.Context, schedule *entities.Schedule) (err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.Resume", attribute.String("schedule.id", schedule.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	switch schedule.Status {
+	case entities.ScheduleStatusCancelled:
+		return ErrScheduleCancelled
+	case entities.ScheduleStatusActive:
+		return nil
+	}
+
+	now := time.Now()
+	if schedule.NextRunAt.Before(now) {
+		schedule.NextRunAt = nextScheduleRun(schedule, now)
+		if schedule.NextRunAt.IsZero() {
+			return ErrScheduleNeverFires
+		}
+	}
+	schedule.Status = entities.ScheduleStatusActive
+	schedule.PausedReason = nil
+	schedule.RetryAt = nil
+	schedule.Attempts = 0
+	schedule.InsufficientFundsCount = 0
+	schedule.UpdatedAt = now
+	return s.scheduleRepo.UpdateState(ctx, schedule)
+}
+
+// Cancel окончательно останавливает расписание; история запусков сохраняется
+func (s *ScheduleService) Cancel(ctx context.Context, schedule *entities.Schedule) (err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.Cancel", attribute.String("schedule.id", schedule.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if schedule.Status == entities.ScheduleStatusCancelled {
+		return nil
+	}
+	schedule.Status = entities.ScheduleStatusCancelled
+	schedule.RetryAt = nil
+	schedule.UpdatedAt = time.Now()
+	return s.scheduleRepo.UpdateState(ctx, schedule)
+}
+
+func (s *ScheduleService) ListRuns(ctx context.Context, scheduleID uuid.UUID, limit, offset int) (_ []*entities.ScheduleRun, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.ListRuns", attribute.String("schedule.id", scheduleID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if limit <= 0 {
+		limit = DefaultScheduleRunsPageSize
+	}
+	if limit > MaxScheduleRunsPageSize {
+		limit = MaxScheduleRunsPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.scheduleRepo.ListRuns(ctx, scheduleID, limit, offset)
+}
+
+// RunDue выполняет наступившие запуски и возвращает их число. Вызывается
+// только ведущим экземпляром; повтор после сбоя безопасен, так как перевод
+// каждого срока идемпотентен.
+func (s *ScheduleService) RunDue(ctx context.Context, now time.Time) (executed int, err error) {
+	ctx, span := tracing.Start(ctx, "ScheduleService.RunDue")
+	defer func() { tracing.End(span, err) }()
+
+	for {
+		schedules, err := s.scheduleRepo.ListDue(ctx, now, scheduleDueBatch)
+		if err != nil {
+			return executed, err
+		}
+		for _, schedule := range schedules {
+			if err = s.execute(ctx, schedule, now); err != nil {
+				return executed, err
+			}
+			executed++
+		}
+		if len(schedules) < scheduleDueBatch {
+			return executed, nil
+		}
+	}
+}
+
+// execute проводит перевод текущего срока и сохраняет исход попытки
+func (s *ScheduleService) execute(ctx context.Context, schedule *entities.Schedule, now time.Time) error {
+	log := logger.FromContext(ctx).With("schedule_id", schedule.ID, "scheduled_for", schedule.NextRunAt)
+	run := entities.NewScheduleRun(schedule)
+
+	transfer := entities.NewTransfer(run.TransferID, schedule.FromWalletID, schedule.ToWalletID, schedule.Amount)
+	reason := "scheduled transfer " + schedule.ID.String()
+	if schedule.Description != "" {
+		reason += ": " + schedule.Description
+	}
+	err := s.walletService.Transfer(ctx, transfer, reason)
+
+	run.ExecutedAt = time.Now()
+	schedule.LastRunAt = &run.ExecutedAt
+	schedule.UpdatedAt = run.ExecutedAt
+	advance := true
+
+	switch {
+	case err == nil:
+		run.Status = entities.ScheduleRunSucceeded
+		schedule.InsufficientFundsCount = 0
+		log.Info("scheduled transfer completed", "transfer_id", transfer.ID, "replayed", transfer.Replayed)
+
+	case errors.Is(err, ErrInsufficientFunds):
+		run.Status = entities.ScheduleRunFailed
+		schedule.InsufficientFundsCount++
+		if limit := s.policy.MaxInsufficientFunds; limit > 0 && schedule.InsufficientFundsCount >= limit {
+			pause(schedule, fmt.Sprintf("insufficient funds %d times in a row", schedule.InsufficientFundsCount))
+		}
+		log.Warn("scheduled transfer skipped: insufficient funds", "in_a_row", schedule.InsufficientFundsCount)
+
+	case errors.Is(err, ErrWalletFrozen):
+		// Срок пропускается; после разморозки расписание продолжит работу
+		run.Status = entities.ScheduleRunFailed
+		log.Warn("scheduled transfer skipped: wallet frozen")
+
+	case errors.Is(err, ErrWalletNotFound), errors.Is(err, ErrTransferMismatch), errors.Is(err, ErrSameWallet),
+		errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrCurrencyMismatch):
+		run.Status = entities.ScheduleRunFailed
+		pause(schedule, err.Error())
+		log.Error("scheduled transfer failed permanently, schedule paused", "error", err)
+
+	default:
+		// Временная ошибка (сеть, конфликт сериализации): тот же срок повторяется
+		// с тем же ключом перевода, поэтому двойного списания не будет
+		schedule.Attempts++
+		if schedule.Attempts < s.policy.MaxAttempts {
+			retryAt := now.Add(s.retryBackoff(schedule.Attempts))
+			run.Status = entities.ScheduleRunRetrying
+			schedule.RetryAt = &retryAt
+			advance = false
+			log.Warn("scheduled transfer failed, will retry", "error", err, "attempt", run.Attempt, "retry_at", retryAt)
+		} else {
+			run.Status = entities.ScheduleRunFailed
+			log.Error("scheduled transfer failed after retries", "error", err, "attempts", schedule.Attempts)
+		}
+	}
+	if err != nil {
+		msg := err.Error()
+		run.Error = &msg
+	}
+
+	if advance {
+		schedule.NextRunAt = nextScheduleRun(schedule, now)
+		schedule.RetryAt = nil
+		schedule.Attempts = 0
+		if schedule.NextRunAt.IsZero() {
+			pause(schedule, ErrScheduleNeverFires.Error())
+			schedule.NextRunAt = run.ScheduledFor
+		}
+	}
+
+	return s.scheduleRepo.RecordRun(ctx, schedule, run)
+}
+
+func (s *ScheduleService) retryBackoff(attempt int) time.Duration {
+	d := s.policy.RetryBackoff
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d
+}
+
+func pause(schedule *entities.Schedule, reason string) {
+	schedule.Status = entities.ScheduleStatusPaused
+	schedule.PausedReason = &reason
+}
+
+func validateScheduleRule(schedule *entities.Schedule) error {
+	switch {
+	case (schedule.Cron == nil) == (schedule.IntervalSeconds == nil):
+		return ErrScheduleRuleRequired
+	case schedule.IntervalSeconds != nil:
+		if time.Duration(*schedule.IntervalSeconds)*time.Second < minScheduleInterval {
+			return ErrScheduleInterval
+		}
+	default:
+		if _, err := cron.Parse(*schedule.Cron); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextScheduleRun возвращает первый срок по правилу строго после t. Сроки,
+// пропущенные за время простоя, не наверстываются. Нулевое время - сроков нет.
+func nextScheduleRun(schedule *entities.Schedule, t time.Time) time.Time {
+	if schedule.IntervalSeconds != nil {
+		interval := time.Duration(*schedule.IntervalSeconds) * time.Second
+		next := schedule.NextRunAt
+		if !next.After(t) {
+			next = next.Add((t.Sub(next)/interval + 1) * interval)
+		}
+		return next.Truncate(time.Microsecond)
+	}
+
+	expr, err := cron.Parse(*schedule.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return expr.Next(t.UTC())
+}
@@ -0,0 +1,372 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/infrastructure/database/postgres"
+
+	"github.com/google/uuid"
+)
+
+// fakeScheduleRepository хранит расписания и попытки так же, как Postgres:
+// ListDue отдает активные расписания с наступившим сроком или повтором
+type fakeScheduleRepository struct {
+	repositories.ScheduleRepository
+	schedules map[uuid.UUID]entities.Schedule
+	runs      []*entities.ScheduleRun
+}
+
+func newFakeScheduleRepository() *fakeScheduleRepository {
+	return &fakeScheduleRepository{schedules: make(map[uuid.UUID]entities.Schedule)}
+}
+
+func (r *fakeScheduleRepository) Create(ctx context.Context, schedule *entities.Schedule) error {
+	r.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *fakeScheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.Schedule, error) {
+	due := []*entities.Schedule{}
+	for _, schedule := range r.schedules {
+		at := schedule.NextRunAt
+		if schedule.RetryAt != nil {
+			at = *schedule.RetryAt
+		}
+		if schedule.Status == entities.ScheduleStatusActive && !at.After(now) {
+			schedule := schedule
+			due = append(due, &schedule)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextRunAt.Before(due[j].NextRunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *fakeScheduleRepository) RecordRun(ctx context.Context, schedule *entities.Schedule, run *entities.ScheduleRun) error {
+	r.schedules[schedule.ID] = *schedule
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+// errTransient - временный сбой базы, после которого перевод повторяется
+var errTransient = errors.New("connection reset by peer")
+
+// flakyTransfers отклоняет переводы временной ошибкой, пока failures > 0.
+// С committed перевод проводится, но ответ теряется, как при обрыве
+// соединения после COMMIT.
+type flakyTransfers struct {
+	repositories.WalletRepository
+	failures  int
+	committed bool
+	transfers int
+}
+
+func (r *flakyTransfers) TransferAtomic(ctx context.Context, transfer *entities.Transfer, reason *string, fees *entities.FeeSchedule) error {
+	if r.failures > 0 && !r.committed {
+		r.failures--
+		return errTransient
+	}
+	if err := r.WalletRepository.TransferAtomic(ctx, transfer, reason, fees); err != nil {
+		return err
+	}
+	if !transfer.Replayed {
+		r.transfers++
+	}
+	if r.failures > 0 {
+		r.failures--
+		return errTransient
+	}
+	return nil
+}
+
+// scheduleFixture - сервис расписаний на кошельках в памяти
+type scheduleFixture struct {
+	transfers *flakyTransfers
+	wallets   *services.WalletService
+	repo      *fakeScheduleRepository
+	schedules *services.ScheduleService
+	owner     uuid.UUID
+	// due - срок первого запуска расписаний фикстуры
+	due time.Time
+}
+
+func newScheduleFixture(t *testing.T, policy services.SchedulePolicy) *scheduleFixture {
+	t.Helper()
+	store := memory.NewStore()
+	f := &scheduleFixture{
+		transfers: &flakyTransfers{WalletRepository: memory.NewWalletRepository(store)},
+		repo:      newFakeScheduleRepository(),
+		owner:     createUser(t, store).ID,
+		due:       time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	f.wallets = services.NewWalletService(f.transfers, nil, nil, nil)
+	f.schedules = services.NewScheduleService(f.repo, f.wallets, policy)
+	return f
+}
+
+func (f *scheduleFixture) wallet(t *testing.T, balance int64) uuid.UUID {
+	t.Helper()
+	id := createWallet(t, f.wallets, f.owner)
+	if balance > 0 {
+		if _, err := f.wallets.ProcessOperation(context.Background(), id, entities.OperationTypeDeposit, balance, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+func (f *scheduleFixture) balance(t *testing.T, id uuid.UUID) int64 {
+	t.Helper()
+	wallet, err := f.wallets.GetWallet(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet.Balance
+}
+
+// schedule заводит ежеминутный перевод amount из from в to со сроком f.due
+func (f *scheduleFixture) schedule(t *testing.T, from, to uuid.UUID, amount int64) *entities.Schedule {
+	t.Helper()
+	interval := int64(60)
+	schedule := entities.NewSchedule(f.owner, from, to, amount, "", nil, &interval)
+	schedule.NextRunAt = f.due
+	if err := f.repo.Create(context.Background(), schedule); err != nil {
+		t.Fatal(err)
+	}
+	return schedule
+}
+
+// run выполняет наступившие к now запуски и возвращает состояние расписания
+func (f *scheduleFixture) run(t *testing.T, now time.Time, id uuid.UUID, wantExecuted int) entities.Schedule {
+	t.Helper()
+	executed, err := f.schedules.RunDue(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed != wantExecuted {
+		t.Fatalf("RunDue(%s) executed %d, want %d", now.Format(time.TimeOnly), executed, wantExecuted)
+	}
+	return f.repo.schedules[id]
+}
+
+func (f *scheduleFixture) lastRun(t *testing.T) *entities.ScheduleRun {
+	t.Helper()
+	if len(f.repo.runs) == 0 {
+		t.Fatal("no runs recorded")
+	}
+	return f.repo.runs[len(f.repo.runs)-1]
+}
+
+func TestScheduleRetriesTransientErrors(t *testing.T) {
+	const backoff = 10 * time.Second
+	f := newScheduleFixture(t, services.SchedulePolicy{MaxAttempts: 3, RetryBackoff: backoff})
+	from, to := f.wallet(t, 100), f.wallet(t, 0)
+	schedule := f.schedule(t, from, to, 10)
+	f.transfers.failures = 3
+
+	// Повторы одного срока с удваивающейся паузой; срок не сдвигается
+	now := f.due
+	for attempt, wait := range []time.Duration{backoff, 2 * backoff} {
+		state := f.run(t, now, schedule.ID, 1)
+		run := f.lastRun(t)
+		if run.Status != entities.ScheduleRunRetrying || run.Attempt != attempt+1 {
+			t.Fatalf("attempt %d: run = %+v, want retrying", attempt+1, run)
+		}
+		if state.RetryAt == nil || !state.RetryAt.Equal(now.Add(wait)) {
+			t.Fatalf("attempt %d: retry at %v, want %s", attempt+1, state.RetryAt, now.Add(wait))
+		}
+		if !state.NextRunAt.Equal(f.due) || state.Attempts != attempt+1 || state.Status != entities.ScheduleStatusActive {
+			t.Fatalf("attempt %d: schedule = %+v", attempt+1, state)
+		}
+		// До паузы повтор не выполняется
+		f.run(t, now.Add(wait-time.Second), schedule.ID, 0)
+		now = now.Add(wait)
+	}
+
+	// Последняя попытка исчерпана: срок пропускается, расписание продолжает работу
+	state := f.run(t, now, schedule.ID, 1)
+	if run := f.lastRun(t); run.Status != entities.ScheduleRunFailed || run.Error == nil {
+		t.Fatalf("last attempt: run = %+v, want failed", run)
+	}
+	if state.RetryAt != nil || state.Attempts != 0 || state.Status != entities.ScheduleStatusActive {
+		t.Fatalf("after retries: schedule = %+v, want active without retry", state)
+	}
+	if want := f.due.Add(time.Minute); !state.NextRunAt.Equal(want) {
+		t.Fatalf("next run at %s, want %s", state.NextRunAt, want)
+	}
+
+	// Следующий срок проходит
+	f.run(t, state.NextRunAt, schedule.ID, 1)
+	if run := f.lastRun(t); run.Status != entities.ScheduleRunSucceeded || run.Attempt != 1 {
+		t.Fatalf("next run = %+v, want succeeded first attempt", run)
+	}
+	if got := f.balance(t, to); got != 10 {
+		t.Fatalf("payee balance = %d, want 10", got)
+	}
+}
+
+func TestScheduleRetryDoesNotTransferTwice(t *testing.T) {
+	const backoff = 10 * time.Second
+	f := newScheduleFixture(t, services.SchedulePolicy{MaxAttempts: 3, RetryBackoff: backoff})
+	from, to := f.wallet(t, 100), f.wallet(t, 0)
+	schedule := f.schedule(t, from, to, 30)
+
+	// Перевод проведен, но ответ потерян: попытка считается неудачной
+	f.transfers.failures, f.transfers.committed = 1, true
+	f.run(t, f.due, schedule.ID, 1)
+	if run := f.lastRun(t); run.Status != entities.ScheduleRunRetrying {
+		t.Fatalf("first attempt: run = %+v, want retrying", run)
+	}
+
+	// Повтор с тем же ключом находит проведенный перевод
+	state := f.run(t, f.due.Add(backoff), schedule.ID, 1)
+	if run := f.lastRun(t); run.Status != entities.ScheduleRunSucceeded || run.Attempt != 2 {
+		t.Fatalf("retry: run = %+v, want succeeded second attempt", run)
+	}
+	if first, retry := f.repo.runs[0], f.lastRun(t); first.TransferID != retry.TransferID {
+		t.Fatalf("transfer id changed between attempts: %s, %s", first.TransferID, retry.TransferID)
+	}
+	if f.transfers.transfers != 1 {
+		t.Fatalf("transfers = %d, want 1", f.transfers.transfers)
+	}
+	if got := f.balance(t, from); got != 70 {
+		t.Fatalf("payer balance = %d, want 70", got)
+	}
+
+	// Повторное выполнение того же срока после сбоя до записи попытки
+	// тоже не проводит перевод еще раз
+	state.NextRunAt, state.RetryAt, state.Attempts = f.due, nil, 0
+	f.repo.schedules[schedule.ID] = state
+	f.run(t, f.due.Add(backoff), schedule.ID, 1)
+	if f.transfers.transfers != 1 || f.balance(t, from) != 70 || f.balance(t, to) != 30 {
+		t.Fatalf("re-run of the same due date transferred again: payer %d, payee %d", f.balance(t, from), f.balance(t, to))
+	}
+}
+
+func TestSchedulePausesAfterInsufficientFunds(t *testing.T) {
+	f := newScheduleFixture(t, services.SchedulePolicy{MaxAttempts: 3, RetryBackoff: time.Second, MaxInsufficientFunds: 3})
+	from, to := f.wallet(t, 10), f.wallet(t, 0)
+	schedule := f.schedule(t, from, to, 20)
+
+	now := f.due
+	for i := 1; i <= 2; i++ {
+		// Срок без денег пропускается без повторов
+		state := f.run(t, now, schedule.ID, 1)
+		if state.Status != entities.ScheduleStatusActive || state.InsufficientFundsCount != i || state.RetryAt != nil {
+			t.Fatalf("run %d: schedule = %+v, want active", i, state)
+		}
+		now = state.NextRunAt
+	}
+
+	// Пополнение сбрасывает счетчик
+	if _, err := f.wallets.ProcessOperation(context.Background(), from, entities.OperationTypeDeposit, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	state := f.run(t, now, schedule.ID, 1)
+	if state.InsufficientFundsCount != 0 {
+		t.Fatalf("insufficient funds count after success = %d, want 0", state.InsufficientFundsCount)
+	}
+	now = state.NextRunAt
+
+	for i := 1; i <= 3; i++ {
+		state = f.run(t, now, schedule.ID, 1)
+		now = state.NextRunAt
+	}
+	if state.Status != entities.ScheduleStatusPaused || state.PausedReason == nil {
+		t.Fatalf("schedule = %+v, want paused after 3 insufficient funds in a row", state)
+	}
+	// Приостановленное расписание больше не выполняется
+	f.run(t, now.Add(time.Hour), schedule.ID, 0)
+}
+
+func TestSchedulePausesOnPermanentError(t *testing.T) {
+	tests := []struct {
+		name string
+		to   func(t *testing.T, f *scheduleFixture) uuid.UUID
+		want error
+	}{
+		{"wallet deleted", func(t *testing.T, f *scheduleFixture) uuid.UUID {
+			return uuid.New()
+		}, services.ErrWalletNotFound},
+		{"currency mismatch", func(t *testing.T, f *scheduleFixture) uuid.UUID {
+			wallet, err := f.wallets.CreateWallet(context.Background(), f.owner, "EUR")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return wallet.ID
+		}, services.ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newScheduleFixture(t, services.SchedulePolicy{MaxAttempts: 3, RetryBackoff: time.Second})
+			schedule := f.schedule(t, f.wallet(t, 100), tt.to(t, f), 10)
+
+			state := f.run(t, f.due, schedule.ID, 1)
+			run := f.lastRun(t)
+			if run.Status != entities.ScheduleRunFailed || run.Error == nil || *run.Error != tt.want.Error() {
+				t.Fatalf("run = %+v, want failed with %v", run, tt.want)
+			}
+			// Ошибку не исправить повтором: расписание сразу приостанавливается
+			if state.Status != entities.ScheduleStatusPaused || state.RetryAt != nil || state.Attempts != 0 {
+				t.Fatalf("schedule = %+v, want paused without retry", state)
+			}
+			if state.PausedReason == nil || *state.PausedReason != tt.want.Error() {
+				t.Fatalf("paused reason = %v, want %v", state.PausedReason, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderLockIsExclusive(t *testing.T) {
+	db := testDB(t)
+	if db == nil {
+		t.Skipf("%s is not set", envTestDSN)
+	}
+	ctx := context.Background()
+	// Свой ключ, чтобы не пересечься с запущенным сервисом на той же базе
+	key := time.Now().UnixNano()
+	first, second := postgres.NewLeaderLock(db, key), postgres.NewLeaderLock(db, key)
+	t.Cleanup(func() {
+		first.Release(ctx)
+		second.Release(ctx)
+	})
+
+	acquire := func(lock *postgres.LeaderLock) bool {
+		t.Helper()
+		ok, err := lock.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !acquire(first) {
+		t.Fatal("first instance did not become leader")
+	}
+	if acquire(second) {
+		t.Fatal("second instance became leader while the first holds the lock")
+	}
+	// Ведущий подтверждает блокировку на каждом тике
+	if !acquire(first) {
+		t.Fatal("leader lost the lock")
+	}
+
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !acquire(second) {
+		t.Fatal("second instance did not take over after release")
+	}
+	if acquire(first) {
+		t.Fatal("former leader took the lock back")
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+const (
+	RevokeReasonLogout        = "logout"
+	RevokeReasonLogoutAll     = "logout_all"
+	RevokeReasonReuse         = "refresh_token_reuse"
+	RevokeReasonPasswordReset = "password_reset"
+	RevokeReasonRoleChanged   = "role_changed"
+	RevokeReasonTierChanged   = "tier_changed"
+)
+
+// SessionMeta - сведения о клиенте, открывающем сессию
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+// TokenPair - выданная пара токенов
+type TokenPair struct {
+	SessionID        uuid.UUID
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+type SessionService struct {
+	sessionRepo repositories.SessionRepository
+	userRepo    repositories.UserRepository
+	tokens      *auth.TokenManager
+	denylist    auth.Denylist
+	refreshTTL  time.Duration
+}
+
+func NewSessionService(
+	sessionRepo repositories.SessionRepository,
+	userRepo repositories.UserRepository,
+	tokens *auth.TokenManager,
+	denylist auth.Denylist,
+	refreshTTL time.Duration,
+) *SessionService {
+	return &SessionService{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+		tokens:      tokens,
+		denylist:    denylist,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// Start открывает сессию для аутентифицированного пользователя
+func (s *SessionService) Start(ctx context.Context, user *entities.User, meta SessionMeta) (_ *TokenPair, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Start", attribute.String("user.id", user.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := entities.NewSession(user.ID, meta.UserAgent, meta.IP)
+	token := entities.NewRefreshToken(session.ID, hash, s.refreshTTL)
+	if err = s.sessionRepo.Create(ctx, session, token); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("session started", "session_id", session.ID)
+	return s.issue(user, session.ID, refresh, token.ExpiresAt)
+}
+
+// Refresh обменивает refresh-токен на новую пару. Повторное предъявление уже
+// обмененного токена означает его утечку: сессия отзывается целиком.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (_ *TokenPair, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Refresh")
+	defer func() { tracing.End(span, err) }()
+
+	log := logger.FromContext(ctx)
+
+	old, err := s.sessionRepo.FindRefreshToken(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if old == nil || time.Now().After(old.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.FindByID(ctx, old.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.Revoked() {
+		return nil, ErrSessionRevoked
+	}
+	span.SetAttributes(attribute.String("session.id", session.ID.String()))
+
+	if old.UsedAt != nil {
+		log.Warn("refresh token reuse detected", "session_id", session.ID, "user_id", session.UserID)
+		if err = s.revoke(ctx, session.ID, RevokeReasonReuse); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrSessionRevoked
+	}
+
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	next := entities.NewRefreshToken(session.ID, hash, s.refreshTTL)
+
+	if err = s.sessionRepo.Rotate(ctx, old, next); err != nil {
+		if errors.Is(err, repositories.ErrTokenAlreadyUsed) {
+			// Токен обменяли параллельно - это тоже повторное использование
+			log.Warn("refresh token reuse detected", "session_id", session.ID, "user_id", session.UserID)
+			if err = s.revoke(ctx, session.ID, RevokeReasonReuse); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, err
+	}
+
+	return s.issue(user, session.ID, refresh, next.ExpiresAt)
+}
+
+// Logout отзывает одну сессию
+func (s *SessionService) Logout(ctx context.Context, sessionID uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.Logout", attribute.String("session.id", sessionID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if err = s.revoke(ctx, sessionID, RevokeReasonLogout); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("session revoked", "session_id", sessionID, "reason", RevokeReasonLogout)
+	return nil
+}
+
+// LogoutAll отзывает все сессии пользователя ("выйти на всех устройствах")
+func (s *SessionService) LogoutAll(ctx context.Context, userID uuid.UUID) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.LogoutAll", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return s.RevokeAll(ctx, userID, RevokeReasonLogoutAll)
+}
+
+// RevokeAll отзывает все сессии пользователя вместе с refresh-токенами и
+// заносит их в denylist, чтобы выданные access-токены перестали приниматься
+// сразу, а не по истечении. Нужен, когда прежние токены больше не отражают
+// права или владельца аккаунта (смена пароля, роли, тарифа).
+func (s *SessionService) RevokeAll(ctx context.Context, userID uuid.UUID, reason string) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeAll",
+		attribute.String("user.id", userID.String()),
+		attribute.String("session.revoke_reason", reason),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	ids, err := s.sessionRepo.RevokeAllForUser(ctx, userID, reason)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err = s.denylist.Add(ctx, id, s.tokens.TTL()); err != nil {
+			return 0, err
+		}
+	}
+
+	logger.FromContext(ctx).Info("all sessions revoked", "user_id", userID, "count", len(ids), "reason", reason)
+	return len(ids), nil
+}
+
+func (s *SessionService) revoke(ctx context.Context, sessionID uuid.UUID, reason string) error {
+	if err := s.sessionRepo.Revoke(ctx, sessionID, reason); err != nil {
+		return err
+	}
+	return s.denylist.Add(ctx, sessionID, s.tokens.TTL())
+}
+
+func (s *SessionService) issue(user *entities.User, sessionID uuid.UUID, refresh string, refreshExpiresAt time.Time) (*TokenPair, error) {
+	access, accessExpiresAt, err := s.tokens.Issue(user.ID, sessionID, string(user.Role), user.Role.PermissionNames())
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		SessionID:        sessionID,
+		AccessToken:      access,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
@@ -0,0 +1,323 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+type sessionFixture struct {
+	sessions *fakeSessionRepository
+	denylist *fakeDenylist
+	tokens   *auth.TokenManager
+	service  *services.SessionService
+	user     *entities.User
+}
+
+// newSessionFixture - сервис сессий на фейковых репозиториях; wrap позволяет
+// подменить репозиторий сессий (nil - без подмены)
+func newSessionFixture(t *testing.T, refreshTTL time.Duration, wrap func(repositories.SessionRepository) repositories.SessionRepository) *sessionFixture {
+	t.Helper()
+	user := entities.NewUser("session-"+uuid.NewString()+"@example.com", "session-"+uuid.NewString(), "secret")
+	f := &sessionFixture{
+		sessions: newFakeSessionRepository(),
+		denylist: newFakeDenylist(),
+		tokens:   auth.NewTokenManager("test secret", time.Minute),
+		user:     user,
+	}
+	var sessions repositories.SessionRepository = f.sessions
+	if wrap != nil {
+		sessions = wrap(sessions)
+	}
+	users := &fakeUserRepository{users: map[uuid.UUID]*entities.User{user.ID: user}}
+	f.service = services.NewSessionService(sessions, users, f.tokens, f.denylist, refreshTTL)
+	return f
+}
+
+func (f *sessionFixture) start(t *testing.T) *services.TokenPair {
+	t.Helper()
+	pair, err := f.service.Start(context.Background(), f.user, services.SessionMeta{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	return pair
+}
+
+// assertRevokedForReuse проверяет, что сессия отозвана за повторное
+// использование и ее access-токены больше не принимаются
+func (f *sessionFixture) assertRevokedForReuse(t *testing.T, sessionID uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	session, err := f.sessions.FindByID(ctx, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.Revoked() || session.RevokeReason == nil || *session.RevokeReason != services.RevokeReasonReuse {
+		t.Errorf("session revoked = %v, reason = %v; want revoked for %s", session.Revoked(), session.RevokeReason, services.RevokeReasonReuse)
+	}
+	if denied, err := f.denylist.Contains(ctx, sessionID); err != nil || !denied {
+		t.Errorf("session in denylist = %v, %v; want true", denied, err)
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	f := newSessionFixture(t, time.Hour, nil)
+	ctx := context.Background()
+	first := f.start(t)
+
+	second, err := f.service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if second.SessionID != first.SessionID {
+		t.Errorf("session = %s, want %s", second.SessionID, first.SessionID)
+	}
+	claims, err := f.tokens.Parse(second.AccessToken)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	if claims.UserID != f.user.ID || claims.SessionID != first.SessionID {
+		t.Errorf("access token for user %s session %s, want %s %s", claims.UserID, claims.SessionID, f.user.ID, first.SessionID)
+	}
+
+	// Новый токен тоже обменивается, цепочка продолжается
+	if _, err := f.service.Refresh(ctx, second.RefreshToken); err != nil {
+		t.Fatalf("refresh rotated token: %v", err)
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpiredTokens(t *testing.T) {
+	f := newSessionFixture(t, time.Hour, nil)
+	if _, err := f.service.Refresh(context.Background(), "not a token"); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: got %v, want %v", err, services.ErrInvalidRefreshToken)
+	}
+
+	expired := newSessionFixture(t, -time.Minute, nil)
+	pair := expired.start(t)
+	if _, err := expired.service.Refresh(context.Background(), pair.RefreshToken); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("expired token: got %v, want %v", err, services.ErrInvalidRefreshToken)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	f := newSessionFixture(t, time.Hour, nil)
+	ctx := context.Background()
+	first := f.start(t)
+	second, err := f.service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	if _, err := f.service.Refresh(ctx, first.RefreshToken); !errors.Is(err, services.ErrRefreshTokenReused) {
+		t.Fatalf("reused token: got %v, want %v", err, services.ErrRefreshTokenReused)
+	}
+	f.assertRevokedForReuse(t, first.SessionID)
+
+	// Токен, выданный до обнаружения утечки, тоже больше не действует
+	if _, err := f.service.Refresh(ctx, second.RefreshToken); !errors.Is(err, services.ErrSessionRevoked) {
+		t.Fatalf("token of revoked session: got %v, want %v", err, services.ErrSessionRevoked)
+	}
+}
+
+// rotateHookRepository вызывает beforeRotate перед первым Rotate: так
+// параллельный обмен того же токена попадает между чтением токена и его
+// обменом
+type rotateHookRepository struct {
+	repositories.SessionRepository
+	beforeRotate func()
+}
+
+func (r *rotateHookRepository) Rotate(ctx context.Context, old, next *entities.RefreshToken) error {
+	if hook := r.beforeRotate; hook != nil {
+		r.beforeRotate = nil
+		hook()
+	}
+	return r.SessionRepository.Rotate(ctx, old, next)
+}
+
+func TestRefreshConcurrentRotationRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	var f *sessionFixture
+	var first *services.TokenPair
+	var concurrentErr error
+	hook := &rotateHookRepository{beforeRotate: func() {
+		_, concurrentErr = f.service.Refresh(ctx, first.RefreshToken)
+	}}
+	f = newSessionFixture(t, time.Hour, func(r repositories.SessionRepository) repositories.SessionRepository {
+		hook.SessionRepository = r
+		return hook
+	})
+	first = f.start(t)
+
+	// Оба запроса прочитали неиспользованный токен; обменивает его первый
+	// дошедший до Rotate, второй получает ErrTokenAlreadyUsed
+	_, err := f.service.Refresh(ctx, first.RefreshToken)
+	if concurrentErr != nil {
+		t.Fatalf("concurrent refresh: %v", concurrentErr)
+	}
+	if !errors.Is(err, services.ErrRefreshTokenReused) {
+		t.Fatalf("losing refresh: got %v, want %v", err, services.ErrRefreshTokenReused)
+	}
+	f.assertRevokedForReuse(t, first.SessionID)
+}
+
+func TestRefreshParallelRequestsIssueOnePair(t *testing.T) {
+	const requests = 8
+	f := newSessionFixture(t, time.Hour, nil)
+	first := f.start(t)
+
+	var wg sync.WaitGroup
+	errs := make([]error, requests)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.service.Refresh(context.Background(), first.RefreshToken)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, services.ErrRefreshTokenReused), errors.Is(err, services.ErrSessionRevoked):
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d refreshes succeeded, want 1", succeeded)
+	}
+	f.assertRevokedForReuse(t, first.SessionID)
+}
+
+// fakeSessionRepository хранит сессии и refresh-токены в памяти; Rotate
+// атомарен, как UPDATE ... WHERE used_at IS NULL в Postgres
+type fakeSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*entities.Session
+	tokens   map[string]*entities.RefreshToken
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{
+		sessions: map[uuid.UUID]*entities.Session{},
+		tokens:   map[string]*entities.RefreshToken{},
+	}
+}
+
+func (r *fakeSessionRepository) Create(ctx context.Context, session *entities.Session, token *entities.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sc, tc := *session, *token
+	r.sessions[session.ID] = &sc
+	r.tokens[token.TokenHash] = &tc
+	return nil
+}
+
+func (r *fakeSessionRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok {
+		c := *session
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*entities.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[tokenHash]; ok {
+		c := *token
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepository) Rotate(ctx context.Context, old, next *entities.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.tokens[old.TokenHash]
+	if !ok || current.UsedAt != nil {
+		return repositories.ErrTokenAlreadyUsed
+	}
+	now := time.Now()
+	current.UsedAt = &now
+	c := *next
+	r.tokens[next.TokenHash] = &c
+	return nil
+}
+
+func (r *fakeSessionRepository) Revoke(ctx context.Context, id uuid.UUID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		session.RevokeReason = &reason
+	}
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, reason string) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := []uuid.UUID{}
+	for id, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			now := time.Now()
+			session.RevokedAt = &now
+			session.RevokeReason = &reason
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+type fakeDenylist struct {
+	mu      sync.Mutex
+	revoked map[uuid.UUID]bool
+}
+
+func newFakeDenylist() *fakeDenylist {
+	return &fakeDenylist{revoked: map[uuid.UUID]bool{}}
+}
+
+func (d *fakeDenylist) Add(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.revoked[sessionID] = true
+	return nil
+}
+
+func (d *fakeDenylist) Contains(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.revoked[sessionID], nil
+}
+
+// fakeUserRepository отдает пользователей по ID; остальные методы
+// сервисом сессий не вызываются
+type fakeUserRepository struct {
+	repositories.UserRepository
+	users map[uuid.UUID]*entities.User
+}
+
+func (r *fakeUserRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	return r.users[id], nil
+}
@@ -3,45 +3,99 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 	"walletapitest/internal/domain/entities"
 	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
 	
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	ErrUserNotFound    = errors.New("user not found")
 	ErrInvalidPassword = errors.New("invalid password")
 	ErrEmailExists     = errors.New("email already exists")
+	ErrUsernameExists  = errors.New("username already exists")
+	ErrUserHasFunds    = repositories.ErrUserHasFunds
+	ErrForbidden       = errors.New("forbidden")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrInvalidTier     = errors.New("tier must be 1-32 lowercase letters, digits, '-' or '_'")
+	// ErrReauthRequired - смена email без текущего пароля или кода второго фактора
+	ErrReauthRequired = errors.New("current password or otp is required to change the email")
 )
 
+const (
+	DefaultUsersPageSize = 20
+	MaxUsersPageSize     = 100
+)
+
+// UserUpdate - изменяемые поля пользователя; nil означает "не менять"
+type UserUpdate struct {
+	Email    *string
+	Username *string
+	// reauthenticated - вызывающий подтвердил личность (AccessService.UpdateUser);
+	// без этого email не меняется
+	reauthenticated bool
+}
+
 type UserService struct {
-	userRepo repositories.UserRepository
+	userRepo  repositories.UserRepository
+	loginRepo repositories.LoginRepository
+	// sessions отзывает сессии пользователя при смене роли и тарифа; nil -
+	// в утилитах, которые их не меняют
+	sessions    *SessionService
+	loginPolicy LoginPolicy
+	audit       *AuditService
 }
 
-func NewUserService(userRepo repositories.UserRepository) *UserService {
+func NewUserService(
+	userRepo repositories.UserRepository,
+	loginRepo repositories.LoginRepository,
+	sessions *SessionService,
+	loginPolicy LoginPolicy,
+	audit *AuditService,
+) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		loginRepo:   loginRepo,
+		sessions:    sessions,
+		loginPolicy: loginPolicy,
+		audit:       audit,
 	}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, email, username, password string) (*entities.User, error) {
+func (s *UserService) CreateUser(ctx context.Context, email, username, password string) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateUser")
+	defer func() { tracing.End(span, err) }()
+
 	// Проверяем существование пользователя с таким email
 	existing, _ := s.userRepo.FindByEmail(ctx, email)
 	if existing != nil {
 		return nil, ErrEmailExists
 	}
+	if existing, _ := s.userRepo.FindByUsername(ctx, username); existing != nil {
+		return nil, ErrUsernameExists
+	}
 	
 	user := entities.NewUser(email, username, password)
 	
-	if err := s.userRepo.Create(ctx, user); err != nil {
+	if err = s.userRepo.Create(ctx, user); err != nil {
 		return nil, err
 	}
+
+	logger.FromContext(ctx).Info("user created", "user_id", user.ID)
 	
 	return user, nil
 }
 
-func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUser", attribute.String("user.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
 	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -54,20 +108,275 @@ func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (*entities.User
 	return user, nil
 }
 
-func (s *UserService) Authenticate(ctx context.Context, email, password string) (*entities.User, error) {
+// Authenticate проверяет пароль с учетом блокировок по IP и аккаунту.
+// Неудачи записываются сразу; успешный вход вызывающий фиксирует через
+// RecordLoginSuccess после проверки всех факторов.
+func (s *UserService) Authenticate(ctx context.Context, email, password string, meta SessionMeta) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Authenticate")
+	defer func() { tracing.End(span, err) }()
+
+	if err = s.checkIPThrottle(ctx, email, meta); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
 	
+	log := logger.FromContext(ctx)
 	if user == nil {
+		log.Warn("authentication failed", "reason", "unknown email")
+		s.recordEvent(ctx, entities.NewLoginEvent(nil, email, meta.IP, meta.UserAgent), entities.LoginFailureUnknownEmail)
 		return nil, ErrUserNotFound
 	}
+
+	if err = s.checkAccountLock(ctx, user, meta); err != nil {
+		return nil, err
+	}
 	
 	// В реальном приложении сравниваем хеши паролей
 	if user.Password != password {
+		log.Warn("authentication failed", "reason", "invalid password", "user_id", user.ID)
+		if err = s.RecordLoginFailure(ctx, user, meta, entities.LoginFailureInvalidPassword); err != nil {
+			return nil, err
+		}
 		return nil, ErrInvalidPassword
 	}
 	
+	log.Info("user authenticated", "user_id", user.ID)
+
+	return user, nil
+}
+
+// VerifyPassword повторно проверяет пароль вошедшего пользователя с теми же
+// блокировками и учетом неудач, что при входе
+func (s *UserService) VerifyPassword(ctx context.Context, userID uuid.UUID, password string, meta SessionMeta) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err = s.checkAccountLock(ctx, user, meta); err != nil {
+		return err
+	}
+	if user.Password != password {
+		if err = s.RecordLoginFailure(ctx, user, meta, entities.LoginFailureInvalidPassword); err != nil {
+			return err
+		}
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// UpdateUser меняет email и/или username. Менять данные может сам пользователь
+// или обладатель users:manage. Смена email требует повторной аутентификации
+// и потому проходит только через AccessService.UpdateUser.
+func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, update UserUpdate) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUser", attribute.String("user.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if !auth.CanActOn(ctx, id, string(entities.PermUsersManage)) {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := newAuditUser(user)
+
+	if update.Email != nil && !strings.EqualFold(*update.Email, user.Email) {
+		// До проверки занятости адреса: иначе по ответу можно перебирать чужие email
+		if !update.reauthenticated {
+			return nil, ErrReauthRequired
+		}
+		existing, err := s.userRepo.FindByEmail(ctx, *update.Email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.ID != user.ID {
+			return nil, ErrEmailExists
+		}
+		user.Email = *update.Email
+		user.EmailVerifiedAt = nil
+	}
+
+	if update.Username != nil && *update.Username != user.Username {
+		existing, err := s.userRepo.FindByUsername(ctx, *update.Username)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.ID != user.ID {
+			return nil, ErrUsernameExists
+		}
+		user.Username = *update.Username
+	}
+
+	user.UpdatedAt = time.Now()
+	event, err := s.audit.Event(ctx, entities.AuditUserUpdated, entities.AuditTargetUser, user.ID.String(), before, newAuditUser(user))
+	if err != nil {
+		return nil, err
+	}
+	if err = s.userRepo.Update(ctx, user, event); err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return nil, ErrEmailExists
+		}
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("user updated", "target_user_id", user.ID)
 	return user, nil
-}
\ No newline at end of file
+}
+
+// DeleteUser удаляет пользователя и его кошельки. Пользователя с ненулевым
+// балансом хотя бы на одном кошельке удалить нельзя: сначала средства
+// нужно вывести.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser", attribute.String("user.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if !auth.CanActOn(ctx, id, string(entities.PermUsersManage)) {
+		return ErrForbidden
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	event, err := s.audit.Event(ctx, entities.AuditUserDeleted, entities.AuditTargetUser, id.String(), newAuditUser(user), nil)
+	if err != nil {
+		return err
+	}
+	if err = s.userRepo.DeleteWithWallets(ctx, id, event); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("user deleted", "target_user_id", id)
+	return nil
+}
+
+// ListUsers возвращает страницу пользователей и общее число совпадений
+func (s *UserService) ListUsers(ctx context.Context, filter repositories.UserFilter) (_ []*entities.User, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultUsersPageSize
+	}
+	if filter.Limit > MaxUsersPageSize {
+		filter.Limit = MaxUsersPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return s.userRepo.Search(ctx, filter)
+}
+
+// SetRole назначает пользователю роль. Свою роль поменять нельзя, чтобы
+// последний администратор не лишил себя доступа по ошибке. Роль и разрешения
+// зашиты в access-токен, поэтому сессии пользователя отзываются: иначе
+// понижение роли не действовало бы до истечения токена.
+func (s *UserService) SetRole(ctx context.Context, id uuid.UUID, role entities.Role) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SetRole",
+		attribute.String("user.id", id.String()),
+		attribute.String("user.role", string(role)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok || !claims.HasPermission(string(entities.PermUsersManage)) || claims.UserID == id {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	previous := user.Role
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	event, err := s.audit.Event(ctx, entities.AuditUserRoleChanged, entities.AuditTargetUser, user.ID.String(),
+		map[string]entities.Role{"role": previous},
+		map[string]entities.Role{"role": role},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.userRepo.Update(ctx, user, event); err != nil {
+		return nil, err
+	}
+	if _, err = s.sessions.RevokeAll(ctx, user.ID, RevokeReasonRoleChanged); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("user role changed", "target_user_id", user.ID, "from", previous, "to", role)
+	return user, nil
+}
+
+// SetTier назначает пользователю тариф комиссий. Свой тариф поменять нельзя:
+// администратор не должен снижать комиссии самому себе. Сессии отзываются,
+// как при смене роли: клиенты перечитывают профиль с новым тарифом.
+func (s *UserService) SetTier(ctx context.Context, id uuid.UUID, tier string) (_ *entities.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SetTier",
+		attribute.String("user.id", id.String()),
+		attribute.String("user.tier", tier),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if !validTier(tier) {
+		return nil, ErrInvalidTier
+	}
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok || !claims.HasPermission(string(entities.PermUsersManage)) || claims.UserID == id {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Tier == tier {
+		return user, nil
+	}
+
+	previous := user.Tier
+	user.Tier = tier
+	user.UpdatedAt = time.Now()
+	event, err := s.audit.Event(ctx, entities.AuditUserTierChanged, entities.AuditTargetUser, user.ID.String(),
+		map[string]string{"tier": previous},
+		map[string]string{"tier": tier},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.userRepo.Update(ctx, user, event); err != nil {
+		return nil, err
+	}
+	if _, err = s.sessions.RevokeAll(ctx, user.ID, RevokeReasonTierChanged); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("user tier changed", "target_user_id", user.ID, "from", previous, "to", tier)
+	return user, nil
+}
+
+func validTier(tier string) bool {
+	if len(tier) == 0 || len(tier) > 32 {
+		return false
+	}
+	for _, r := range tier {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+// userFixture - сервисы пользователей и сессий в одном хранилище в памяти
+type userFixture struct {
+	store    *memory.Store
+	users    *services.UserService
+	sessions *services.SessionService
+	denylist *fakeDenylist
+}
+
+func newUserFixture() *userFixture {
+	store := memory.NewStore()
+	f := &userFixture{store: store, denylist: newFakeDenylist()}
+	f.sessions = services.NewSessionService(memory.NewSessionRepository(store), memory.NewUserRepository(store),
+		auth.NewTokenManager("test secret", time.Minute), f.denylist, time.Hour)
+	f.users = services.NewUserService(memory.NewUserRepository(store), memory.NewLoginRepository(store), f.sessions,
+		services.LoginPolicy{}, services.NewAuditService(memory.NewAuditRepository(store)))
+	return f
+}
+
+func TestRoleAndTierChangesRevokeSessions(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ctx context.Context, f *userFixture, user *entities.User) error
+		reason string
+	}{
+		{"role", func(ctx context.Context, f *userFixture, user *entities.User) error {
+			_, err := f.users.SetRole(ctx, user.ID, entities.RoleSupport)
+			return err
+		}, services.RevokeReasonRoleChanged},
+		{"tier", func(ctx context.Context, f *userFixture, user *entities.User) error {
+			_, err := f.users.SetTier(ctx, user.ID, "premium")
+			return err
+		}, services.RevokeReasonTierChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserFixture()
+			user := createUser(t, f.store)
+			pair, err := f.sessions.Start(context.Background(), user, services.SessionMeta{IP: "192.0.2.1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := adminContext(createUser(t, f.store).ID, entities.PermUsersManage)
+			if err := tt.change(ctx, f, user); err != nil {
+				t.Fatal(err)
+			}
+
+			// Токен со старой ролью отклоняется сразу, а не по истечении
+			if denied, _ := f.denylist.Contains(ctx, pair.SessionID); !denied {
+				t.Fatal("session is not in the denylist")
+			}
+			session, err := memory.NewSessionRepository(f.store).FindByID(ctx, pair.SessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.RevokeReason == nil || *session.RevokeReason != tt.reason {
+				t.Fatalf("revoke reason = %v, want %s", session.RevokeReason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRejectsTakenEmailAndUsername(t *testing.T) {
+	f := newUserFixture()
+	user, other := createUser(t, f.store), createUser(t, f.store)
+	access := services.NewAccessService(f.users, nil, nil, nil, nil, 0)
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{UserID: user.ID})
+	reauth := services.Reauthentication{Password: "secret"}
+
+	email := other.Email
+	if _, err := access.UpdateUser(ctx, user.ID, services.UserUpdate{Email: &email}, reauth); !errors.Is(err, services.ErrEmailExists) {
+		t.Fatalf("taken email: got %v, want %v", err, services.ErrEmailExists)
+	}
+	// username, в отличие от email, сравнивается без учета регистра
+	username := strings.ToUpper(other.Username)
+	if _, err := access.UpdateUser(ctx, user.ID, services.UserUpdate{Username: &username}, reauth); !errors.Is(err, services.ErrUsernameExists) {
+		t.Fatalf("taken username: got %v, want %v", err, services.ErrUsernameExists)
+	}
+	// Неудачная смена не меняет и второе поле
+	username = "renamed-" + uuid.NewString()
+	if _, err := access.UpdateUser(ctx, user.ID, services.UserUpdate{Email: &email, Username: &username}, reauth); !errors.Is(err, services.ErrEmailExists) {
+		t.Fatalf("taken email with new username: got %v, want %v", err, services.ErrEmailExists)
+	}
+	stored, err := f.users.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Email != user.Email || stored.Username != user.Username {
+		t.Fatalf("user = %s/%s, want unchanged %s/%s", stored.Email, stored.Username, user.Email, user.Username)
+	}
+
+	// Свои же email в другом регистре и username - не конфликт
+	email = strings.ToUpper(user.Email)
+	updated, err := access.UpdateUser(ctx, user.ID, services.UserUpdate{Email: &email, Username: &user.Username}, services.Reauthentication{})
+	if err != nil {
+		t.Fatalf("own email and username: %v", err)
+	}
+	if updated.Email != user.Email {
+		t.Fatalf("email = %s, want unchanged %s", updated.Email, user.Email)
+	}
+}
+
+func TestDeleteUserWithFunds(t *testing.T) {
+	f := newUserFixture()
+	user := createUser(t, f.store)
+	wallets := services.NewWalletService(memory.NewWalletRepository(f.store), nil, nil, nil)
+	empty, funded := createWallet(t, wallets, user.ID), createWallet(t, wallets, user.ID)
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{UserID: user.ID})
+	if _, err := wallets.ProcessOperation(ctx, funded, entities.OperationTypeDeposit, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Чужого пользователя без users:manage удалить нельзя
+	if err := f.users.DeleteUser(ctx, createUser(t, f.store).ID); !errors.Is(err, services.ErrForbidden) {
+		t.Fatalf("other user: got %v, want %v", err, services.ErrForbidden)
+	}
+
+	// Ненулевой баланс на любом кошельке запрещает удаление целиком
+	if err := f.users.DeleteUser(ctx, user.ID); !errors.Is(err, services.ErrUserHasFunds) {
+		t.Fatalf("with funds: got %v, want %v", err, services.ErrUserHasFunds)
+	}
+	if _, err := f.users.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("user after refused delete: %v", err)
+	}
+	for _, id := range []uuid.UUID{empty, funded} {
+		if _, err := wallets.GetWallet(ctx, id); err != nil {
+			t.Fatalf("wallet %s after refused delete: %v", id, err)
+		}
+	}
+
+	if _, err := wallets.ProcessOperation(ctx, funded, entities.OperationTypeWithdraw, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.users.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("without funds: %v", err)
+	}
+	if _, err := f.users.GetUser(ctx, user.ID); !errors.Is(err, services.ErrUserNotFound) {
+		t.Fatalf("deleted user: got %v, want %v", err, services.ErrUserNotFound)
+	}
+	for _, id := range []uuid.UUID{empty, funded} {
+		if _, err := wallets.GetWallet(ctx, id); !errors.Is(err, services.ErrWalletNotFound) {
+			t.Fatalf("wallet %s of deleted user: got %v, want %v", id, err, services.ErrWalletNotFound)
+		}
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	f := newUserFixture()
+	ctx := context.Background()
+	for _, name := range []string{"carol", "alice", "bob", "alan", "dave"} {
+		if _, err := f.users.CreateUser(ctx, name+"@example.com", name, "secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter repositories.UserFilter
+		want   []string
+		total  int
+	}{
+		{"first page", repositories.UserFilter{SortBy: "username", Limit: 2}, []string{"alan", "alice"}, 5},
+		{"second page", repositories.UserFilter{SortBy: "username", Limit: 2, Offset: 2}, []string{"bob", "carol"}, 5},
+		{"last page", repositories.UserFilter{SortBy: "username", Limit: 2, Offset: 4}, []string{"dave"}, 5},
+		{"past the end", repositories.UserFilter{SortBy: "username", Limit: 2, Offset: 10}, []string{}, 5},
+		{"descending", repositories.UserFilter{SortBy: "email", SortDesc: true, Limit: 3}, []string{"dave", "carol", "bob"}, 5},
+		{"by creation", repositories.UserFilter{SortBy: "created_at"}, []string{"carol", "alice", "bob", "alan", "dave"}, 5},
+		// Префикс ищется без учета регистра; total - число совпадений, а не страницы
+		{"email prefix", repositories.UserFilter{EmailPrefix: "AL", SortBy: "username", Limit: 1}, []string{"alan"}, 2},
+		{"username prefix", repositories.UserFilter{UsernamePrefix: "b", SortBy: "username"}, []string{"bob"}, 1},
+		{"both prefixes", repositories.UserFilter{EmailPrefix: "a", UsernamePrefix: "ali"}, []string{"alice"}, 1},
+		{"no match", repositories.UserFilter{EmailPrefix: "zed"}, []string{}, 0},
+		// Некорректные limit и offset заменяются значениями по умолчанию
+		{"limit above max", repositories.UserFilter{SortBy: "username", Limit: services.MaxUsersPageSize + 1, Offset: -1},
+			[]string{"alan", "alice", "bob", "carol", "dave"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := f.users.ListUsers(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(users))
+			for i, user := range users {
+				got[i] = user.Username
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || total != tt.total {
+				t.Fatalf("got %v of %d, want %v of %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+}
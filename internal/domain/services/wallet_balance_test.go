@@ -0,0 +1,124 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	postgres "walletapitest/internal/infrastructure/database/postgres"
+
+	"github.com/google/uuid"
+)
+
+func TestBalanceAtBeforeWalletCreated(t *testing.T) {
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			owner := entities.NewUser("balance-"+uuid.NewString()+"@example.com", "balance-"+uuid.NewString(), "secret")
+			if err := backend.users.Create(ctx, owner); err != nil {
+				t.Fatal(err)
+			}
+			svc := services.NewWalletService(backend.wallets, nil, nil, nil)
+			wallet := func(amount int64) *entities.Wallet {
+				w, err := svc.CreateWallet(ctx, owner.ID, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := svc.ProcessOperation(ctx, w.ID, entities.OperationTypeDeposit, amount, nil); err != nil {
+					t.Fatal(err)
+				}
+				return w
+			}
+			first := wallet(50)
+			time.Sleep(time.Millisecond)
+			between := time.Now()
+			time.Sleep(time.Millisecond)
+			second := wallet(70)
+
+			// До создания кошелька его баланс нулевой, а в список пользователя он не входит
+			before := first.CreatedAt.Add(-time.Millisecond)
+			balance, err := svc.BalanceAt(ctx, first.ID, before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance.Balance != 0 || balance.OperationID != nil {
+				t.Fatalf("balance before creation = %+v, want 0 without operation", balance)
+			}
+			balances, err := svc.UserBalancesAt(ctx, owner.ID, before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(balances) != 0 {
+				t.Fatalf("user balances before any wallet = %+v, want none", balances)
+			}
+
+			balances, err = svc.UserBalancesAt(ctx, owner.ID, between)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(balances) != 1 || balances[0].WalletID != first.ID || balances[0].Balance != 50 {
+				t.Fatalf("user balances = %+v, want only the first wallet with 50", balances)
+			}
+			if balance, err = svc.BalanceAt(ctx, second.ID, between); err != nil {
+				t.Fatal(err)
+			}
+			if balance.Balance != 0 || balance.OperationID != nil {
+				t.Fatalf("second wallet before creation = %+v, want 0", balance)
+			}
+
+			balances, err = svc.UserBalancesAt(ctx, owner.ID, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(balances) != 2 || balances[0].WalletID != first.ID || balances[1].Balance != 70 {
+				t.Fatalf("user balances now = %+v, want both wallets by creation time", balances)
+			}
+		})
+	}
+}
+
+// Операции одной транзакции получают одно время created_at; последней из
+// них считается операция с большим seq
+func TestBalanceAtSameCreatedAtPostgres(t *testing.T) {
+	db := testDB(t)
+	if db == nil {
+		t.Skipf("%s is not set", envTestDSN)
+	}
+	ctx := context.Background()
+	owner := entities.NewUser("balance-"+uuid.NewString()+"@example.com", "balance-"+uuid.NewString(), "secret")
+	if err := postgres.NewUserRepository(db).Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	svc := services.NewWalletService(postgres.NewWalletRepository(db), nil, nil, nil)
+	wallet, err := svc.CreateWallet(ctx, owner.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last *entities.OperationResult
+	for _, amount := range []int64{10, 20, 30} {
+		if last, err = svc.ProcessOperation(ctx, wallet.ID, entities.OperationTypeDeposit, amount, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	at := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	if _, err := db.ExecContext(ctx, `UPDATE operations SET created_at = $1 WHERE wallet_id = $2`, at, wallet.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err := svc.BalanceAt(ctx, wallet.ID, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != 60 || balance.OperationID == nil || *balance.OperationID != last.Operation.ID {
+		t.Fatalf("balance = %+v, want 60 by the last operation %s", balance, last.Operation.ID)
+	}
+	if balance, err = svc.BalanceAt(ctx, wallet.ID, at.Add(-time.Microsecond)); err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != 0 || balance.OperationID != nil {
+		t.Fatalf("balance before operations = %+v, want 0", balance)
+	}
+}
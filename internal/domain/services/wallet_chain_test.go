@@ -0,0 +1,206 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+
+	"github.com/google/uuid"
+)
+
+// chainRows отдает VerifyOperationsChain операции как есть, в том числе
+// испорченные в обход репозитория, и legacy операций без хеша
+type chainRows struct {
+	repositories.WalletRepository
+	rows   []*entities.Operation
+	legacy int64
+}
+
+func (r *chainRows) ListChainedOperations(ctx context.Context, walletID uuid.UUID, afterSeq int64, limit int) ([]*entities.Operation, error) {
+	operations := []*entities.Operation{}
+	for _, op := range r.rows {
+		if len(operations) == limit {
+			break
+		}
+		if op.Seq != nil && *op.Seq > afterSeq {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+func (r *chainRows) CountLegacyOperations(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	return r.legacy, nil
+}
+
+// operationsChain проводит операции через WalletService и возвращает
+// репозиторий с кошельком и его цепочку по возрастанию seq
+func operationsChain(t *testing.T, amounts ...int64) (repositories.WalletRepository, uuid.UUID, []*entities.Operation) {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	owner := entities.NewUser("chain-"+uuid.NewString()+"@example.com", "chain-"+uuid.NewString(), "secret")
+	if err := memory.NewUserRepository(store).Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	repo := memory.NewWalletRepository(store)
+	svc := services.NewWalletService(repo, nil, nil, nil)
+	wallet, err := svc.CreateWallet(ctx, owner.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range amounts {
+		operationType := entities.OperationTypeDeposit
+		if amount < 0 {
+			operationType, amount = entities.OperationTypeWithdraw, -amount
+		}
+		if _, err := svc.ProcessOperation(ctx, wallet.ID, operationType, amount, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := repo.ListChainedOperations(ctx, wallet.ID, 0, len(amounts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, wallet.ID, rows
+}
+
+// rechain пересчитывает ссылки и хеши начиная с rows[from], как сделал бы
+// тот, кто подделывает историю целиком
+func rechain(rows []*entities.Operation, from int) {
+	for i := from; i < len(rows); i++ {
+		prevHash := entities.GenesisHash
+		if i > 0 {
+			prevHash = *rows[i-1].Hash
+		}
+		rows[i].Chain(int64(i+1), prevHash)
+	}
+}
+
+func TestVerifyOperationsChain(t *testing.T) {
+	amounts := []int64{500, -100, 300, -200, 50}
+	tests := []struct {
+		name   string
+		tamper func(rows []*entities.Operation) []*entities.Operation
+		// brokenAt - 0, если цепочка цела
+		brokenAt int64
+		reason   string
+	}{
+		{"intact", func(rows []*entities.Operation) []*entities.Operation { return rows }, 0, ""},
+		{"amount changed", func(rows []*entities.Operation) []*entities.Operation {
+			rows[2].Amount = 3000
+			return rows
+		}, 3, "content hash does not match"},
+		{"hash recomputed after change", func(rows []*entities.Operation) []*entities.Operation {
+			reason := "refund"
+			rows[2].Reason = &reason
+			hash := rows[2].ComputeHash()
+			rows[2].Hash = &hash
+			return rows
+		}, 4, "previous hash does not match"},
+		// Хеши сходятся, но баланс после операции уже не следует из суммы
+		{"whole chain rewritten", func(rows []*entities.Operation) []*entities.Operation {
+			rows[2].Amount = 3000
+			rechain(rows, 2)
+			return rows
+		}, 3, "balance_after does not follow from the previous operation"},
+		{"hash removed", func(rows []*entities.Operation) []*entities.Operation {
+			rows[1].Hash = nil
+			return rows
+		}, 2, "content hash does not match"},
+		{"row deleted", func(rows []*entities.Operation) []*entities.Operation {
+			return append(rows[:3:3], rows[4:]...)
+		}, 5, "missing operations before this entry"},
+		{"first row deleted", func(rows []*entities.Operation) []*entities.Operation {
+			return rows[1:]
+		}, 2, "missing operations before this entry"},
+		// Операции переставлены вместе с номерами, чтобы не было пропуска
+		{"rows reordered", func(rows []*entities.Operation) []*entities.Operation {
+			rows[1], rows[2] = rows[2], rows[1]
+			seq1, seq2 := int64(2), int64(3)
+			rows[1].Seq, rows[2].Seq = &seq1, &seq2
+			return rows
+		}, 2, "previous hash does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, walletID, rows := operationsChain(t, amounts...)
+			rows = tt.tamper(rows)
+			svc := services.NewWalletService(&chainRows{WalletRepository: repo, rows: rows}, nil, nil, nil)
+
+			result, err := svc.VerifyOperationsChain(context.Background(), walletID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.brokenAt == 0 {
+				if !result.Valid || result.BrokenAt != nil || result.Checked != int64(len(amounts)) {
+					t.Fatalf("result = %+v, want valid chain of %d", result, len(amounts))
+				}
+				return
+			}
+			if result.Valid || result.BrokenAt == nil || *result.BrokenAt != tt.brokenAt || result.Reason != tt.reason {
+				t.Fatalf("result = %+v, want broken at %d: %s", result, tt.brokenAt, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifyOperationsChainLegacy(t *testing.T) {
+	// Цепочка началась, когда на кошельке уже было 1000 от операций без хеша
+	const legacyBalance = 1000
+	repo, walletID, rows := operationsChain(t, 500, -100, 300)
+	for _, op := range rows {
+		op.BalanceAfter += legacyBalance
+	}
+	rechain(rows, 0)
+
+	tests := []struct {
+		name     string
+		legacy   int64
+		tamper   func(rows []*entities.Operation)
+		brokenAt int64
+	}{
+		// Баланс до первой операции цепочки неизвестен и не проверяется
+		{"legacy rows before chain", 2, func([]*entities.Operation) {}, 0},
+		// Без legacy операций цепочка начинается с нулевого баланса
+		{"no legacy rows", 0, func([]*entities.Operation) {}, 1},
+		// Дальше баланс проверяется как обычно
+		{"legacy rows, later balance rewritten", 2, func(rows []*entities.Operation) {
+			rows[1].BalanceAfter += 10
+			rechain(rows, 1)
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := make([]*entities.Operation, len(rows))
+			for i, op := range rows {
+				c := *op
+				tampered[i] = &c
+			}
+			tt.tamper(tampered)
+			svc := services.NewWalletService(&chainRows{WalletRepository: repo, rows: tampered, legacy: tt.legacy}, nil, nil, nil)
+
+			result, err := svc.VerifyOperationsChain(context.Background(), walletID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Legacy != tt.legacy {
+				t.Fatalf("legacy = %d, want %d", result.Legacy, tt.legacy)
+			}
+			if tt.brokenAt == 0 {
+				if !result.Valid || result.Checked != int64(len(rows)) {
+					t.Fatalf("result = %+v, want valid chain of %d", result, len(rows))
+				}
+				return
+			}
+			if result.Valid || result.BrokenAt == nil || *result.BrokenAt != tt.brokenAt ||
+				result.Reason != "balance_after does not follow from the previous operation" {
+				t.Fatalf("result = %+v, want balance mismatch at %d", result, tt.brokenAt)
+			}
+		})
+	}
+}
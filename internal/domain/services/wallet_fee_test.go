@@ -0,0 +1,225 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+
+	"github.com/google/uuid"
+)
+
+// feeFixture - плательщик, получатель и кошелек комиссий на одном хранилище.
+// Комиссия: 1% за списание (не меньше 1), 2 + 0,5% за перевод, пополнения
+// бесплатны.
+type feeFixture struct {
+	svc                       *services.WalletService
+	payer, payee, feeWalletID uuid.UUID
+}
+
+func feeRules() []entities.FeeRule {
+	return []entities.FeeRule{
+		{Name: "withdraw", Operation: string(entities.OperationTypeWithdraw), RateBps: 100, Min: 1},
+		{Name: "transfer", Operation: entities.FeeOperationTransfer, Fixed: 2, RateBps: 50},
+	}
+}
+
+func newFeeFixture(t *testing.T, backend testBackend, funds int64) *feeFixture {
+	t.Helper()
+	ctx := context.Background()
+	owner := entities.NewUser("fees-"+uuid.NewString()+"@example.com", "fees-"+uuid.NewString(), "secret")
+	if err := backend.users.Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	plain := services.NewWalletService(backend.wallets, nil, nil, nil)
+	ids := make([]uuid.UUID, 3)
+	for i := range ids {
+		wallet, err := plain.CreateWallet(ctx, owner.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = wallet.ID
+	}
+	if _, err := plain.ProcessOperation(ctx, ids[0], entities.OperationTypeDeposit, funds, nil); err != nil {
+		t.Fatal(err)
+	}
+	fees := &entities.FeeSchedule{Rules: feeRules(), Wallets: map[string]uuid.UUID{entities.DefaultCurrency: ids[2]}}
+	return &feeFixture{
+		svc:         services.NewWalletService(backend.wallets, nil, fees, nil),
+		payer:       ids[0],
+		payee:       ids[1],
+		feeWalletID: ids[2],
+	}
+}
+
+// expectBalances сверяет балансы плательщика, получателя и кошелька комиссий
+func (f *feeFixture) expectBalances(t *testing.T, payer, payee, fee int64) {
+	t.Helper()
+	for _, w := range []struct {
+		name string
+		id   uuid.UUID
+		want int64
+	}{{"payer", f.payer, payer}, {"payee", f.payee, payee}, {"fee wallet", f.feeWalletID, fee}} {
+		wallet, err := f.svc.GetWallet(context.Background(), w.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wallet.Balance != w.want {
+			t.Errorf("%s balance = %d, want %d", w.name, wallet.Balance, w.want)
+		}
+	}
+}
+
+// checkFeeLegs проверяет, что комиссия fee за операцию sourceID списана с
+// плательщика и зачислена на кошелек комиссий одной суммой
+func (f *feeFixture) checkFeeLegs(t *testing.T, fee *entities.Fee, sourceID uuid.UUID, want int64) {
+	t.Helper()
+	if fee == nil {
+		t.Fatal("no fee charged")
+	}
+	if fee.Amount != want || fee.FeeWalletID != f.feeWalletID || fee.TransferID != entities.FeeTransferID(sourceID) {
+		t.Fatalf("fee = %+v, want %d to %s", fee, want, f.feeWalletID)
+	}
+	for _, leg := range []struct {
+		operation     *entities.Operation
+		walletID      uuid.UUID
+		operationType entities.OperationType
+	}{
+		{fee.Debit, f.payer, entities.OperationTypeWithdraw},
+		{fee.Credit, f.feeWalletID, entities.OperationTypeDeposit},
+	} {
+		op := leg.operation
+		if op == nil || op.WalletID != leg.walletID || op.OperationType != leg.operationType || op.Amount != fee.Amount ||
+			op.TransferID == nil || *op.TransferID != fee.TransferID {
+			t.Fatalf("fee leg = %+v, want %s of %d on %s", op, leg.operationType, fee.Amount, leg.walletID)
+		}
+	}
+}
+
+func TestFeeChargedWithOperation(t *testing.T) {
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFeeFixture(t, backend, 1000)
+
+			result, err := f.svc.ProcessOperation(ctx, f.payer, entities.OperationTypeWithdraw, 300, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.checkFeeLegs(t, result.Fee, result.Operation.ID, 3)
+			f.expectBalances(t, 1000-300-3, 0, 3)
+
+			transfer := entities.NewTransfer(uuid.New(), f.payer, f.payee, 200)
+			if err := f.svc.Transfer(ctx, transfer, ""); err != nil {
+				t.Fatal(err)
+			}
+			f.checkFeeLegs(t, transfer.Fee, transfer.ID, 2+1)
+			f.expectBalances(t, 697-200-3, 200, 3+3)
+		})
+	}
+}
+
+func TestFeeIncludedInBalanceCheck(t *testing.T) {
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFeeFixture(t, backend, 100)
+
+			// Денег хватает на саму операцию, но не на операцию с комиссией
+			if _, err := f.svc.ProcessOperation(ctx, f.payer, entities.OperationTypeWithdraw, 100, nil); !errors.Is(err, services.ErrInsufficientFunds) {
+				t.Fatalf("withdraw: got %v, want %v", err, services.ErrInsufficientFunds)
+			}
+			if err := f.svc.Transfer(ctx, entities.NewTransfer(uuid.New(), f.payer, f.payee, 99), ""); !errors.Is(err, services.ErrInsufficientFunds) {
+				t.Fatalf("transfer: got %v, want %v", err, services.ErrInsufficientFunds)
+			}
+			f.expectBalances(t, 100, 0, 0)
+
+			// Перевод вместе с комиссией ровно на весь баланс проходит
+			transfer := entities.NewTransfer(uuid.New(), f.payer, f.payee, 98)
+			if err := f.svc.Transfer(ctx, transfer, ""); err != nil {
+				t.Fatal(err)
+			}
+			f.checkFeeLegs(t, transfer.Fee, transfer.ID, 2)
+			f.expectBalances(t, 0, 98, 2)
+		})
+	}
+}
+
+func TestFeeRolledBackWithOperation(t *testing.T) {
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFeeFixture(t, backend, 1000)
+			// Кошелька комиссий нет: проводка комиссии не удается
+			svc := services.NewWalletService(backend.wallets, nil, &entities.FeeSchedule{
+				Rules:   feeRules(),
+				Wallets: map[string]uuid.UUID{entities.DefaultCurrency: uuid.New()},
+			}, nil)
+
+			if _, err := svc.ProcessOperation(ctx, f.payer, entities.OperationTypeWithdraw, 300, nil); !errors.Is(err, services.ErrFeeWalletNotConfigured) {
+				t.Fatalf("withdraw: got %v, want %v", err, services.ErrFeeWalletNotConfigured)
+			}
+			if err := svc.Transfer(ctx, entities.NewTransfer(uuid.New(), f.payer, f.payee, 200), ""); !errors.Is(err, services.ErrFeeWalletNotConfigured) {
+				t.Fatalf("transfer: got %v, want %v", err, services.ErrFeeWalletNotConfigured)
+			}
+
+			// Операция и комиссия - одна транзакция: не проведено ничего
+			f.expectBalances(t, 1000, 0, 0)
+			for _, w := range []struct {
+				id   uuid.UUID
+				want int
+			}{{f.payer, 1}, {f.payee, 0}, {f.feeWalletID, 0}} {
+				operations, err := f.svc.GetOperations(ctx, w.id, 10, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(operations) != w.want {
+					t.Errorf("wallet %s has %d operations, want %d", w.id, len(operations), w.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFeeNotChargedOnReplay(t *testing.T) {
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFeeFixture(t, backend, 1000)
+
+			requestID := uuid.New()
+			first, err := f.svc.ProcessOperation(ctx, f.payer, entities.OperationTypeWithdraw, 300, &requestID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replay, err := f.svc.ProcessOperation(ctx, f.payer, entities.OperationTypeWithdraw, 300, &requestID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !replay.Replayed || replay.Operation.ID != first.Operation.ID {
+				t.Fatalf("replay = %+v, want the first operation", replay)
+			}
+			// Повтор возвращает ту же комиссию, а не новую
+			f.checkFeeLegs(t, replay.Fee, first.Operation.ID, 3)
+			if replay.Fee.Debit.ID != first.Fee.Debit.ID {
+				t.Fatalf("replayed fee debit %s, want %s", replay.Fee.Debit.ID, first.Fee.Debit.ID)
+			}
+
+			transferID := uuid.New()
+			for i := 0; i < 2; i++ {
+				transfer := entities.NewTransfer(transferID, f.payer, f.payee, 200)
+				if err := f.svc.Transfer(ctx, transfer, ""); err != nil {
+					t.Fatal(err)
+				}
+				if transfer.Replayed != (i == 1) {
+					t.Fatalf("attempt %d: replayed = %v", i+1, transfer.Replayed)
+				}
+				f.checkFeeLegs(t, transfer.Fee, transferID, 3)
+			}
+
+			f.expectBalances(t, 1000-303-203, 200, 3+3)
+		})
+	}
+}
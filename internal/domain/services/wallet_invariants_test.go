@@ -0,0 +1,451 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/infrastructure/database/migrations"
+	postgres "walletapitest/internal/infrastructure/database/postgres"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Свойства балансов при конкурентных операциях. Каждый сценарий - случайные
+// пополнения, списания, переводы и повторы с теми же ключами идемпотентности,
+// которые несколько горутин проводят одновременно. После сценария:
+//   - ни один баланс не отрицателен;
+//   - баланс равен начальному плюс проведенные операции и комиссии;
+//   - баланс равен сумме истории операций, а хеш-цепочка цела;
+//   - сумма всех балансов меняется только на внешние пополнения и списания;
+//   - операция с одним ключом проведена не больше одного раза.
+//
+// WALLET_TEST_DSN - Postgres для тех же проверок (отдельная база: данные
+// тестов остаются в ней), WALLET_SEED - повтор сценариев,
+// WALLET_STRESS - длительность стресс-режима, например 1m; запускать с -race.
+const (
+	envTestDSN = "WALLET_TEST_DSN"
+	envSeed    = "WALLET_SEED"
+	envStress  = "WALLET_STRESS"
+)
+
+// testBackend - хранилище, на котором проверяются свойства
+type testBackend struct {
+	name    string
+	users   repositories.UserRepository
+	wallets repositories.WalletRepository
+	audit   repositories.AuditRepository
+}
+
+func testBackends(t *testing.T) []testBackend {
+	t.Helper()
+	store := memory.NewStore()
+	backends := []testBackend{{
+		name:    "memory",
+		users:   memory.NewUserRepository(store),
+		wallets: memory.NewWalletRepository(store),
+		audit:   memory.NewAuditRepository(store),
+	}}
+
+	db := testDB(t)
+	if db == nil {
+		t.Logf("%s is not set, Postgres is skipped", envTestDSN)
+		return backends
+	}
+	return append(backends, testBackend{
+		name:    "postgres",
+		users:   postgres.NewUserRepository(db),
+		wallets: postgres.NewWalletRepository(db),
+		audit:   postgres.NewAuditRepository(db),
+	})
+}
+
+// testDB подключается к WALLET_TEST_DSN и применяет миграции; nil - не задан
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv(envTestDSN)
+	if dsn == "" {
+		return nil
+	}
+	ctx := context.Background()
+	db, err := sqlx.ConnectContext(ctx, "postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", envTestDSN, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(32)
+	if _, err := migrations.Up(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func baseSeed(t *testing.T) int64 {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	if raw := os.Getenv(envSeed); raw != "" {
+		var err error
+		if seed, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			t.Fatalf("%s: %v", envSeed, err)
+		}
+	}
+	t.Logf("seed %d (set %s to repeat)", seed, envSeed)
+	return seed
+}
+
+// scenarioSize - границы случайного сценария
+type scenarioSize struct {
+	maxWallets, maxWorkers, maxSteps int
+}
+
+type stepKind int
+
+const (
+	stepDeposit stepKind = iota
+	stepWithdraw
+	stepTransfer
+)
+
+// step - операция сценария; key - ключ идемпотентности или ID перевода.
+// Повтор - копия другого шага с тем же key, возможно из другой горутины.
+type step struct {
+	kind     stepKind
+	from, to int
+	amount   int64
+	key      uuid.UUID
+}
+
+type scenario struct {
+	seed    int64
+	wallets int
+	initial []int64
+	fees    bool
+	workers [][]step
+}
+
+func newScenario(seed int64, size scenarioSize) scenario {
+	rng := rand.New(rand.NewSource(seed))
+	sc := scenario{seed: seed, wallets: 2 + rng.Intn(size.maxWallets-1), fees: rng.Intn(2) == 0}
+	for i := 0; i < sc.wallets; i++ {
+		sc.initial = append(sc.initial, rng.Int63n(1000))
+	}
+
+	var generated []step
+	workers := 2 + rng.Intn(size.maxWorkers-1)
+	for w := 0; w < workers; w++ {
+		steps := make([]step, 1+rng.Intn(size.maxSteps))
+		for i := range steps {
+			if len(generated) > 0 && rng.Intn(100) < 15 {
+				steps[i] = generated[rng.Intn(len(generated))]
+				continue
+			}
+			s := step{kind: stepKind(rng.Intn(3)), from: rng.Intn(sc.wallets), key: uuid.New()}
+			// Крупные суммы чаще упираются в нехватку средств
+			if rng.Intn(10) == 0 {
+				s.amount = 1 + rng.Int63n(3000)
+			} else {
+				s.amount = 1 + rng.Int63n(200)
+			}
+			if s.kind == stepTransfer {
+				s.to = (s.from + 1 + rng.Intn(sc.wallets-1)) % sc.wallets
+			}
+			steps[i] = s
+			generated = append(generated, s)
+		}
+		sc.workers = append(sc.workers, steps)
+	}
+	return sc
+}
+
+// testFees - комиссии на все виды операций, чтобы проверки затрагивали и их
+func testFees(feeWallet uuid.UUID) *entities.FeeSchedule {
+	return &entities.FeeSchedule{
+		Rules: []entities.FeeRule{
+			{Name: "deposit", Operation: string(entities.OperationTypeDeposit), Fixed: 1},
+			{Name: "withdraw", Operation: string(entities.OperationTypeWithdraw), RateBps: 100, Min: 1},
+			{Name: "transfer", Operation: entities.FeeOperationTransfer, Fixed: 2, RateBps: 50},
+		},
+		Wallets: map[string]uuid.UUID{entities.DefaultCurrency: feeWallet},
+	}
+}
+
+// ledger - изменения, которые сценарий должен был внести. Индекс кошелька
+// комиссий - последний.
+type ledger struct {
+	deltas []int64
+	// external - пополнения минус списания; переводы и комиссии сумму
+	// балансов не меняют
+	external int64
+	applied  map[uuid.UUID]int
+}
+
+func newLedger(wallets int) *ledger {
+	return &ledger{deltas: make([]int64, wallets+1), applied: make(map[uuid.UUID]int)}
+}
+
+func (l *ledger) merge(other *ledger) {
+	for i, d := range other.deltas {
+		l.deltas[i] += d
+	}
+	l.external += other.external
+	for key, n := range other.applied {
+		l.applied[key] += n
+	}
+}
+
+func (l *ledger) charge(payer int, fee *entities.Fee) {
+	if fee == nil {
+		return
+	}
+	l.deltas[payer] -= fee.Amount
+	l.deltas[len(l.deltas)-1] += fee.Amount
+}
+
+// runScenario создает кошельки, проводит сценарий и проверяет свойства
+func runScenario(t *testing.T, backend testBackend, sc scenario) {
+	t.Helper()
+	ctx := context.Background()
+
+	owner := entities.NewUser(fmt.Sprintf("invariants-%s@example.com", uuid.NewString()), "invariants-"+uuid.NewString(), "secret")
+	if err := backend.users.Create(ctx, owner); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	plain := services.NewWalletService(backend.wallets, services.NewAuditService(backend.audit), nil, nil)
+	ids := make([]uuid.UUID, sc.wallets+1)
+	for i := range ids {
+		wallet, err := plain.CreateWallet(ctx, owner.ID, "")
+		if err != nil {
+			t.Fatalf("create wallet: %v", err)
+		}
+		ids[i] = wallet.ID
+	}
+	feeIndex := sc.wallets
+
+	svc := plain
+	if sc.fees {
+		svc = services.NewWalletService(backend.wallets, services.NewAuditService(backend.audit), testFees(ids[feeIndex]), nil)
+	}
+	for i, amount := range sc.initial {
+		if amount == 0 {
+			continue
+		}
+		if _, err := svc.ProcessOperation(ctx, ids[i], entities.OperationTypeDeposit, amount, nil); err != nil {
+			t.Fatalf("fund wallet: %v", err)
+		}
+	}
+	before := balances(t, svc, ids)
+
+	start := make(chan struct{})
+	ledgers := make([]*ledger, len(sc.workers))
+	var wg sync.WaitGroup
+	for w, steps := range sc.workers {
+		ledgers[w] = newLedger(sc.wallets)
+		wg.Add(1)
+		go func(l *ledger, steps []step) {
+			defer wg.Done()
+			<-start
+			for _, s := range steps {
+				if err := execute(ctx, svc, ids, l, s); err != nil {
+					t.Errorf("seed %d: %v", sc.seed, err)
+					return
+				}
+			}
+		}(ledgers[w], steps)
+	}
+	close(start)
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	total := newLedger(sc.wallets)
+	for _, l := range ledgers {
+		total.merge(l)
+	}
+	checkInvariants(t, backend, svc, ids, before, total, sc.seed)
+}
+
+// execute проводит шаг и записывает в l то, что он изменил. Нехватка средств -
+// допустимый отказ; остальные ошибки - нарушение.
+func execute(ctx context.Context, svc *services.WalletService, ids []uuid.UUID, l *ledger, s step) error {
+	switch s.kind {
+	case stepTransfer:
+		transfer := entities.NewTransfer(s.key, ids[s.from], ids[s.to], s.amount)
+		err := svc.Transfer(ctx, transfer, "invariants")
+		if errors.Is(err, services.ErrInsufficientFunds) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("transfer %s: %w", s.key, err)
+		}
+		if transfer.Replayed {
+			return nil
+		}
+		l.deltas[s.from] -= s.amount
+		l.deltas[s.to] += s.amount
+		l.charge(s.from, transfer.Fee)
+		l.applied[s.key]++
+
+	default:
+		operationType, sign := entities.OperationTypeDeposit, int64(1)
+		if s.kind == stepWithdraw {
+			operationType, sign = entities.OperationTypeWithdraw, -1
+		}
+		key := s.key
+		result, err := svc.ProcessOperation(ctx, ids[s.from], operationType, s.amount, &key)
+		if errors.Is(err, services.ErrInsufficientFunds) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", operationType, s.key, err)
+		}
+		if result.Operation.Amount != s.amount || result.Operation.WalletID != ids[s.from] {
+			return fmt.Errorf("%s %s: result does not match the request", operationType, s.key)
+		}
+		if result.Replayed {
+			return nil
+		}
+		l.deltas[s.from] += sign * s.amount
+		l.external += sign * s.amount
+		l.charge(s.from, result.Fee)
+		l.applied[s.key]++
+	}
+	return nil
+}
+
+func balances(t *testing.T, svc *services.WalletService, ids []uuid.UUID) []int64 {
+	t.Helper()
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		wallet, err := svc.GetWallet(context.Background(), id)
+		if err != nil {
+			t.Fatalf("get wallet: %v", err)
+		}
+		out[i] = wallet.Balance
+	}
+	return out
+}
+
+func checkInvariants(t *testing.T, backend testBackend, svc *services.WalletService, ids []uuid.UUID, before []int64, l *ledger, seed int64) {
+	t.Helper()
+	ctx := context.Background()
+	after := balances(t, svc, ids)
+
+	var sumBefore, sumAfter int64
+	for i, id := range ids {
+		sumBefore += before[i]
+		sumAfter += after[i]
+		if after[i] < 0 {
+			t.Errorf("seed %d: wallet %d balance %d is negative", seed, i, after[i])
+		}
+		if want := before[i] + l.deltas[i]; after[i] != want {
+			t.Errorf("seed %d: wallet %d balance %d, want %d from the applied operations", seed, i, after[i], want)
+		}
+
+		historySum, last, lowest, err := history(ctx, backend.wallets, id)
+		if err != nil {
+			t.Fatalf("list operations: %v", err)
+		}
+		if lowest < 0 {
+			t.Errorf("seed %d: wallet %d history went down to %d", seed, i, lowest)
+		}
+		if historySum != after[i] || last != after[i] {
+			t.Errorf("seed %d: wallet %d balance %d, operations sum %d, last balance_after %d", seed, i, after[i], historySum, last)
+		}
+		chain, err := svc.VerifyOperationsChain(ctx, id)
+		if err != nil {
+			t.Fatalf("verify chain: %v", err)
+		}
+		if !chain.Valid {
+			t.Errorf("seed %d: wallet %d chain broken at %d: %s", seed, i, *chain.BrokenAt, chain.Reason)
+		}
+	}
+	if sumAfter != sumBefore+l.external {
+		t.Errorf("seed %d: total balance %d, want %d (%d before, %d external)", seed, sumAfter, sumBefore+l.external, sumBefore, l.external)
+	}
+	for key, n := range l.applied {
+		if n > 1 {
+			t.Errorf("seed %d: operation %s applied %d times", seed, key, n)
+		}
+	}
+}
+
+// history - сумма операций кошелька со знаком, balance_after последней и
+// наименьший balance_after по пути
+func history(ctx context.Context, repo repositories.WalletRepository, walletID uuid.UUID) (sum, last, lowest int64, err error) {
+	var afterSeq int64
+	for {
+		operations, err := repo.ListChainedOperations(ctx, walletID, afterSeq, 1000)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, op := range operations {
+			sum += op.SignedAmount()
+			last = op.BalanceAfter
+			lowest = min(lowest, op.BalanceAfter)
+			afterSeq = *op.Seq
+		}
+		if len(operations) < 1000 {
+			return sum, last, lowest, nil
+		}
+	}
+}
+
+func TestWalletBalanceInvariants(t *testing.T) {
+	scenarios := 30
+	if testing.Short() {
+		scenarios = 5
+	}
+	size := scenarioSize{maxWallets: 5, maxWorkers: 6, maxSteps: 40}
+	seed := baseSeed(t)
+	for _, backend := range testBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			for i := 0; i < scenarios && !t.Failed(); i++ {
+				runScenario(t, backend, newScenario(seed+int64(i), size))
+			}
+		})
+	}
+}
+
+// TestWalletBalanceInvariantsStress гоняет крупные сценарии с высокой
+// конкуренцией за кошельки, пока не истечет WALLET_STRESS:
+//
+//	WALLET_STRESS=1m go test -race -run Stress ./internal/domain/services/
+func TestWalletBalanceInvariantsStress(t *testing.T) {
+	raw := os.Getenv(envStress)
+	if raw == "" {
+		t.Skipf("set %s to a duration to run the stress mode", envStress)
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		t.Fatalf("%s: %v", envStress, err)
+	}
+	if !raceEnabled {
+		t.Logf("race detector is off; run with -race to catch data races")
+	}
+
+	size := scenarioSize{maxWallets: 8, maxWorkers: 16, maxSteps: 200}
+	seed := baseSeed(t)
+	backends := testBackends(t)
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			end := time.Now().Add(duration / time.Duration(len(backends)))
+			runs := 0
+			for ; time.Now().Before(end) && !t.Failed(); runs++ {
+				runScenario(t, backend, newScenario(seed+int64(runs), size))
+			}
+			t.Logf("%d scenarios", runs)
+		})
+	}
+}
@@ -3,57 +3,212 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 	"walletapitest/internal/domain/entities"
 	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
-	ErrWalletNotFound    = errors.New("wallet not found")
-	ErrInsufficientFunds = errors.New("insufficient funds")
-	ErrInvalidOperation  = errors.New("invalid operation type")
+	ErrWalletNotFound    = repositories.ErrWalletNotFound
+	ErrInsufficientFunds = repositories.ErrInsufficientFunds
+	ErrWalletFrozen      = repositories.ErrWalletFrozen
+	ErrInvalidOperation  = repositories.ErrInvalidOperation
 	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrReasonRequired    = errors.New("reason is required")
+	ErrTransferMismatch  = repositories.ErrTransferMismatch
+	ErrSameWallet        = errors.New("cannot transfer to the same wallet")
+	ErrCurrencyMismatch  = repositories.ErrCurrencyMismatch
+	ErrInvalidCurrency   = errors.New("currency must be a three-letter ISO 4217 code")
+	// ErrFeeWalletNotConfigured - ошибка конфигурации комиссий, а не запроса
+	ErrFeeWalletNotConfigured = repositories.ErrFeeWalletNotConfigured
+	ErrRequestMismatch        = repositories.ErrRequestMismatch
+	ErrBalanceFeedClosed      = repositories.ErrBalanceFeedClosed
 )
 
+const (
+	DefaultOperationsPageSize = 50
+	MaxOperationsPageSize     = 500
+
+	chainVerifyBatch = 1000
+	statementBatch   = 500
+)
+
+var ErrInvalidPeriod = errors.New("period start must not be after its end")
+
+// StatementWriter выводит выписку по мере чтения операций: заголовок,
+// строки по одной и итоги. Реализации не должны накапливать строки.
+type StatementWriter interface {
+	Begin(statement *entities.Statement) error
+	Line(line *entities.StatementLine) error
+	End(statement *entities.Statement) error
+}
+
 type WalletService struct {
 	walletRepo repositories.WalletRepository
+	audit      *AuditService
+	// fees - комиссии за пополнения, списания и переводы; nil - без комиссий
+	fees *entities.FeeSchedule
+	// balances - изменения балансов для WatchBalance
+	balances repositories.BalanceFeed
 }
 
-func NewWalletService(walletRepo repositories.WalletRepository) *WalletService {
+func NewWalletService(
+	walletRepo repositories.WalletRepository,
+	audit *AuditService,
+	fees *entities.FeeSchedule,
+	balances repositories.BalanceFeed,
+) *WalletService {
 	return &WalletService{
 		walletRepo: walletRepo,
+		audit:      audit,
+		fees:       fees,
+		balances:   balances,
 	}
 }
 
+// ProcessOperation проводит пополнение или списание и возвращает операцию
+// и комиссию за нее. requestID (nil - без него) - ключ идемпотентности:
+// повтор с тем же ключом возвращает уже проведенную операцию с Replayed.
 func (s *WalletService) ProcessOperation(
 	ctx context.Context,
 	walletID uuid.UUID,
 	operationType entities.OperationType,
 	amount int64,
-) error {
+	requestID *uuid.UUID,
+) (_ *entities.OperationResult, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.ProcessOperation",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("operation.type", string(operationType)),
+		attribute.Int64("operation.amount", amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	if amount <= 0 {
-		return ErrInvalidAmount
+		return nil, ErrInvalidAmount
 	}
 
-	err := s.walletRepo.ProcessOperationAtomic(ctx, walletID, operationType, amount)
-	
+	log := logger.FromContext(ctx)
+
+	result, err := s.walletRepo.ProcessOperationAtomic(ctx, walletID, operationType, amount, requestID, s.fees)
+
 	if err != nil {
-		switch err {
-		case ErrWalletNotFound:
-			return ErrWalletNotFound
-		case ErrInsufficientFunds:
-			return ErrInsufficientFunds
-		case ErrInvalidOperation:
-			return ErrInvalidOperation
+		if errors.Is(err, ErrFeeWalletNotConfigured) {
+			log.Error("wallet operation rejected: fee configuration is incomplete", "operation_type", operationType, "error", err)
+		} else {
+			log.Warn("wallet operation failed", "operation_type", operationType, "amount", amount, "error", err)
 		}
+		return nil, err
+	}
+
+	if result.Replayed {
+		log.Info("wallet operation replayed", "operation_id", result.Operation.ID)
+		return result, nil
+	}
+	var feeAmount int64
+	if result.Fee != nil {
+		feeAmount = result.Fee.Amount
+	}
+	log.Info("wallet operation processed", "operation_type", operationType, "amount", amount, "fee", feeAmount)
+	return result, nil
+}
+
+// isOperationRejection - отказ в самой операции, а не сбой: повтор даст тот же результат
+func isOperationRejection(err error) bool {
+	return errors.Is(err, ErrWalletNotFound) || errors.Is(err, ErrInsufficientFunds) ||
+		errors.Is(err, ErrWalletFrozen) || errors.Is(err, ErrInvalidOperation) || errors.Is(err, ErrInvalidAmount)
+}
+
+// Replay возвращает результат операции, уже проведенной с requestID;
+// nil - операции с этим ключом еще не было. Та же операция с другими
+// параметрами - ErrRequestMismatch.
+func (s *WalletService) Replay(
+	ctx context.Context,
+	walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	requestID uuid.UUID,
+) (_ *entities.OperationResult, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.Replay",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("operation.idempotency_key", requestID.String()),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	result, err := s.walletRepo.FindRequest(ctx, walletID, operationType, amount, requestID)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("wallet operation replayed", "operation_id", result.Operation.ID)
+	return result, nil
+}
+
+// ProcessBatch проводит операции пакета в одной транзакции: все или ни одной.
+// Отказ операции возвращается как *repositories.BatchItemError.
+func (s *WalletService) ProcessBatch(ctx context.Context, items []*entities.BatchItem) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.ProcessBatch", attribute.Int("batch.items", len(items)))
+	defer func() { tracing.End(span, err) }()
+
+	for _, item := range items {
+		if item.Amount <= 0 {
+			return &repositories.BatchItemError{Index: item.Index, Err: ErrInvalidAmount}
+		}
+	}
+	return s.walletRepo.ProcessBatchAtomic(ctx, items, s.fees)
+}
+
+// Transfer переводит средства между кошельками. transfer.ID - ключ
+// идемпотентности: повтор уже проведенного перевода возвращает его операции
+// с Replayed и балансы не меняет, поэтому при временной ошибке перевод
+// можно безопасно повторить с тем же ID.
+func (s *WalletService) Transfer(ctx context.Context, transfer *entities.Transfer, reason string) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.Transfer",
+		attribute.String("transfer.id", transfer.ID.String()),
+		attribute.Int64("transfer.amount", transfer.Amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if transfer.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if transfer.FromWalletID == transfer.ToWalletID {
+		return ErrSameWallet
+	}
+
+	var reasonPtr *string
+	if reason = strings.TrimSpace(reason); reason != "" {
+		reasonPtr = &reason
+	}
+	if err = s.walletRepo.TransferAtomic(ctx, transfer, reasonPtr, s.fees); err != nil {
+		logger.FromContext(ctx).Warn("transfer rejected", "transfer_id", transfer.ID, "error", err)
 		return err
 	}
-	
+
+	var feeAmount int64
+	if transfer.Fee != nil {
+		feeAmount = transfer.Fee.Amount
+	}
+
+	logger.FromContext(ctx).Info("transfer completed",
+		"transfer_id", transfer.ID,
+		"from_wallet_id", transfer.FromWalletID,
+		"to_wallet_id", transfer.ToWalletID,
+		"amount", transfer.Amount,
+		"fee", feeAmount,
+		"replayed", transfer.Replayed,
+	)
 	return nil
 }
 
-func (s *WalletService) GetWallet(ctx context.Context, walletID uuid.UUID) (*entities.Wallet, error) {
+func (s *WalletService) GetWallet(ctx context.Context, walletID uuid.UUID) (_ *entities.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.GetWallet", attribute.String("wallet.id", walletID.String()))
+	defer func() { tracing.End(span, err) }()
+
 	wallet, err := s.walletRepo.FindByID(ctx, walletID)
 	if err != nil {
 		return nil, err
@@ -66,16 +221,325 @@ func (s *WalletService) GetWallet(ctx context.Context, walletID uuid.UUID) (*ent
 	return wallet, nil
 }
 
-func (s *WalletService) CreateWallet(ctx context.Context, userID uuid.UUID) (*entities.Wallet, error) {
-	wallet := entities.NewWallet(userID)
+// CreateWallet создает кошелек в валюте currency (пусто - DefaultCurrency)
+func (s *WalletService) CreateWallet(ctx context.Context, userID uuid.UUID, currency string) (_ *entities.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.CreateWallet", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if currency == "" {
+		currency = entities.DefaultCurrency
+	}
+	if !validCurrency(currency) {
+		return nil, ErrInvalidCurrency
+	}
+	wallet := entities.NewWallet(userID, currency)
 
-	if err := s.walletRepo.Create(ctx, wallet); err != nil {
+	if err = s.walletRepo.Create(ctx, wallet); err != nil {
 		return nil, err
 	}
 
+	logger.FromContext(ctx).Info("wallet created", "wallet_id", wallet.ID)
+
 	return wallet, nil
 }
 
-func (s *WalletService) GetUserWallets(ctx context.Context, userID uuid.UUID) ([]*entities.Wallet, error) {
+// validCurrency - три заглавные латинские буквы, как в ISO 4217
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// GetWallets возвращает найденные кошельки из ids по id
+func (s *WalletService) GetWallets(ctx context.Context, ids []uuid.UUID) (_ map[uuid.UUID]*entities.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.GetWallets", attribute.Int("wallet.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+
+	wallets, err := s.walletRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*entities.Wallet, len(wallets))
+	for _, wallet := range wallets {
+		byID[wallet.ID] = wallet
+	}
+	return byID, nil
+}
+
+func (s *WalletService) GetUserWallets(ctx context.Context, userID uuid.UUID) (_ []*entities.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.GetUserWallets", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
 	return s.walletRepo.FindByUserID(ctx, userID)
 }
+
+// SetStatus замораживает или размораживает кошелек
+func (s *WalletService) SetStatus(ctx context.Context, walletID uuid.UUID, status entities.WalletStatus) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.SetStatus",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("wallet.status", string(status)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	event, err := s.audit.Event(ctx, entities.AuditWalletStatus, entities.AuditTargetWallet, walletID.String(),
+		map[string]entities.WalletStatus{"status": wallet.Status},
+		map[string]entities.WalletStatus{"status": status},
+	)
+	if err != nil {
+		return err
+	}
+	if err = s.walletRepo.SetStatus(ctx, walletID, status, event); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("wallet status changed", "status", status)
+	return nil
+}
+
+// Adjust - ручная корректировка баланса администратором. Положительная сумма
+// зачисляется, отрицательная списывается; причина обязательна.
+func (s *WalletService) Adjust(ctx context.Context, walletID uuid.UUID, amount int64, reason string) (_ *entities.Operation, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.Adjust",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.Int64("operation.amount", amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if amount == 0 {
+		return nil, ErrInvalidAmount
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	operation, err := s.walletRepo.AdjustBalanceAtomic(ctx, walletID, amount, reason, func(operation *entities.Operation) (*entities.AuditEvent, error) {
+		return s.audit.Event(ctx, entities.AuditWalletAdjusted, entities.AuditTargetWallet, walletID.String(),
+			map[string]int64{"balance": operation.BalanceAfter - amount},
+			map[string]interface{}{
+				"balance":      operation.BalanceAfter,
+				"amount":       amount,
+				"reason":       reason,
+				"operation_id": operation.ID,
+			},
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("wallet balance adjusted", "amount", amount, "reason", reason, "balance_after", operation.BalanceAfter)
+	return operation, nil
+}
+
+// GetOperations возвращает страницу истории операций кошелька, новые первыми
+func (s *WalletService) GetOperations(ctx context.Context, walletID uuid.UUID, limit, offset int) (_ []*entities.Operation, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.GetOperations", attribute.String("wallet.id", walletID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if limit <= 0 {
+		limit = DefaultOperationsPageSize
+	}
+	if limit > MaxOperationsPageSize {
+		limit = MaxOperationsPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	operations, err := s.walletRepo.GetOperationsHistory(ctx, walletID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if operations == nil {
+		operations = []*entities.Operation{}
+	}
+	return operations, nil
+}
+
+// WatchBalance передает в send текущий баланс кошелька и затем каждое его
+// изменение, пока ctx не отменен или send не вернул ошибку. Промежуточные
+// значения могут быть пропущены, если send не успевает за изменениями.
+func (s *WalletService) WatchBalance(ctx context.Context, walletID uuid.UUID, send func(*entities.BalanceUpdate) error) error {
+	// Подписка раньше чтения баланса: изменение между ними не потеряется
+	updates, unsubscribe := s.balances.Subscribe(walletID)
+	defer unsubscribe()
+
+	var last *entities.BalanceUpdate
+	deliver := func(update *entities.BalanceUpdate) error {
+		if last != nil && update.Balance == last.Balance && update.UpdatedAt.Equal(last.UpdatedAt) {
+			return nil
+		}
+		last = update
+		return send(update)
+	}
+	current := func() error {
+		wallet, err := s.GetWallet(ctx, walletID)
+		if err != nil {
+			return err
+		}
+		return deliver(&entities.BalanceUpdate{WalletID: wallet.ID, Balance: wallet.Balance, UpdatedAt: wallet.UpdatedAt})
+	}
+
+	if err := current(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-updates:
+			var err error
+			switch {
+			case !ok:
+				return ErrBalanceFeedClosed
+			case update == nil:
+				err = current()
+			default:
+				err = deliver(update)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// VerifyOperationsChain проходит хеш-цепочку операций кошелька и сообщает о
+// первой операции, у которой не совпадает хеш, ссылка на предыдущую,
+// пропущен номер или balance_after не следует из предыдущего баланса
+func (s *WalletService) VerifyOperationsChain(ctx context.Context, walletID uuid.UUID) (_ *entities.ChainVerification, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.VerifyOperationsChain", attribute.String("wallet.id", walletID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if _, err = s.GetWallet(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	result := &entities.ChainVerification{Valid: true}
+	if result.Legacy, err = s.walletRepo.CountLegacyOperations(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	prevHash := entities.GenesisHash
+	var prevBalance int64
+	for {
+		operations, err := s.walletRepo.ListChainedOperations(ctx, walletID, result.LastSeq, chainVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range operations {
+			reason := ""
+			switch {
+			case *op.Seq != result.LastSeq+1:
+				reason = "missing operations before this entry"
+			case op.PrevHash == nil || *op.PrevHash != prevHash:
+				reason = "previous hash does not match"
+			case op.Hash == nil || op.ComputeHash() != *op.Hash:
+				reason = "content hash does not match"
+			// До первой операции цепочки баланс неизвестен, если были операции без хеша
+			case (result.LastSeq > 0 || result.Legacy == 0) && op.BalanceAfter != prevBalance+op.SignedAmount():
+				reason = "balance_after does not follow from the previous operation"
+			}
+			if reason != "" {
+				seq := *op.Seq
+				result.Valid = false
+				result.BrokenAt = &seq
+				result.Reason = reason
+				logger.FromContext(ctx).Error("operations chain broken", "seq", seq, "operation_id", op.ID, "reason", reason)
+				return result, nil
+			}
+			prevHash = *op.Hash
+			prevBalance = op.BalanceAfter
+			result.LastSeq = *op.Seq
+			result.Checked++
+		}
+		if len(operations) < chainVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// BalanceAt возвращает баланс кошелька на момент at по истории операций
+func (s *WalletService) BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (_ *entities.WalletBalance, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.BalanceAt",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("balance.at", at.Format(time.RFC3339)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return s.walletRepo.BalanceAt(ctx, walletID, at)
+}
+
+// UserBalancesAt возвращает балансы всех кошельков пользователя на момент at
+// (для отчетности на конец периода). Кошельки, созданные позже at, не входят.
+func (s *WalletService) UserBalancesAt(ctx context.Context, userID uuid.UUID, at time.Time) (_ []*entities.WalletBalance, err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.UserBalancesAt",
+		attribute.String("user.id", userID.String()),
+		attribute.String("balance.at", at.Format(time.RFC3339)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return s.walletRepo.BalancesAtByUser(ctx, userID, at)
+}
+
+// WriteStatement формирует выписку по кошельку за период [from, to] с
+// входящим и исходящим остатком. Операции читаются страницами и сразу
+// передаются в w, поэтому длина периода не ограничена памятью.
+func (s *WalletService) WriteStatement(ctx context.Context, walletID uuid.UUID, from, to time.Time, w StatementWriter) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletService.WriteStatement",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("statement.from", from.Format(time.RFC3339)),
+		attribute.String("statement.to", to.Format(time.RFC3339)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if from.After(to) {
+		return ErrInvalidPeriod
+	}
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+
+	// Postgres хранит микросекунды: остаток на последнюю микросекунду до начала периода
+	opening, err := s.walletRepo.BalanceAt(ctx, walletID, from.Add(-time.Microsecond))
+	if err != nil {
+		return err
+	}
+
+	statement := entities.NewStatement(wallet, from, to, opening.Balance)
+	if err = w.Begin(statement); err != nil {
+		return err
+	}
+
+	var last *entities.Operation
+	for {
+		operations, err := s.walletRepo.ListOperationsBetween(ctx, walletID, from, to, last, statementBatch)
+		if err != nil {
+			return err
+		}
+		for _, op := range operations {
+			if err = w.Line(statement.Add(op)); err != nil {
+				return err
+			}
+		}
+		if len(operations) < statementBatch {
+			break
+		}
+		last = operations[len(operations)-1]
+	}
+
+	logger.FromContext(ctx).Debug("statement generated", "wallet_id", walletID, "operations", statement.Count)
+	return w.End(statement)
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const revokedSessionPrefix = "revoked_session:"
+
+// SessionDenylist хранит отозванные сессии в Redis. Ключ живет не дольше
+// access-токена: после его истечения сессию отсекает уже refresh.
+type SessionDenylist struct {
+	client *redis.Client
+}
+
+func NewSessionDenylist(client *redis.Client) *SessionDenylist {
+	return &SessionDenylist{client: client}
+}
+
+func (d *SessionDenylist) Add(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	return d.client.Set(ctx, revokedSessionPrefix+sessionID.String(), 1, ttl).Err()
+}
+
+func (d *SessionDenylist) Contains(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	n, err := d.client.Exists(ctx, revokedSessionPrefix+sessionID.String()).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository struct {
+	s *Store
+}
+
+func NewAPIKeyRepository(s *Store) repositories.APIKeyRepository {
+	return &APIKeyRepository{s: s}
+}
+
+func copyAPIKey(key *entities.APIKey) *entities.APIKey {
+	c := *key
+	c.Permissions = append([]entities.Permission{}, key.Permissions...)
+	c.WalletIDs = append([]uuid.UUID{}, key.WalletIDs...)
+	return &c
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *entities.APIKey, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if err := r.insert(key); err != nil {
+		return err
+	}
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *APIKeyRepository) insert(key *entities.APIKey) error {
+	for _, k := range r.s.apiKeys {
+		if k.ID == key.ID || k.Prefix == key.Prefix {
+			return repositories.ErrDuplicate
+		}
+	}
+	r.s.apiKeys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+func (r *APIKeyRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.APIKey, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if key, ok := r.s.apiKeys[id]; ok {
+		return copyAPIKey(key), nil
+	}
+	return nil, nil
+}
+
+func (r *APIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*entities.APIKey, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	for _, key := range r.s.apiKeys {
+		if key.Prefix == prefix {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *APIKeyRepository) List(ctx context.Context) ([]*entities.APIKey, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	keys := make([]*entities.APIKey, 0, len(r.s.apiKeys))
+	for _, key := range r.s.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if key, ok := r.s.apiKeys[id]; ok && key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *APIKeyRepository) Rotate(ctx context.Context, oldID uuid.UUID, oldExpiresAt time.Time, next *entities.APIKey, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if err := r.insert(next); err != nil {
+		return err
+	}
+	if old, ok := r.s.apiKeys[oldID]; ok && old.RevokedAt == nil {
+		if old.ExpiresAt == nil || oldExpiresAt.Before(*old.ExpiresAt) {
+			old.ExpiresAt = &oldExpiresAt
+		}
+	}
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if key, ok := r.s.apiKeys[id]; ok {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
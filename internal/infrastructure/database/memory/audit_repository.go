@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+)
+
+type AuditRepository struct {
+	s *Store
+}
+
+func NewAuditRepository(s *Store) repositories.AuditRepository {
+	return &AuditRepository{s: s}
+}
+
+func (r *AuditRepository) Append(ctx context.Context, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	r.s.appendAudit(event)
+	return nil
+}
+
+// appendAudit добавляет запись в конец цепочки; вызывается под блокировкой
+// хранилища, в том числе другими репозиториями вместе с изменением. nil
+// ничего не пишет.
+func (s *Store) appendAudit(event *entities.AuditEvent) {
+	if event == nil {
+		return
+	}
+	event.Seq = 1
+	event.PrevHash = entities.GenesisHash
+	if n := len(s.audit); n > 0 {
+		event.Seq = s.audit[n-1].Seq + 1
+		event.PrevHash = s.audit[n-1].Hash
+	}
+	event.Hash = event.ComputeHash()
+
+	c := *event
+	s.audit = append(s.audit, &c)
+}
+
+func (r *AuditRepository) Search(ctx context.Context, filter repositories.AuditFilter) ([]*entities.AuditEvent, int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer r.s.unlock()
+
+	// Новые первыми
+	matched := []*entities.AuditEvent{}
+	for i := len(r.s.audit) - 1; i >= 0; i-- {
+		if e := r.s.audit[i]; auditMatches(e, filter) {
+			matched = append(matched, e)
+		}
+	}
+
+	events := []*entities.AuditEvent{}
+	for _, e := range page(matched, filter.Limit, filter.Offset) {
+		c := *e
+		events = append(events, &c)
+	}
+	return events, len(matched), nil
+}
+
+func auditMatches(e *entities.AuditEvent, filter repositories.AuditFilter) bool {
+	switch {
+	case filter.ActorID != nil && (e.ActorID == nil || *e.ActorID != *filter.ActorID):
+		return false
+	case filter.Action != "" && e.Action != filter.Action:
+		return false
+	case filter.TargetType != "" && e.TargetType != filter.TargetType:
+		return false
+	case filter.TargetID != "" && e.TargetID != filter.TargetID:
+		return false
+	case filter.From != nil && e.CreatedAt.Before(*filter.From):
+		return false
+	case filter.To != nil && !e.CreatedAt.Before(*filter.To):
+		return false
+	}
+	return true
+}
+
+func (r *AuditRepository) ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*entities.AuditEvent, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	events := []*entities.AuditEvent{}
+	for _, e := range r.s.audit {
+		if len(events) == limit {
+			break
+		}
+		if e.Seq > afterSeq {
+			c := *e
+			events = append(events, &c)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type BatchRepository struct {
+	s *Store
+}
+
+func NewBatchRepository(s *Store) repositories.BatchRepository {
+	return &BatchRepository{s: s}
+}
+
+func copyBatchItem(item *entities.BatchItem) *entities.BatchItem {
+	c := *item
+	return &c
+}
+
+func (r *BatchRepository) Create(ctx context.Context, job *entities.BatchJob, items []*entities.BatchItem) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if _, ok := r.s.batchJobs[job.ID]; ok {
+		return repositories.ErrDuplicate
+	}
+	c := *job
+	r.s.batchJobs[job.ID] = &c
+	stored := make([]*entities.BatchItem, len(items))
+	for i, item := range items {
+		stored[i] = copyBatchItem(item)
+	}
+	r.s.batchItems[job.ID] = stored
+	return nil
+}
+
+func (r *BatchRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.BatchJob, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	job, ok := r.s.batchJobs[id]
+	if !ok {
+		return nil, repositories.ErrBatchJobNotFound
+	}
+	c := *job
+	c.Succeeded, c.Failed, c.Skipped = 0, 0, 0
+	for _, item := range r.s.batchItems[id] {
+		switch item.Status {
+		case entities.BatchItemSucceeded:
+			c.Succeeded++
+		case entities.BatchItemFailed:
+			c.Failed++
+		case entities.BatchItemSkipped:
+			c.Skipped++
+		}
+	}
+	return &c, nil
+}
+
+func (r *BatchRepository) ListItems(
+	ctx context.Context,
+	jobID uuid.UUID,
+	status *entities.BatchItemStatus,
+	limit, offset int,
+) ([]*entities.BatchItem, int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer r.s.unlock()
+
+	var matched []*entities.BatchItem
+	for _, item := range r.s.batchItems[jobID] {
+		if status == nil || item.Status == *status {
+			matched = append(matched, item)
+		}
+	}
+	items := []*entities.BatchItem{}
+	for i := offset; i < len(matched) && len(items) < limit; i++ {
+		items = append(items, copyBatchItem(matched[i]))
+	}
+	return items, len(matched), nil
+}
+
+func (r *BatchRepository) PendingItems(ctx context.Context, jobID uuid.UUID) ([]*entities.BatchItem, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	items := []*entities.BatchItem{}
+	for _, item := range r.s.batchItems[jobID] {
+		if item.Status == entities.BatchItemPending {
+			items = append(items, copyBatchItem(item))
+		}
+	}
+	return items, nil
+}
+
+func (r *BatchRepository) UpdateItems(ctx context.Context, items []*entities.BatchItem) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	// Результат пишется только один раз, как в Postgres
+	for _, item := range items {
+		stored := r.s.batchItems[item.JobID]
+		if item.Index < 0 || item.Index >= len(stored) || stored[item.Index].Status != entities.BatchItemPending {
+			continue
+		}
+		stored[item.Index] = copyBatchItem(item)
+	}
+	return nil
+}
+
+func (r *BatchRepository) Claim(ctx context.Context, staleBefore time.Time) (*entities.BatchJob, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	var oldest *entities.BatchJob
+	for _, job := range r.s.batchJobs {
+		stale := job.Status == entities.BatchStatusRunning && job.HeartbeatAt != nil && job.HeartbeatAt.Before(staleBefore)
+		if job.Status != entities.BatchStatusQueued && !stale {
+			continue
+		}
+		if oldest == nil || job.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = job
+		}
+	}
+	if oldest == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	oldest.Status = entities.BatchStatusRunning
+	oldest.HeartbeatAt = &now
+	if oldest.StartedAt == nil {
+		oldest.StartedAt = &now
+	}
+	c := *oldest
+	return &c, nil
+}
+
+func (r *BatchRepository) Heartbeat(ctx context.Context, jobID uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if job, ok := r.s.batchJobs[jobID]; ok && job.Status == entities.BatchStatusRunning {
+		now := time.Now()
+		job.HeartbeatAt = &now
+	}
+	return nil
+}
+
+func (r *BatchRepository) Finish(ctx context.Context, job *entities.BatchJob) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	stored, ok := r.s.batchJobs[job.ID]
+	if !ok {
+		return repositories.ErrBatchJobNotFound
+	}
+	stored.Status = job.Status
+	stored.Error = job.Error
+	stored.FinishedAt = job.FinishedAt
+	return nil
+}
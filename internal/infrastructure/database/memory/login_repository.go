@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type LoginRepository struct {
+	s *Store
+}
+
+func NewLoginRepository(s *Store) repositories.LoginRepository {
+	return &LoginRepository{s: s}
+}
+
+func (r *LoginRepository) RecordEvent(ctx context.Context, event *entities.LoginEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	e := *event
+	r.s.loginEvents = append(r.s.loginEvents, &e)
+	return nil
+}
+
+func (r *LoginRepository) RecentEvents(ctx context.Context, userID uuid.UUID, limit int) ([]*entities.LoginEvent, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	events := []*entities.LoginEvent{}
+	for i := len(r.s.loginEvents) - 1; i >= 0 && len(events) < limit; i-- {
+		if e := r.s.loginEvents[i]; e.UserID != nil && *e.UserID == userID {
+			c := *e
+			events = append(events, &c)
+		}
+	}
+	return events, nil
+}
+
+func (r *LoginRepository) CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.s.unlock()
+
+	count := 0
+	for _, e := range r.s.loginEvents {
+		if e.IP == ip && !e.Success && !e.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *LoginRepository) KnownIP(ctx context.Context, userID uuid.UUID, ip string) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.s.unlock()
+
+	for _, e := range r.s.loginEvents {
+		if e.Success && e.IP == ip && e.UserID != nil && *e.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *LoginRepository) FindLockout(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if l, ok := r.s.lockouts[userID]; ok {
+		c := *l
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *LoginRepository) RegisterFailure(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	l, ok := r.s.lockouts[userID]
+	if !ok {
+		l = &entities.LoginLockout{UserID: userID}
+		r.s.lockouts[userID] = l
+	}
+	now := time.Now()
+	l.FailedCount++
+	l.LastFailedAt = &now
+	c := *l
+	return &c, nil
+}
+
+func (r *LoginRepository) Lock(ctx context.Context, userID uuid.UUID, threshold int, until time.Time, event *entities.AuditEvent) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.s.unlock()
+
+	l, ok := r.s.lockouts[userID]
+	if !ok || l.FailedCount < threshold {
+		return false, nil
+	}
+	l.LockedUntil = &until
+	l.LockoutCount++
+	l.FailedCount = 0
+	r.s.appendAudit(event)
+	return true, nil
+}
+
+func (r *LoginRepository) ResetFailures(ctx context.Context, userID uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	delete(r.s.lockouts, userID)
+	return nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository struct {
+	s *Store
+}
+
+func NewMFARepository(s *Store) repositories.MFARepository {
+	return &MFARepository{s: s}
+}
+
+func (r *MFARepository) Find(ctx context.Context, userID uuid.UUID) (*entities.UserMFA, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if mfa, ok := r.s.mfa[userID]; ok {
+		c := *mfa
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *MFARepository) Save(ctx context.Context, mfa *entities.UserMFA, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	c := *mfa
+	r.s.mfa[mfa.UserID] = &c
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *MFARepository) Delete(ctx context.Context, userID uuid.UUID, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	delete(r.s.recoveryCodes, userID)
+	delete(r.s.mfa, userID)
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *MFARepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.s.unlock()
+
+	mfa, ok := r.s.mfa[userID]
+	if !ok || mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	r.s.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.s.unlock()
+
+	used, ok := r.s.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.s.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository struct {
+	s *Store
+}
+
+func NewSessionRepository(s *Store) repositories.SessionRepository {
+	return &SessionRepository{s: s}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *entities.Session, token *entities.RefreshToken) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if _, ok := r.s.refreshTokens[token.TokenHash]; ok {
+		return repositories.ErrDuplicate
+	}
+	sc, tc := *session, *token
+	r.s.sessions[session.ID] = &sc
+	r.s.refreshTokens[token.TokenHash] = &tc
+	return nil
+}
+
+func (r *SessionRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.Session, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if session, ok := r.s.sessions[id]; ok {
+		c := *session
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *SessionRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*entities.RefreshToken, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if token, ok := r.s.refreshTokens[tokenHash]; ok {
+		c := *token
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *SessionRepository) Rotate(ctx context.Context, old, next *entities.RefreshToken) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	current, ok := r.s.refreshTokens[old.TokenHash]
+	if !ok || current.ID != old.ID || current.UsedAt != nil {
+		return repositories.ErrTokenAlreadyUsed
+	}
+	if _, ok := r.s.refreshTokens[next.TokenHash]; ok {
+		return repositories.ErrDuplicate
+	}
+
+	now := time.Now()
+	current.UsedAt = &now
+	c := *next
+	r.s.refreshTokens[next.TokenHash] = &c
+	if session, ok := r.s.sessions[old.SessionID]; ok {
+		session.LastUsedAt = now
+	}
+	return nil
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, id uuid.UUID, reason string) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if session, ok := r.s.sessions[id]; ok && session.RevokedAt == nil {
+		revoke(session, reason)
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, reason string) ([]uuid.UUID, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	ids := []uuid.UUID{}
+	for id, session := range r.s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			revoke(session, reason)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func revoke(session *entities.Session, reason string) {
+	now := time.Now()
+	session.RevokedAt = &now
+	session.RevokeReason = &reason
+}
+
+// SessionDenylist считает отозванной любую сессию, которая не активна в
+// хранилище, как postgres.SessionDenylist
+type SessionDenylist struct {
+	s *Store
+}
+
+func NewSessionDenylist(s *Store) *SessionDenylist {
+	return &SessionDenylist{s: s}
+}
+
+// Add ничего не делает: отзыв уже записан в сессию
+func (d *SessionDenylist) Add(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	return nil
+}
+
+func (d *SessionDenylist) Contains(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	if err := d.s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer d.s.unlock()
+
+	session, ok := d.s.sessions[sessionID]
+	return !ok || session.Revoked(), nil
+}
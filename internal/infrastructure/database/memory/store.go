@@ -0,0 +1,102 @@
+// Package memory - репозитории в памяти процесса с тем же поведением, что
+// у реализаций на Postgres. Состояние теряется при остановке: хранилище
+// предназначено для тестов и локальных прогонов без базы данных.
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+// ErrTxUnsupported - у хранилища в памяти нет транзакций sqlx
+var ErrTxUnsupported = errors.New("memory: sql transactions are not supported")
+
+// Store - общее состояние репозиториев. Один мьютекс сериализует все
+// обращения, поэтому изменения нескольких кошельков атомарны так же, как
+// транзакции serializable в Postgres. Репозитории отдают и принимают копии
+// сущностей: изменение полученного объекта не меняет хранилище.
+type Store struct {
+	mu sync.Mutex
+
+	users   map[uuid.UUID]*entities.User
+	wallets map[uuid.UUID]*entities.Wallet
+	// operations - операции кошелька в порядке проведения
+	operations map[uuid.UUID][]*entities.Operation
+	byRequest  map[uuid.UUID]*entities.Operation
+	byTransfer map[uuid.UUID][]*entities.Operation
+
+	sessions      map[uuid.UUID]*entities.Session
+	refreshTokens map[string]*entities.RefreshToken
+	loginEvents   []*entities.LoginEvent
+	lockouts      map[uuid.UUID]*entities.LoginLockout
+	mfa           map[uuid.UUID]*entities.UserMFA
+	// recoveryCodes - хеши кодов восстановления пользователя и признак использования
+	recoveryCodes map[uuid.UUID]map[string]bool
+	userTokens    []*entities.UserToken
+	audit         []*entities.AuditEvent
+	apiKeys       map[uuid.UUID]*entities.APIKey
+
+	batchJobs map[uuid.UUID]*entities.BatchJob
+	// batchItems - операции задания по порядку item_index
+	batchItems map[uuid.UUID][]*entities.BatchItem
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:         make(map[uuid.UUID]*entities.User),
+		wallets:       make(map[uuid.UUID]*entities.Wallet),
+		operations:    make(map[uuid.UUID][]*entities.Operation),
+		byRequest:     make(map[uuid.UUID]*entities.Operation),
+		byTransfer:    make(map[uuid.UUID][]*entities.Operation),
+		sessions:      make(map[uuid.UUID]*entities.Session),
+		refreshTokens: make(map[string]*entities.RefreshToken),
+		lockouts:      make(map[uuid.UUID]*entities.LoginLockout),
+		mfa:           make(map[uuid.UUID]*entities.UserMFA),
+		recoveryCodes: make(map[uuid.UUID]map[string]bool),
+		apiKeys:       make(map[uuid.UUID]*entities.APIKey),
+		batchJobs:     make(map[uuid.UUID]*entities.BatchJob),
+		batchItems:    make(map[uuid.UUID][]*entities.BatchItem),
+	}
+}
+
+// lock захватывает хранилище, если контекст еще не отменен: как и запрос
+// к базе, отмененный вызов ничего не меняет
+func (s *Store) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	return nil
+}
+
+func (s *Store) unlock() {
+	s.mu.Unlock()
+}
+
+func copyUser(u *entities.User) *entities.User {
+	c := *u
+	return &c
+}
+
+func copyWallet(w *entities.Wallet) *entities.Wallet {
+	c := *w
+	return &c
+}
+
+func copyOperation(o *entities.Operation) *entities.Operation {
+	c := *o
+	return &c
+}
+
+func copyOperations(ops []*entities.Operation) []*entities.Operation {
+	out := make([]*entities.Operation, len(ops))
+	for i, o := range ops {
+		out[i] = copyOperation(o)
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type UserRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) repositories.UserRepository {
+	return &UserRepository{s: s}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *entities.User) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if _, ok := r.s.users[user.ID]; ok || r.s.conflictingUser(user) {
+		return repositories.ErrDuplicate
+	}
+	r.s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// conflictingUser - другой пользователь с тем же email или именем (имена
+// уникальны без учета регистра, как индекс lower(username))
+func (s *Store) conflictingUser(user *entities.User) bool {
+	for _, u := range s.users {
+		if u.ID == user.ID {
+			continue
+		}
+		if u.Email == user.Email || strings.EqualFold(u.Username, user.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *UserRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if u, ok := r.s.users[id]; ok {
+		return copyUser(u), nil
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*entities.User, error) {
+	return r.findOne(ctx, func(u *entities.User) bool { return u.Email == email })
+}
+
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*entities.User, error) {
+	return r.findOne(ctx, func(u *entities.User) bool { return strings.EqualFold(u.Username, username) })
+}
+
+func (r *UserRepository) findOne(ctx context.Context, match func(*entities.User) bool) (*entities.User, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	for _, u := range r.s.users {
+		if match(u) {
+			return copyUser(u), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, user *entities.User, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	old, ok := r.s.users[user.ID]
+	if !ok {
+		return nil
+	}
+	if r.s.conflictingUser(user) {
+		return repositories.ErrDuplicate
+	}
+	if !strings.EqualFold(old.Email, user.Email) {
+		tokens := r.s.userTokens[:0]
+		for _, t := range r.s.userTokens {
+			if t.UserID != user.ID || t.UsedAt != nil {
+				tokens = append(tokens, t)
+			}
+		}
+		r.s.userTokens = tokens
+	}
+	r.s.users[user.ID] = copyUser(user)
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	delete(r.s.users, id)
+	return nil
+}
+
+func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*entities.User, error) {
+	users, _, err := r.Search(ctx, repositories.UserFilter{SortBy: "created_at", SortDesc: true, Limit: limit, Offset: offset})
+	return users, err
+}
+
+func (r *UserRepository) Search(ctx context.Context, filter repositories.UserFilter) ([]*entities.User, int, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer r.s.unlock()
+
+	emailPrefix := strings.ToLower(filter.EmailPrefix)
+	usernamePrefix := strings.ToLower(filter.UsernamePrefix)
+	matched := []*entities.User{}
+	for _, u := range r.s.users {
+		if strings.HasPrefix(strings.ToLower(u.Email), emailPrefix) &&
+			strings.HasPrefix(strings.ToLower(u.Username), usernamePrefix) {
+			matched = append(matched, u)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		c := compareUsers(a, b, filter.SortBy)
+		if c == 0 {
+			// Второй ключ - id по возрастанию, как в запросе к Postgres
+			return a.ID.String() < b.ID.String()
+		}
+		return (c < 0) != filter.SortDesc
+	})
+
+	users := []*entities.User{}
+	for _, u := range page(matched, filter.Limit, filter.Offset) {
+		users = append(users, copyUser(u))
+	}
+	return users, len(matched), nil
+}
+
+func (r *UserRepository) DeleteWithWallets(ctx context.Context, id uuid.UUID, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	for _, w := range r.s.wallets {
+		if w.UserID == id && w.Balance != 0 {
+			return repositories.ErrUserHasFunds
+		}
+	}
+	for walletID, w := range r.s.wallets {
+		if w.UserID == id {
+			delete(r.s.wallets, walletID)
+		}
+	}
+	delete(r.s.users, id)
+	r.s.appendAudit(event)
+	return nil
+}
+
+func compareUsers(a, b *entities.User, sortBy string) int {
+	switch sortBy {
+	case "email":
+		return strings.Compare(a.Email, b.Email)
+	case "username":
+		return strings.Compare(a.Username, b.Username)
+	default:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+}
+
+// page - срез items по LIMIT и OFFSET; limit <= 0 - без ограничения
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	if offset > 0 {
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+type UserTokenRepository struct {
+	s *Store
+}
+
+func NewUserTokenRepository(s *Store) repositories.UserTokenRepository {
+	return &UserTokenRepository{s: s}
+}
+
+func (r *UserTokenRepository) Create(ctx context.Context, token *entities.UserToken) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	now := time.Now()
+	for _, t := range r.s.userTokens {
+		if t.UserID == token.UserID && t.Purpose == token.Purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	c := *token
+	r.s.userTokens = append(r.s.userTokens, &c)
+	return nil
+}
+
+func (r *UserTokenRepository) Consume(ctx context.Context, tokenHash string, purpose entities.UserTokenPurpose) (*entities.UserToken, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	now := time.Now()
+	for _, t := range r.s.userTokens {
+		if t.TokenHash == tokenHash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *UserTokenRepository) Latest(ctx context.Context, userID uuid.UUID, purpose entities.UserTokenPurpose) (*entities.UserToken, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	for i := len(r.s.userTokens) - 1; i >= 0; i-- {
+		if t := r.s.userTokens[i]; t.UserID == userID && t.Purpose == purpose {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,742 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type WalletRepository struct {
+	s *Store
+}
+
+func NewWalletRepository(s *Store) repositories.WalletRepository {
+	return &WalletRepository{s: s}
+}
+
+// ProcessOperationAtomic проводит пополнение или списание и комиссию за
+// него; операция с уже использованным requestID не проводится повторно
+func (r *WalletRepository) ProcessOperationAtomic(
+	ctx context.Context,
+	walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	requestID *uuid.UUID,
+	fees *entities.FeeSchedule,
+) (*entities.OperationResult, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	leg := operationLeg{
+		walletID:      walletID,
+		operationType: operationType,
+		amount:        amount,
+		requestID:     requestID,
+	}
+	if requestID != nil {
+		if result, err := r.s.loadRequest(leg); err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	tx := r.s.begin()
+	operation, err := tx.post(leg)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := tx.chargeFee(fees, string(operationType), walletID, amount, operation.ID)
+	if err != nil {
+		return nil, err
+	}
+	tx.commit()
+	return &entities.OperationResult{Operation: operation, Fee: fee}, nil
+}
+
+func (r *WalletRepository) FindRequest(
+	ctx context.Context,
+	walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	requestID uuid.UUID,
+) (*entities.OperationResult, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	return r.s.loadRequest(operationLeg{
+		walletID:      walletID,
+		operationType: operationType,
+		amount:        amount,
+		requestID:     &requestID,
+	})
+}
+
+// loadRequest возвращает результат операции, уже проведенной с leg.requestID;
+// nil - такой операции не было
+func (s *Store) loadRequest(leg operationLeg) (*entities.OperationResult, error) {
+	operation, ok := s.byRequest[*leg.requestID]
+	if !ok {
+		return nil, nil
+	}
+	if operation.WalletID != leg.walletID || operation.OperationType != leg.operationType || operation.Amount != leg.amount {
+		return nil, repositories.ErrRequestMismatch
+	}
+	return &entities.OperationResult{
+		Operation: copyOperation(operation),
+		Fee:       s.loadFee(operation.ID),
+		Replayed:  true,
+	}, nil
+}
+
+// loadFee возвращает проводки комиссии за операцию или перевод sourceID;
+// nil - комиссии не было
+func (s *Store) loadFee(sourceID uuid.UUID) *entities.Fee {
+	feeTransferID := entities.FeeTransferID(sourceID)
+	debit, credit := s.findTransferOperations(feeTransferID)
+	if debit == nil || credit == nil {
+		return nil
+	}
+	return &entities.Fee{
+		Amount:      debit.Amount,
+		FeeWalletID: credit.WalletID,
+		TransferID:  feeTransferID,
+		Debit:       debit,
+		Credit:      credit,
+	}
+}
+
+func (s *Store) findTransferOperations(transferID uuid.UUID) (debit, credit *entities.Operation) {
+	for _, op := range s.byTransfer[transferID] {
+		if op.OperationType == entities.OperationTypeWithdraw {
+			debit = copyOperation(op)
+		} else {
+			credit = copyOperation(op)
+		}
+	}
+	return debit, credit
+}
+
+// AdjustBalanceAtomic - ручная корректировка: положительная сумма зачисляется,
+// отрицательная списывается. Работает и для замороженных кошельков.
+func (r *WalletRepository) AdjustBalanceAtomic(ctx context.Context, walletID uuid.UUID, amount int64, reason string, audit repositories.AuditFunc) (*entities.Operation, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	operationType := entities.OperationTypeDeposit
+	if amount < 0 {
+		operationType = entities.OperationTypeWithdraw
+		amount = -amount
+	}
+	tx := r.s.begin()
+	operation, err := tx.post(operationLeg{
+		walletID:      walletID,
+		operationType: operationType,
+		amount:        amount,
+		reason:        &reason,
+		allowFrozen:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var event *entities.AuditEvent
+	if audit != nil {
+		if event, err = audit(operation); err != nil {
+			return nil, err
+		}
+	}
+	tx.commit()
+	r.s.appendAudit(event)
+	return operation, nil
+}
+
+// TransferAtomic списывает с одного кошелька и зачисляет на другой; повтор
+// перевода с тем же ID заполняет его операции из истории
+func (r *WalletRepository) TransferAtomic(ctx context.Context, transfer *entities.Transfer, reason *string, fees *entities.FeeSchedule) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if replayed, err := r.s.loadTransfer(transfer); err != nil || replayed {
+		return err
+	}
+
+	tx := r.s.begin()
+	from, to := tx.wallet(transfer.FromWalletID), tx.wallet(transfer.ToWalletID)
+	if from != nil && to != nil && from.Currency != to.Currency {
+		return repositories.ErrCurrencyMismatch
+	}
+
+	legs := []struct {
+		leg       operationLeg
+		operation **entities.Operation
+	}{
+		{operationLeg{walletID: transfer.FromWalletID, operationType: entities.OperationTypeWithdraw}, &transfer.Debit},
+		{operationLeg{walletID: transfer.ToWalletID, operationType: entities.OperationTypeDeposit}, &transfer.Credit},
+	}
+	for _, l := range legs {
+		l.leg.amount = transfer.Amount
+		l.leg.reason = reason
+		l.leg.transferID = &transfer.ID
+		operation, err := tx.post(l.leg)
+		if err != nil {
+			return err
+		}
+		*l.operation = operation
+	}
+
+	fee, err := tx.chargeFee(fees, entities.FeeOperationTransfer, transfer.FromWalletID, transfer.Amount, transfer.ID)
+	if err != nil {
+		return err
+	}
+	transfer.Fee = fee
+	tx.commit()
+	return nil
+}
+
+// loadTransfer заполняет операции уже проведенного перевода и его комиссии;
+// false - перевода не было
+func (s *Store) loadTransfer(transfer *entities.Transfer) (bool, error) {
+	debit, credit := s.findTransferOperations(transfer.ID)
+	if debit == nil && credit == nil {
+		return false, nil
+	}
+	if debit == nil || credit == nil || debit.WalletID != transfer.FromWalletID ||
+		credit.WalletID != transfer.ToWalletID || debit.Amount != transfer.Amount {
+		return false, repositories.ErrTransferMismatch
+	}
+	transfer.Debit, transfer.Credit = debit, credit
+	transfer.Fee = s.loadFee(transfer.ID)
+	transfer.Replayed = true
+	return true, nil
+}
+
+// ProcessBatchAtomic проводит операции пакета все вместе или ни одной
+func (r *WalletRepository) ProcessBatchAtomic(ctx context.Context, items []*entities.BatchItem, fees *entities.FeeSchedule) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if replayed, err := r.s.loadBatch(items); err != nil || replayed {
+		return err
+	}
+
+	tx := r.s.begin()
+	results := make([]*entities.OperationResult, len(items))
+	for i, item := range items {
+		requestID := item.RequestID()
+		result := &entities.OperationResult{}
+		operation, err := tx.post(operationLeg{
+			walletID:      item.WalletID,
+			operationType: item.OperationType,
+			amount:        item.Amount,
+			requestID:     &requestID,
+		})
+		if err == nil {
+			result.Operation = operation
+			result.Fee, err = tx.chargeFee(fees, string(item.OperationType), item.WalletID, item.Amount, operation.ID)
+		}
+		if err != nil {
+			return &repositories.BatchItemError{Index: item.Index, Err: err}
+		}
+		results[i] = result
+	}
+	tx.commit()
+
+	for i, item := range items {
+		item.Succeed(results[i])
+	}
+	return nil
+}
+
+// loadBatch заполняет результаты уже проведенного пакета; false - пакет не
+// проводился
+func (s *Store) loadBatch(items []*entities.BatchItem) (bool, error) {
+	results := make([]*entities.OperationResult, len(items))
+	found := 0
+	for i, item := range items {
+		operation, ok := s.byRequest[item.RequestID()]
+		if !ok {
+			continue
+		}
+		if operation.WalletID != item.WalletID || operation.OperationType != item.OperationType || operation.Amount != item.Amount {
+			return false, repositories.ErrRequestMismatch
+		}
+		results[i] = &entities.OperationResult{Operation: copyOperation(operation), Fee: s.loadFee(operation.ID), Replayed: true}
+		found++
+	}
+	if found == 0 {
+		return false, nil
+	}
+	if found != len(items) {
+		return false, repositories.ErrRequestMismatch
+	}
+
+	for i, item := range items {
+		item.Succeed(results[i])
+	}
+	return true, nil
+}
+
+// operationLeg - изменение баланса одного кошелька и операция, которая его записывает
+type operationLeg struct {
+	walletID      uuid.UUID
+	operationType entities.OperationType
+	amount        int64
+	reason        *string
+	transferID    *uuid.UUID
+	requestID     *uuid.UUID
+	allowFrozen   bool
+}
+
+// tx - изменения одного атомарного вызова: рабочие копии кошельков и новые
+// операции. commit применяет их к хранилищу; без commit они отбрасываются.
+// Вызывается под блокировкой хранилища.
+type tx struct {
+	s          *Store
+	wallets    map[uuid.UUID]*entities.Wallet
+	operations []*entities.Operation
+}
+
+func (s *Store) begin() *tx {
+	return &tx{s: s, wallets: make(map[uuid.UUID]*entities.Wallet)}
+}
+
+// wallet - рабочая копия кошелька; nil - кошелька нет
+func (t *tx) wallet(id uuid.UUID) *entities.Wallet {
+	if w, ok := t.wallets[id]; ok {
+		return w
+	}
+	w, ok := t.s.wallets[id]
+	if !ok {
+		return nil
+	}
+	t.wallets[id] = copyWallet(w)
+	return t.wallets[id]
+}
+
+// post меняет баланс кошелька и добавляет операцию в его хеш-цепочку.
+// Причины отказа проверяются в том же порядке, что в Postgres.
+func (t *tx) post(leg operationLeg) (*entities.Operation, error) {
+	if leg.operationType != entities.OperationTypeDeposit && leg.operationType != entities.OperationTypeWithdraw {
+		return nil, repositories.ErrInvalidOperation
+	}
+	w := t.wallet(leg.walletID)
+	switch {
+	case w == nil:
+		return nil, repositories.ErrWalletNotFound
+	case w.Frozen() && !leg.allowFrozen:
+		return nil, repositories.ErrWalletFrozen
+	case leg.operationType == entities.OperationTypeWithdraw && w.Balance < leg.amount:
+		return nil, repositories.ErrInsufficientFunds
+	}
+
+	if leg.operationType == entities.OperationTypeWithdraw {
+		w.Balance -= leg.amount
+	} else {
+		w.Balance += leg.amount
+	}
+	w.UpdatedAt = time.Now()
+
+	operation := entities.NewOperation(leg.walletID, leg.operationType, leg.amount, w.Balance)
+	operation.UserID = w.UserID
+	operation.Reason = leg.reason
+	operation.TransferID = leg.transferID
+	operation.RequestID = leg.requestID
+	seq, hash := t.lastChained(leg.walletID)
+	operation.Chain(seq+1, hash)
+	t.operations = append(t.operations, operation)
+	return operation, nil
+}
+
+// lastChained - номер и хеш последней операции цепочки кошелька с учетом
+// еще не примененных
+func (t *tx) lastChained(walletID uuid.UUID) (int64, string) {
+	for i := len(t.operations) - 1; i >= 0; i-- {
+		if op := t.operations[i]; op.WalletID == walletID {
+			return *op.Seq, *op.Hash
+		}
+	}
+	if ops := t.s.operations[walletID]; len(ops) > 0 {
+		last := ops[len(ops)-1]
+		return *last.Seq, *last.Hash
+	}
+	return 0, entities.GenesisHash
+}
+
+// chargeFee считает комиссию за операцию sourceID на сумму amount и переводит
+// ее с кошелька payerID на кошелек комиссий его валюты. nil - комиссии нет.
+func (t *tx) chargeFee(fees *entities.FeeSchedule, operation string, payerID uuid.UUID, amount int64, sourceID uuid.UUID) (*entities.Fee, error) {
+	if fees == nil || len(fees.Rules) == 0 {
+		return nil, nil
+	}
+
+	payer := t.wallet(payerID)
+	if payer == nil {
+		return nil, repositories.ErrWalletNotFound
+	}
+	user, ok := t.s.users[payer.UserID]
+	if !ok {
+		return nil, repositories.ErrWalletNotFound
+	}
+
+	fee := fees.Quote(operation, payer.Currency, user.Tier, amount)
+	if fee == nil {
+		return nil, nil
+	}
+	feeWalletID, ok := fees.Wallets[payer.Currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", repositories.ErrFeeWalletNotConfigured, payer.Currency)
+	}
+	if feeWalletID == payerID {
+		// Операции самого кошелька комиссий комиссией не облагаются
+		return nil, nil
+	}
+	fee.FeeWalletID = feeWalletID
+	fee.TransferID = entities.FeeTransferID(sourceID)
+
+	reason := fmt.Sprintf("fee for %s %s", operation, sourceID)
+	var err error
+	if fee.Debit, err = t.post(operationLeg{
+		walletID:      payerID,
+		operationType: entities.OperationTypeWithdraw,
+		amount:        fee.Amount,
+		reason:        &reason,
+		transferID:    &fee.TransferID,
+	}); err != nil {
+		return nil, err
+	}
+	fee.Credit, err = t.post(operationLeg{
+		walletID:      feeWalletID,
+		operationType: entities.OperationTypeDeposit,
+		amount:        fee.Amount,
+		reason:        &reason,
+		transferID:    &fee.TransferID,
+		allowFrozen:   true,
+	})
+	if err == repositories.ErrWalletNotFound {
+		return nil, fmt.Errorf("%w: fee wallet %s does not exist", repositories.ErrFeeWalletNotConfigured, feeWalletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fee, nil
+}
+
+// commit применяет изменения; операции сохраняются копиями, поэтому
+// возвращенные вызывающему объекты с хранилищем не связаны
+func (t *tx) commit() {
+	for id, w := range t.wallets {
+		t.s.wallets[id] = w
+	}
+	for _, op := range t.operations {
+		stored := copyOperation(op)
+		t.s.operations[op.WalletID] = append(t.s.operations[op.WalletID], stored)
+		if op.RequestID != nil {
+			t.s.byRequest[*op.RequestID] = stored
+		}
+		if op.TransferID != nil {
+			t.s.byTransfer[*op.TransferID] = append(t.s.byTransfer[*op.TransferID], stored)
+		}
+	}
+}
+
+func (r *WalletRepository) SetStatus(ctx context.Context, walletID uuid.UUID, status entities.WalletStatus, event *entities.AuditEvent) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	w, ok := r.s.wallets[walletID]
+	if !ok {
+		return repositories.ErrWalletNotFound
+	}
+	w.Status = status
+	w.UpdatedAt = time.Now()
+	r.s.appendAudit(event)
+	return nil
+}
+
+func (r *WalletRepository) Create(ctx context.Context, wallet *entities.Wallet) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if _, ok := r.s.wallets[wallet.ID]; ok {
+		return repositories.ErrDuplicate
+	}
+	// Внешний ключ wallets.user_id
+	if _, ok := r.s.users[wallet.UserID]; !ok {
+		return fmt.Errorf("memory: user %s does not exist", wallet.UserID)
+	}
+	r.s.wallets[wallet.ID] = copyWallet(wallet)
+	return nil
+}
+
+func (r *WalletRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.Wallet, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if w, ok := r.s.wallets[id]; ok {
+		return copyWallet(w), nil
+	}
+	return nil, nil
+}
+
+func (r *WalletRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]*entities.Wallet, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	wallets := []*entities.Wallet{}
+	for _, w := range r.s.wallets {
+		if w.UserID == userID {
+			wallets = append(wallets, copyWallet(w))
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].CreatedAt.After(wallets[j].CreatedAt) })
+	return wallets, nil
+}
+
+func (r *WalletRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*entities.Wallet, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	wallets := []*entities.Wallet{}
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if w, ok := r.s.wallets[id]; ok && !seen[id] {
+			seen[id] = true
+			wallets = append(wallets, copyWallet(w))
+		}
+	}
+	return wallets, nil
+}
+
+func (r *WalletRepository) Update(ctx context.Context, wallet *entities.Wallet) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if w, ok := r.s.wallets[wallet.ID]; ok {
+		w.Balance = wallet.Balance
+		w.UpdatedAt = wallet.UpdatedAt
+	}
+	return nil
+}
+
+func (r *WalletRepository) UpdateBalance(ctx context.Context, walletID uuid.UUID, amount int64) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if w, ok := r.s.wallets[walletID]; ok {
+		w.Balance += amount
+		w.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *WalletRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := r.s.lock(ctx); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	delete(r.s.wallets, id)
+	return nil
+}
+
+func (r *WalletRepository) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
+	return nil, ErrTxUnsupported
+}
+
+func (r *WalletRepository) UpdateBalanceWithTx(ctx context.Context, tx *sqlx.Tx, walletID uuid.UUID, amount int64) error {
+	return ErrTxUnsupported
+}
+
+func (r *WalletRepository) FindByIDWithTx(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (*entities.Wallet, error) {
+	return nil, ErrTxUnsupported
+}
+
+func (r *WalletRepository) ListChainedOperations(ctx context.Context, walletID uuid.UUID, afterSeq int64, limit int) ([]*entities.Operation, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	operations := []*entities.Operation{}
+	for _, op := range r.s.operations[walletID] {
+		if len(operations) == limit {
+			break
+		}
+		if op.Seq != nil && *op.Seq > afterSeq {
+			operations = append(operations, copyOperation(op))
+		}
+	}
+	return operations, nil
+}
+
+// CountLegacyOperations - операций без цепочки в памяти не бывает
+func (r *WalletRepository) CountLegacyOperations(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	return 0, ctx.Err()
+}
+
+func (r *WalletRepository) BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (*entities.WalletBalance, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if _, ok := r.s.wallets[walletID]; !ok {
+		return nil, repositories.ErrWalletNotFound
+	}
+	return r.s.balanceAt(walletID, at), nil
+}
+
+func (r *WalletRepository) BalancesAtByUser(ctx context.Context, userID uuid.UUID, at time.Time) ([]*entities.WalletBalance, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	wallets := []*entities.Wallet{}
+	for _, w := range r.s.wallets {
+		if w.UserID == userID && !w.CreatedAt.After(at) {
+			wallets = append(wallets, w)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		if wallets[i].CreatedAt.Equal(wallets[j].CreatedAt) {
+			return wallets[i].ID.String() < wallets[j].ID.String()
+		}
+		return wallets[i].CreatedAt.Before(wallets[j].CreatedAt)
+	})
+
+	balances := make([]*entities.WalletBalance, 0, len(wallets))
+	for _, w := range wallets {
+		balances = append(balances, r.s.balanceAt(w.ID, at))
+	}
+	return balances, nil
+}
+
+// balanceAt - баланс по последней операции не позже at; из операций с
+// одинаковым временем последней считается операция с большим seq
+func (s *Store) balanceAt(walletID uuid.UUID, at time.Time) *entities.WalletBalance {
+	balance := &entities.WalletBalance{WalletID: walletID, At: at}
+	var last *entities.Operation
+	for _, op := range s.operations[walletID] {
+		if op.CreatedAt.After(at) {
+			continue
+		}
+		if last == nil || operationKeyLess(last, op) {
+			last = op
+		}
+	}
+	if last != nil {
+		id := last.ID
+		balance.Balance = last.BalanceAfter
+		balance.OperationID = &id
+	}
+	return balance
+}
+
+// operationKeyLess упорядочивает операции по (created_at, seq, id), как
+// выписка и BalanceAt в Postgres
+func operationKeyLess(a, b *entities.Operation) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	var aSeq, bSeq int64
+	if a.Seq != nil {
+		aSeq = *a.Seq
+	}
+	if b.Seq != nil {
+		bSeq = *b.Seq
+	}
+	if aSeq != bSeq {
+		return aSeq < bSeq
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+func (r *WalletRepository) ListOperationsBetween(
+	ctx context.Context,
+	walletID uuid.UUID,
+	from, to time.Time,
+	after *entities.Operation,
+	limit int,
+) ([]*entities.Operation, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	matched := []*entities.Operation{}
+	for _, op := range r.s.operations[walletID] {
+		if op.CreatedAt.Before(from) || op.CreatedAt.After(to) {
+			continue
+		}
+		if after != nil && !operationKeyLess(after, op) {
+			continue
+		}
+		matched = append(matched, op)
+	}
+	sort.Slice(matched, func(i, j int) bool { return operationKeyLess(matched[i], matched[j]) })
+	return copyOperations(page(matched, limit, 0)), nil
+}
+
+// GetOperationsHistory - операции кошелька, новые первыми
+func (r *WalletRepository) GetOperationsHistory(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entities.Operation, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	ops := r.s.operations[walletID]
+	newest := make([]*entities.Operation, len(ops))
+	for i, op := range ops {
+		newest[len(ops)-1-i] = op
+	}
+	return copyOperations(page(newest, limit, offset)), nil
+}
+
+// GetOperationsHistoryByUser - операции всех кошельков пользователя, новые первыми
+func (r *WalletRepository) GetOperationsHistoryByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*entities.Operation, error) {
+	if err := r.s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	matched := []*entities.Operation{}
+	for _, ops := range r.s.operations {
+		for _, op := range ops {
+			if op.UserID == userID {
+				matched = append(matched, op)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return operationKeyLess(matched[j], matched[i]) })
+	return copyOperations(page(matched, limit, offset)), nil
+}
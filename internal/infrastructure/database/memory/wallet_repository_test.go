@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+)
+
+// walletWithDeposits создает кошелек и проводит по нему пополнения amounts
+func walletWithDeposits(t *testing.T, store *Store, amounts ...int64) (repositories.WalletRepository, *entities.Wallet) {
+	t.Helper()
+	ctx := context.Background()
+	user := entities.NewUser("balance-"+uuid.NewString()+"@example.com", "balance-"+uuid.NewString(), "secret")
+	if err := NewUserRepository(store).Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewWalletRepository(store)
+	wallet := entities.NewWallet(user.ID, entities.DefaultCurrency)
+	if err := repo.Create(ctx, wallet); err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range amounts {
+		if _, err := repo.ProcessOperationAtomic(ctx, wallet.ID, entities.OperationTypeDeposit, amount, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo, wallet
+}
+
+func TestBalanceAtSameCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	repo, wallet := walletWithDeposits(t, store, 10, 20, 30)
+
+	// Операции одной транзакции в Postgres получают одно время; порядок в
+	// срезе обратный, чтобы результат определял только seq
+	at := time.Now().Add(-time.Minute)
+	operations := store.operations[wallet.ID]
+	for i, j := 0, len(operations)-1; i < j; i, j = i+1, j-1 {
+		operations[i], operations[j] = operations[j], operations[i]
+	}
+	for _, op := range operations {
+		op.CreatedAt = at
+	}
+	last := operations[0]
+
+	balance, err := repo.BalanceAt(ctx, wallet.ID, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != 60 || balance.OperationID == nil || *balance.OperationID != last.ID {
+		t.Fatalf("balance = %+v, want 60 by operation seq %d", balance, *last.Seq)
+	}
+
+	// Мгновением раньше операций еще не было
+	balance, err = repo.BalanceAt(ctx, wallet.ID, at.Add(-time.Microsecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != 0 || balance.OperationID != nil {
+		t.Fatalf("balance before operations = %+v, want 0", balance)
+	}
+}
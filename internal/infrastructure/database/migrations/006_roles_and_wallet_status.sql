@@ -0,0 +1,12 @@
+-- Роли пользователей вместо флага is_admin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'support', 'admin', 'auditor'));
+UPDATE users SET role = 'admin' WHERE is_admin;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
+
+-- Статус кошелька: замороженный кошелек не принимает операций
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'frozen'));
+
+-- Ручные корректировки помечаются причиной
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS reason TEXT;
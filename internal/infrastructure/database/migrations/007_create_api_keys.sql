@@ -0,0 +1,16 @@
+-- Ключи для межсервисных интеграций
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    secret_hash CHAR(64) NOT NULL,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    wallet_ids UUID[] NOT NULL DEFAULT '{}',
+    created_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_created_at ON api_keys(created_at);
@@ -0,0 +1,24 @@
+-- Журнал попыток входа
+CREATE TABLE IF NOT EXISTS login_events (
+    id UUID PRIMARY KEY,
+    user_id UUID,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(32),
+    new_ip BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_user_created ON login_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_events_ip_failed ON login_events(ip, created_at) WHERE NOT success;
+
+-- Счетчики неудачных входов и блокировки аккаунтов
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    user_id UUID PRIMARY KEY,
+    failed_count INT NOT NULL DEFAULT 0,
+    lockout_count INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_failed_at TIMESTAMP WITH TIME ZONE
+);
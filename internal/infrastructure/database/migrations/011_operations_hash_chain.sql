@@ -0,0 +1,8 @@
+-- Хеш-цепочка операций по каждому кошельку: seq - номер операции в кошельке,
+-- hash покрывает содержимое строки и prev_hash. Операции, созданные до
+-- миграции, остаются без хеша и в цепочку не входят.
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_operations_wallet_seq ON operations(wallet_id, seq) WHERE seq IS NOT NULL;
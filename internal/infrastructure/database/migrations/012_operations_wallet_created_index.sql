@@ -0,0 +1,2 @@
+-- Баланс на момент времени ищет последнюю операцию кошелька до указанного времени
+CREATE INDEX IF NOT EXISTS idx_operations_wallet_created_at ON operations(wallet_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Выписка читает операции периода страницами по ключу (created_at, seq, id)
+CREATE INDEX IF NOT EXISTS idx_operations_wallet_statement
+    ON operations(wallet_id, created_at, (COALESCE(seq, 0)), id);
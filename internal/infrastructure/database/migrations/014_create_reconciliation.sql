@@ -0,0 +1,27 @@
+-- Ежедневная сверка балансов кошельков с историей операций
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    id UUID PRIMARY KEY,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    wallets_checked BIGINT NOT NULL DEFAULT 0,
+    discrepancies BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_started_at ON reconciliation_runs(started_at DESC);
+
+-- Расхождение: баланс кошелька не равен balance_after последней операции
+-- или сумме операций со знаком. drift - баланс минус ожидаемое значение.
+CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    run_id UUID NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL,
+    balance BIGINT NOT NULL,
+    last_balance_after BIGINT,
+    operations_sum BIGINT NOT NULL,
+    operations_count BIGINT NOT NULL,
+    last_balance_drift BIGINT NOT NULL,
+    sum_drift BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_discrepancies_run ON reconciliation_discrepancies(run_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_discrepancies_wallet ON reconciliation_discrepancies(wallet_id);
@@ -0,0 +1,47 @@
+-- Перевод между кошельками - пара операций (списание и зачисление) с общим
+-- transfer_id. Уникальный индекс делает повтор перевода с тем же id безопасным.
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS transfer_id UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_operations_transfer
+    ON operations(transfer_id, operation_type) WHERE transfer_id IS NOT NULL;
+
+-- Регулярные переводы: правило - cron-выражение (UTC) или интервал в секундах
+CREATE TABLE IF NOT EXISTS schedules (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_wallet_id UUID NOT NULL,
+    to_wallet_id UUID NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    cron VARCHAR(100),
+    interval_seconds BIGINT CHECK (interval_seconds > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'cancelled')),
+    paused_reason VARCHAR(255),
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- retry_at - повтор текущего запуска после временной ошибки
+    retry_at TIMESTAMP WITH TIME ZONE,
+    attempts INT NOT NULL DEFAULT 0,
+    insufficient_funds_count INT NOT NULL DEFAULT 0,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((cron IS NULL) <> (interval_seconds IS NULL)),
+    CHECK (from_wallet_id <> to_wallet_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_from_wallet ON schedules(from_wallet_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_due
+    ON schedules((COALESCE(retry_at, next_run_at))) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id UUID PRIMARY KEY,
+    schedule_id UUID NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempt INT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('succeeded', 'failed', 'retrying')),
+    error TEXT,
+    transfer_id UUID NOT NULL,
+    executed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_id, executed_at DESC);
@@ -0,0 +1,6 @@
+-- Комиссии зависят от валюты кошелька и тарифа пользователя
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_currency_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier VARCHAR(32) NOT NULL DEFAULT 'standard';
@@ -0,0 +1,42 @@
+-- Ключ идемпотентности разовой операции: операция с тем же request_id
+-- проводится один раз, повтор возвращает уже проведенную
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS request_id UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_operations_request
+    ON operations(request_id) WHERE request_id IS NOT NULL;
+
+-- Пакет операций (выплаты): все или ничего (atomic) либо каждая по отдельности
+CREATE TABLE IF NOT EXISTS batch_jobs (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    atomic BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    total INT NOT NULL,
+    error TEXT,
+    -- heartbeat_at - отметка исполнителя; задание с устаревшей отметкой забирает другой экземпляр
+    heartbeat_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_jobs_pending
+    ON batch_jobs(created_at) WHERE status IN ('queued', 'running');
+
+CREATE TABLE IF NOT EXISTS batch_job_items (
+    job_id UUID NOT NULL REFERENCES batch_jobs(id) ON DELETE CASCADE,
+    item_index INT NOT NULL,
+    wallet_id UUID NOT NULL,
+    operation_type VARCHAR(10) NOT NULL CHECK (operation_type IN ('DEPOSIT', 'WITHDRAW')),
+    amount BIGINT NOT NULL,
+    reference VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed', 'skipped')),
+    error TEXT,
+    operation_id UUID,
+    fee BIGINT,
+    balance_after BIGINT,
+    PRIMARY KEY (job_id, item_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_job_items_pending
+    ON batch_job_items(job_id, item_index) WHERE status = 'pending';
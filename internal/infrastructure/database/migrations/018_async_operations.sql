@@ -0,0 +1,27 @@
+-- Отложенные операции: запрос принимается сразу и проводится воркером.
+-- id запроса - ключ идемпотентности операции (operations.request_id).
+CREATE TABLE IF NOT EXISTS operation_requests (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    wallet_id UUID NOT NULL,
+    operation_type VARCHAR(10) NOT NULL CHECK (operation_type IN ('DEPOSIT', 'WITHDRAW')),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'completed', 'rejected')),
+    reason TEXT,
+    operation_id UUID,
+    fee BIGINT,
+    balance_after BIGINT,
+    attempts INT NOT NULL DEFAULT 0,
+    -- available_at - не раньше этого момента (повтор после временной ошибки)
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- claimed_at - когда воркер взял запрос; запрос с устаревшей отметкой забирает другой
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_operation_requests_pending
+    ON operation_requests(available_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_operation_requests_processing
+    ON operation_requests(claimed_at) WHERE status = 'processing';
@@ -0,0 +1,19 @@
+-- Каждое изменение баланса публикуется в канал wallet_balance; уведомления
+-- доставляются слушателям после фиксации транзакции в порядке фиксации.
+CREATE OR REPLACE FUNCTION notify_wallet_balance() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('wallet_balance', json_build_object(
+        'wallet_id', NEW.id,
+        'balance', NEW.balance,
+        'updated_at', NEW.updated_at
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS wallets_balance_notify ON wallets;
+CREATE TRIGGER wallets_balance_notify
+    AFTER UPDATE OF balance ON wallets
+    FOR EACH ROW
+    WHEN (OLD.balance IS DISTINCT FROM NEW.balance)
+    EXECUTE FUNCTION notify_wallet_balance();
@@ -0,0 +1,3 @@
+-- API-ключ, которым отправлен пакет: у ключа нет пользователя (user_id -
+-- нулевой UUID), и результаты пакета видит только сам ключ
+ALTER TABLE batch_jobs ADD COLUMN IF NOT EXISTS api_key_id UUID;
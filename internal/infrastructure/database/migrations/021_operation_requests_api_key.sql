@@ -0,0 +1,4 @@
+-- API-ключ, которым принят запрос: у ключа нет пользователя (user_id -
+-- нулевой UUID), и повторить запрос с тем же ключом идемпотентности может
+-- только сам ключ
+ALTER TABLE operation_requests ADD COLUMN IF NOT EXISTS api_key_id UUID;
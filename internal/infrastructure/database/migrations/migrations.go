@@ -0,0 +1,137 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration - один SQL-файл вида NNN_name.sql
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// All возвращает все встроенные миграции в порядке версий
+func All() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, rest, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing version prefix", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+		body, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(rest, ".sql"),
+			SQL:     string(body),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest возвращает версию последней встроенной миграции
+func Latest() int {
+	migrations, err := All()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+const createVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// CurrentVersion возвращает версию последней примененной миграции
+func CurrentVersion(ctx context.Context, db *sqlx.DB) (int, error) {
+	if _, err := db.ExecContext(ctx, createVersionTable); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.GetContext(ctx, &version, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)
+	return version, err
+}
+
+// Up применяет все непримененные миграции, каждую в своей транзакции.
+// Advisory lock не дает нескольким инстансам мигрировать одновременно.
+func Up(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	const lockID = 7_314_001
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	current, err := CurrentVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return applied, err
+		}
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name,
+		); err != nil {
+			tx.Rollback()
+			return applied, err
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
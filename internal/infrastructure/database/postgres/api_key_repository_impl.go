@@ -0,0 +1,235 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type APIKeyRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepository(db *sqlx.DB) repositories.APIKeyRepository {
+	return &APIKeyRepositoryImpl{db: db}
+}
+
+// apiKeyRow - строка api_keys; массивы хранятся как TEXT[]/UUID[]
+type apiKeyRow struct {
+	ID          uuid.UUID      `db:"id"`
+	Name        string         `db:"name"`
+	Prefix      string         `db:"prefix"`
+	SecretHash  string         `db:"secret_hash"`
+	Permissions pq.StringArray `db:"permissions"`
+	WalletIDs   pq.StringArray `db:"wallet_ids"`
+	CreatedBy   *uuid.UUID     `db:"created_by"`
+	CreatedAt   time.Time      `db:"created_at"`
+	ExpiresAt   *time.Time     `db:"expires_at"`
+	LastUsedAt  *time.Time     `db:"last_used_at"`
+	RevokedAt   *time.Time     `db:"revoked_at"`
+}
+
+func newAPIKeyRow(key *entities.APIKey) *apiKeyRow {
+	row := &apiKeyRow{
+		ID:          key.ID,
+		Name:        key.Name,
+		Prefix:      key.Prefix,
+		SecretHash:  key.SecretHash,
+		Permissions: pq.StringArray{},
+		WalletIDs:   pq.StringArray{},
+		CreatedBy:   key.CreatedBy,
+		CreatedAt:   key.CreatedAt,
+		ExpiresAt:   key.ExpiresAt,
+		LastUsedAt:  key.LastUsedAt,
+		RevokedAt:   key.RevokedAt,
+	}
+	for _, p := range key.Permissions {
+		row.Permissions = append(row.Permissions, string(p))
+	}
+	for _, id := range key.WalletIDs {
+		row.WalletIDs = append(row.WalletIDs, id.String())
+	}
+	return row
+}
+
+func (row *apiKeyRow) entity() (*entities.APIKey, error) {
+	key := &entities.APIKey{
+		ID:          row.ID,
+		Name:        row.Name,
+		Prefix:      row.Prefix,
+		SecretHash:  row.SecretHash,
+		Permissions: make([]entities.Permission, 0, len(row.Permissions)),
+		WalletIDs:   make([]uuid.UUID, 0, len(row.WalletIDs)),
+		CreatedBy:   row.CreatedBy,
+		CreatedAt:   row.CreatedAt,
+		ExpiresAt:   row.ExpiresAt,
+		LastUsedAt:  row.LastUsedAt,
+		RevokedAt:   row.RevokedAt,
+	}
+	for _, p := range row.Permissions {
+		key.Permissions = append(key.Permissions, entities.Permission(p))
+	}
+	for _, s := range row.WalletIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		key.WalletIDs = append(key.WalletIDs, id)
+	}
+	return key, nil
+}
+
+const insertAPIKeyQuery = `
+	INSERT INTO api_keys (id, name, prefix, secret_hash, permissions, wallet_ids, created_by, created_at, expires_at)
+	VALUES (:id, :name, :prefix, :secret_hash, :permissions, :wallet_ids, :created_by, :created_at, :expires_at)
+`
+
+func (r *APIKeyRepositoryImpl) Create(ctx context.Context, key *entities.APIKey, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	ictx, span := startSpan(ctx, "APIKeyRepository.Create", insertAPIKeyQuery)
+	res, err := tx.NamedExecContext(ictx, insertAPIKeyQuery, newAPIKeyRow(key))
+	endSpan(span, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *APIKeyRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*entities.APIKey, error) {
+	return r.findOne(ctx, "APIKeyRepository.FindByID", `SELECT * FROM api_keys WHERE id = $1`, id)
+}
+
+func (r *APIKeyRepositoryImpl) FindByPrefix(ctx context.Context, prefix string) (*entities.APIKey, error) {
+	return r.findOne(ctx, "APIKeyRepository.FindByPrefix", `SELECT * FROM api_keys WHERE prefix = $1`, prefix)
+}
+
+func (r *APIKeyRepositoryImpl) findOne(ctx context.Context, name, query string, arg interface{}) (*entities.APIKey, error) {
+	var row apiKeyRow
+
+	ctx, span := startSpan(ctx, name, query)
+	err := r.db.GetContext(ctx, &row, query, arg)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return row.entity()
+}
+
+func (r *APIKeyRepositoryImpl) List(ctx context.Context) ([]*entities.APIKey, error) {
+	var rows []apiKeyRow
+	query := `SELECT * FROM api_keys ORDER BY created_at DESC`
+
+	ctx, span := startSpan(ctx, "APIKeyRepository.List", query)
+	err := r.db.SelectContext(ctx, &rows, query)
+	endSpan(span, int64(len(rows)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*entities.APIKey, 0, len(rows))
+	for i := range rows {
+		key, err := rows[i].entity()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepositoryImpl) Revoke(ctx context.Context, id uuid.UUID, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`
+	rctx, span := startSpan(ctx, "APIKeyRepository.Revoke", query)
+	res, err := tx.ExecContext(rctx, query, id)
+	endSpan(span, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *APIKeyRepositoryImpl) Rotate(ctx context.Context, oldID uuid.UUID, oldExpiresAt time.Time, next *entities.APIKey, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	expireQuery := `
+		UPDATE api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+	ectx, espan := startSpan(ctx, "APIKeyRepository.ExpireOld", expireQuery)
+	res, err := tx.ExecContext(ectx, expireQuery, oldID, oldExpiresAt)
+	endSpan(espan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	ictx, ispan := startSpan(ctx, "APIKeyRepository.Create", insertAPIKeyQuery)
+	res, err = tx.NamedExecContext(ictx, insertAPIKeyQuery, newAPIKeyRow(next))
+	endSpan(ispan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *APIKeyRepositoryImpl) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`
+
+	ctx, span := startSpan(ctx, "APIKeyRepository.TouchLastUsed", query)
+	res, err := r.db.ExecContext(ctx, query, id, at)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
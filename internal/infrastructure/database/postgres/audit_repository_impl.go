@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// auditChainLockID - ключ advisory-блокировки, сериализующей добавления в журнал аудита
+const auditChainLockID = 7318462051
+
+type AuditRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) repositories.AuditRepository {
+	return &AuditRepositoryImpl{db: db}
+}
+
+func (r *AuditRepositoryImpl) Append(ctx context.Context, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	return err
+}
+
+// appendAuditEvent добавляет запись в конец цепочки в транзакции tx, чтобы
+// другие репозитории писали аудит вместе с изменением; nil ничего не пишет.
+// В serializable-транзакции параллельное добавление дает конфликт
+// сериализации, и транзакция повторяется целиком.
+func appendAuditEvent(ctx context.Context, tx *sqlx.Tx, event *entities.AuditEvent) error {
+	if event == nil {
+		return nil
+	}
+
+	lockQuery := `SELECT pg_advisory_xact_lock($1)`
+	lctx, lspan := startSpan(ctx, "AuditRepository.Lock", lockQuery)
+	_, err := tx.ExecContext(lctx, lockQuery, auditChainLockID)
+	endSpan(lspan, 0, err)
+	if err != nil {
+		return err
+	}
+
+	var last struct {
+		Seq  int64  `db:"seq"`
+		Hash string `db:"hash"`
+	}
+	lastQuery := `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`
+	qctx, qspan := startSpan(ctx, "AuditRepository.Last", lastQuery)
+	err = tx.GetContext(qctx, &last, lastQuery)
+	endSpan(qspan, foundRows(err), err)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		last.Hash = entities.GenesisHash
+	default:
+		return err
+	}
+
+	event.Seq = last.Seq + 1
+	event.PrevHash = last.Hash
+	event.Hash = event.ComputeHash()
+
+	insertQuery := `
+		INSERT INTO audit_log (seq, id, actor_type, actor_id, action, target_type, target_id,
+			before_state, after_state, request_id, created_at, prev_hash, hash)
+		VALUES (:seq, :id, :actor_type, :actor_id, :action, :target_type, :target_id,
+			:before_state, :after_state, :request_id, :created_at, :prev_hash, :hash)
+	`
+	ictx, ispan := startSpan(ctx, "AuditRepository.Append", insertQuery)
+	res, err := tx.NamedExecContext(ictx, insertQuery, event)
+	endSpan(ispan, rowsAffected(res), err)
+	return err
+}
+
+func (r *AuditRepositoryImpl) Search(ctx context.Context, filter repositories.AuditFilter) ([]*entities.AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ActorID != nil {
+		args = append(args, *filter.ActorID)
+		conditions = append(conditions, "actor_id = $"+itoa(len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, "action = $"+itoa(len(args)))
+	}
+	if filter.TargetType != "" {
+		args = append(args, filter.TargetType)
+		conditions = append(conditions, "target_type = $"+itoa(len(args)))
+	}
+	if filter.TargetID != "" {
+		args = append(args, filter.TargetID)
+		conditions = append(conditions, "target_id = $"+itoa(len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, "created_at >= $"+itoa(len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, "created_at < $"+itoa(len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM audit_log` + where
+	cctx, cspan := startSpan(ctx, "AuditRepository.Count", countQuery)
+	err := r.db.GetContext(cctx, &total, countQuery, args...)
+	endSpan(cspan, foundRows(err), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := `SELECT * FROM audit_log` + where +
+		` ORDER BY seq DESC LIMIT $` + itoa(len(args)-1) + ` OFFSET $` + itoa(len(args))
+
+	events := []*entities.AuditEvent{}
+	ctx, span := startSpan(ctx, "AuditRepository.Search", query)
+	err = r.db.SelectContext(ctx, &events, query, args...)
+	endSpan(span, int64(len(events)), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+func (r *AuditRepositoryImpl) ListAfter(ctx context.Context, afterSeq int64, limit int) ([]*entities.AuditEvent, error) {
+	events := []*entities.AuditEvent{}
+	query := `SELECT * FROM audit_log WHERE seq > $1 ORDER BY seq LIMIT $2`
+
+	ctx, span := startSpan(ctx, "AuditRepository.ListAfter", query)
+	err := r.db.SelectContext(ctx, &events, query, afterSeq, limit)
+	endSpan(span, int64(len(events)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
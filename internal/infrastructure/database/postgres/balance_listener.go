@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// balanceChannel - канал уведомлений триггера wallets_balance_notify
+const balanceChannel = "wallet_balance"
+
+const balanceListenerPing = 90 * time.Second
+
+// BalanceListener слушает изменения балансов (LISTEN wallet_balance) на
+// выделенном соединении и раздает их подписчикам этого экземпляра
+type BalanceListener struct {
+	dsn string
+
+	mu     sync.Mutex
+	subs   map[uuid.UUID]map[chan *entities.BalanceUpdate]struct{}
+	closed bool
+}
+
+func NewBalanceListener(dsn string) *BalanceListener {
+	return &BalanceListener{
+		dsn:  dsn,
+		subs: make(map[uuid.UUID]map[chan *entities.BalanceUpdate]struct{}),
+	}
+}
+
+// Run слушает уведомления, пока ctx не отменен; потерянное соединение
+// восстанавливается само. По выходе каналы подписчиков закрываются.
+func (l *BalanceListener) Run(ctx context.Context) error {
+	defer l.closeAll()
+
+	log := logger.FromContext(ctx)
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Warn("balance listener disconnected", "error", err)
+		case pq.ListenerEventReconnected:
+			log.Info("balance listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Warn("balance listener failed to reconnect", "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(balanceChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				// Соединение восстановлено: уведомления за время обрыва потеряны
+				l.publishAll(nil)
+				continue
+			}
+			var update entities.BalanceUpdate
+			if err := json.Unmarshal([]byte(n.Extra), &update); err != nil {
+				log.Warn("invalid balance notification", "payload", n.Extra, "error", err)
+				continue
+			}
+			l.publish(update.WalletID, &update)
+		case <-time.After(balanceListenerPing):
+			// Проверка соединения: обрыв без трафика иначе не заметить
+			go listener.Ping()
+		}
+	}
+}
+
+func (l *BalanceListener) Subscribe(walletID uuid.UUID) (<-chan *entities.BalanceUpdate, func()) {
+	ch := make(chan *entities.BalanceUpdate, 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if l.subs[walletID] == nil {
+		l.subs[walletID] = make(map[chan *entities.BalanceUpdate]struct{})
+	}
+	l.subs[walletID][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if _, ok := l.subs[walletID][ch]; !ok {
+				return
+			}
+			delete(l.subs[walletID], ch)
+			if len(l.subs[walletID]) == 0 {
+				delete(l.subs, walletID)
+			}
+		})
+	}
+}
+
+func (l *BalanceListener) publish(walletID uuid.UUID, update *entities.BalanceUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subs[walletID] {
+		offer(ch, update)
+	}
+}
+
+func (l *BalanceListener) publishAll(update *entities.BalanceUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, chans := range l.subs {
+		for ch := range chans {
+			offer(ch, update)
+		}
+	}
+}
+
+func (l *BalanceListener) closeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for walletID, chans := range l.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(l.subs, walletID)
+	}
+}
+
+// offer кладет изменение в канал, заменяя недоставленное: подписчику нужен
+// последний баланс, а не все промежуточные. Вызывается под l.mu.
+func offer(ch chan *entities.BalanceUpdate, update *entities.BalanceUpdate) {
+	select {
+	case ch <- update:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+	default:
+	}
+	select {
+	case ch <- update:
+	default:
+	}
+}
@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// batchInsertChunk - операций в одном INSERT при создании задания
+const batchInsertChunk = 500
+
+type BatchRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewBatchRepository(db *sqlx.DB) repositories.BatchRepository {
+	return &BatchRepositoryImpl{db: db}
+}
+
+func (r *BatchRepositoryImpl) Create(ctx context.Context, job *entities.BatchJob, items []*entities.BatchItem) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	jobQuery := `
+		INSERT INTO batch_jobs (id, user_id, api_key_id, atomic, status, total, heartbeat_at, created_at, started_at)
+		VALUES (:id, :user_id, :api_key_id, :atomic, :status, :total, :heartbeat_at, :created_at, :started_at)
+	`
+	jctx, jspan := startSpan(ctx, "BatchRepository.Create", jobQuery)
+	res, err := tx.NamedExecContext(jctx, jobQuery, job)
+	endSpan(jspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	itemsQuery := `
+		INSERT INTO batch_job_items (job_id, item_index, wallet_id, operation_type, amount, reference, status)
+		VALUES (:job_id, :item_index, :wallet_id, :operation_type, :amount, :reference, :status)
+	`
+	for start := 0; start < len(items); start += batchInsertChunk {
+		chunk := items[start:min(start+batchInsertChunk, len(items))]
+		ictx, ispan := startSpan(ctx, "BatchRepository.CreateItems", itemsQuery)
+		res, err = tx.NamedExecContext(ictx, itemsQuery, chunk)
+		endSpan(ispan, rowsAffected(res), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *BatchRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*entities.BatchJob, error) {
+	var job entities.BatchJob
+	query := `
+		SELECT j.*,
+			COUNT(i.item_index) FILTER (WHERE i.status = 'succeeded') AS succeeded,
+			COUNT(i.item_index) FILTER (WHERE i.status = 'failed') AS failed,
+			COUNT(i.item_index) FILTER (WHERE i.status = 'skipped') AS skipped
+		FROM batch_jobs j
+		LEFT JOIN batch_job_items i ON i.job_id = j.id
+		WHERE j.id = $1
+		GROUP BY j.id
+	`
+
+	ctx, span := startSpan(ctx, "BatchRepository.FindByID", query)
+	err := r.db.GetContext(ctx, &job, query, id)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, repositories.ErrBatchJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *BatchRepositoryImpl) ListItems(
+	ctx context.Context,
+	jobID uuid.UUID,
+	status *entities.BatchItemStatus,
+	limit, offset int,
+) ([]*entities.BatchItem, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM batch_job_items WHERE job_id = $1 AND ($2::varchar IS NULL OR status = $2)`
+	cctx, cspan := startSpan(ctx, "BatchRepository.CountItems", countQuery)
+	err := r.db.GetContext(cctx, &total, countQuery, jobID, status)
+	endSpan(cspan, foundRows(err), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := []*entities.BatchItem{}
+	query := `
+		SELECT * FROM batch_job_items
+		WHERE job_id = $1 AND ($2::varchar IS NULL OR status = $2)
+		ORDER BY item_index
+		LIMIT $3 OFFSET $4
+	`
+	ctx, span := startSpan(ctx, "BatchRepository.ListItems", query)
+	err = r.db.SelectContext(ctx, &items, query, jobID, status, limit, offset)
+	endSpan(span, int64(len(items)), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
+
+func (r *BatchRepositoryImpl) PendingItems(ctx context.Context, jobID uuid.UUID) ([]*entities.BatchItem, error) {
+	items := []*entities.BatchItem{}
+	query := `SELECT * FROM batch_job_items WHERE job_id = $1 AND status = 'pending' ORDER BY item_index`
+
+	ctx, span := startSpan(ctx, "BatchRepository.PendingItems", query)
+	err := r.db.SelectContext(ctx, &items, query, jobID)
+	endSpan(span, int64(len(items)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (r *BatchRepositoryImpl) UpdateItems(ctx context.Context, items []*entities.BatchItem) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Результат пишется только один раз: исполнитель, у которого задание
+	// забрали, не перезапишет результат нового
+	query := `
+		UPDATE batch_job_items
+		SET status = :status, error = :error, operation_id = :operation_id, fee = :fee, balance_after = :balance_after
+		WHERE job_id = :job_id AND item_index = :item_index AND status = 'pending'
+	`
+	for _, item := range items {
+		qctx, span := startSpan(ctx, "BatchRepository.UpdateItem", query)
+		res, err := tx.NamedExecContext(qctx, query, item)
+		endSpan(span, rowsAffected(res), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *BatchRepositoryImpl) Claim(ctx context.Context, staleBefore time.Time) (*entities.BatchJob, error) {
+	var job entities.BatchJob
+	query := `
+		UPDATE batch_jobs
+		SET status = 'running', heartbeat_at = CURRENT_TIMESTAMP, started_at = COALESCE(started_at, CURRENT_TIMESTAMP)
+		WHERE id = (
+			SELECT id FROM batch_jobs
+			WHERE status = 'queued' OR status = 'running' AND heartbeat_at < $1
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+
+	ctx, span := startSpan(ctx, "BatchRepository.Claim", query)
+	err := r.db.GetContext(ctx, &job, query, staleBefore)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *BatchRepositoryImpl) Heartbeat(ctx context.Context, jobID uuid.UUID) error {
+	query := `UPDATE batch_jobs SET heartbeat_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'running'`
+
+	ctx, span := startSpan(ctx, "BatchRepository.Heartbeat", query)
+	res, err := r.db.ExecContext(ctx, query, jobID)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
+
+func (r *BatchRepositoryImpl) Finish(ctx context.Context, job *entities.BatchJob) error {
+	query := `UPDATE batch_jobs SET status = :status, error = :error, finished_at = :finished_at WHERE id = :id`
+
+	ctx, span := startSpan(ctx, "BatchRepository.Finish", query)
+	res, err := r.db.NamedExecContext(ctx, query, job)
+	affected := rowsAffected(res)
+	endSpan(span, affected, err)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repositories.ErrBatchJobNotFound
+	}
+	return nil
+}
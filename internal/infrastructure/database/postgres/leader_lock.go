@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LeaderLock - выбор ведущего экземпляра через сессионную advisory-блокировку
+// Postgres. Блокировка держится на выделенном соединении: если экземпляр
+// падает или теряет соединение, Postgres снимает ее сам и ведущим становится
+// другой экземпляр.
+type LeaderLock struct {
+	db  *sqlx.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewLeaderLock(db *sqlx.DB, key int64) *LeaderLock {
+	return &LeaderLock{db: db, key: key}
+}
+
+// Acquire возвращает true, если этот экземпляр ведущий: уже держит
+// блокировку на живом соединении или только что ее получил
+func (l *LeaderLock) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// Соединение потеряно - вместе с ним и блокировка
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	query := `SELECT pg_try_advisory_lock($1)`
+	qctx, span := startSpan(ctx, "LeaderLock.TryLock", query)
+	err = conn.QueryRowContext(qctx, query, l.key).Scan(&locked)
+	endSpan(span, foundRows(err), err)
+	if err != nil || !locked {
+		conn.Close()
+		return false, err
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// Release снимает блокировку и возвращает соединение в пул
+func (l *LeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	query := `SELECT pg_advisory_unlock($1)`
+	_, err := l.conn.ExecContext(ctx, query, l.key)
+	if err != nil {
+		// Соединение с неснятой блокировкой нельзя возвращать в пул - закрываем его
+		l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	l.conn.Close()
+	l.conn = nil
+	return err
+}
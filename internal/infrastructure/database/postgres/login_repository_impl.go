@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type LoginRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewLoginRepository(db *sqlx.DB) repositories.LoginRepository {
+	return &LoginRepositoryImpl{db: db}
+}
+
+func (r *LoginRepositoryImpl) RecordEvent(ctx context.Context, event *entities.LoginEvent) error {
+	query := `
+		INSERT INTO login_events (id, user_id, email, ip, user_agent, success, failure_reason, new_ip, created_at)
+		VALUES (:id, :user_id, :email, :ip, :user_agent, :success, :failure_reason, :new_ip, :created_at)
+	`
+
+	ctx, span := startSpan(ctx, "LoginRepository.RecordEvent", query)
+	res, err := r.db.NamedExecContext(ctx, query, event)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
+
+func (r *LoginRepositoryImpl) RecentEvents(ctx context.Context, userID uuid.UUID, limit int) ([]*entities.LoginEvent, error) {
+	events := []*entities.LoginEvent{}
+	query := `SELECT * FROM login_events WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	ctx, span := startSpan(ctx, "LoginRepository.RecentEvents", query)
+	err := r.db.SelectContext(ctx, &events, query, userID, limit)
+	endSpan(span, int64(len(events)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *LoginRepositoryImpl) CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM login_events WHERE ip = $1 AND NOT success AND created_at >= $2`
+
+	ctx, span := startSpan(ctx, "LoginRepository.CountFailuresByIP", query)
+	err := r.db.GetContext(ctx, &count, query, ip, since)
+	endSpan(span, foundRows(err), err)
+	return count, err
+}
+
+func (r *LoginRepositoryImpl) KnownIP(ctx context.Context, userID uuid.UUID, ip string) (bool, error) {
+	var known bool
+	query := `SELECT EXISTS(SELECT 1 FROM login_events WHERE user_id = $1 AND ip = $2 AND success)`
+
+	ctx, span := startSpan(ctx, "LoginRepository.KnownIP", query)
+	err := r.db.GetContext(ctx, &known, query, userID, ip)
+	endSpan(span, foundRows(err), err)
+	return known, err
+}
+
+func (r *LoginRepositoryImpl) FindLockout(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error) {
+	var lockout entities.LoginLockout
+	query := `SELECT * FROM login_lockouts WHERE user_id = $1`
+
+	ctx, span := startSpan(ctx, "LoginRepository.FindLockout", query)
+	err := r.db.GetContext(ctx, &lockout, query, userID)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockout, nil
+}
+
+func (r *LoginRepositoryImpl) RegisterFailure(ctx context.Context, userID uuid.UUID) (*entities.LoginLockout, error) {
+	var lockout entities.LoginLockout
+	query := `
+		INSERT INTO login_lockouts (user_id, failed_count, last_failed_at)
+		VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE
+		SET failed_count = login_lockouts.failed_count + 1, last_failed_at = CURRENT_TIMESTAMP
+		RETURNING *
+	`
+
+	ctx, span := startSpan(ctx, "LoginRepository.RegisterFailure", query)
+	err := r.db.GetContext(ctx, &lockout, query, userID)
+	endSpan(span, foundRows(err), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockout, nil
+}
+
+func (r *LoginRepositoryImpl) Lock(ctx context.Context, userID uuid.UUID, threshold int, until time.Time, event *entities.AuditEvent) (_ bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE login_lockouts
+		SET locked_until = $3, lockout_count = lockout_count + 1, failed_count = 0
+		WHERE user_id = $1 AND failed_count >= $2
+	`
+	lctx, span := startSpan(ctx, "LoginRepository.Lock", query)
+	res, err := tx.ExecContext(lctx, query, userID, threshold, until)
+	affected := rowsAffected(res)
+	endSpan(span, affected, err)
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		if err = appendAuditEvent(ctx, tx, event); err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	return affected > 0, err
+}
+
+func (r *LoginRepositoryImpl) ResetFailures(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM login_lockouts WHERE user_id = $1`
+
+	ctx, span := startSpan(ctx, "LoginRepository.ResetFailures", query)
+	res, err := r.db.ExecContext(ctx, query, userID)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
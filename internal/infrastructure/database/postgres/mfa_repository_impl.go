@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type MFARepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewMFARepository(db *sqlx.DB) repositories.MFARepository {
+	return &MFARepositoryImpl{db: db}
+}
+
+func (r *MFARepositoryImpl) Find(ctx context.Context, userID uuid.UUID) (*entities.UserMFA, error) {
+	var mfa entities.UserMFA
+	query := `SELECT * FROM user_mfa WHERE user_id = $1`
+
+	ctx, span := startSpan(ctx, "MFARepository.Find", query)
+	err := r.db.GetContext(ctx, &mfa, query, userID)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &mfa, nil
+}
+
+func (r *MFARepositoryImpl) Save(ctx context.Context, mfa *entities.UserMFA, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled, last_used_step, created_at, confirmed_at)
+		VALUES (:user_id, :secret, :enabled, :last_used_step, :created_at, :confirmed_at)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			enabled = EXCLUDED.enabled,
+			last_used_step = EXCLUDED.last_used_step,
+			created_at = EXCLUDED.created_at,
+			confirmed_at = EXCLUDED.confirmed_at
+	`
+
+	sctx, span := startSpan(ctx, "MFARepository.Save", query)
+	res, err := tx.NamedExecContext(sctx, query, mfa)
+	endSpan(span, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *MFARepositoryImpl) Delete(ctx context.Context, userID uuid.UUID, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, query := range []string{
+		`DELETE FROM user_recovery_codes WHERE user_id = $1`,
+		`DELETE FROM user_mfa WHERE user_id = $1`,
+	} {
+		qctx, span := startSpan(ctx, "MFARepository.Delete", query)
+		var res sql.Result
+		res, err = tx.ExecContext(qctx, query, userID)
+		endSpan(span, rowsAffected(res), err)
+		if err != nil {
+			return err
+		}
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *MFARepositoryImpl) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+
+	ctx, span := startSpan(ctx, "MFARepository.UseStep", query)
+	res, err := r.db.ExecContext(ctx, query, userID, step)
+	endSpan(span, rowsAffected(res), err)
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected(res) == 1, nil
+}
+
+func (r *MFARepositoryImpl) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	deleteQuery := `DELETE FROM user_recovery_codes WHERE user_id = $1`
+	dctx, dspan := startSpan(ctx, "MFARepository.DeleteRecoveryCodes", deleteQuery)
+	res, err := tx.ExecContext(dctx, deleteQuery, userID)
+	endSpan(dspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `INSERT INTO user_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+	for _, hash := range codeHashes {
+		ictx, ispan := startSpan(ctx, "MFARepository.InsertRecoveryCode", insertQuery)
+		res, err = tx.ExecContext(ictx, insertQuery, uuid.New(), userID, hash)
+		endSpan(ispan, rowsAffected(res), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *MFARepositoryImpl) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	ctx, span := startSpan(ctx, "MFARepository.UseRecoveryCode", query)
+	res, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	endSpan(span, rowsAffected(res), err)
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected(res) == 1, nil
+}
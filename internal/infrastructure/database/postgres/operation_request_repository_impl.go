@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type OperationRequestRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewOperationRequestRepository(db *sqlx.DB) repositories.OperationRequestRepository {
+	return &OperationRequestRepositoryImpl{db: db}
+}
+
+func (r *OperationRequestRepositoryImpl) Create(ctx context.Context, request *entities.OperationRequest) error {
+	query := `
+		INSERT INTO operation_requests (id, user_id, api_key_id, wallet_id, operation_type, amount, status,
+			available_at, created_at, updated_at)
+		VALUES (:id, :user_id, :api_key_id, :wallet_id, :operation_type, :amount, :status,
+			:available_at, :created_at, :updated_at)
+	`
+
+	ctx, span := startSpan(ctx, "OperationRequestRepository.Create", query)
+	res, err := r.db.NamedExecContext(ctx, query, request)
+	endSpan(span, rowsAffected(res), err)
+	if isUniqueViolation(err) {
+		return repositories.ErrDuplicate
+	}
+	return err
+}
+
+func (r *OperationRequestRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*entities.OperationRequest, error) {
+	var request entities.OperationRequest
+	query := `SELECT * FROM operation_requests WHERE id = $1`
+
+	ctx, span := startSpan(ctx, "OperationRequestRepository.FindByID", query)
+	err := r.db.GetContext(ctx, &request, query, id)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, repositories.ErrOperationRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (r *OperationRequestRepositoryImpl) Claim(ctx context.Context, now, staleBefore time.Time) (*entities.OperationRequest, error) {
+	var request entities.OperationRequest
+	query := `
+		UPDATE operation_requests
+		SET status = 'processing', claimed_at = $1, attempts = attempts + 1, updated_at = $1
+		WHERE id = (
+			SELECT id FROM operation_requests
+			WHERE status = 'pending' AND available_at <= $1
+				OR status = 'processing' AND claimed_at < $2
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+
+	ctx, span := startSpan(ctx, "OperationRequestRepository.Claim", query)
+	err := r.db.GetContext(ctx, &request, query, now, staleBefore)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (r *OperationRequestRepositoryImpl) Save(ctx context.Context, request *entities.OperationRequest) error {
+	// attempts меняется при каждом захвате и отличает эту попытку от следующей
+	query := `
+		UPDATE operation_requests
+		SET status = :status, reason = :reason, operation_id = :operation_id, fee = :fee,
+			balance_after = :balance_after, available_at = :available_at, claimed_at = :claimed_at,
+			updated_at = :updated_at, completed_at = :completed_at
+		WHERE id = :id AND status = 'processing' AND attempts = :attempts
+	`
+
+	ctx, span := startSpan(ctx, "OperationRequestRepository.Save", query)
+	res, err := r.db.NamedExecContext(ctx, query, request)
+	rows := rowsAffected(res)
+	endSpan(span, rows, err)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repositories.ErrOperationRequestClaimLost
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePrefix готовит значение для поиска по префиксу через LIKE
+func likePrefix(prefix string) string {
+	return likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
+
+// uuidArray передает список id параметром вида $1::uuid[]
+func uuidArray(ids []uuid.UUID) pq.StringArray {
+	arr := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		arr[i] = id.String()
+	}
+	return arr
+}
@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// reconciliationLockID - ключ advisory-блокировки, не дающей двум экземплярам
+// сервиса сверять кошельки одновременно
+const reconciliationLockID = 7318462052
+
+type ReconciliationRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewReconciliationRepository(db *sqlx.DB) repositories.ReconciliationRepository {
+	return &ReconciliationRepositoryImpl{db: db}
+}
+
+// discrepanciesInsert сравнивает баланс каждого кошелька с balance_after
+// последней операции (в том же порядке, что и BalanceAt) и с суммой операций
+// со знаком. Кошелек без операций должен иметь нулевой баланс.
+const discrepanciesInsert = `
+	INSERT INTO reconciliation_discrepancies (run_id, wallet_id, balance, last_balance_after,
+		operations_sum, operations_count, last_balance_drift, sum_drift)
+	SELECT $1, s.wallet_id, s.balance, s.last_balance_after, s.operations_sum, s.operations_count,
+		s.balance - COALESCE(s.last_balance_after, 0), s.balance - s.operations_sum
+	FROM (
+		SELECT w.id AS wallet_id, w.balance, last.balance_after AS last_balance_after,
+			COALESCE(agg.operations_sum, 0) AS operations_sum, agg.operations_count
+		FROM wallets w
+		LEFT JOIN LATERAL (
+			SELECT o.balance_after FROM operations o
+			WHERE o.wallet_id = w.id
+			ORDER BY o.created_at DESC, o.seq DESC NULLS LAST
+			LIMIT 1
+		) last ON TRUE
+		CROSS JOIN LATERAL (
+			SELECT SUM(CASE WHEN o.operation_type = 'WITHDRAW' THEN -o.amount ELSE o.amount END) AS operations_sum,
+				COUNT(*) AS operations_count
+			FROM operations o
+			WHERE o.wallet_id = w.id
+		) agg
+	) s
+	WHERE s.balance <> COALESCE(s.last_balance_after, 0) OR s.balance <> s.operations_sum
+	RETURNING *
+`
+
+func (r *ReconciliationRepositoryImpl) Reconcile(ctx context.Context, run *entities.ReconciliationRun) (_ []*entities.Discrepancy, err error) {
+	// REPEATABLE READ: подсчет кошельков и поиск расхождений видят один снимок
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var locked bool
+	lockQuery := `SELECT pg_try_advisory_xact_lock($1)`
+	lctx, lspan := startSpan(ctx, "ReconciliationRepository.Lock", lockQuery)
+	err = tx.GetContext(lctx, &locked, lockQuery, reconciliationLockID)
+	endSpan(lspan, foundRows(err), err)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		err = repositories.ErrReconciliationRunning
+		return nil, err
+	}
+
+	runQuery := `
+		INSERT INTO reconciliation_runs (id, started_at, finished_at, wallets_checked, discrepancies)
+		VALUES (:id, :started_at, :finished_at, :wallets_checked, :discrepancies)
+	`
+	rctx, rspan := startSpan(ctx, "ReconciliationRepository.CreateRun", runQuery)
+	res, err := tx.NamedExecContext(rctx, runQuery, run)
+	endSpan(rspan, rowsAffected(res), err)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := []*entities.Discrepancy{}
+	dctx, dspan := startSpan(ctx, "ReconciliationRepository.FindDiscrepancies", discrepanciesInsert)
+	err = tx.SelectContext(dctx, &discrepancies, discrepanciesInsert, run.ID)
+	endSpan(dspan, int64(len(discrepancies)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	run.Discrepancies = int64(len(discrepancies))
+	run.FinishedAt = time.Now().Truncate(time.Microsecond)
+	finishQuery := `
+		UPDATE reconciliation_runs
+		SET finished_at = $2, discrepancies = $3, wallets_checked = (SELECT COUNT(*) FROM wallets)
+		WHERE id = $1
+		RETURNING wallets_checked
+	`
+	fctx, fspan := startSpan(ctx, "ReconciliationRepository.FinishRun", finishQuery)
+	err = tx.GetContext(fctx, &run.WalletsChecked, finishQuery, run.ID, run.FinishedAt, run.Discrepancies)
+	endSpan(fspan, foundRows(err), err)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	return discrepancies, err
+}
+
+func (r *ReconciliationRepositoryImpl) LatestRun(ctx context.Context) (*entities.ReconciliationRun, error) {
+	var run entities.ReconciliationRun
+	query := `SELECT * FROM reconciliation_runs ORDER BY started_at DESC LIMIT 1`
+
+	ctx, span := startSpan(ctx, "ReconciliationRepository.LatestRun", query)
+	err := r.db.GetContext(ctx, &run, query)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (r *ReconciliationRepositoryImpl) FindRun(ctx context.Context, id uuid.UUID) (*entities.ReconciliationRun, error) {
+	var run entities.ReconciliationRun
+	query := `SELECT * FROM reconciliation_runs WHERE id = $1`
+
+	ctx, span := startSpan(ctx, "ReconciliationRepository.FindRun", query)
+	err := r.db.GetContext(ctx, &run, query, id)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, repositories.ErrReconciliationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (r *ReconciliationRepositoryImpl) ListRuns(ctx context.Context, limit, offset int) ([]*entities.ReconciliationRun, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM reconciliation_runs`
+	cctx, cspan := startSpan(ctx, "ReconciliationRepository.CountRuns", countQuery)
+	err := r.db.GetContext(cctx, &total, countQuery)
+	endSpan(cspan, foundRows(err), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runs := []*entities.ReconciliationRun{}
+	query := `SELECT * FROM reconciliation_runs ORDER BY started_at DESC LIMIT $1 OFFSET $2`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.ListRuns", query)
+	err = r.db.SelectContext(ctx, &runs, query, limit, offset)
+	endSpan(span, int64(len(runs)), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+func (r *ReconciliationRepositoryImpl) ListDiscrepancies(ctx context.Context, runID uuid.UUID) ([]*entities.Discrepancy, error) {
+	discrepancies := []*entities.Discrepancy{}
+	query := `SELECT * FROM reconciliation_discrepancies WHERE run_id = $1 ORDER BY wallet_id`
+
+	ctx, span := startSpan(ctx, "ReconciliationRepository.ListDiscrepancies", query)
+	err := r.db.SelectContext(ctx, &discrepancies, query, runID)
+	endSpan(span, int64(len(discrepancies)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return discrepancies, nil
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// serializableAttempts - сколько раз выполняется serializable-транзакция,
+// прежде чем конфликт с параллельной отдается вызывающему
+const serializableAttempts = 3
+
+// isSerializationFailure - транзакция откачена из-за конфликта с параллельной
+// и может быть повторена целиком
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// retrySerializable выполняет tx заново при конфликте сериализации. Кошелек
+// комиссий участвует во многих операциях, и без повтора параллельные
+// операции с комиссией отказывали бы чаще, чем проходили.
+func retrySerializable(ctx context.Context, tx func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = tx(); !isSerializationFailure(err) || attempt == serializableAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type ScheduleRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewScheduleRepository(db *sqlx.DB) repositories.ScheduleRepository {
+	return &ScheduleRepositoryImpl{db: db}
+}
+
+func (r *ScheduleRepositoryImpl) Create(ctx context.Context, schedule *entities.Schedule) error {
+	query := `
+		INSERT INTO schedules (id, user_id, from_wallet_id, to_wallet_id, amount, description, cron, interval_seconds,
+			status, next_run_at, created_at, updated_at)
+		VALUES (:id, :user_id, :from_wallet_id, :to_wallet_id, :amount, :description, :cron, :interval_seconds,
+			:status, :next_run_at, :created_at, :updated_at)
+	`
+
+	ctx, span := startSpan(ctx, "ScheduleRepository.Create", query)
+	res, err := r.db.NamedExecContext(ctx, query, schedule)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
+
+func (r *ScheduleRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*entities.Schedule, error) {
+	var schedule entities.Schedule
+	query := `SELECT * FROM schedules WHERE id = $1`
+
+	ctx, span := startSpan(ctx, "ScheduleRepository.FindByID", query)
+	err := r.db.GetContext(ctx, &schedule, query, id)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, repositories.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+func (r *ScheduleRepositoryImpl) ListByWallet(ctx context.Context, walletID uuid.UUID) ([]*entities.Schedule, error) {
+	schedules := []*entities.Schedule{}
+	query := `
+		SELECT * FROM schedules
+		WHERE from_wallet_id = $1 AND status <> 'cancelled'
+		ORDER BY created_at
+	`
+
+	ctx, span := startSpan(ctx, "ScheduleRepository.ListByWallet", query)
+	err := r.db.SelectContext(ctx, &schedules, query, walletID)
+	endSpan(span, int64(len(schedules)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepositoryImpl) UpdateState(ctx context.Context, schedule *entities.Schedule) error {
+	query := `
+		UPDATE schedules
+		SET status = :status, paused_reason = :paused_reason, next_run_at = :next_run_at, retry_at = :retry_at,
+			attempts = :attempts, insufficient_funds_count = :insufficient_funds_count, updated_at = :updated_at
+		WHERE id = :id
+	`
+
+	ctx, span := startSpan(ctx, "ScheduleRepository.UpdateState", query)
+	res, err := r.db.NamedExecContext(ctx, query, schedule)
+	affected := rowsAffected(res)
+	endSpan(span, affected, err)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repositories.ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (r *ScheduleRepositoryImpl) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.Schedule, error) {
+	schedules := []*entities.Schedule{}
+	query := `
+		SELECT * FROM schedules
+		WHERE status = 'active' AND COALESCE(retry_at, next_run_at) <= $1
+		ORDER BY COALESCE(retry_at, next_run_at)
+		LIMIT $2
+	`
+
+	ctx, span := startSpan(ctx, "ScheduleRepository.ListDue", query)
+	err := r.db.SelectContext(ctx, &schedules, query, now, limit)
+	endSpan(span, int64(len(schedules)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepositoryImpl) RecordRun(ctx context.Context, schedule *entities.Schedule, run *entities.ScheduleRun) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	runQuery := `
+		INSERT INTO schedule_runs (id, schedule_id, scheduled_for, attempt, status, error, transfer_id, executed_at)
+		VALUES (:id, :schedule_id, :scheduled_for, :attempt, :status, :error, :transfer_id, :executed_at)
+	`
+	rctx, rspan := startSpan(ctx, "ScheduleRepository.InsertRun", runQuery)
+	res, err := tx.NamedExecContext(rctx, runQuery, run)
+	endSpan(rspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	updateQuery := `
+		UPDATE schedules
+		SET next_run_at = :next_run_at, retry_at = :retry_at, attempts = :attempts,
+			insufficient_funds_count = :insufficient_funds_count, last_run_at = :last_run_at,
+			status = CASE WHEN status = 'active' THEN :status ELSE status END,
+			paused_reason = CASE WHEN status = 'active' THEN :paused_reason ELSE paused_reason END,
+			updated_at = :updated_at
+		WHERE id = :id
+	`
+	uctx, uspan := startSpan(ctx, "ScheduleRepository.UpdateAfterRun", updateQuery)
+	res, err = tx.NamedExecContext(uctx, updateQuery, schedule)
+	endSpan(uspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *ScheduleRepositoryImpl) ListRuns(ctx context.Context, scheduleID uuid.UUID, limit, offset int) ([]*entities.ScheduleRun, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM schedule_runs WHERE schedule_id = $1`
+	cctx, cspan := startSpan(ctx, "ScheduleRepository.CountRuns", countQuery)
+	err := r.db.GetContext(cctx, &total, countQuery, scheduleID)
+	endSpan(cspan, foundRows(err), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runs := []*entities.ScheduleRun{}
+	query := `
+		SELECT * FROM schedule_runs
+		WHERE schedule_id = $1
+		ORDER BY executed_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	ctx, span := startSpan(ctx, "ScheduleRepository.ListRuns", query)
+	err = r.db.SelectContext(ctx, &runs, query, scheduleID, limit, offset)
+	endSpan(span, int64(len(runs)), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
@@ -0,0 +1,198 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SessionRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewSessionRepository(db *sqlx.DB) repositories.SessionRepository {
+	return &SessionRepositoryImpl{db: db}
+}
+
+func (r *SessionRepositoryImpl) Create(ctx context.Context, session *entities.Session, token *entities.RefreshToken) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	sessionQuery := `
+		INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at)
+		VALUES (:id, :user_id, :user_agent, :ip, :created_at, :last_used_at)
+	`
+	sctx, sspan := startSpan(ctx, "SessionRepository.Create", sessionQuery)
+	res, err := tx.NamedExecContext(sctx, sessionQuery, session)
+	endSpan(sspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	if err = insertRefreshToken(ctx, tx, token); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *SessionRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*entities.Session, error) {
+	var session entities.Session
+	query := `SELECT * FROM sessions WHERE id = $1`
+
+	ctx, span := startSpan(ctx, "SessionRepository.FindByID", query)
+	err := r.db.GetContext(ctx, &session, query, id)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *SessionRepositoryImpl) FindRefreshToken(ctx context.Context, tokenHash string) (*entities.RefreshToken, error) {
+	var token entities.RefreshToken
+	query := `SELECT * FROM refresh_tokens WHERE token_hash = $1`
+
+	ctx, span := startSpan(ctx, "SessionRepository.FindRefreshToken", query)
+	err := r.db.GetContext(ctx, &token, query, tokenHash)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *SessionRepositoryImpl) Rotate(ctx context.Context, old, next *entities.RefreshToken) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Условие used_at IS NULL делает обмен однократным даже при гонке
+	useQuery := `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL`
+	uctx, uspan := startSpan(ctx, "SessionRepository.MarkTokenUsed", useQuery)
+	res, err := tx.ExecContext(uctx, useQuery, old.ID)
+	endSpan(uspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+	if rowsAffected(res) == 0 {
+		err = repositories.ErrTokenAlreadyUsed
+		return err
+	}
+
+	if err = insertRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+
+	touchQuery := `UPDATE sessions SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`
+	tctx, tspan := startSpan(ctx, "SessionRepository.Touch", touchQuery)
+	res, err = tx.ExecContext(tctx, touchQuery, old.SessionID)
+	endSpan(tspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *SessionRepositoryImpl) Revoke(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, span := startSpan(ctx, "SessionRepository.Revoke", query)
+	res, err := r.db.ExecContext(ctx, query, id, reason)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
+
+func (r *SessionRepositoryImpl) RevokeAllForUser(ctx context.Context, userID uuid.UUID, reason string) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	query := `
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoke_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING id
+	`
+
+	ctx, span := startSpan(ctx, "SessionRepository.RevokeAllForUser", query)
+	err := r.db.SelectContext(ctx, &ids, query, userID, reason)
+	endSpan(span, int64(len(ids)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func insertRefreshToken(ctx context.Context, tx *sqlx.Tx, token *entities.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, session_id, token_hash, created_at, expires_at)
+		VALUES (:id, :session_id, :token_hash, :created_at, :expires_at)
+	`
+
+	ctx, span := startSpan(ctx, "SessionRepository.InsertRefreshToken", query)
+	res, err := tx.NamedExecContext(ctx, query, token)
+	endSpan(span, rowsAffected(res), err)
+	return err
+}
+
+// SessionDenylist проверяет отзыв сессии прямо по таблице sessions.
+// Используется, когда Redis не настроен.
+type SessionDenylist struct {
+	db *sqlx.DB
+}
+
+func NewSessionDenylist(db *sqlx.DB) *SessionDenylist {
+	return &SessionDenylist{db: db}
+}
+
+// Add ничего не делает: отзыв уже записан в sessions
+func (d *SessionDenylist) Add(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	return nil
+}
+
+// Contains считает отозванной и сессию, которой больше нет (например, после удаления пользователя)
+func (d *SessionDenylist) Contains(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS(SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL)`
+
+	ctx, span := startSpan(ctx, "SessionDenylist.Contains", query)
+	err := d.db.GetContext(ctx, &active, query, sessionID)
+	endSpan(span, foundRows(err), err)
+	if err != nil {
+		return false, err
+	}
+
+	return !active, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
+)
+
+const rowsAttr = attribute.Key("db.rows_affected")
+
+// querySpan объединяет спан запроса и логгер запроса
+type querySpan struct {
+	trace.Span
+	name  string
+	start time.Time
+	log   logger.Logger
+}
+
+// startSpan открывает клиентский спан для SQL-запроса
+func startSpan(ctx context.Context, name, query string) (context.Context, *querySpan) {
+	query = strings.Join(strings.Fields(query), " ")
+	operation := query
+	if i := strings.IndexByte(query, ' '); i > 0 {
+		operation = query[:i]
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+			semconv.DBOperationName(strings.ToUpper(operation)),
+		),
+	)
+	return ctx, &querySpan{Span: span, name: name, start: time.Now(), log: logger.FromContext(ctx)}
+}
+
+// endSpan записывает число строк и ошибку в спан и лог запроса;
+// sql.ErrNoRows ошибкой не считается
+func endSpan(span *querySpan, rows int64, err error) {
+	span.SetAttributes(rowsAttr.Int64(rows))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.log.Error("query failed", "query", span.name, "duration", time.Since(span.start), "error", err)
+	} else {
+		span.log.Debug("query executed", "query", span.name, "rows", rows, "duration", time.Since(span.start))
+	}
+	span.End()
+}
+
+// rowsAffected безопасно извлекает число затронутых строк
+func rowsAffected(res sql.Result) int64 {
+	if res == nil {
+		return 0
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// foundRows возвращает число строк для запросов, читающих одну запись
+func foundRows(err error) int64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
@@ -3,11 +3,14 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"walletapitest/internal/domain/entities"
 	"walletapitest/internal/domain/repositories"
-	
+
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type UserRepositoryImpl struct {
@@ -20,67 +23,256 @@ func NewUserRepository(db *sqlx.DB) repositories.UserRepository {
 
 func (r *UserRepositoryImpl) Create(ctx context.Context, user *entities.User) error {
 	query := `
-		INSERT INTO users (id, email, username, password, created_at, updated_at)
-		VALUES (:id, :email, :username, :password, :created_at, :updated_at)
+		INSERT INTO users (id, email, username, password, role, tier, email_verified_at, created_at, updated_at)
+		VALUES (:id, :email, :username, :password, :role, :tier, :email_verified_at, :created_at, :updated_at)
 	`
-	
-	_, err := r.db.NamedExecContext(ctx, query, user)
+
+	ctx, span := startSpan(ctx, "UserRepository.Create", query)
+	res, err := r.db.NamedExecContext(ctx, query, user)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
 func (r *UserRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
 	var user entities.User
 	query := `SELECT * FROM users WHERE id = $1`
-	
+
+	ctx, span := startSpan(ctx, "UserRepository.FindByID", query)
 	err := r.db.GetContext(ctx, &user, query, id)
+	endSpan(span, foundRows(err), err)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	
+
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &user, nil
 }
 
 func (r *UserRepositoryImpl) FindByEmail(ctx context.Context, email string) (*entities.User, error) {
 	var user entities.User
 	query := `SELECT * FROM users WHERE email = $1`
-	
+
+	ctx, span := startSpan(ctx, "UserRepository.FindByEmail", query)
 	err := r.db.GetContext(ctx, &user, query, email)
+	endSpan(span, foundRows(err), err)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	
+
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &user, nil
 }
 
-func (r *UserRepositoryImpl) Update(ctx context.Context, user *entities.User) error {
+func (r *UserRepositoryImpl) Update(ctx context.Context, user *entities.User, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var oldEmail string
+	lockQuery := `SELECT email FROM users WHERE id = $1 FOR UPDATE`
+	lctx, span := startSpan(ctx, "UserRepository.Update.lock", lockQuery)
+	err = tx.GetContext(lctx, &oldEmail, lockQuery, user.ID)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		err = nil
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
 	query := `
-		UPDATE users 
-		SET email = :email, username = :username, password = :password, updated_at = :updated_at
+		UPDATE users
+		SET email = :email, username = :username, password = :password, role = :role, tier = :tier,
+			email_verified_at = :email_verified_at, updated_at = :updated_at
 		WHERE id = :id
 	`
-	
-	_, err := r.db.NamedExecContext(ctx, query, user)
+
+	uctx, span := startSpan(ctx, "UserRepository.Update", query)
+	res, err := tx.NamedExecContext(uctx, query, user)
+	endSpan(span, rowsAffected(res), err)
+	if isUniqueViolation(err) {
+		err = repositories.ErrDuplicate
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(oldEmail, user.Email) {
+		tokensQuery := `DELETE FROM user_tokens WHERE user_id = $1 AND used_at IS NULL`
+		tctx, span := startSpan(ctx, "UserRepository.Update.tokens", tokensQuery)
+		res, err = tx.ExecContext(tctx, tokensQuery, user.ID)
+		endSpan(span, rowsAffected(res), err)
+		if err != nil {
+			return err
+		}
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	return err
 }
 
 func (r *UserRepositoryImpl) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+
+	ctx, span := startSpan(ctx, "UserRepository.Delete", query)
+	res, err := r.db.ExecContext(ctx, query, id)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
 func (r *UserRepositoryImpl) List(ctx context.Context, limit, offset int) ([]*entities.User, error) {
 	var users []*entities.User
 	query := `SELECT * FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2`
-	
+
+	ctx, span := startSpan(ctx, "UserRepository.List", query)
 	err := r.db.SelectContext(ctx, &users, query, limit, offset)
+	endSpan(span, int64(len(users)), err)
 	return users, err
-}
\ No newline at end of file
+}
+
+func (r *UserRepositoryImpl) FindByUsername(ctx context.Context, username string) (*entities.User, error) {
+	var user entities.User
+	query := `SELECT * FROM users WHERE lower(username) = lower($1) LIMIT 1`
+
+	ctx, span := startSpan(ctx, "UserRepository.FindByUsername", query)
+	err := r.db.GetContext(ctx, &user, query, username)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// userSortColumns - белый список колонок сортировки
+var userSortColumns = map[string]string{
+	"created_at": "created_at",
+	"email":      "email",
+	"username":   "username",
+}
+
+func (r *UserRepositoryImpl) Search(ctx context.Context, filter repositories.UserFilter) ([]*entities.User, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.EmailPrefix != "" {
+		args = append(args, likePrefix(filter.EmailPrefix))
+		conditions = append(conditions, "lower(email) LIKE $"+itoa(len(args)))
+	}
+	if filter.UsernamePrefix != "" {
+		args = append(args, likePrefix(filter.UsernamePrefix))
+		conditions = append(conditions, "lower(username) LIKE $"+itoa(len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users` + where
+	cctx, cspan := startSpan(ctx, "UserRepository.Count", countQuery)
+	err := r.db.GetContext(cctx, &total, countQuery, args...)
+	endSpan(cspan, foundRows(err), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := `SELECT * FROM users` + where +
+		` ORDER BY ` + column + ` ` + direction + `, id` +
+		` LIMIT $` + itoa(len(args)-1) + ` OFFSET $` + itoa(len(args))
+
+	users := []*entities.User{}
+	ctx, span := startSpan(ctx, "UserRepository.Search", query)
+	err = r.db.SelectContext(ctx, &users, query, args...)
+	endSpan(span, int64(len(users)), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *UserRepositoryImpl) DeleteWithWallets(ctx context.Context, id uuid.UUID, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Блокируем все кошельки, чтобы параллельное пополнение не прошло между проверкой и удалением
+	var balances []int64
+	lockQuery := `SELECT balance FROM wallets WHERE user_id = $1 FOR UPDATE`
+	lctx, lspan := startSpan(ctx, "UserRepository.LockWallets", lockQuery)
+	err = tx.SelectContext(lctx, &balances, lockQuery, id)
+	endSpan(lspan, int64(len(balances)), err)
+	if err != nil {
+		return err
+	}
+	for _, balance := range balances {
+		if balance != 0 {
+			err = repositories.ErrUserHasFunds
+			return err
+		}
+	}
+
+	walletsQuery := `DELETE FROM wallets WHERE user_id = $1`
+	wctx, wspan := startSpan(ctx, "UserRepository.DeleteWallets", walletsQuery)
+	res, err := tx.ExecContext(wctx, walletsQuery, id)
+	endSpan(wspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	userQuery := `DELETE FROM users WHERE id = $1`
+	uctx, uspan := startSpan(ctx, "UserRepository.Delete", userQuery)
+	res, err = tx.ExecContext(uctx, userQuery, id)
+	endSpan(uspan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type UserTokenRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewUserTokenRepository(db *sqlx.DB) repositories.UserTokenRepository {
+	return &UserTokenRepositoryImpl{db: db}
+}
+
+func (r *UserTokenRepositoryImpl) Create(ctx context.Context, token *entities.UserToken) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	expireQuery := `
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+	ectx, espan := startSpan(ctx, "UserTokenRepository.ExpirePrevious", expireQuery)
+	res, err := tx.ExecContext(ectx, expireQuery, token.UserID, token.Purpose)
+	endSpan(espan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, created_at, expires_at)
+		VALUES (:id, :user_id, :purpose, :token_hash, :created_at, :expires_at)
+	`
+	ictx, ispan := startSpan(ctx, "UserTokenRepository.Create", insertQuery)
+	res, err = tx.NamedExecContext(ictx, insertQuery, token)
+	endSpan(ispan, rowsAffected(res), err)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *UserTokenRepositoryImpl) Consume(ctx context.Context, tokenHash string, purpose entities.UserTokenPurpose) (*entities.UserToken, error) {
+	var token entities.UserToken
+	query := `
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING *
+	`
+
+	ctx, span := startSpan(ctx, "UserTokenRepository.Consume", query)
+	err := r.db.GetContext(ctx, &token, query, tokenHash, purpose)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *UserTokenRepositoryImpl) Latest(ctx context.Context, userID uuid.UUID, purpose entities.UserTokenPurpose) (*entities.UserToken, error) {
+	var token entities.UserToken
+	query := `SELECT * FROM user_tokens WHERE user_id = $1 AND purpose = $2 ORDER BY created_at DESC LIMIT 1`
+
+	ctx, span := startSpan(ctx, "UserTokenRepository.Latest", query)
+	err := r.db.GetContext(ctx, &token, query, userID, purpose)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
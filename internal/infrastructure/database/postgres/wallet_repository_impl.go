@@ -4,12 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 	"walletapitest/internal/domain/entities"
 	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/tracing"
 )
 
 type WalletRepositoryImpl struct {
@@ -20,114 +24,814 @@ func NewWalletRepository(db *sqlx.DB) repositories.WalletRepository {
 	return &WalletRepositoryImpl{db: db}
 }
 
-// ProcessOperationAtomic выполняет атомарную операцию пополнения или списания
+// ProcessOperationAtomic выполняет атомарную операцию пополнения или списания.
+// Если по fees за операцию положена комиссия, она списывается с того же
+// кошелька на кошелек комиссий в той же транзакции. Операция с уже
+// использованным requestID не проводится: возвращается прежний результат.
 func (r *WalletRepositoryImpl) ProcessOperationAtomic(
 	ctx context.Context,
 	walletID uuid.UUID,
 	operationType entities.OperationType,
 	amount int64,
-) error {
+	requestID *uuid.UUID,
+	fees *entities.FeeSchedule,
+) (_ *entities.OperationResult, err error) {
+	ctx, span := tracing.Start(ctx, "WalletRepository.ProcessOperationAtomic",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.String("operation.type", string(operationType)),
+		attribute.Int64("operation.amount", amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	leg := operationLeg{
+		walletID:      walletID,
+		operationType: operationType,
+		amount:        amount,
+		requestID:     requestID,
+	}
+	if requestID != nil {
+		if result, err := r.loadRequest(ctx, leg); err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	var fee *entities.Fee
+	operation, err := r.applyOperation(ctx, leg, func(tx *sqlx.Tx, operation *entities.Operation) (err error) {
+		fee, err = r.chargeFee(ctx, tx, fees, string(operationType), operation.WalletID, amount, operation.ID)
+		return err
+	})
+	if requestID != nil && isUniqueViolation(err) {
+		// Ту же операцию провели параллельно: отдаем ее результат
+		if result, lerr := r.loadRequest(ctx, leg); lerr != nil || result != nil {
+			return result, lerr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entities.OperationResult{Operation: operation, Fee: fee}, nil
+}
+
+func (r *WalletRepositoryImpl) FindRequest(
+	ctx context.Context,
+	walletID uuid.UUID,
+	operationType entities.OperationType,
+	amount int64,
+	requestID uuid.UUID,
+) (*entities.OperationResult, error) {
+	return r.loadRequest(ctx, operationLeg{
+		walletID:      walletID,
+		operationType: operationType,
+		amount:        amount,
+		requestID:     &requestID,
+	})
+}
+
+// loadRequest возвращает результат операции, уже проведенной с leg.requestID;
+// nil - такой операции не было
+func (r *WalletRepositoryImpl) loadRequest(ctx context.Context, leg operationLeg) (*entities.OperationResult, error) {
+	var operation entities.Operation
+	query := `SELECT * FROM operations WHERE request_id = $1`
+
+	qctx, span := startSpan(ctx, "WalletRepository.FindRequest", query)
+	err := r.db.GetContext(qctx, &operation, query, *leg.requestID)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if operation.WalletID != leg.walletID || operation.OperationType != leg.operationType || operation.Amount != leg.amount {
+		return nil, repositories.ErrRequestMismatch
+	}
+
+	fee, err := r.loadFee(ctx, operation.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.OperationResult{Operation: &operation, Fee: fee, Replayed: true}, nil
+}
+
+// loadFee возвращает проводки комиссии за операцию или перевод sourceID;
+// nil - комиссии не было. Расчет комиссии не хранится, известны только проводки.
+func (r *WalletRepositoryImpl) loadFee(ctx context.Context, sourceID uuid.UUID) (*entities.Fee, error) {
+	feeTransferID := entities.FeeTransferID(sourceID)
+	debit, credit, err := r.findTransferOperations(ctx, feeTransferID)
+	if err != nil || debit == nil || credit == nil {
+		return nil, err
+	}
+	return &entities.Fee{
+		Amount:      debit.Amount,
+		FeeWalletID: credit.WalletID,
+		TransferID:  feeTransferID,
+		Debit:       debit,
+		Credit:      credit,
+	}, nil
+}
+
+// AdjustBalanceAtomic - ручная корректировка: положительная сумма зачисляется,
+// отрицательная списывается. Работает и для замороженных кошельков.
+func (r *WalletRepositoryImpl) AdjustBalanceAtomic(
+	ctx context.Context,
+	walletID uuid.UUID,
+	amount int64,
+	reason string,
+	audit repositories.AuditFunc,
+) (_ *entities.Operation, err error) {
+	ctx, span := tracing.Start(ctx, "WalletRepository.AdjustBalanceAtomic",
+		attribute.String("wallet.id", walletID.String()),
+		attribute.Int64("operation.amount", amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	operationType := entities.OperationTypeDeposit
+	if amount < 0 {
+		operationType = entities.OperationTypeWithdraw
+		amount = -amount
+	}
+	return r.applyOperation(ctx, operationLeg{
+		walletID:      walletID,
+		operationType: operationType,
+		amount:        amount,
+		reason:        &reason,
+		allowFrozen:   true,
+	}, func(tx *sqlx.Tx, operation *entities.Operation) error {
+		if audit == nil {
+			return nil
+		}
+		event, err := audit(operation)
+		if err != nil {
+			return err
+		}
+		return appendAuditEvent(ctx, tx, event)
+	})
+}
+
+// operationLeg - изменение баланса одного кошелька и операция, которая его записывает
+type operationLeg struct {
+	walletID      uuid.UUID
+	operationType entities.OperationType
+	amount        int64
+	reason        *string
+	transferID    *uuid.UUID
+	requestID     *uuid.UUID
+	allowFrozen   bool
+}
+
+// applyOperation меняет баланс и пишет операцию в одной транзакции. then
+// выполняется в той же транзакции после записи операции. При конфликте
+// сериализации транзакция повторяется целиком.
+func (r *WalletRepositoryImpl) applyOperation(
+	ctx context.Context,
+	leg operationLeg,
+	then func(tx *sqlx.Tx, operation *entities.Operation) error,
+) (operation *entities.Operation, err error) {
+	if leg.operationType != entities.OperationTypeDeposit && leg.operationType != entities.OperationTypeWithdraw {
+		return nil, repositories.ErrInvalidOperation
+	}
+	err = retrySerializable(ctx, func() (err error) {
+		operation, err = r.applyOperationOnce(ctx, leg, then)
+		return err
+	})
+	return operation, err
+}
+
+func (r *WalletRepositoryImpl) applyOperationOnce(
+	ctx context.Context,
+	leg operationLeg,
+	then func(tx *sqlx.Tx, operation *entities.Operation) error,
+) (_ *entities.Operation, err error) {
 	// Начинаем транзакцию
 	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelSerializable,
 		ReadOnly:  false,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	log := logger.FromContext(ctx)
 	defer func() {
 		if err != nil {
-			tx.Rollback()
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Error("failed to rollback wallet operation", "error", rbErr)
+			}
 		}
 	}()
 
-	// Для DEPOSIT - пополнение
-	if operationType == entities.OperationTypeDeposit {
-		query := `
+	operation, err := r.postOperation(ctx, tx, leg)
+	if err != nil {
+		return nil, err
+	}
+	if then != nil {
+		if err = then(tx, operation); err != nil {
+			return nil, err
+		}
+	}
+
+	// Коммитим транзакцию
+	err = tx.Commit()
+	if err != nil {
+		log.Error("failed to commit wallet operation", "error", err)
+		return nil, err
+	}
+
+	log.Debug("wallet operation committed", "operation_type", leg.operationType, "amount", leg.amount)
+	return operation, nil
+}
+
+// postOperation меняет баланс кошелька и записывает операцию в транзакции tx
+func (r *WalletRepositoryImpl) postOperation(ctx context.Context, tx *sqlx.Tx, leg operationLeg) (*entities.Operation, error) {
+	var query, spanName string
+	switch leg.operationType {
+	case entities.OperationTypeDeposit:
+		// Для DEPOSIT - пополнение
+		spanName = "WalletRepository.CreditBalance"
+		query = `
 			UPDATE wallets 
 			SET balance = balance + $1, updated_at = CURRENT_TIMESTAMP
-			WHERE id = $2
+			WHERE id = $2 AND (status = 'active' OR $3)
 			RETURNING balance, user_id
 		`
-		var newBalance int64
-		var userID uuid.UUID
-		err := tx.QueryRowContext(ctx, query, amount, walletID).Scan(&newBalance, &userID)
+	case entities.OperationTypeWithdraw:
+		// Для WITHDRAW - списание с проверкой баланса
+		spanName = "WalletRepository.DebitBalance"
+		query = `
+			UPDATE wallets 
+			SET balance = balance - $1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND (status = 'active' OR $3) AND balance >= $1
+			RETURNING balance, user_id
+		`
+	default:
+		return nil, repositories.ErrInvalidOperation
+	}
+
+	var newBalance int64
+	var userID uuid.UUID
+	qctx, qspan := startSpan(ctx, spanName, query)
+	err := tx.QueryRowContext(qctx, query, leg.amount, leg.walletID, leg.allowFrozen).Scan(&newBalance, &userID)
+	endSpan(qspan, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		// Выясняем, почему кошелек не обновился
+		return nil, r.rejectionReason(ctx, tx, leg.walletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Логируем операцию
+	operation := entities.NewOperation(leg.walletID, leg.operationType, leg.amount, newBalance)
+	operation.UserID = userID
+	operation.Reason = leg.reason
+	operation.TransferID = leg.transferID
+	operation.RequestID = leg.requestID
+	if err = r.insertOperation(ctx, tx, operation); err != nil {
+		return nil, err
+	}
+	return operation, nil
+}
+
+// chargeFee считает комиссию за операцию sourceID на сумму amount и переводит
+// ее с кошелька payerID на кошелек комиссий его валюты. nil - комиссии нет.
+// Вызывается в транзакции, где строка кошелька плательщика уже заблокирована;
+// кошелек комиссий блокируется последним.
+func (r *WalletRepositoryImpl) chargeFee(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	fees *entities.FeeSchedule,
+	operation string,
+	payerID uuid.UUID,
+	amount int64,
+	sourceID uuid.UUID,
+) (*entities.Fee, error) {
+	if fees == nil || len(fees.Rules) == 0 {
+		return nil, nil
+	}
+
+	var payer struct {
+		Currency string `db:"currency"`
+		Tier     string `db:"tier"`
+	}
+	query := `
+		SELECT w.currency, u.tier FROM wallets w
+		JOIN users u ON u.id = w.user_id
+		WHERE w.id = $1
+	`
+	qctx, span := startSpan(ctx, "WalletRepository.FeeContext", query)
+	err := tx.GetContext(qctx, &payer, query, payerID)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, repositories.ErrWalletNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fee := fees.Quote(operation, payer.Currency, payer.Tier, amount)
+	if fee == nil {
+		return nil, nil
+	}
+	feeWalletID, ok := fees.Wallets[payer.Currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", repositories.ErrFeeWalletNotConfigured, payer.Currency)
+	}
+	if feeWalletID == payerID {
+		// Операции самого кошелька комиссий комиссией не облагаются
+		return nil, nil
+	}
+	fee.FeeWalletID = feeWalletID
+	fee.TransferID = entities.FeeTransferID(sourceID)
+
+	reason := fmt.Sprintf("fee for %s %s", operation, sourceID)
+	if fee.Debit, err = r.postOperation(ctx, tx, operationLeg{
+		walletID:      payerID,
+		operationType: entities.OperationTypeWithdraw,
+		amount:        fee.Amount,
+		reason:        &reason,
+		transferID:    &fee.TransferID,
+	}); err != nil {
+		return nil, err
+	}
+	fee.Credit, err = r.postOperation(ctx, tx, operationLeg{
+		walletID:      feeWalletID,
+		operationType: entities.OperationTypeDeposit,
+		amount:        fee.Amount,
+		reason:        &reason,
+		transferID:    &fee.TransferID,
+		allowFrozen:   true,
+	})
+	if errors.Is(err, repositories.ErrWalletNotFound) {
+		return nil, fmt.Errorf("%w: fee wallet %s does not exist", repositories.ErrFeeWalletNotConfigured, feeWalletID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fee, nil
+}
+
+// TransferAtomic списывает с одного кошелька и зачисляет на другой в одной
+// транзакции. Строки обоих кошельков блокируются в порядке id, чтобы
+// встречные переводы не взаимоблокировались.
+func (r *WalletRepositoryImpl) TransferAtomic(ctx context.Context, transfer *entities.Transfer, reason *string, fees *entities.FeeSchedule) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletRepository.TransferAtomic",
+		attribute.String("transfer.id", transfer.ID.String()),
+		attribute.String("transfer.from_wallet_id", transfer.FromWalletID.String()),
+		attribute.String("transfer.to_wallet_id", transfer.ToWalletID.String()),
+		attribute.Int64("transfer.amount", transfer.Amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	replayed, err := r.loadTransfer(ctx, transfer)
+	if err != nil || replayed {
+		return err
+	}
+
+	err = retrySerializable(ctx, func() error {
+		return r.transfer(ctx, transfer, reason, fees)
+	})
+	if isUniqueViolation(err) {
+		// Тот же перевод провели параллельно: отдаем его результат
+		if replayed, lerr := r.loadTransfer(ctx, transfer); lerr != nil || replayed {
+			return lerr
+		}
+	}
+	return err
+}
+
+func (r *WalletRepositoryImpl) transfer(ctx context.Context, transfer *entities.Transfer, reason *string, fees *entities.FeeSchedule) (err error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	log := logger.FromContext(ctx)
+	defer func() {
 		if err != nil {
-			if err == sql.ErrNoRows {
-				return errors.New("wallet not found")
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Error("failed to rollback transfer", "error", rbErr)
 			}
-			return err
 		}
+	}()
 
-		// Логируем операцию пополнения
-		insertQuery := `
-			INSERT INTO operations (id, wallet_id, user_id, operation_type, amount, balance_after, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`
-		_, err = tx.ExecContext(ctx, insertQuery,
-			uuid.New(),
-			walletID,
-			userID,
-			operationType,
-			amount,
-			newBalance,
-			time.Now(),
-		)
-		if err != nil {
+	lockQuery := `SELECT currency FROM wallets WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`
+	lctx, lspan := startSpan(ctx, "WalletRepository.LockTransferWallets", lockQuery)
+	var currencies []string
+	err = tx.SelectContext(lctx, &currencies, lockQuery, transfer.FromWalletID, transfer.ToWalletID)
+	endSpan(lspan, int64(len(currencies)), err)
+	if err != nil {
+		return err
+	}
+	if len(currencies) == 2 && currencies[0] != currencies[1] {
+		err = repositories.ErrCurrencyMismatch
+		return err
+	}
+
+	legs := []struct {
+		leg       operationLeg
+		operation **entities.Operation
+	}{
+		{operationLeg{walletID: transfer.FromWalletID, operationType: entities.OperationTypeWithdraw}, &transfer.Debit},
+		{operationLeg{walletID: transfer.ToWalletID, operationType: entities.OperationTypeDeposit}, &transfer.Credit},
+	}
+	for _, l := range legs {
+		l.leg.amount = transfer.Amount
+		l.leg.reason = reason
+		l.leg.transferID = &transfer.ID
+		if *l.operation, err = r.postOperation(ctx, tx, l.leg); err != nil {
 			return err
 		}
+	}
 
-	} else if operationType == entities.OperationTypeWithdraw {
-		// Для WITHDRAW - списание с проверкой баланса
-		query := `
-			UPDATE wallets 
-			SET balance = balance - $1, updated_at = CURRENT_TIMESTAMP
-			WHERE id = $2 AND balance >= $1
-			RETURNING balance, user_id
-		`
-		var newBalance int64
-		var userID uuid.UUID
-		err := tx.QueryRowContext(ctx, query, amount, walletID).Scan(&newBalance, &userID)
+	transfer.Fee, err = r.chargeFee(ctx, tx, fees, entities.FeeOperationTransfer, transfer.FromWalletID, transfer.Amount, transfer.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit transfer", "error", err)
+		return err
+	}
+
+	log.Debug("transfer committed", "transfer_id", transfer.ID, "amount", transfer.Amount)
+	return nil
+}
+
+// ProcessBatchAtomic проводит операции пакета в одной транзакции. Строки
+// всех кошельков пакета и кошельков комиссий блокируются заранее в порядке id.
+func (r *WalletRepositoryImpl) ProcessBatchAtomic(ctx context.Context, items []*entities.BatchItem, fees *entities.FeeSchedule) (err error) {
+	ctx, span := tracing.Start(ctx, "WalletRepository.ProcessBatchAtomic",
+		attribute.Int("batch.items", len(items)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	replayed, err := r.loadBatch(ctx, items)
+	if err != nil || replayed {
+		return err
+	}
+
+	err = retrySerializable(ctx, func() error {
+		return r.processBatch(ctx, items, fees)
+	})
+	if isUniqueViolation(err) {
+		// Тот же пакет провели параллельно: отдаем его результат
+		if replayed, lerr := r.loadBatch(ctx, items); lerr != nil || replayed {
+			return lerr
+		}
+	}
+	return err
+}
+
+func (r *WalletRepositoryImpl) processBatch(ctx context.Context, items []*entities.BatchItem, fees *entities.FeeSchedule) (err error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	log := logger.FromContext(ctx)
+	defer func() {
 		if err != nil {
-			if err == sql.ErrNoRows {
-				// Проверяем, существует ли кошелек
-				var exists bool
-				checkQuery := `SELECT EXISTS(SELECT 1 FROM wallets WHERE id = $1)`
-				err = tx.QueryRowContext(ctx, checkQuery, walletID).Scan(&exists)
-				if err != nil {
-					return err
-				}
-				if !exists {
-					return errors.New("wallet not found")
-				}
-				// Кошелек существует, но недостаточно средств
-				return errors.New("insufficient funds")
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Error("failed to rollback batch", "error", rbErr)
 			}
-			return err
 		}
+	}()
 
-		// Логируем операцию списания
-		insertQuery := `
-			INSERT INTO operations (id, wallet_id, user_id, operation_type, amount, balance_after, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`
-		_, err = tx.ExecContext(ctx, insertQuery,
-			uuid.New(),
-			walletID,
-			userID,
-			operationType,
-			amount,
-			newBalance,
-			time.Now(),
-		)
+	walletIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		walletIDs = append(walletIDs, item.WalletID)
+	}
+	if fees != nil && len(fees.Rules) > 0 {
+		for _, id := range fees.Wallets {
+			walletIDs = append(walletIDs, id)
+		}
+	}
+	lockQuery := `SELECT id FROM wallets WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`
+	lctx, lspan := startSpan(ctx, "WalletRepository.LockBatchWallets", lockQuery)
+	var locked []uuid.UUID
+	err = tx.SelectContext(lctx, &locked, lockQuery, uuidArray(walletIDs))
+	endSpan(lspan, int64(len(locked)), err)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*entities.OperationResult, len(items))
+	for i, item := range items {
+		requestID := item.RequestID()
+		result := &entities.OperationResult{}
+		result.Operation, err = r.postOperation(ctx, tx, operationLeg{
+			walletID:      item.WalletID,
+			operationType: item.OperationType,
+			amount:        item.Amount,
+			requestID:     &requestID,
+		})
+		if err == nil {
+			result.Fee, err = r.chargeFee(ctx, tx, fees, string(item.OperationType), item.WalletID, item.Amount, result.Operation.ID)
+		}
 		if err != nil {
+			err = &repositories.BatchItemError{Index: item.Index, Err: err}
 			return err
 		}
-	} else {
-		return errors.New("invalid operation type")
+		results[i] = result
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("failed to commit batch", "error", err)
+		return err
+	}
+
+	for i, item := range items {
+		item.Succeed(results[i])
+	}
+	log.Debug("batch committed", "items", len(items))
+	return nil
+}
+
+// loadBatch заполняет результаты уже проведенного пакета; false - пакет не
+// проводился. Проведенная часть пакета означает, что ключи операций
+// использованы для других операций.
+func (r *WalletRepositoryImpl) loadBatch(ctx context.Context, items []*entities.BatchItem) (bool, error) {
+	requestIDs := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		requestIDs[i] = item.RequestID()
+	}
+
+	var operations []*entities.Operation
+	query := `SELECT * FROM operations WHERE request_id = ANY($1::uuid[])`
+	qctx, span := startSpan(ctx, "WalletRepository.FindBatch", query)
+	err := r.db.SelectContext(qctx, &operations, query, uuidArray(requestIDs))
+	endSpan(span, int64(len(operations)), err)
+	if err != nil || len(operations) == 0 {
+		return false, err
+	}
+	if len(operations) != len(items) {
+		return false, repositories.ErrRequestMismatch
+	}
+
+	byRequest := make(map[uuid.UUID]*entities.Operation, len(operations))
+	for _, operation := range operations {
+		byRequest[*operation.RequestID] = operation
+	}
+	results := make([]*entities.OperationResult, len(items))
+	for i, item := range items {
+		operation := byRequest[requestIDs[i]]
+		if operation == nil || operation.WalletID != item.WalletID ||
+			operation.OperationType != item.OperationType || operation.Amount != item.Amount {
+			return false, repositories.ErrRequestMismatch
+		}
+		fee, err := r.loadFee(ctx, operation.ID)
+		if err != nil {
+			return false, err
+		}
+		results[i] = &entities.OperationResult{Operation: operation, Fee: fee, Replayed: true}
+	}
+
+	for i, item := range items {
+		item.Succeed(results[i])
+	}
+	return true, nil
+}
+
+// loadTransfer заполняет операции уже проведенного перевода и его комиссии;
+// false - перевода не было
+func (r *WalletRepositoryImpl) loadTransfer(ctx context.Context, transfer *entities.Transfer) (bool, error) {
+	debit, credit, err := r.findTransferOperations(ctx, transfer.ID)
+	if err != nil || debit == nil && credit == nil {
+		return false, err
+	}
+	if debit == nil || credit == nil || debit.WalletID != transfer.FromWalletID ||
+		credit.WalletID != transfer.ToWalletID || debit.Amount != transfer.Amount {
+		return false, repositories.ErrTransferMismatch
+	}
+	transfer.Debit, transfer.Credit = debit, credit
+
+	if transfer.Fee, err = r.loadFee(ctx, transfer.ID); err != nil {
+		return false, err
+	}
+
+	transfer.Replayed = true
+	return true, nil
+}
+
+// findTransferOperations возвращает списание и зачисление с общим transferID
+func (r *WalletRepositoryImpl) findTransferOperations(ctx context.Context, transferID uuid.UUID) (debit, credit *entities.Operation, err error) {
+	var operations []*entities.Operation
+	query := `SELECT * FROM operations WHERE transfer_id = $1`
+
+	ctx, span := startSpan(ctx, "WalletRepository.FindTransfer", query)
+	err = r.db.SelectContext(ctx, &operations, query, transferID)
+	endSpan(span, int64(len(operations)), err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, op := range operations {
+		if op.OperationType == entities.OperationTypeWithdraw {
+			debit = op
+		} else {
+			credit = op
+		}
+	}
+	return debit, credit, nil
+}
+
+// insertOperation добавляет операцию в хеш-цепочку кошелька и записывает ее.
+// Вызывается в транзакции, уже заблокировавшей строку кошелька, поэтому
+// операции одного кошелька получают номера строго по очереди.
+func (r *WalletRepositoryImpl) insertOperation(ctx context.Context, tx *sqlx.Tx, operation *entities.Operation) error {
+	var last struct {
+		Seq  int64  `db:"seq"`
+		Hash string `db:"hash"`
+	}
+	lastQuery := `
+		SELECT seq, hash FROM operations
+		WHERE wallet_id = $1 AND seq IS NOT NULL
+		ORDER BY seq DESC LIMIT 1
+	`
+	lctx, lspan := startSpan(ctx, "WalletRepository.LastChainedOperation", lastQuery)
+	err := tx.GetContext(lctx, &last, lastQuery, operation.WalletID)
+	endSpan(lspan, foundRows(err), err)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		last.Hash = entities.GenesisHash
+	default:
+		return err
+	}
+	operation.Chain(last.Seq+1, last.Hash)
+
+	insertQuery := `
+		INSERT INTO operations (id, wallet_id, user_id, operation_type, amount, balance_after, reason, created_at,
+			seq, prev_hash, hash, transfer_id, request_id)
+		VALUES (:id, :wallet_id, :user_id, :operation_type, :amount, :balance_after, :reason, :created_at,
+			:seq, :prev_hash, :hash, :transfer_id, :request_id)
+	`
+	ictx, ispan := startSpan(ctx, "WalletRepository.InsertOperation", insertQuery)
+	res, err := tx.NamedExecContext(ictx, insertQuery, operation)
+	endSpan(ispan, rowsAffected(res), err)
+	return err
+}
+
+// ListChainedOperations возвращает до limit операций цепочки кошелька с seq > afterSeq по возрастанию
+func (r *WalletRepositoryImpl) ListChainedOperations(ctx context.Context, walletID uuid.UUID, afterSeq int64, limit int) ([]*entities.Operation, error) {
+	operations := []*entities.Operation{}
+	query := `
+		SELECT * FROM operations
+		WHERE wallet_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3
+	`
+
+	ctx, span := startSpan(ctx, "WalletRepository.ListChainedOperations", query)
+	err := r.db.SelectContext(ctx, &operations, query, walletID, afterSeq, limit)
+	endSpan(span, int64(len(operations)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
+// CountLegacyOperations - число операций кошелька без хеша (созданных до цепочки)
+func (r *WalletRepositoryImpl) CountLegacyOperations(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM operations WHERE wallet_id = $1 AND seq IS NULL`
+
+	ctx, span := startSpan(ctx, "WalletRepository.CountLegacyOperations", query)
+	err := r.db.GetContext(ctx, &count, query, walletID)
+	endSpan(span, foundRows(err), err)
+	return count, err
+}
+
+// balanceAtSelect - баланс каждого кошелька по последней операции не позже $2.
+// Среди операций с одинаковым временем последней считается операция с большим seq.
+const balanceAtSelect = `
+	SELECT w.id AS wallet_id, COALESCE(op.balance_after, 0) AS balance, op.id AS operation_id
+	FROM wallets w
+	LEFT JOIN LATERAL (
+		SELECT o.id, o.balance_after FROM operations o
+		WHERE o.wallet_id = w.id AND o.created_at <= $2
+		ORDER BY o.created_at DESC, o.seq DESC NULLS LAST
+		LIMIT 1
+	) op ON TRUE
+`
+
+func (r *WalletRepositoryImpl) BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (*entities.WalletBalance, error) {
+	var balance entities.WalletBalance
+	query := balanceAtSelect + `WHERE w.id = $1`
+
+	ctx, span := startSpan(ctx, "WalletRepository.BalanceAt", query)
+	err := r.db.GetContext(ctx, &balance, query, walletID, at)
+	endSpan(span, foundRows(err), err)
+	if err == sql.ErrNoRows {
+		return nil, repositories.ErrWalletNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	balance.At = at
+	return &balance, nil
+}
+
+func (r *WalletRepositoryImpl) BalancesAtByUser(ctx context.Context, userID uuid.UUID, at time.Time) ([]*entities.WalletBalance, error) {
+	balances := []*entities.WalletBalance{}
+	query := balanceAtSelect + `WHERE w.user_id = $1 AND w.created_at <= $2 ORDER BY w.created_at, w.id`
+
+	ctx, span := startSpan(ctx, "WalletRepository.BalancesAtByUser", query)
+	err := r.db.SelectContext(ctx, &balances, query, userID, at)
+	endSpan(span, int64(len(balances)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range balances {
+		b.At = at
+	}
+	return balances, nil
+}
+
+// ListOperationsBetween читает операции периода страницами по ключу
+// (created_at, seq, id), чтобы выписка не держала весь период в памяти.
+// Порядок совпадает с тем, по которому BalanceAt выбирает последнюю операцию.
+func (r *WalletRepositoryImpl) ListOperationsBetween(
+	ctx context.Context,
+	walletID uuid.UUID,
+	from, to time.Time,
+	after *entities.Operation,
+	limit int,
+) ([]*entities.Operation, error) {
+	operations := []*entities.Operation{}
+	query := `
+		SELECT * FROM operations
+		WHERE wallet_id = $1 AND created_at >= $2 AND created_at <= $3
+	`
+	args := []interface{}{walletID, from, to, limit}
+	if after != nil {
+		var afterSeq int64
+		if after.Seq != nil {
+			afterSeq = *after.Seq
+		}
+		query += ` AND (created_at, COALESCE(seq, 0), id) > ($5, $6, $7)`
+		args = append(args, after.CreatedAt, afterSeq, after.ID)
+	}
+	query += ` ORDER BY created_at, COALESCE(seq, 0), id LIMIT $4`
+
+	ctx, span := startSpan(ctx, "WalletRepository.ListOperationsBetween", query)
+	err := r.db.SelectContext(ctx, &operations, query, args...)
+	endSpan(span, int64(len(operations)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
+// rejectionReason определяет причину, по которой операция не изменила баланс
+func (r *WalletRepositoryImpl) rejectionReason(ctx context.Context, tx *sqlx.Tx, walletID uuid.UUID) error {
+	var status entities.WalletStatus
+	query := `SELECT status FROM wallets WHERE id = $1`
+	ctx, span := startSpan(ctx, "WalletRepository.WalletStatus", query)
+	err := tx.GetContext(ctx, &status, query, walletID)
+	endSpan(span, foundRows(err), err)
+	switch {
+	case err == sql.ErrNoRows:
+		return repositories.ErrWalletNotFound
+	case err != nil:
+		return err
+	case status == entities.WalletStatusFrozen:
+		return repositories.ErrWalletFrozen
+	default:
+		// Кошелек существует, но недостаточно средств
+		return repositories.ErrInsufficientFunds
+	}
+}
+
+// SetStatus меняет статус кошелька
+func (r *WalletRepositoryImpl) SetStatus(ctx context.Context, walletID uuid.UUID, status entities.WalletStatus, event *entities.AuditEvent) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `UPDATE wallets SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	sctx, span := startSpan(ctx, "WalletRepository.SetStatus", query)
+	res, err := tx.ExecContext(sctx, query, status, walletID)
+	affected := rowsAffected(res)
+	endSpan(span, affected, err)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		err = repositories.ErrWalletNotFound
+		return err
+	}
+	if err = appendAuditEvent(ctx, tx, event); err != nil {
+		return err
 	}
 
-	// Коммитим транзакцию
 	err = tx.Commit()
 	return err
 }
@@ -136,11 +840,13 @@ func (r *WalletRepositoryImpl) ProcessOperationAtomic(
 
 func (r *WalletRepositoryImpl) Create(ctx context.Context, wallet *entities.Wallet) error {
 	query := `
-		INSERT INTO wallets (id, user_id, balance, created_at, updated_at)
-		VALUES (:id, :user_id, :balance, :created_at, :updated_at)
+		INSERT INTO wallets (id, user_id, balance, currency, status, created_at, updated_at)
+		VALUES (:id, :user_id, :balance, :currency, :status, :created_at, :updated_at)
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, wallet)
+	ctx, span := startSpan(ctx, "WalletRepository.Create", query)
+	res, err := r.db.NamedExecContext(ctx, query, wallet)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
@@ -148,7 +854,9 @@ func (r *WalletRepositoryImpl) FindByID(ctx context.Context, id uuid.UUID) (*ent
 	var wallet entities.Wallet
 	query := `SELECT * FROM wallets WHERE id = $1`
 
+	ctx, span := startSpan(ctx, "WalletRepository.FindByID", query)
 	err := r.db.GetContext(ctx, &wallet, query, id)
+	endSpan(span, foundRows(err), err)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -164,7 +872,23 @@ func (r *WalletRepositoryImpl) FindByUserID(ctx context.Context, userID uuid.UUI
 	var wallets []*entities.Wallet
 	query := `SELECT * FROM wallets WHERE user_id = $1 ORDER BY created_at DESC`
 
+	ctx, span := startSpan(ctx, "WalletRepository.FindByUserID", query)
 	err := r.db.SelectContext(ctx, &wallets, query, userID)
+	endSpan(span, int64(len(wallets)), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+func (r *WalletRepositoryImpl) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*entities.Wallet, error) {
+	var wallets []*entities.Wallet
+	query := `SELECT * FROM wallets WHERE id = ANY($1::uuid[])`
+
+	ctx, span := startSpan(ctx, "WalletRepository.FindByIDs", query)
+	err := r.db.SelectContext(ctx, &wallets, query, uuidArray(ids))
+	endSpan(span, int64(len(wallets)), err)
 	if err != nil {
 		return nil, err
 	}
@@ -174,18 +898,23 @@ func (r *WalletRepositoryImpl) FindByUserID(ctx context.Context, userID uuid.UUI
 
 func (r *WalletRepositoryImpl) Update(ctx context.Context, wallet *entities.Wallet) error {
 	query := `
-		UPDATE wallets 
+		UPDATE wallets
 		SET balance = :balance, updated_at = :updated_at
 		WHERE id = :id
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, wallet)
+	ctx, span := startSpan(ctx, "WalletRepository.Update", query)
+	res, err := r.db.NamedExecContext(ctx, query, wallet)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
 func (r *WalletRepositoryImpl) UpdateBalance(ctx context.Context, walletID uuid.UUID, amount int64) error {
 	query := `UPDATE wallets SET balance = balance + $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-	_, err := r.db.ExecContext(ctx, query, amount, walletID)
+
+	ctx, span := startSpan(ctx, "WalletRepository.UpdateBalance", query)
+	res, err := r.db.ExecContext(ctx, query, amount, walletID)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
@@ -195,7 +924,10 @@ func (r *WalletRepositoryImpl) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
 
 func (r *WalletRepositoryImpl) UpdateBalanceWithTx(ctx context.Context, tx *sqlx.Tx, walletID uuid.UUID, amount int64) error {
 	query := `UPDATE wallets SET balance = balance + $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-	_, err := tx.ExecContext(ctx, query, amount, walletID)
+
+	ctx, span := startSpan(ctx, "WalletRepository.UpdateBalanceWithTx", query)
+	res, err := tx.ExecContext(ctx, query, amount, walletID)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
@@ -203,7 +935,9 @@ func (r *WalletRepositoryImpl) FindByIDWithTx(ctx context.Context, tx *sqlx.Tx,
 	var wallet entities.Wallet
 	query := `SELECT * FROM wallets WHERE id = $1 FOR UPDATE`
 
+	ctx, span := startSpan(ctx, "WalletRepository.FindByIDWithTx", query)
 	err := tx.GetContext(ctx, &wallet, query, id)
+	endSpan(span, foundRows(err), err)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -217,7 +951,10 @@ func (r *WalletRepositoryImpl) FindByIDWithTx(ctx context.Context, tx *sqlx.Tx,
 
 func (r *WalletRepositoryImpl) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM wallets WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+
+	ctx, span := startSpan(ctx, "WalletRepository.Delete", query)
+	res, err := r.db.ExecContext(ctx, query, id)
+	endSpan(span, rowsAffected(res), err)
 	return err
 }
 
@@ -225,17 +962,19 @@ func (r *WalletRepositoryImpl) Delete(ctx context.Context, id uuid.UUID) error {
 func (r *WalletRepositoryImpl) GetOperationsHistory(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entities.Operation, error) {
 	var operations []*entities.Operation
 	query := `
-		SELECT * FROM operations 
-		WHERE wallet_id = $1 
-		ORDER BY created_at DESC 
+		SELECT * FROM operations
+		WHERE wallet_id = $1
+		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
-	
+
+	ctx, span := startSpan(ctx, "WalletRepository.GetOperationsHistory", query)
 	err := r.db.SelectContext(ctx, &operations, query, walletID, limit, offset)
+	endSpan(span, int64(len(operations)), err)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return operations, nil
 }
 
@@ -243,16 +982,18 @@ func (r *WalletRepositoryImpl) GetOperationsHistory(ctx context.Context, walletI
 func (r *WalletRepositoryImpl) GetOperationsHistoryByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*entities.Operation, error) {
 	var operations []*entities.Operation
 	query := `
-		SELECT * FROM operations 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC 
+		SELECT * FROM operations
+		WHERE user_id = $1
+		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
-	
+
+	ctx, span := startSpan(ctx, "WalletRepository.GetOperationsHistoryByUser", query)
 	err := r.db.SelectContext(ctx, &operations, query, userID, limit, offset)
+	endSpan(span, int64(len(operations)), err)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return operations, nil
-}
\ No newline at end of file
+}
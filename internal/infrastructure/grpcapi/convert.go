@@ -0,0 +1,102 @@
+package grpcapi
+
+import (
+	walletv1 "walletapitest/api/wallet/v1"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// parseID разбирает UUID из поля запроса; ошибка - InvalidArgument
+func parseID(raw, what string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid "+what)
+	}
+	return id, nil
+}
+
+func toUser(user *entities.User) *walletv1.User {
+	return &walletv1.User{
+		Id:            user.ID.String(),
+		Email:         user.Email,
+		Username:      user.Username,
+		Role:          string(user.Role),
+		Tier:          user.Tier,
+		EmailVerified: user.EmailVerified(),
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+	}
+}
+
+func toWallet(wallet *entities.Wallet) *walletv1.Wallet {
+	return &walletv1.Wallet{
+		Id:        wallet.ID.String(),
+		UserId:    wallet.UserID.String(),
+		Balance:   wallet.Balance,
+		Currency:  wallet.Currency,
+		Status:    string(wallet.Status),
+		CreatedAt: timestamppb.New(wallet.CreatedAt),
+		UpdatedAt: timestamppb.New(wallet.UpdatedAt),
+	}
+}
+
+func toOperation(operation *entities.Operation) *walletv1.Operation {
+	out := &walletv1.Operation{
+		Id:            operation.ID.String(),
+		WalletId:      operation.WalletID.String(),
+		OperationType: string(operation.OperationType),
+		Amount:        operation.Amount,
+		BalanceAfter:  operation.BalanceAfter,
+		CreatedAt:     timestamppb.New(operation.CreatedAt),
+	}
+	if operation.Reason != nil {
+		out.Reason = *operation.Reason
+	}
+	if operation.TransferID != nil {
+		out.TransferId = operation.TransferID.String()
+	}
+	if operation.RequestID != nil {
+		out.RequestId = operation.RequestID.String()
+	}
+	return out
+}
+
+func toFee(fee *entities.Fee) *walletv1.Fee {
+	if fee == nil {
+		return nil
+	}
+	return &walletv1.Fee{
+		Amount:      fee.Amount,
+		Currency:    fee.Currency,
+		Rule:        fee.Rule,
+		Fixed:       fee.Fixed,
+		RateBps:     fee.RateBps,
+		Percentage:  fee.Percentage,
+		TierUpTo:    fee.TierUpTo,
+		MinApplied:  fee.MinApplied,
+		MaxApplied:  fee.MaxApplied,
+		FeeWalletId: fee.FeeWalletID.String(),
+		TransferId:  fee.TransferID.String(),
+	}
+}
+
+func toBalanceUpdate(update *entities.BalanceUpdate) *walletv1.BalanceUpdate {
+	return &walletv1.BalanceUpdate{
+		WalletId:  update.WalletID.String(),
+		Balance:   update.Balance,
+		UpdatedAt: timestamppb.New(update.UpdatedAt),
+	}
+}
+
+func operationType(t walletv1.OperationType) (entities.OperationType, bool) {
+	switch t {
+	case walletv1.OperationType_OPERATION_TYPE_DEPOSIT:
+		return entities.OperationTypeDeposit, true
+	case walletv1.OperationType_OPERATION_TYPE_WITHDRAW:
+		return entities.OperationTypeWithdraw, true
+	}
+	return "", false
+}
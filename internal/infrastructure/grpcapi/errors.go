@@ -0,0 +1,81 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/logger"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain - домен причин в google.rpc.ErrorInfo
+const errorDomain = "wallet.v1"
+
+// Причины отказа, которые клиент обрабатывает отдельно: в REST им
+// соответствуют флаги mfa_required, step_up_required и
+// email_verification_required в теле ответа
+const (
+	reasonMFARequired               = "MFA_REQUIRED"
+	reasonStepUpRequired            = "STEP_UP_REQUIRED"
+	reasonEmailVerificationRequired = "EMAIL_VERIFICATION_REQUIRED"
+)
+
+// internalError пишет неожиданную ошибку в лог вызова и скрывает ее от клиента
+func internalError(ctx context.Context, msg string, err error) error {
+	logger.FromContext(ctx).Error(msg, "error", err)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// reasonError - отказ с причиной в google.rpc.ErrorInfo
+func reasonError(code codes.Code, msg, reason string, meta map[string]string) error {
+	st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   errorDomain,
+		Metadata: meta,
+	})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// throttledError - ResourceExhausted с google.rpc.RetryInfo вместо Retry-After
+func throttledError(msg string, retryAfter time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, msg).WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter.Truncate(time.Second) + time.Second),
+	})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, msg)
+	}
+	return st.Err()
+}
+
+// accessError переводит отказ AccessService в статус с той же причиной, что
+// флаг в ответе REST
+func accessError(ctx context.Context, err error) error {
+	var stepUp *services.StepUpError
+	var throttle *services.ThrottleError
+	switch {
+	case errors.Is(err, services.ErrWalletNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, services.ErrForbidden), errors.Is(err, services.ErrAPIKeyScope):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, services.ErrEmailNotVerified):
+		return reasonError(codes.PermissionDenied, err.Error(), reasonEmailVerificationRequired, nil)
+	case errors.As(err, &stepUp):
+		return reasonError(codes.PermissionDenied, stepUp.Err.Error(), reasonStepUpRequired, map[string]string{
+			"threshold": strconv.FormatInt(stepUp.Threshold, 10),
+		})
+	case errors.As(err, &throttle):
+		return throttledError(throttle.Err.Error(), throttle.RetryAfter)
+	default:
+		return internalError(ctx, "failed to check wallet access", err)
+	}
+}
@@ -0,0 +1,289 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	walletv1 "walletapitest/api/wallet/v1"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/requestid"
+	"walletapitest/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const requestIDHeader = "x-request-id"
+
+// publicMethods открыты без токена, как публичные маршруты REST
+var publicMethods = map[string]bool{
+	walletv1.UserService_CreateUser_FullMethodName:   true,
+	walletv1.UserService_Authenticate_FullMethodName: true,
+	healthpb.Health_Check_FullMethodName:             true,
+	healthpb.Health_Watch_FullMethodName:             true,
+}
+
+// authenticatedMethods открыты любому вызывающему с токеном или ключом;
+// доступ к объекту проверяет сам метод, как хендлеры REST без RequirePermission
+var authenticatedMethods = map[string]bool{
+	walletv1.UserService_GetUser_FullMethodName: true,
+}
+
+// methodPermissions - те же разрешения, что на соответствующих маршрутах
+// REST: нужно хотя бы одно. Владение кошельком проверяет сам метод. Метод,
+// которого нет ни здесь, ни в publicMethods, ни в authenticatedMethods,
+// недоступен никому.
+var methodPermissions = map[string][]entities.Permission{
+	walletv1.WalletService_CreateWallet_FullMethodName:     {entities.PermWalletsOperate},
+	walletv1.WalletService_GetWallet_FullMethodName:        {entities.PermWalletsRead, entities.PermWalletsReadAll},
+	walletv1.WalletService_ProcessOperation_FullMethodName: {entities.PermWalletsOperate},
+	walletv1.WalletService_ListOperations_FullMethodName:   {entities.PermWalletsRead, entities.PermHistoryReadAll},
+	walletv1.WalletService_WatchBalance_FullMethodName:     {entities.PermWalletsRead, entities.PermWalletsReadAll},
+}
+
+type interceptors struct {
+	log      logger.Logger
+	tokens   *auth.TokenManager
+	denylist auth.Denylist
+	apiKeys  auth.APIKeyVerifier
+}
+
+// serverStream подменяет контекст потока
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (i *interceptors) observeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, finish := i.observe(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	finish(err)
+	return resp, err
+}
+
+func (i *interceptors) observeStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, finish := i.observe(ss.Context(), info.FullMethod)
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	finish(err)
+	return err
+}
+
+// observe - то же, что RequestID, Tracing и Logging для HTTP: принимает
+// x-request-id или генерирует новый, продолжает трейс из метаданных,
+// кладет в контекст логгер вызова; finish пишет строку access-лога
+func (i *interceptors) observe(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := firstValue(md, requestIDHeader)
+	if !requestid.Valid(id) {
+		id = uuid.NewString()
+	}
+	ctx = requestid.WithContext(ctx, id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+	service, rpc := splitMethod(method)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, span := tracing.Tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCService(service),
+			semconv.RPCMethod(rpc),
+			attribute.String("rpc.request_id", id),
+		),
+	)
+
+	fields := []interface{}{"request_id", id}
+	if sc := span.SpanContext(); sc.IsValid() {
+		fields = append(fields, "trace_id", sc.TraceID().String())
+	}
+	ctx = logger.WithContext(ctx, i.log.With(fields...))
+
+	return ctx, func(err error) {
+		defer span.End()
+
+		code := status.Code(err)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		if serverFault(code) {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, code.String())
+		}
+
+		log := logger.FromContext(ctx)
+		accessFields := []interface{}{
+			"method", method,
+			"code", code.String(),
+			"latency", time.Since(start),
+			"peer", peerAddress(ctx),
+		}
+		if claims, ok := auth.ClaimsFromContext(ctx); ok {
+			accessFields = append(accessFields, "user_id", claims.UserID)
+		}
+		switch {
+		case serverFault(code):
+			log.Error("rpc completed", append(accessFields, "error", err)...)
+		case code != codes.OK:
+			log.Warn("rpc completed", append(accessFields, "error", status.Convert(err).Message())...)
+		default:
+			log.Info("rpc completed", accessFields...)
+		}
+	}
+}
+
+func (i *interceptors) recoverUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer recoverPanic(ctx, &err)
+	return handler(ctx, req)
+}
+
+func (i *interceptors) recoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverPanic(ss.Context(), &err)
+	return handler(srv, ss)
+}
+
+func recoverPanic(ctx context.Context, err *error) {
+	if r := recover(); r != nil {
+		logger.FromContext(ctx).Error("panic recovered", "panic", r)
+		*err = status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func (i *interceptors) authorizeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := i.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (i *interceptors) authorizeStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := i.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorize - то же, что AuthMiddleware и RequirePermission: токен из
+// authorization: Bearer <token> или x-api-key, отозванные сессии
+// отклоняются, затем проверяются разрешения метода. Закрытый метод без
+// записи в methodPermissions или authenticatedMethods запрещен.
+func (i *interceptors) authorize(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	token, ok := strings.CutPrefix(firstValue(md, "authorization"), "Bearer ")
+	if !ok || token == "" {
+		token = firstValue(md, "x-api-key")
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	var claims *auth.Claims
+	if auth.IsAPIKey(token) {
+		var err error
+		claims, err = i.apiKeys.VerifyAPIKey(ctx, token)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidAPIKey) {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			logger.FromContext(ctx).Error("failed to verify api key", "error", err)
+			return nil, status.Error(codes.Unavailable, "unable to verify api key")
+		}
+		ctx = logger.WithFields(ctx, "api_key_id", claims.APIKeyID)
+	} else {
+		var err error
+		claims, err = i.tokens.Parse(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		revoked, err := i.denylist.Contains(ctx, claims.SessionID)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to check session denylist", "error", err)
+			return nil, status.Error(codes.Unavailable, "unable to verify session")
+		}
+		if revoked {
+			return nil, status.Error(codes.Unauthenticated, "session revoked")
+		}
+		ctx = logger.WithFields(ctx, "user_id", claims.UserID, "session_id", claims.SessionID, "role", claims.Role)
+	}
+	ctx = auth.WithClaims(ctx, claims)
+
+	if authenticatedMethods[method] {
+		return ctx, nil
+	}
+	for _, perm := range methodPermissions[method] {
+		if claims.HasPermission(string(perm)) {
+			return ctx, nil
+		}
+	}
+	return nil, status.Error(codes.PermissionDenied, "permission denied")
+}
+
+// serverFault - код означает ошибку сервера, а не запроса
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, _ = strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
+
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// metadataCarrier позволяет пропагатору OTel читать traceparent из метаданных
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	return firstValue(metadata.MD(c), key)
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,84 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	walletv1 "walletapitest/api/wallet/v1"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestEveryMethodHasAccessRule - новый метод сервиса без правила доступа
+// закрыт, но правило нужно добавить явно
+func TestEveryMethodHasAccessRule(t *testing.T) {
+	for _, desc := range []grpc.ServiceDesc{walletv1.UserService_ServiceDesc, walletv1.WalletService_ServiceDesc} {
+		var names []string
+		for _, m := range desc.Methods {
+			names = append(names, m.MethodName)
+		}
+		for _, s := range desc.Streams {
+			names = append(names, s.StreamName)
+		}
+		for _, name := range names {
+			method := "/" + desc.ServiceName + "/" + name
+			if _, ok := methodPermissions[method]; !ok && !publicMethods[method] && !authenticatedMethods[method] {
+				t.Errorf("%s has neither permissions nor public access", method)
+			}
+		}
+	}
+}
+
+// emptyDenylist - ни одна сессия не отозвана
+type emptyDenylist struct{}
+
+func (emptyDenylist) Add(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	return nil
+}
+
+func (emptyDenylist) Contains(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+func TestAuthorizeDeniesUnlistedMethod(t *testing.T) {
+	tokens := auth.NewTokenManager("test secret", time.Minute)
+	token, _, err := tokens.Issue(uuid.New(), uuid.New(), string(entities.RoleAdmin), entities.RoleAdmin.PermissionNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &interceptors{tokens: tokens, denylist: emptyDenylist{}}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+
+	if _, err := i.authorize(ctx, "/wallet.v1.WalletService/Unlisted"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("unlisted method: got %v, want %v", err, codes.PermissionDenied)
+	}
+	if _, err := i.authorize(ctx, walletv1.WalletService_GetWallet_FullMethodName); err != nil {
+		t.Fatalf("listed method: %v", err)
+	}
+}
+
+func TestAuthorizeGetUserRequiresToken(t *testing.T) {
+	tokens := auth.NewTokenManager("test secret", time.Minute)
+	token, _, err := tokens.Issue(uuid.New(), uuid.New(), string(entities.RoleUser), entities.RoleUser.PermissionNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &interceptors{tokens: tokens, denylist: emptyDenylist{}}
+
+	if _, err := i.authorize(context.Background(), walletv1.UserService_GetUser_FullMethodName); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("anonymous: got %v, want %v", err, codes.Unauthenticated)
+	}
+	// Роль без users:read_all проходит интерцептор: чужого пользователя
+	// отклоняет сам метод
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	if _, err := i.authorize(ctx, walletv1.UserService_GetUser_FullMethodName); err != nil {
+		t.Fatalf("authenticated: %v", err)
+	}
+}
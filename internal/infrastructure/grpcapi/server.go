@@ -0,0 +1,96 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+
+	walletv1 "walletapitest/api/wallet/v1"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Deps - сервисы и проверка вызывающего, общие с REST API
+type Deps struct {
+	UserService    *services.UserService
+	AccountService *services.AccountService
+	WalletService  *services.WalletService
+	// AccessService - вход, доступ к кошелькам и step-up, как в REST
+	AccessService *services.AccessService
+	Tokens        *auth.TokenManager
+	Denylist      auth.Denylist
+	APIKeys       auth.APIKeyVerifier
+}
+
+// Server - gRPC API: UserService и WalletService поверх тех же доменных
+// сервисов, что и REST, плюс стандартная проверка здоровья grpc.health.v1
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+	// streams отменяется при остановке: потоки WatchBalance бесконечны и
+	// иначе не дали бы GracefulStop завершиться
+	streams     context.Context
+	stopStreams context.CancelFunc
+}
+
+func NewServer(deps Deps, log logger.Logger) *Server {
+	streams, stopStreams := context.WithCancel(context.Background())
+	i := &interceptors{
+		log:      log,
+		tokens:   deps.Tokens,
+		denylist: deps.Denylist,
+		apiKeys:  deps.APIKeys,
+	}
+	s := &Server{
+		server: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(i.observeUnary, i.recoverUnary, i.authorizeUnary),
+			grpc.ChainStreamInterceptor(i.observeStream, i.recoverStream, i.authorizeStream),
+		),
+		health:      health.NewServer(),
+		streams:     streams,
+		stopStreams: stopStreams,
+	}
+
+	walletv1.RegisterUserServiceServer(s.server, &userServer{
+		userService:    deps.UserService,
+		accountService: deps.AccountService,
+		access:         deps.AccessService,
+	})
+	walletv1.RegisterWalletServiceServer(s.server, &walletServer{
+		walletService: deps.WalletService,
+		access:        deps.AccessService,
+		streams:       streams,
+	})
+	healthpb.RegisterHealthServer(s.server, s.health)
+	return s
+}
+
+// Serve принимает соединения, пока сервер не остановлен
+func (s *Server) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// Shutdown переводит проверку здоровья в NOT_SERVING, закрывает потоки
+// балансов и ждет завершения остальных вызовов; по истечении ctx
+// оставшиеся вызовы обрываются
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	s.stopStreams()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
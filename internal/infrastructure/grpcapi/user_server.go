@@ -0,0 +1,129 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+
+	walletv1 "walletapitest/api/wallet/v1"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type userServer struct {
+	walletv1.UnimplementedUserServiceServer
+
+	userService    *services.UserService
+	accountService *services.AccountService
+	access         *services.AccessService
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *walletv1.CreateUserRequest) (*walletv1.CreateUserResponse, error) {
+	// Те же ограничения, что у CreateUserRequest в REST
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		return nil, status.Error(codes.InvalidArgument, "email must be a valid email address")
+	}
+	if len(req.Username) < 3 {
+		return nil, status.Error(codes.InvalidArgument, "username must be at least 3 characters")
+	}
+	if len(req.Password) < 6 {
+		return nil, status.Error(codes.InvalidArgument, "password must be at least 6 characters")
+	}
+
+	user, err := s.userService.CreateUser(ctx, req.Email, req.Username, req.Password)
+	if err != nil {
+		switch err {
+		case services.ErrEmailExists, services.ErrUsernameExists:
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		default:
+			return nil, internalError(ctx, "failed to create user", err)
+		}
+	}
+	ctx = logger.WithFields(ctx, "user_id", user.ID)
+
+	// Письмо не критично для регистрации: ссылку можно запросить повторно
+	if err := s.accountService.SendVerification(ctx, user); err != nil {
+		logger.FromContext(ctx).Error("failed to send verification email", "error", err)
+	}
+
+	return &walletv1.CreateUserResponse{User: toUser(user)}, nil
+}
+
+// Authenticate - вход как POST /api/v1/login через AccessService.Login:
+// пароль, второй фактор, учет неудач и новая сессия
+func (s *userServer) Authenticate(ctx context.Context, req *walletv1.AuthenticateRequest) (*walletv1.AuthenticateResponse, error) {
+	if req.Email == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "email and password are required")
+	}
+
+	user, tokens, err := s.access.Login(ctx, req.Email, req.Password, req.Otp, sessionMeta(ctx))
+	if err != nil {
+		var throttle *services.ThrottleError
+		switch {
+		case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrInvalidPassword):
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		case errors.Is(err, services.ErrMFARequired), errors.Is(err, services.ErrInvalidOTP):
+			return nil, reasonError(codes.Unauthenticated, err.Error(), reasonMFARequired, nil)
+		case errors.As(err, &throttle):
+			return nil, throttledError(throttle.Err.Error(), throttle.RetryAfter)
+		default:
+			return nil, internalError(ctx, "failed to log in", err)
+		}
+	}
+
+	return &walletv1.AuthenticateResponse{
+		AccessToken:      tokens.AccessToken,
+		ExpiresAt:        timestamppb.New(tokens.AccessExpiresAt),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: timestamppb.New(tokens.RefreshExpiresAt),
+		User:             toUser(user),
+	}, nil
+}
+
+// GetUser - сам пользователь или обладатель users:read_all
+func (s *userServer) GetUser(ctx context.Context, req *walletv1.GetUserRequest) (*walletv1.GetUserResponse, error) {
+	id, err := parseID(req.Id, "user id")
+	if err != nil {
+		return nil, err
+	}
+	if !auth.CanActOn(ctx, id, string(entities.PermUsersReadAll)) {
+		return nil, status.Error(codes.PermissionDenied, services.ErrForbidden.Error())
+	}
+
+	user, err := s.userService.GetUser(ctx, id)
+	if err != nil {
+		switch err {
+		case services.ErrUserNotFound:
+			return nil, status.Error(codes.NotFound, err.Error())
+		default:
+			return nil, internalError(ctx, "failed to get user", err)
+		}
+	}
+
+	return &walletv1.GetUserResponse{User: toUser(user)}, nil
+}
+
+// sessionMeta - клиент вызова для сессий и блокировок входа: адрес
+// соединения (внутренние клиенты ходят без прокси) и user-agent
+func sessionMeta(ctx context.Context) services.SessionMeta {
+	var meta services.SessionMeta
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		meta.UserAgent = firstValue(md, "user-agent")
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		meta.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(meta.IP); err == nil {
+			meta.IP = host
+		}
+	}
+	return meta
+}
@@ -0,0 +1,222 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	walletv1 "walletapitest/api/wallet/v1"
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type walletServer struct {
+	walletv1.UnimplementedWalletServiceServer
+
+	walletService *services.WalletService
+	access        *services.AccessService
+	// streams отменяется при остановке сервера
+	streams context.Context
+}
+
+func (s *walletServer) CreateWallet(ctx context.Context, req *walletv1.CreateWalletRequest) (*walletv1.CreateWalletResponse, error) {
+	userID, err := parseID(req.UserId, "user id")
+	if err != nil {
+		return nil, err
+	}
+	ctx = logger.WithFields(ctx, "target_user_id", userID)
+
+	if !auth.CanActOn(ctx, userID, string(entities.PermUsersManage)) {
+		return nil, status.Error(codes.PermissionDenied, services.ErrForbidden.Error())
+	}
+
+	wallet, err := s.walletService.CreateWallet(ctx, userID, req.Currency)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidCurrency:
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, internalError(ctx, "failed to create wallet", err)
+		}
+	}
+
+	return &walletv1.CreateWalletResponse{Wallet: toWallet(wallet)}, nil
+}
+
+func (s *walletServer) GetWallet(ctx context.Context, req *walletv1.GetWalletRequest) (*walletv1.GetWalletResponse, error) {
+	wallet, ctx, err := s.authorizedWallet(ctx, req.WalletId, entities.PermWalletsReadAll)
+	if err != nil {
+		return nil, err
+	}
+	return &walletv1.GetWalletResponse{Wallet: toWallet(wallet)}, nil
+}
+
+// ProcessOperation - пополнение или списание с теми же проверками, что
+// POST /api/v1/wallet: владелец, подтвержденный email и step-up для списаний
+func (s *walletServer) ProcessOperation(ctx context.Context, req *walletv1.ProcessOperationRequest) (*walletv1.ProcessOperationResponse, error) {
+	opType, ok := operationType(req.OperationType)
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "operation_type must be DEPOSIT or WITHDRAW")
+	}
+	if req.Amount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, services.ErrInvalidAmount.Error())
+	}
+	var requestID *uuid.UUID
+	if req.RequestId != "" {
+		id, err := parseID(req.RequestId, "request id")
+		if err != nil {
+			return nil, err
+		}
+		requestID = &id
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("operation.type", string(opType)),
+		attribute.Int64("operation.amount", req.Amount),
+	)
+
+	walletID, err := parseID(req.WalletId, "wallet id")
+	if err != nil {
+		return nil, err
+	}
+	ctx = walletContext(ctx, walletID)
+
+	// Повтор отдает уже проведенную операцию до проверок списания, как REST
+	result, err := s.access.ReplayedOperation(ctx, walletID, opType, req.Amount, requestID)
+	if errors.Is(err, services.ErrRequestMismatch) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err != nil {
+		return nil, accessError(ctx, err)
+	}
+	if result == nil {
+		result, err = s.process(ctx, walletID, opType, req.Amount, req.Otp, requestID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &walletv1.ProcessOperationResponse{
+		Operation: toOperation(result.Operation),
+		Balance:   result.BalanceAfter(),
+		Fee:       toFee(result.Fee),
+		Replayed:  result.Replayed,
+	}, nil
+}
+
+// process проверяет и проводит новую операцию
+func (s *walletServer) process(
+	ctx context.Context,
+	walletID uuid.UUID,
+	opType entities.OperationType,
+	amount int64,
+	otp string,
+	requestID *uuid.UUID,
+) (*entities.OperationResult, error) {
+	wallet, err := s.access.Operation(ctx, walletID, opType, amount, otp, sessionMeta(ctx))
+	if err != nil {
+		return nil, accessError(ctx, err)
+	}
+
+	result, err := s.walletService.ProcessOperation(ctx, wallet.ID, opType, amount, requestID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWalletNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, services.ErrInsufficientFunds), errors.Is(err, services.ErrWalletFrozen):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, services.ErrInvalidOperation), errors.Is(err, services.ErrInvalidAmount):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, services.ErrRequestMismatch):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		case errors.Is(err, services.ErrFeeWalletNotConfigured):
+			// Подробности ошибки конфигурации клиенту не показываем
+			return nil, internalError(ctx, "fee configuration is incomplete", err)
+		default:
+			return nil, internalError(ctx, "failed to process wallet operation", err)
+		}
+	}
+	return result, nil
+}
+
+// ListOperations - история операций кошелька (владелец или history:read_all)
+func (s *walletServer) ListOperations(ctx context.Context, req *walletv1.ListOperationsRequest) (*walletv1.ListOperationsResponse, error) {
+	wallet, ctx, err := s.authorizedWallet(ctx, req.WalletId, entities.PermHistoryReadAll)
+	if err != nil {
+		return nil, err
+	}
+
+	operations, err := s.walletService.GetOperations(ctx, wallet.ID, int(req.Limit), int(req.Offset))
+	if err != nil {
+		return nil, internalError(ctx, "failed to list operations", err)
+	}
+
+	resp := &walletv1.ListOperationsResponse{Operations: make([]*walletv1.Operation, 0, len(operations))}
+	for _, operation := range operations {
+		resp.Operations = append(resp.Operations, toOperation(operation))
+	}
+	return resp, nil
+}
+
+// WatchBalance - баланс кошелька и его изменения (владелец или
+// wallets:read_all). Поток завершается с Unavailable при остановке сервера.
+func (s *walletServer) WatchBalance(req *walletv1.WatchBalanceRequest, stream grpc.ServerStreamingServer[walletv1.BalanceUpdate]) error {
+	wallet, ctx, err := s.authorizedWallet(stream.Context(), req.WalletId, entities.PermWalletsReadAll)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.streams, cancel)
+	defer stop()
+
+	err = s.walletService.WatchBalance(ctx, wallet.ID, func(update *entities.BalanceUpdate) error {
+		return stream.Send(toBalanceUpdate(update))
+	})
+	switch {
+	case s.streams.Err() != nil:
+		return status.Error(codes.Unavailable, "server is shutting down")
+	case stream.Context().Err() != nil:
+		return status.FromContextError(stream.Context().Err()).Err()
+	case errors.Is(err, services.ErrBalanceFeedClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, services.ErrWalletNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case status.Code(err) != codes.Unknown:
+		// Ошибка отправки в поток уже несет статус
+		return err
+	default:
+		return internalError(ctx, "failed to watch balance", err)
+	}
+}
+
+// authorizedWallet загружает кошелек и проверяет доступ к нему так же, как
+// REST (AccessService.Wallet). Возвращает контекст с полями кошелька для
+// логов.
+func (s *walletServer) authorizedWallet(ctx context.Context, rawID string, perms ...entities.Permission) (*entities.Wallet, context.Context, error) {
+	walletID, err := parseID(rawID, "wallet id")
+	if err != nil {
+		return nil, ctx, err
+	}
+	ctx = walletContext(ctx, walletID)
+
+	wallet, err := s.access.Wallet(ctx, walletID, perms...)
+	if err != nil {
+		return nil, ctx, accessError(ctx, err)
+	}
+	return wallet, ctx, nil
+}
+
+// walletContext добавляет кошелек в поля логов и атрибуты span
+func walletContext(ctx context.Context, walletID uuid.UUID) context.Context {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("wallet.id", walletID.String()))
+	return logger.WithFields(ctx, "wallet_id", walletID)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+	userService    *services.UserService
+}
+
+func NewAccountHandler(
+	accountService *services.AccountService,
+	userService *services.UserService,
+) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		userService:    userService,
+	}
+}
+
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// VerifyEmail подтверждает email по токену из письма
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.accountService.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to verify email", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	withLogFields(c, "user_id", user.ID)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// ResendVerification повторно отправляет ссылку подтверждения вызывающему
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+	if claims.IsAPIKey() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user session required"})
+		return
+	}
+
+	user, err := h.userService.GetUser(c.Request.Context(), claims.UserID)
+	if err == nil {
+		err = h.accountService.SendVerification(c.Request.Context(), user)
+	}
+	if err != nil {
+		var throttle *services.ThrottleError
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.As(err, &throttle):
+			c.Header("Retry-After", strconv.Itoa(int(throttle.RetryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": throttle.Err.Error()})
+		default:
+			logInternalError(c, "failed to send verification email", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+// ForgotPassword отправляет ссылку сброса пароля. Ответ одинаков для
+// известных и неизвестных адресов.
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		logInternalError(c, "failed to request password reset", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the address is registered, a reset link has been sent"})
+}
+
+// ResetPassword меняет пароль по токену из письма и завершает все сессии
+// пользователя
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.accountService.ResetPassword(c.Request.Context(), req.Token, req.Password)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to reset password", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	withLogFields(c, "user_id", user.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset; sign in with the new password"})
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string      `json:"name" binding:"required,max=100"`
+	Permissions []string    `json:"permissions" binding:"required,min=1"`
+	WalletIDs   []uuid.UUID `json:"wallet_ids"`
+	ExpiresAt   *time.Time  `json:"expires_at"`
+}
+
+type RotateAPIKeyRequest struct {
+	// GracePeriodSeconds - сколько еще работает старый ключ
+	GracePeriodSeconds int `json:"grace_period_seconds" binding:"gte=0,lte=604800"`
+}
+
+// APIKeyResponse - ключ с секретом; секрет возвращается только при выпуске
+type APIKeyResponse struct {
+	Key string `json:"key"`
+	*entities.APIKey
+}
+
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	perms := make([]entities.Permission, len(req.Permissions))
+	for i, p := range req.Permissions {
+		perms[i] = entities.Permission(p)
+	}
+
+	key, plain, err := h.apiKeyService.Create(c.Request.Context(), services.APIKeySpec{
+		Name:        req.Name,
+		Permissions: perms,
+		WalletIDs:   req.WalletIDs,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		switch err {
+		case services.ErrInvalidPermission, services.ErrWalletScopeRequired:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case services.ErrPermissionNotGrantable, services.ErrForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to create api key", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	withLogFields(c, "api_key_id", key.ID)
+
+	c.JSON(http.StatusCreated, APIKeyResponse{Key: plain, APIKey: key})
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := h.apiKeyService.List(c.Request.Context())
+	if err != nil {
+		logInternalError(c, "failed to list api keys", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// Rotate выпускает новый ключ с теми же правами и гасит старый после grace-периода
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	id, ok := apiKeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	key, plain, err := h.apiKeyService.Rotate(c.Request.Context(), id, time.Duration(req.GracePeriodSeconds)*time.Second)
+	if err != nil {
+		switch err {
+		case services.ErrAPIKeyNotFound, services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrAPIKeyRevoked, services.ErrInvalidPermission, services.ErrWalletScopeRequired:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case services.ErrPermissionNotGrantable, services.ErrForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to rotate api key", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIKeyResponse{Key: plain, APIKey: key})
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, ok := apiKeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(c.Request.Context(), id); err != nil {
+		switch err {
+		case services.ErrAPIKeyNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to revoke api key", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func apiKeyIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return uuid.Nil, false
+	}
+	withLogFields(c, "api_key_id", id)
+	return id, true
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+type ListAuditResponse struct {
+	Events []*entities.AuditEvent `json:"events"`
+	Total  int                    `json:"total"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+}
+
+// List - поиск по журналу аудита, новые записи первыми.
+// Параметры: actor_id, action, target_type, target_id, from и to (RFC 3339),
+// limit и offset.
+func (h *AuditHandler) List(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DefaultAuditPageSize)))
+	if err != nil || limit <= 0 || limit > services.MaxAuditPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxAuditPageSize)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	filter := repositories.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if raw := c.Query("actor_id"); raw != "" {
+		actorID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid actor_id"})
+			return
+		}
+		filter.ActorID = &actorID
+	}
+	var ok bool
+	if filter.From, ok = timeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = timeQuery(c, "to"); !ok {
+		return
+	}
+
+	events, total, err := h.auditService.Search(c.Request.Context(), filter)
+	if err != nil {
+		logInternalError(c, "failed to search audit log", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListAuditResponse{
+		Events: events,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// Verify проверяет хеш-цепочку журнала целиком
+func (h *AuditHandler) Verify(c *gin.Context) {
+	result, err := h.auditService.Verify(c.Request.Context())
+	if err != nil {
+		logInternalError(c, "failed to verify audit log", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// timeQuery разбирает необязательный параметр времени в RFC 3339 и пишет 400 при ошибке
+func timeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp"})
+		return nil, false
+	}
+	return &t, true
+}
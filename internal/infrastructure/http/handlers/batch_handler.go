@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BatchHandler - пакетные операции (выплаты). Доступ к каждому кошельку
+// пакета проверяется так же, как у разовой операции; пополнять чужие кошельки
+// можно с разрешением payouts:create.
+type BatchHandler struct {
+	batchService *services.BatchService
+	wallets      *WalletHandler
+	// maxItems - предел размера пакета, чтобы не разбирать CSV целиком
+	maxItems int
+}
+
+func NewBatchHandler(batchService *services.BatchService, wallets *WalletHandler, maxItems int) *BatchHandler {
+	return &BatchHandler{
+		batchService: batchService,
+		wallets:      wallets,
+		maxItems:     maxItems,
+	}
+}
+
+type BatchItemRequest struct {
+	WalletID      uuid.UUID `json:"walletId" binding:"required"`
+	OperationType string    `json:"operationType" binding:"required,oneof=DEPOSIT WITHDRAW"`
+	Amount        int64     `json:"amount" binding:"required,gt=0"`
+	Reference     string    `json:"reference,omitempty" binding:"max=255"`
+}
+
+type BatchRequest struct {
+	// Atomic - все операции в одной транзакции; иначе каждая отдельно
+	Atomic bool `json:"atomic"`
+	// Async - поставить в очередь независимо от размера
+	Async bool `json:"async"`
+	// OTP - второй фактор, если в пакете есть списание выше порога step-up
+	OTP   string             `json:"otp,omitempty"`
+	Items []BatchItemRequest `json:"items" binding:"required,dive"`
+}
+
+type BatchJobResponse struct {
+	Job    *entities.BatchJob    `json:"job"`
+	Items  []*entities.BatchItem `json:"items"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+// batchCSVHeader - колонки CSV; reference можно опустить
+var batchCSVHeader = []string{"wallet_id", "operation_type", "amount", "reference"}
+
+// Submit принимает пакет в JSON, CSV (text/csv) или multipart с файлом file.
+// Для CSV флаги atomic, async и otp передаются параметрами запроса или
+// полями формы. Небольшой пакет проводится сразу (200 с результатами),
+// большой ставится в очередь (202 и ссылка на статус).
+func (h *BatchHandler) Submit(c *gin.Context) {
+	req, ok := h.bindBatch(c)
+	if !ok {
+		return
+	}
+	if len(req.Items) > h.maxItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrBatchTooLarge.Error(), "max_items": h.maxItems})
+		return
+	}
+	if !h.authorizeItems(c, req) {
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+	items := make([]*entities.BatchItem, len(req.Items))
+	for i, r := range req.Items {
+		items[i] = &entities.BatchItem{
+			WalletID:      r.WalletID,
+			OperationType: entities.OperationType(r.OperationType),
+			Amount:        r.Amount,
+		}
+		if r.Reference != "" {
+			reference := r.Reference
+			items[i].Reference = &reference
+		}
+	}
+
+	job, err := h.batchService.Submit(c.Request.Context(), claims.UserID, req.Atomic, req.Async, items)
+	if err != nil {
+		var itemErr *repositories.BatchItemError
+		switch {
+		case errors.As(err, &itemErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": itemErr.Err.Error(), "index": itemErr.Index})
+		case err == services.ErrBatchEmpty, err == services.ErrBatchTooLarge:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to submit batch", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	withLogFields(c, "batch_id", job.ID)
+	if !job.Done() {
+		c.Header("Location", "/api/v1/wallet/batch/"+job.ID.String())
+		c.JSON(http.StatusAccepted, gin.H{"job": job})
+		return
+	}
+
+	// Небольшой пакет: результаты всех операций сразу
+	jobItems, total, err := h.batchService.ListItems(c.Request.Context(), job.ID, nil, job.Total, 0)
+	if err != nil {
+		logInternalError(c, "failed to list batch items", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, BatchJobResponse{Job: job, Items: jobItems, Total: total, Limit: job.Total})
+}
+
+// Get - статус задания и результаты операций постранично (автор задания -
+// тот же пользователь или тот же API-ключ - или history:read_all); status
+// фильтрует операции по статусу
+func (h *BatchHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch job id"})
+		return
+	}
+	withLogFields(c, "batch_id", id)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DefaultBatchItemsPageSize)))
+	if err != nil || limit <= 0 || limit > services.MaxBatchItemsPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxBatchItemsPageSize)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+	var status *entities.BatchItemStatus
+	if raw := c.Query("status"); raw != "" {
+		s := entities.BatchItemStatus(raw)
+		switch s {
+		case entities.BatchItemPending, entities.BatchItemSucceeded, entities.BatchItemFailed, entities.BatchItemSkipped:
+			status = &s
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of pending, succeeded, failed, skipped"})
+			return
+		}
+	}
+
+	job, err := h.batchService.Get(c.Request.Context(), id)
+	if err != nil {
+		switch err {
+		case services.ErrBatchJobNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to get batch job", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+	if !claims.IsAuthor(job.UserID, job.APIKeyID) && !claims.HasPermission(string(entities.PermHistoryReadAll)) {
+		// Чужое задание неотличимо от несуществующего
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrBatchJobNotFound.Error()})
+		return
+	}
+
+	items, total, err := h.batchService.ListItems(c.Request.Context(), job.ID, status, limit, offset)
+	if err != nil {
+		logInternalError(c, "failed to list batch items", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, BatchJobResponse{
+		Job:    job,
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// bindBatch разбирает пакет из тела запроса; пишет 400 при ошибке
+func (h *BatchHandler) bindBatch(c *gin.Context) (*BatchRequest, bool) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	var (
+		body io.Reader
+		flag = c.Query
+	)
+	switch mediaType {
+	case "text/csv":
+		body = c.Request.Body
+	case "multipart/form-data":
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "multipart request must contain a CSV file in field \"file\""})
+			return nil, false
+		}
+		f, err := file.Open()
+		if err != nil {
+			logInternalError(c, "failed to open uploaded batch file", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return nil, false
+		}
+		defer f.Close()
+		body = f
+		flag = func(key string) string {
+			if value, ok := c.GetPostForm(key); ok {
+				return value
+			}
+			return c.Query(key)
+		}
+	default:
+		var req BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		return &req, true
+	}
+
+	req := &BatchRequest{OTP: flag("otp")}
+	var err error
+	if req.Atomic, err = parseBatchFlag(flag("atomic")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "atomic: " + err.Error()})
+		return nil, false
+	}
+	if req.Async, err = parseBatchFlag(flag("async")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "async: " + err.Error()})
+		return nil, false
+	}
+	if req.Items, err = h.parseBatchCSV(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return req, true
+}
+
+func parseBatchFlag(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseBatchCSV читает операции из CSV с заголовком batchCSVHeader. Чтение
+// прекращается, как только операций становится больше maxItems.
+func (h *BatchHandler) parseBatchCSV(body io.Reader) ([]BatchItemRequest, error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, services.ErrBatchEmpty
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+	if len(header) < 3 || len(header) > 4 {
+		return nil, fmt.Errorf("csv header must be %s", strings.Join(batchCSVHeader, ","))
+	}
+	for i, name := range header {
+		if strings.ToLower(strings.TrimSpace(name)) != batchCSVHeader[i] {
+			return nil, fmt.Errorf("csv header must be %s", strings.Join(batchCSVHeader, ","))
+		}
+	}
+
+	var items []BatchItemRequest
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(items) == h.maxItems {
+			return nil, services.ErrBatchTooLarge
+		}
+		if len(record) != len(header) {
+			return nil, fmt.Errorf("line %d: expected %d fields, got %d", line, len(header), len(record))
+		}
+
+		var item BatchItemRequest
+		if item.WalletID, err = uuid.Parse(record[0]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid wallet_id", line)
+		}
+		item.OperationType = strings.ToUpper(record[1])
+		if item.OperationType != string(entities.OperationTypeDeposit) && item.OperationType != string(entities.OperationTypeWithdraw) {
+			return nil, fmt.Errorf("line %d: operation_type must be DEPOSIT or WITHDRAW", line)
+		}
+		if item.Amount, err = strconv.ParseInt(record[2], 10, 64); err != nil || item.Amount <= 0 {
+			return nil, fmt.Errorf("line %d: amount must be a positive integer", line)
+		}
+		if len(record) == 4 {
+			if len(record[3]) > 255 {
+				return nil, fmt.Errorf("line %d: reference is longer than 255 characters", line)
+			}
+			item.Reference = record[3]
+		}
+		items = append(items, item)
+	}
+}
+
+// authorizeItems проверяет доступ к кошелькам пакета и защиту списаний и
+// пишет ответ при отказе. Несуществующие кошельки не отклоняют пакет:
+// такие операции получат отказ при проведении.
+func (h *BatchHandler) authorizeItems(c *gin.Context, req *BatchRequest) bool {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	ids := make([]uuid.UUID, 0, len(req.Items))
+	seen := make(map[uuid.UUID]bool, len(req.Items))
+	for _, item := range req.Items {
+		if !seen[item.WalletID] {
+			seen[item.WalletID] = true
+			ids = append(ids, item.WalletID)
+		}
+	}
+	wallets, err := h.wallets.walletService.GetWallets(c.Request.Context(), ids)
+	if err != nil {
+		logInternalError(c, "failed to load batch wallets", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+
+	canPayOut := !claims.IsAPIKey() && claims.HasPermission(string(entities.PermPayoutsCreate))
+	owners := make(map[uuid.UUID]bool)
+	stepUp := false
+	for i, item := range req.Items {
+		wallet := wallets[item.WalletID]
+		if wallet == nil {
+			continue
+		}
+		withdraw := item.OperationType == string(entities.OperationTypeWithdraw)
+		var allowed bool
+		switch {
+		case claims.IsAPIKey() && withdraw:
+			allowed = claims.CoversWallet(wallet.ID)
+		case claims.IsAPIKey():
+			// Ключ без области пополняет чужие кошельки только как выплаты
+			allowed = claims.CoversWallet(wallet.ID, string(entities.PermPayoutsCreate))
+		case claims.UserID == wallet.UserID:
+			allowed = true
+		default:
+			// Чужие кошельки можно только пополнять
+			allowed = canPayOut && !withdraw
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrForbidden.Error(), "index": i})
+			return false
+		}
+		if withdraw {
+			owners[wallet.UserID] = true
+			stepUp = stepUp || h.wallets.access.StepUpRequired(item.Amount)
+		}
+	}
+
+	for ownerID := range owners {
+		if err := h.wallets.access.RequireVerifiedEmail(c.Request.Context(), ownerID); err != nil {
+			writeAccessError(c, err)
+			return false
+		}
+	}
+	// Без API-ключа списывать можно только со своих кошельков: второй фактор -
+	// самого вызывающего
+	if stepUp {
+		if err := h.wallets.access.VerifyStepUp(c.Request.Context(), claims.UserID, req.OTP, sessionMeta(c)); err != nil {
+			writeAccessError(c, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/infrastructure/http/handlers"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const batchMaxItems = 3
+
+// batchAPI - обработчик пакетов на хранилище в памяти; каждый запрос
+// выполняется от имени claims, как после AuthMiddleware
+type batchAPI struct {
+	store   *memory.Store
+	wallets *services.WalletService
+	router  *gin.Engine
+	claims  *auth.Claims
+}
+
+func newBatchAPI(t *testing.T) *batchAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := memory.NewStore()
+	api := &batchAPI{
+		store:   store,
+		wallets: services.NewWalletService(memory.NewWalletRepository(store), nil, nil, nil),
+	}
+	account := services.NewAccountService(memory.NewUserRepository(store), memory.NewUserTokenRepository(store),
+		memory.NewLoginRepository(store), nil, nil, services.AccountPolicy{}, nil)
+	// Порог step-up не задан: второй фактор проверяют тесты доступа
+	access := services.NewAccessService(nil, nil, nil, account, api.wallets, 0)
+	batches := services.NewBatchService(memory.NewBatchRepository(store), api.wallets, services.BatchPolicy{
+		MaxItems:  batchMaxItems,
+		SyncLimit: batchMaxItems,
+		Workers:   1,
+	})
+	handler := handlers.NewBatchHandler(batches, handlers.NewWalletHandler(api.wallets, nil, access), batchMaxItems)
+
+	api.router = gin.New()
+	api.router.POST("/batch", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.WithClaims(c.Request.Context(), api.claims))
+		handler.Submit(c)
+	})
+	return api
+}
+
+// user создает пользователя с подтвержденным email и его кошелек с балансом
+func (api *batchAPI) user(t *testing.T, balance int64) (uuid.UUID, uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	name := "batch-" + uuid.NewString()
+	user := entities.NewUser(name+"@example.com", name, "secret")
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := memory.NewUserRepository(api.store).Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := api.wallets.CreateWallet(ctx, user.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance > 0 {
+		if _, err := api.wallets.ProcessOperation(ctx, wallet.ID, entities.OperationTypeDeposit, balance, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user.ID, wallet.ID
+}
+
+func (api *batchAPI) post(t *testing.T, contentType string, body []byte, query string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/batch"+query, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+func (api *batchAPI) postJSON(t *testing.T, req handlers.BatchRequest) (int, map[string]interface{}) {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api.post(t, "application/json", body, "")
+}
+
+// itemStatuses - статусы операций из ответа проведенного пакета
+func itemStatuses(t *testing.T, resp map[string]interface{}) []string {
+	t.Helper()
+	items, ok := resp["items"].([]interface{})
+	if !ok {
+		t.Fatalf("no items in %v", resp)
+	}
+	statuses := make([]string, len(items))
+	for i, item := range items {
+		statuses[i], _ = item.(map[string]interface{})["status"].(string)
+	}
+	return statuses
+}
+
+func TestBatchCSV(t *testing.T) {
+	api := newBatchAPI(t)
+	userID, walletID := api.user(t, 100)
+	api.claims = &auth.Claims{UserID: userID}
+	row := func(operation string, amount int) string {
+		return fmt.Sprintf("%s,%s,%d\n", walletID, operation, amount)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		query  string
+		status int
+		error  string
+	}{
+		{"header and rows", "wallet_id,operation_type,amount\n" + row("deposit", 10) + row("WITHDRAW", 5), "", http.StatusOK, ""},
+		{"reference column", "wallet_id,operation_type,amount,reference\n" + walletID.String() + ",DEPOSIT,10,invoice 42\n", "", http.StatusOK, ""},
+		{"atomic flag", "wallet_id,operation_type,amount\n" + row("DEPOSIT", 10), "?atomic=true", http.StatusOK, ""},
+		{"invalid flag", "wallet_id,operation_type,amount\n" + row("DEPOSIT", 10), "?async=maybe", http.StatusBadRequest, "async: "},
+		{"empty body", "", "", http.StatusBadRequest, services.ErrBatchEmpty.Error()},
+		{"wrong header", "wallet,type,amount\n" + row("DEPOSIT", 10), "", http.StatusBadRequest, "csv header must be"},
+		{"invalid wallet", "wallet_id,operation_type,amount\nnot-a-uuid,DEPOSIT,10\n", "", http.StatusBadRequest, "line 2: invalid wallet_id"},
+		{"invalid operation", "wallet_id,operation_type,amount\n" + row("TRANSFER", 10), "", http.StatusBadRequest, "line 2: operation_type"},
+		{"zero amount", "wallet_id,operation_type,amount\n" + row("DEPOSIT", 10) + row("DEPOSIT", 0), "", http.StatusBadRequest, "line 3: amount"},
+		{"missing field", "wallet_id,operation_type,amount\n" + walletID.String() + ",DEPOSIT\n", "", http.StatusBadRequest, "line 2: expected 3 fields"},
+		{"long reference", "wallet_id,operation_type,amount,reference\n" + walletID.String() + ",DEPOSIT,10," + strings.Repeat("r", 256) + "\n",
+			"", http.StatusBadRequest, "reference is longer"},
+		// Разбор прекращается на строке сверх предела
+		{"too many rows", "wallet_id,operation_type,amount\n" + strings.Repeat(row("DEPOSIT", 1), batchMaxItems+1), "",
+			http.StatusBadRequest, services.ErrBatchTooLarge.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := api.post(t, "text/csv", []byte(tt.body), tt.query)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %v", status, tt.status, resp)
+			}
+			if msg, _ := resp["error"].(string); !strings.Contains(msg, tt.error) {
+				t.Fatalf("error = %q, want %q", msg, tt.error)
+			}
+		})
+	}
+}
+
+func TestBatchMultipartCSV(t *testing.T) {
+	api := newBatchAPI(t)
+	userID, walletID := api.user(t, 0)
+	api.claims = &auth.Claims{UserID: userID}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	// Флаги передаются полями формы
+	if err := form.WriteField("atomic", "true"); err != nil {
+		t.Fatal(err)
+	}
+	file, err := form.CreateFormFile("file", "payouts.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(file, "wallet_id,operation_type,amount\n%s,DEPOSIT,10\n%s,WITHDRAW,50\n", walletID, walletID)
+	form.Close()
+
+	status, resp := api.post(t, form.FormDataContentType(), body.Bytes(), "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d: %v", status, resp)
+	}
+	// atomic из формы: списание без средств откатывает пополнение
+	if got := itemStatuses(t, resp); strings.Join(got, ",") != "skipped,failed" {
+		t.Fatalf("item statuses = %v, want skipped,failed", got)
+	}
+}
+
+func TestBatchJSONLimits(t *testing.T) {
+	api := newBatchAPI(t)
+	userID, walletID := api.user(t, 0)
+	api.claims = &auth.Claims{UserID: userID}
+	deposit := handlers.BatchItemRequest{WalletID: walletID, OperationType: "DEPOSIT", Amount: 1}
+
+	tests := []struct {
+		name   string
+		items  []handlers.BatchItemRequest
+		status int
+	}{
+		{"within limit", []handlers.BatchItemRequest{deposit, deposit, deposit}, http.StatusOK},
+		{"over limit", []handlers.BatchItemRequest{deposit, deposit, deposit, deposit}, http.StatusBadRequest},
+		{"invalid operation", []handlers.BatchItemRequest{{WalletID: walletID, OperationType: "TRANSFER", Amount: 1}}, http.StatusBadRequest},
+		{"zero amount", []handlers.BatchItemRequest{{WalletID: walletID, OperationType: "DEPOSIT"}}, http.StatusBadRequest},
+		{"long reference", []handlers.BatchItemRequest{{WalletID: walletID, OperationType: "DEPOSIT", Amount: 1, Reference: strings.Repeat("r", 256)}},
+			http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := api.postJSON(t, handlers.BatchRequest{Items: tt.items})
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %v", status, tt.status, resp)
+			}
+		})
+	}
+	if _, resp := api.postJSON(t, handlers.BatchRequest{Items: []handlers.BatchItemRequest{deposit, deposit, deposit, deposit}}); resp["max_items"] != float64(batchMaxItems) {
+		t.Fatalf("max_items = %v, want %d", resp["max_items"], batchMaxItems)
+	}
+}
+
+func TestBatchItemAuthorization(t *testing.T) {
+	api := newBatchAPI(t)
+	ownerID, own := api.user(t, 100)
+	_, foreign := api.user(t, 100)
+	payouts := string(entities.PermPayoutsCreate)
+	operate := string(entities.PermWalletsOperate)
+
+	tests := []struct {
+		name      string
+		claims    *auth.Claims
+		operation string
+		wallet    uuid.UUID
+		allowed   bool
+	}{
+		{"own wallet withdrawal", &auth.Claims{UserID: ownerID}, "WITHDRAW", own, true},
+		{"foreign wallet withdrawal", &auth.Claims{UserID: ownerID}, "WITHDRAW", foreign, false},
+		// Даже выплаты не дают списывать с чужих кошельков
+		{"foreign wallet withdrawal with payouts:create", &auth.Claims{UserID: ownerID, Permissions: []string{payouts}}, "WITHDRAW", foreign, false},
+		{"foreign wallet deposit", &auth.Claims{UserID: ownerID}, "DEPOSIT", foreign, false},
+		{"foreign wallet deposit with payouts:create", &auth.Claims{UserID: ownerID, Permissions: []string{payouts}}, "DEPOSIT", foreign, true},
+		{"api key withdrawal in scope", &auth.Claims{APIKeyID: uuid.New(), Permissions: []string{operate}, WalletIDs: []uuid.UUID{own}},
+			"WITHDRAW", own, true},
+		{"api key withdrawal out of scope", &auth.Claims{APIKeyID: uuid.New(), Permissions: []string{operate}, WalletIDs: []uuid.UUID{own}},
+			"WITHDRAW", foreign, false},
+		{"api key deposit out of scope", &auth.Claims{APIKeyID: uuid.New(), Permissions: []string{payouts}, WalletIDs: []uuid.UUID{own}},
+			"DEPOSIT", foreign, false},
+		{"unscoped api key payout", &auth.Claims{APIKeyID: uuid.New(), Permissions: []string{payouts}}, "DEPOSIT", foreign, true},
+		{"unscoped api key withdrawal", &auth.Claims{APIKeyID: uuid.New(), Permissions: []string{payouts}}, "WITHDRAW", foreign, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api.claims = tt.claims
+			// Запрещенная операция вторая: пакет отклоняется целиком с ее индексом
+			status, resp := api.postJSON(t, handlers.BatchRequest{Items: []handlers.BatchItemRequest{
+				{WalletID: own, OperationType: "DEPOSIT", Amount: 1},
+				{WalletID: tt.wallet, OperationType: tt.operation, Amount: 1},
+			}})
+			if !tt.allowed {
+				if status != http.StatusForbidden || resp["index"] != float64(1) {
+					t.Fatalf("status = %d, want 403 at index 1: %v", status, resp)
+				}
+				return
+			}
+			if status != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %v", status, resp)
+			}
+			if got := itemStatuses(t, resp); strings.Join(got, ",") != "succeeded,succeeded" {
+				t.Fatalf("item statuses = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerInitializer заменяет инициализатор из дистрибутива Swagger UI,
+// который открывает демо-спецификацию
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// DocsHandler отдает спецификацию OpenAPI и встроенный Swagger UI к ней
+type DocsHandler struct {
+	spec   []byte
+	index  []byte
+	assets http.Handler
+}
+
+func NewDocsHandler(spec []byte) *DocsHandler {
+	index, _ := fs.ReadFile(swaggerFiles.FS, "index.html")
+	return &DocsHandler{
+		spec:   spec,
+		index:  index,
+		assets: http.StripPrefix("/docs", http.FileServer(http.FS(swaggerFiles.FS))),
+	}
+}
+
+// Spec - документ OpenAPI 3.1
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", h.spec)
+}
+
+// UI - файлы Swagger UI под /docs/
+func (h *DocsHandler) UI(c *gin.Context) {
+	switch c.Param("filepath") {
+	case "/", "/index.html":
+		// FileServer перенаправляет index.html на каталог, поэтому страницу
+		// отдаем сами
+		c.Data(http.StatusOK, "text/html; charset=utf-8", h.index)
+	case "/swagger-initializer.js":
+		c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(swaggerInitializer))
+	default:
+		h.assets.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"walletapitest/internal/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	health *health.Service
+}
+
+func NewHealthHandler(health *health.Service) *HealthHandler {
+	return &HealthHandler{
+		health: health,
+	}
+}
+
+// Livez - процесс жив и обслуживает запросы; зависимости не проверяются
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readyz - инстанс готов принимать трафик: critical-зависимости доступны
+// и не идет graceful shutdown
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report, ready := h.health.Ready(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Health - подробный отчет по всем зависимостям и фоновым воркерам
+func (h *HealthHandler) Health(c *gin.Context) {
+	report := h.health.Report(c.Request.Context())
+	if report.Status == health.StatusDown {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
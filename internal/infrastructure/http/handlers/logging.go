@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"walletapitest/internal/pkg/logger"
+)
+
+// withLogFields дополняет логгер запроса полями (user_id, wallet_id),
+// чтобы они попали в логи сервисов, репозиториев и в access-лог
+func withLogFields(c *gin.Context, fields ...interface{}) {
+	c.Request = c.Request.WithContext(logger.WithFields(c.Request.Context(), fields...))
+}
+
+// logInternalError пишет неожиданную ошибку в лог запроса
+func logInternalError(c *gin.Context, msg string, err error) {
+	_ = c.Error(err)
+	logger.FromContext(c.Request.Context()).Error(msg, "error", err)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	mfaService *services.MFAService
+}
+
+func NewMFAHandler(mfaService *services.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+type OTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Enroll выдает новый TOTP-секрет и otpauth:// URI для QR-кода
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	enrollment, err := h.mfaService.Enroll(c.Request.Context(), claims.UserID)
+	if err != nil {
+		switch err {
+		case services.ErrMFAAlreadyEnabled:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case services.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to enroll totp", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      enrollment.Secret,
+		"otpauth_uri": enrollment.URI,
+	})
+}
+
+// Confirm включает второй фактор и возвращает коды восстановления
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req OTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	codes, err := h.mfaService.Confirm(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		switch err {
+		case services.ErrMFANotEnrolled:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrMFAAlreadyEnabled:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case services.ErrInvalidOTP:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to confirm totp", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Disable выключает второй фактор
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req OTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	if err := h.mfaService.Disable(c.Request.Context(), claims.UserID, req.Code, sessionMeta(c)); err != nil {
+		var throttle *services.ThrottleError
+		switch {
+		case err == services.ErrMFANotEnrolled:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case err == services.ErrInvalidOTP, err == services.ErrMFARequired:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.As(err, &throttle):
+			writeThrottled(c, throttle)
+		default:
+			logInternalError(c, "failed to disable totp", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
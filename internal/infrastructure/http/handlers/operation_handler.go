@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// queueOperation ставит проверенную операцию в очередь и отвечает 202
+// со ссылкой на ее статус; requestID - ключ идемпотентности клиента
+func (h *WalletHandler) queueOperation(c *gin.Context, req *WalletOperationRequest, operationType entities.OperationType, requestID *uuid.UUID) {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	request, err := h.operationQueue.Submit(c.Request.Context(), claims.UserID, req.WalletID, operationType, req.Amount, requestID)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidOperation, services.ErrInvalidAmount:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case services.ErrRequestMismatch:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to queue wallet operation", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	withLogFields(c, "operation_request_id", request.ID)
+	c.Header("Location", "/api/v1/operations/"+request.ID.String())
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":       "operation accepted",
+		"walletId":      req.WalletID,
+		"operationType": req.OperationType,
+		"amount":        req.Amount,
+		"operationId":   request.ID,
+		"status":        request.Status,
+		"replayed":      request.Replayed,
+	})
+}
+
+// GetOperation - состояние отложенной операции (владелец кошелька или
+// history:read_all)
+func (h *WalletHandler) GetOperation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid operation id"})
+		return
+	}
+	withLogFields(c, "operation_request_id", id)
+
+	request, err := h.operationQueue.Get(c.Request.Context(), id)
+	if err != nil {
+		switch err {
+		case services.ErrOperationRequestNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to get queued operation", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if _, ok := h.authorizedWallet(c, request.WalletID, entities.PermHistoryReadAll); !ok {
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: reconciliationService,
+	}
+}
+
+type ListReconciliationRunsResponse struct {
+	Runs   []*entities.ReconciliationRun `json:"runs"`
+	Total  int                           `json:"total"`
+	Limit  int                           `json:"limit"`
+	Offset int                           `json:"offset"`
+}
+
+type ReconciliationReportResponse struct {
+	Run           *entities.ReconciliationRun `json:"run"`
+	Discrepancies []*entities.Discrepancy     `json:"discrepancies"`
+}
+
+// ListRuns - проходы сверки, новые первыми
+func (h *ReconciliationHandler) ListRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DefaultReconciliationPageSize)))
+	if err != nil || limit <= 0 || limit > services.MaxReconciliationPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxReconciliationPageSize)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	runs, total, err := h.reconciliationService.ListRuns(c.Request.Context(), limit, offset)
+	if err != nil {
+		logInternalError(c, "failed to list reconciliation runs", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListReconciliationRunsResponse{
+		Runs:   runs,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// Report - отчет о проходе сверки с расхождениями; id "latest" - последний проход
+func (h *ReconciliationHandler) Report(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var runID uuid.UUID
+	if raw := c.Param("id"); raw == "latest" {
+		latest, err := h.reconciliationService.LatestRun(ctx)
+		if err != nil {
+			logInternalError(c, "failed to load last reconciliation run", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if latest == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrReconciliationNotFound.Error()})
+			return
+		}
+		runID = latest.ID
+	} else {
+		var err error
+		if runID, err = uuid.Parse(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reconciliation run id"})
+			return
+		}
+	}
+
+	run, discrepancies, err := h.reconciliationService.GetRun(ctx, runID)
+	if err != nil {
+		switch err {
+		case services.ErrReconciliationNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to load reconciliation report", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ReconciliationReportResponse{Run: run, Discrepancies: discrepancies})
+}
+
+// Run запускает сверку вне расписания и возвращает ее отчет
+func (h *ReconciliationHandler) Run(c *gin.Context) {
+	run, discrepancies, err := h.reconciliationService.Reconcile(c.Request.Context())
+	if err != nil {
+		switch err {
+		case services.ErrReconciliationRunning:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to run reconciliation", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ReconciliationReportResponse{Run: run, Discrepancies: discrepancies})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/cron"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ScheduleHandler - регулярные переводы. Доступ к кошельку списания и
+// проверки перед списанием (email, второй фактор) те же, что у разовых операций.
+type ScheduleHandler struct {
+	scheduleService *services.ScheduleService
+	wallets         *WalletHandler
+}
+
+func NewScheduleHandler(scheduleService *services.ScheduleService, wallets *WalletHandler) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+		wallets:         wallets,
+	}
+}
+
+type CreateScheduleRequest struct {
+	ToWalletID  uuid.UUID `json:"to_wallet_id" binding:"required"`
+	Amount      int64     `json:"amount" binding:"required,gt=0"`
+	Description string    `json:"description" binding:"max=255"`
+	// Ровно одно из правил: cron-выражение в UTC или интервал в секундах
+	Cron            *string `json:"cron,omitempty"`
+	IntervalSeconds *int64  `json:"interval_seconds,omitempty"`
+	// StartAt - не раньше этого момента (по умолчанию сейчас)
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// OTP - второй фактор, если сумма выше порога step-up
+	OTP string `json:"otp,omitempty"`
+}
+
+type ListScheduleRunsResponse struct {
+	Runs   []*entities.ScheduleRun `json:"runs"`
+	Total  int                     `json:"total"`
+	Limit  int                     `json:"limit"`
+	Offset int                     `json:"offset"`
+}
+
+// Create заводит регулярный перевод с кошелька :walletId (только владелец)
+func (h *ScheduleHandler) Create(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok {
+		return
+	}
+	var req CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Каждый запуск - списание, поэтому проверки те же, что у POST /api/v1/wallet
+	wallet, err := h.wallets.access.Operation(c.Request.Context(), walletID, entities.OperationTypeWithdraw, req.Amount, req.OTP, sessionMeta(c))
+	if err != nil {
+		writeAccessError(c, err)
+		return
+	}
+	withLogFields(c, "user_id", wallet.UserID)
+
+	startAt := time.Now()
+	if req.StartAt != nil {
+		startAt = *req.StartAt
+	}
+
+	schedule, err := h.scheduleService.Create(c.Request.Context(), wallet, req.ToWalletID, req.Amount, req.Description,
+		req.Cron, req.IntervalSeconds, startAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, cron.ErrInvalidExpression):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "destination wallet not found"})
+		case err == services.ErrScheduleRuleRequired, err == services.ErrScheduleInterval,
+			err == services.ErrScheduleNeverFires, err == services.ErrSameWallet, err == services.ErrInvalidAmount,
+			err == services.ErrCurrencyMismatch:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to create schedule", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	withLogFields(c, "schedule_id", schedule.ID)
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// List - расписания кошелька, кроме отмененных (владелец или wallets:read_all)
+func (h *ScheduleHandler) List(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.wallets.authorizedWallet(c, walletID, entities.PermWalletsReadAll); !ok {
+		return
+	}
+
+	schedules, err := h.scheduleService.ListByWallet(c.Request.Context(), walletID)
+	if err != nil {
+		logInternalError(c, "failed to list schedules", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// Get - расписание (владелец кошелька списания или wallets:read_all)
+func (h *ScheduleHandler) Get(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c, entities.PermWalletsReadAll)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Pause приостанавливает расписание (владелец)
+func (h *ScheduleHandler) Pause(c *gin.Context) {
+	h.changeState(c, h.scheduleService.Pause)
+}
+
+// Resume возобновляет расписание со следующего срока (владелец)
+func (h *ScheduleHandler) Resume(c *gin.Context) {
+	h.changeState(c, h.scheduleService.Resume)
+}
+
+// Cancel отменяет расписание (владелец)
+func (h *ScheduleHandler) Cancel(c *gin.Context) {
+	h.changeState(c, h.scheduleService.Cancel)
+}
+
+// Runs - история попыток выполнения, новые первыми
+// (владелец, wallets:read_all или history:read_all)
+func (h *ScheduleHandler) Runs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DefaultScheduleRunsPageSize)))
+	if err != nil || limit <= 0 || limit > services.MaxScheduleRunsPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxScheduleRunsPageSize)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	schedule, ok := h.loadSchedule(c, entities.PermWalletsReadAll, entities.PermHistoryReadAll)
+	if !ok {
+		return
+	}
+
+	runs, total, err := h.scheduleService.ListRuns(c.Request.Context(), schedule.ID, limit, offset)
+	if err != nil {
+		logInternalError(c, "failed to list schedule runs", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListScheduleRunsResponse{
+		Runs:   runs,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+func (h *ScheduleHandler) changeState(c *gin.Context, change func(ctx context.Context, schedule *entities.Schedule) error) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+
+	if err := change(c.Request.Context(), schedule); err != nil {
+		switch err {
+		case services.ErrScheduleCancelled:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case services.ErrScheduleNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrScheduleNeverFires:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to change schedule state", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// loadSchedule загружает расписание из :id и проверяет доступ к кошельку
+// списания так же, как authorizedWallet; пишет ответ при отказе
+func (h *ScheduleHandler) loadSchedule(c *gin.Context, perms ...entities.Permission) (*entities.Schedule, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return nil, false
+	}
+	withLogFields(c, "schedule_id", id)
+
+	schedule, err := h.scheduleService.Get(c.Request.Context(), id)
+	if err != nil {
+		switch err {
+		case services.ErrScheduleNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to get schedule", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return nil, false
+	}
+
+	if _, ok := h.wallets.authorizedWallet(c, schedule.FromWalletID, perms...); !ok {
+		return nil, false
+	}
+	return schedule, true
+}
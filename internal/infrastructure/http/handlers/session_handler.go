@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenResponse struct {
+	Token            string `json:"token"`
+	ExpiresAt        string `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов (ротация)
+func (h *SessionHandler) Refresh(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidRefreshToken, services.ErrSessionRevoked, services.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to refresh token", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.AccessExpiresAt.UTC().Format(time.RFC3339),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// Logout отзывает текущую сессию
+func (h *SessionHandler) Logout(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	if err := h.sessionService.Logout(c.Request.Context(), claims.SessionID); err != nil {
+		logInternalError(c, "failed to revoke session", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll отзывает все сессии пользователя, включая текущую
+func (h *SessionHandler) LogoutAll(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+
+	count, err := h.sessionService.LogoutAll(c.Request.Context(), claims.UserID)
+	if err != nil {
+		logInternalError(c, "failed to revoke sessions", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked_sessions": count})
+}
+
+func sessionMeta(c *gin.Context) services.SessionMeta {
+	return services.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// writeThrottled отвечает 429 с Retry-After на блокировку за перебор
+func writeThrottled(c *gin.Context, throttle *services.ThrottleError) {
+	c.Header("Retry-After", strconv.Itoa(int(throttle.RetryAfter.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": throttle.Err.Error()})
+}
@@ -1,21 +1,36 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/repositories"
 	"walletapitest/internal/domain/services"
+	"walletapitest/internal/pkg/auth"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService    *services.UserService
+	accountService *services.AccountService
+	// access - вход со вторым фактором, общий с gRPC
+	access *services.AccessService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
+func NewUserHandler(
+	userService *services.UserService,
+	accountService *services.AccountService,
+	access *services.AccessService,
+) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:    userService,
+		accountService: accountService,
+		access:         access,
 	}
 }
 
@@ -28,13 +43,42 @@ type CreateUserRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// OTP - TOTP-код или код восстановления; обязателен, если включен второй фактор
+	OTP string `json:"otp"`
+}
+
+type UpdateUserRequest struct {
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Username *string `json:"username" binding:"omitempty,min=3"`
+	// CurrentPassword или OTP вызывающего обязательны для смены email
+	CurrentPassword string `json:"current_password,omitempty"`
+	OTP             string `json:"otp,omitempty"`
+}
+
+type ListUsersResponse struct {
+	Users  []UserResponse `json:"users"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
 }
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	CreatedAt string    `json:"created_at"`
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Tier     string    `json:"tier"`
+	// EmailVerified - без подтвержденного email списания запрещены
+	EmailVerified bool   `json:"email_verified"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type SetTierRequest struct {
+	Tier string `json:"tier" binding:"required"`
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
@@ -47,10 +91,11 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	user, err := h.userService.CreateUser(c.Request.Context(), req.Email, req.Username, req.Password)
 	if err != nil {
 		switch err {
-		case services.ErrEmailExists:
+		case services.ErrEmailExists, services.ErrUsernameExists:
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		default:
 			// Логируем реальную ошибку для отладки
+			logInternalError(c, "failed to create user", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "internal server error",
 				"details": err.Error(),
@@ -58,15 +103,14 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		}
 		return
 	}
-	
-	response := UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Username:  user.Username,
-		CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
+	withLogFields(c, "user_id", user.ID)
+
+	// Письмо не критично для регистрации: ссылку можно запросить повторно
+	if err := h.accountService.SendVerification(c.Request.Context(), user); err != nil {
+		logInternalError(c, "failed to send verification email", err)
 	}
-	
-	c.JSON(http.StatusCreated, response)
+
+	c.JSON(http.StatusCreated, newUserResponse(user))
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
@@ -76,26 +120,27 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
-	
+	withLogFields(c, "user_id", id)
+
+	// Данные пользователя видит он сам или обладатель users:read_all
+	if !auth.CanActOn(c.Request.Context(), id, string(entities.PermUsersReadAll)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrForbidden.Error()})
+		return
+	}
+
 	user, err := h.userService.GetUser(c.Request.Context(), id)
 	if err != nil {
 		switch err {
 		case services.ErrUserNotFound:
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		default:
+			logInternalError(c, "failed to get user", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		}
 		return
 	}
-	
-	response := UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Username:  user.Username,
-		CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
-	}
-	
-	c.JSON(http.StatusOK, response)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
@@ -105,36 +150,266 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 	
-	user, err := h.userService.Authenticate(c.Request.Context(), req.Email, req.Password)
+	// Пароль, второй фактор и учет попытки - общие с gRPC
+	user, tokens, err := h.access.Login(c.Request.Context(), req.Email, req.Password, req.OTP, sessionMeta(c))
 	if err != nil {
-		switch err {
-		case services.ErrUserNotFound, services.ErrInvalidPassword:
+		var throttle *services.ThrottleError
+		switch {
+		case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrInvalidPassword):
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		case errors.Is(err, services.ErrMFARequired), errors.Is(err, services.ErrInvalidOTP):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "mfa_required": true})
+		case errors.As(err, &throttle):
+			writeThrottled(c, throttle)
 		default:
+			logInternalError(c, "failed to log in", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		}
 		return
 	}
+	withLogFields(c, "user_id", user.ID)
 	
-	// Генерация JWT токена
-	token, err := generateJWT(user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"token": tokens.AccessToken,
+		"expires_at": tokens.AccessExpiresAt.UTC().Format(time.RFC3339),
+		"refresh_token": tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt.UTC().Format(time.RFC3339),
+		"user": newUserResponse(user),
+	})
+}
+
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
-	
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			Username:  user.Username,
-			CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
-		},
+	withLogFields(c, "target_user_id", id)
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Email == nil && req.Username == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to update"})
+		return
+	}
+
+	user, err := h.access.UpdateUser(c.Request.Context(), id, services.UserUpdate{
+		Email:    req.Email,
+		Username: req.Username,
+	}, services.Reauthentication{
+		Password: req.CurrentPassword,
+		OTP:      req.OTP,
+		Meta:     sessionMeta(c),
+	})
+	if err != nil {
+		var throttle *services.ThrottleError
+		switch {
+		case err == services.ErrForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case err == services.ErrReauthRequired, err == services.ErrInvalidPassword, err == services.ErrInvalidOTP,
+			err == services.ErrMFAEnrollmentRequired:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "reauthentication_required": true})
+		case errors.As(err, &throttle):
+			writeThrottled(c, throttle)
+		case err == services.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case err == services.ErrEmailExists, err == services.ErrUsernameExists:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to update user", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	// Новый адрес нужно подтвердить заново
+	if req.Email != nil && !user.EmailVerified() {
+		if err := h.accountService.SendVerification(c.Request.Context(), user); err != nil {
+			logInternalError(c, "failed to send verification email", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	withLogFields(c, "target_user_id", id)
+
+	if err := h.userService.DeleteUser(c.Request.Context(), id); err != nil {
+		switch err {
+		case services.ErrForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case services.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrUserHasFunds:
+			c.JSON(http.StatusConflict, gin.H{"error": "user has wallets with non-zero balance; withdraw the funds first"})
+		default:
+			logInternalError(c, "failed to delete user", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUsers - список пользователей для администраторов.
+// Параметры: limit, offset, sort (created_at|email|username), order (asc|desc),
+// email и username - поиск по префиксу.
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DefaultUsersPageSize)))
+	if err != nil || limit <= 0 || limit > services.MaxUsersPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxUsersPageSize)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "created_at")
+	if sortBy != "created_at" && sortBy != "email" && sortBy != "username" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be created_at, email or username"})
+		return
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	users, total, err := h.userService.ListUsers(c.Request.Context(), repositories.UserFilter{
+		EmailPrefix:    c.Query("email"),
+		UsernamePrefix: c.Query("username"),
+		SortBy:         sortBy,
+		SortDesc:       order == "desc",
+		Limit:          limit,
+		Offset:         offset,
 	})
+	if err != nil {
+		logInternalError(c, "failed to list users", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	response := ListUsersResponse{
+		Users:  make([]UserResponse, 0, len(users)),
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, newUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
-func generateJWT(userID uuid.UUID) (string, error) {
-	// Реализация генерации JWT
-	return "jwt-token-example", nil
-}
\ No newline at end of file
+// SetRole назначает пользователю роль. Сессии пользователя отзываются,
+// чтобы новые разрешения вступили в силу сразу, а не после истечения токенов.
+func (h *UserHandler) SetRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	withLogFields(c, "target_user_id", id)
+
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.SetRole(c.Request.Context(), id, entities.Role(req.Role))
+	if err != nil {
+		switch err {
+		case services.ErrInvalidRole:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case services.ErrForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case services.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to set user role", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// SetTier назначает тариф комиссий; действует на следующие операции
+func (h *UserHandler) SetTier(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	withLogFields(c, "target_user_id", id)
+
+	var req SetTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.SetTier(c.Request.Context(), id, req.Tier)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidTier:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case services.ErrForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case services.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to set user tier", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// RecentSignIns - последние попытки входа в аккаунт вызывающего
+func (h *UserHandler) RecentSignIns(c *gin.Context) {
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+	if claims.IsAPIKey() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user session required"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	events, err := h.userService.RecentSignIns(c.Request.Context(), claims.UserID, limit)
+	if err != nil {
+		logInternalError(c, "failed to list sign-ins", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sign_ins": events})
+}
+
+func newUserResponse(user *entities.User) UserResponse {
+	return UserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		Role:          string(user.Role),
+		Tier:          user.Tier,
+		EmailVerified: user.EmailVerified(),
+		CreatedAt:     user.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
@@ -1,22 +1,45 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"walletapitest/internal/domain/entities"
 	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/statement"
+	"walletapitest/internal/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// IdempotencyKeyHeader - ключ идемпотентности операции (UUID): повтор с тем
+// же ключом возвращает уже проведенную операцию, а с async - уже принятый
+// запрос
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type WalletHandler struct {
 	walletService *services.WalletService
+	// operationQueue - отложенные операции (async)
+	operationQueue *services.OperationQueueService
+	// access - доступ к кошелькам, email и step-up, общие с gRPC
+	access *services.AccessService
 }
 
-func NewWalletHandler(walletService *services.WalletService) *WalletHandler {
+func NewWalletHandler(
+	walletService *services.WalletService,
+	operationQueue *services.OperationQueueService,
+	access *services.AccessService,
+) *WalletHandler {
 	return &WalletHandler{
-		walletService: walletService,
+		walletService:  walletService,
+		operationQueue: operationQueue,
+		access:         access,
 	}
 }
 
@@ -24,18 +47,32 @@ type WalletOperationRequest struct {
 	WalletID      uuid.UUID `json:"walletId" binding:"required"`
 	OperationType string    `json:"operationType" binding:"required,oneof=DEPOSIT WITHDRAW"`
 	Amount        int64       `json:"amount" binding:"required,gt=0"`
+	// OTP - второй фактор для списаний выше порога
+	OTP string `json:"otp,omitempty"`
+	// Async - поставить операцию в очередь и сразу ответить 202
+	Async bool `json:"async,omitempty"`
 }
 
 type WalletResponse struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"user_id"`
 	Balance   int64       `json:"balance"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
 	CreatedAt string    `json:"created_at"`
 	UpdatedAt string    `json:"updated_at"`
 }
 
 type CreateWalletRequest struct {
 	UserID      uuid.UUID `json:"user_id" binding:"required"`
+	// Currency - код ISO 4217; по умолчанию USD
+	Currency string `json:"currency,omitempty"`
+}
+
+type AdjustWalletRequest struct {
+	// Amount - сумма со знаком: положительная зачисляется, отрицательная списывается
+	Amount int64  `json:"amount" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
 }
 
 func (h *WalletHandler) ProcessOperation(c *gin.Context) {
@@ -45,28 +82,95 @@ func (h *WalletHandler) ProcessOperation(c *gin.Context) {
 		return
 	}
 
+	withLogFields(c, "wallet_id", req.WalletID)
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(
+		attribute.String("wallet.id", req.WalletID.String()),
+		attribute.String("operation.type", req.OperationType),
+		attribute.Int64("operation.amount", req.Amount),
+	)
+
 	operationType := entities.OperationType(req.OperationType)
 	if operationType != entities.OperationTypeDeposit && operationType != entities.OperationTypeWithdraw {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "operationType must be DEPOSIT or WITHDRAW"})
 		return
 	}
 
-	err := h.walletService.ProcessOperation(
+	requestID, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	// Повтор уже принятой или проведенной операции отдает ее результат до
+	// проверок списания: код второго фактора из первой попытки уже использован
+	if req.Async {
+		// Запрос с этим ключом уже в очереди: Submit вернет его, сверив
+		// автора и параметры. Доступ к кошельку проверяется и при повторе,
+		// как в ReplayedOperation.
+		if requestID != nil {
+			if _, err := h.operationQueue.Get(c.Request.Context(), *requestID); err == nil {
+				if _, err := h.access.Wallet(c.Request.Context(), req.WalletID); err != nil {
+					writeAccessError(c, err)
+					return
+				}
+				h.queueOperation(c, &req, operationType, requestID)
+				return
+			}
+		}
+	} else {
+		replay, err := h.access.ReplayedOperation(c.Request.Context(), req.WalletID, operationType, req.Amount, requestID)
+		if errors.Is(err, services.ErrRequestMismatch) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeAccessError(c, err)
+			return
+		}
+		if replay != nil {
+			writeOperationResult(c, &req, replay)
+			return
+		}
+	}
+
+	// Операции проводит только владелец кошелька; списания - с подтвержденным
+	// email и вторым фактором выше порога
+	wallet, err := h.access.Operation(c.Request.Context(), req.WalletID, operationType, req.Amount, req.OTP, sessionMeta(c))
+	if err != nil {
+		writeAccessError(c, err)
+		return
+	}
+	withLogFields(c, "user_id", wallet.UserID)
+
+	if req.Async {
+		h.queueOperation(c, &req, operationType, requestID)
+		return
+	}
+
+	result, err := h.walletService.ProcessOperation(
 		c.Request.Context(),
 		req.WalletID,
 		operationType,
 		req.Amount,
+		requestID,
 	)
-
 	if err != nil {
 		switch err {
 		case services.ErrWalletNotFound:
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		case services.ErrInsufficientFunds:
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case services.ErrWalletFrozen, services.ErrRequestMismatch:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		case services.ErrInvalidOperation, services.ErrInvalidAmount:
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		default:
+			if errors.Is(err, services.ErrFeeWalletNotConfigured) {
+				// Подробности ошибки конфигурации клиенту не показываем
+				logInternalError(c, "fee configuration is incomplete", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			logInternalError(c, "failed to process wallet operation", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "internal server error",
 				"details": err.Error(),
@@ -75,33 +179,48 @@ func (h *WalletHandler) ProcessOperation(c *gin.Context) {
 		return
 	}
 
+	writeOperationResult(c, &req, result)
+}
+
+// writeOperationResult отвечает результатом проведенной (или повторенной)
+// операции; итоговый баланс - после комиссии, если она была
+func writeOperationResult(c *gin.Context, req *WalletOperationRequest, result *entities.OperationResult) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "operation completed successfully",
 		"walletId": req.WalletID,
 		"operationType": req.OperationType,
 		"amount": req.Amount,
+		"operationId": result.Operation.ID,
+		"balance": result.BalanceAfter(),
+		"fee": result.Fee,
+		"replayed": result.Replayed,
 	})
 }
 
-func (h *WalletHandler) GetWallet(c *gin.Context) {
-	walletIDStr := c.Param("walletId")
-	walletID, err := uuid.Parse(walletIDStr)
+// idempotencyKey разбирает необязательный заголовок Idempotency-Key и
+// отвечает 400, если это не UUID
+func idempotencyKey(c *gin.Context) (*uuid.UUID, bool) {
+	header := c.GetHeader(IdempotencyKeyHeader)
+	if header == "" {
+		return nil, true
+	}
+	key, err := uuid.Parse(header)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wallet id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": IdempotencyKeyHeader + " must be a UUID"})
+		return nil, false
+	}
+	withLogFields(c, "idempotency_key", key)
+	return &key, true
+}
+
+func (h *WalletHandler) GetWallet(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok {
 		return
 	}
 
-	wallet, err := h.walletService.GetWallet(c.Request.Context(), walletID)
-	if err != nil {
-		switch err {
-		case services.ErrWalletNotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "internal server error",
-				"details": err.Error(),
-			})
-		}
+	wallet, ok := h.authorizedWallet(c, walletID, entities.PermWalletsReadAll)
+	if !ok {
 		return
 	}
 
@@ -109,6 +228,8 @@ func (h *WalletHandler) GetWallet(c *gin.Context) {
 		ID:        wallet.ID,
 		UserID:    wallet.UserID,
 		Balance:   wallet.Balance,
+		Currency:  wallet.Currency,
+		Status:    string(wallet.Status),
 		CreatedAt: wallet.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt: wallet.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
@@ -122,18 +243,352 @@ func (h *WalletHandler) CreateWallet(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	withLogFields(c, "user_id", req.UserID)
+
+	if !auth.CanActOn(c.Request.Context(), req.UserID, string(entities.PermUsersManage)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrForbidden.Error()})
+		return
+	}
 	
-	w,err:= h.walletService.CreateWallet(c.Request.Context(), req.UserID)
+	w,err:= h.walletService.CreateWallet(c.Request.Context(), req.UserID, req.Currency)
+	if err == services.ErrInvalidCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 		if err != nil {
+		logInternalError(c, "failed to create wallet", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to create wallet"})
 		return 
 	}
+	withLogFields(c, "wallet_id", w.ID)
 	
 	c.JSON(http.StatusOK, w)
 }
 
+// ListOperations - история операций кошелька (владелец или history:read_all)
+func (h *WalletHandler) ListOperations(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.authorizedWallet(c, walletID, entities.PermHistoryReadAll); !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	operations, err := h.walletService.GetOperations(c.Request.Context(), walletID, limit, offset)
+	if err != nil {
+		logInternalError(c, "failed to list operations", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"operations": operations})
+}
+
+// BalanceAt - баланс кошелька на момент времени (владелец, wallets:read_all
+// или reports:read). Параметр at - RFC 3339 или дата YYYY-MM-DD (конец дня UTC).
+func (h *WalletHandler) BalanceAt(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok {
+		return
+	}
+	at, ok := pointInTime(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.authorizedWallet(c, walletID, entities.PermWalletsReadAll, entities.PermReportsRead); !ok {
+		return
+	}
+
+	balance, err := h.walletService.BalanceAt(c.Request.Context(), walletID, at)
+	if err != nil {
+		switch err {
+		case services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to compute balance at time", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+// UserBalancesAt - балансы всех кошельков пользователя на момент времени
+// (сам пользователь, wallets:read_all или reports:read). API-ключ видит
+// только кошельки из своей области.
+func (h *WalletHandler) UserBalancesAt(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	withLogFields(c, "target_user_id", userID)
+	at, ok := pointInTime(c)
+	if !ok {
+		return
+	}
 
+	claims, _ := auth.ClaimsFromContext(c.Request.Context())
+	self := !claims.IsAPIKey() && claims.UserID == userID
+	if !self && !claims.HasPermission(string(entities.PermWalletsReadAll)) && !claims.HasPermission(string(entities.PermReportsRead)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrForbidden.Error()})
+		return
+	}
+
+	balances, err := h.walletService.UserBalancesAt(c.Request.Context(), userID, at)
+	if err != nil {
+		logInternalError(c, "failed to compute user balances at time", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
 
+	visible := make([]*entities.WalletBalance, 0, len(balances))
+	var total int64
+	for _, b := range balances {
+		if claims.CoversWallet(b.WalletID, string(entities.PermWalletsReadAll), string(entities.PermReportsRead)) {
+			visible = append(visible, b)
+			total += b.Balance
+		}
+	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userID,
+		"at":      at,
+		"wallets": visible,
+		"total":   total,
+	})
+}
 
+// pointInTime разбирает обязательный параметр at: RFC 3339 или дата
+// YYYY-MM-DD, означающая конец этого дня по UTC. Пишет 400 при ошибке.
+func pointInTime(c *gin.Context) (time.Time, bool) {
+	return dateTimeQuery(c, "at", true)
+}
 
+// dateTimeQuery разбирает обязательный параметр времени: RFC 3339 или дата
+// YYYY-MM-DD - начало дня по UTC либо, при endOfDay, его конец
+func dateTimeQuery(c *gin.Context, name string, endOfDay bool) (time.Time, bool) {
+	raw := c.Query(name)
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true
+	}
+	if d, err := time.Parse(time.DateOnly, raw); err == nil {
+		if endOfDay {
+			d = d.Add(24*time.Hour - time.Microsecond)
+		}
+		return d, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp or a YYYY-MM-DD date"})
+	return time.Time{}, false
+}
+
+// Statement отдает выписку по кошельку за период from..to (владелец,
+// history:read_all или reports:read). Даты без времени включают день целиком.
+// Выписка пишется в ответ по мере чтения операций: ошибка посреди вывода
+// уже не меняет статус, и ответ обрывается без итоговой строки.
+func (h *WalletHandler) Statement(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok {
+		return
+	}
+	from, ok := dateTimeQuery(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := dateTimeQuery(c, "to", true)
+	if !ok {
+		return
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidPeriod.Error()})
+		return
+	}
+
+	format := statement.Format(c.DefaultQuery("format", string(statement.FormatCSV)))
+	writer, err := statement.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := h.authorizedWallet(c, walletID, entities.PermHistoryReadAll, entities.PermReportsRead); !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s-%s.%s", walletID, from.UTC().Format("20060102"), to.UTC().Format("20060102"), format.Extension())
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	err = h.walletService.WriteStatement(c.Request.Context(), walletID, from, to, writer)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		logInternalError(c, "statement interrupted", err)
+		return
+	}
+
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	switch err {
+	case services.ErrWalletNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logInternalError(c, "failed to write statement", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// VerifyChain проверяет хеш-цепочку операций кошелька
+func (h *WalletHandler) VerifyChain(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok || !apiKeyScopeAllows(c, walletID, entities.PermHistoryReadAll) {
+		return
+	}
+
+	result, err := h.walletService.VerifyOperationsChain(c.Request.Context(), walletID)
+	if err != nil {
+		switch err {
+		case services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to verify operations chain", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Freeze замораживает кошелек: операции по нему отклоняются
+func (h *WalletHandler) Freeze(c *gin.Context) {
+	h.setStatus(c, entities.WalletStatusFrozen)
+}
+
+// Unfreeze возвращает кошелек в работу
+func (h *WalletHandler) Unfreeze(c *gin.Context) {
+	h.setStatus(c, entities.WalletStatusActive)
+}
+
+func (h *WalletHandler) setStatus(c *gin.Context, status entities.WalletStatus) {
+	walletID, ok := walletIDParam(c)
+	if !ok || !apiKeyScopeAllows(c, walletID, entities.PermWalletsFreeze) {
+		return
+	}
+
+	if err := h.walletService.SetStatus(c.Request.Context(), walletID, status); err != nil {
+		switch err {
+		case services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to change wallet status", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"walletId": walletID, "status": status})
+}
+
+// Adjust - ручная корректировка баланса с указанием причины
+func (h *WalletHandler) Adjust(c *gin.Context) {
+	walletID, ok := walletIDParam(c)
+	if !ok || !apiKeyScopeAllows(c, walletID, entities.PermWalletsAdjust) {
+		return
+	}
+
+	var req AdjustWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	operation, err := h.walletService.Adjust(c.Request.Context(), walletID, req.Amount, req.Reason)
+	if err != nil {
+		switch err {
+		case services.ErrWalletNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrInsufficientFunds, services.ErrInvalidAmount, services.ErrReasonRequired:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logInternalError(c, "failed to adjust wallet balance", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, operation)
+}
+
+// walletIDParam разбирает :walletId из пути и пишет 400 при ошибке
+func walletIDParam(c *gin.Context) (uuid.UUID, bool) {
+	walletID, err := uuid.Parse(c.Param("walletId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wallet id"})
+		return uuid.Nil, false
+	}
+	withLogFields(c, "wallet_id", walletID)
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("wallet.id", walletID.String()))
+	return walletID, true
+}
+
+// authorizedWallet загружает кошелек и проверяет доступ к нему так же, как
+// gRPC (services.AuthorizeWallet); пишет ответ при отказе
+func (h *WalletHandler) authorizedWallet(c *gin.Context, walletID uuid.UUID, perms ...entities.Permission) (*entities.Wallet, bool) {
+	wallet, err := h.access.Wallet(c.Request.Context(), walletID, perms...)
+	if err != nil {
+		writeAccessError(c, err)
+		return nil, false
+	}
+	withLogFields(c, "user_id", wallet.UserID)
+	return wallet, true
+}
+
+// apiKeyScopeAllows проверяет, что кошелек входит в область API-ключа, а
+// ключ без области имеет одно из разрешений all; пишет 403 при отказе. Для
+// пользовательских токенов всегда true.
+func apiKeyScopeAllows(c *gin.Context, walletID uuid.UUID, all ...entities.Permission) bool {
+	if err := services.AuthorizeAPIKeyScope(c.Request.Context(), walletID, all...); err != nil {
+		writeAccessError(c, err)
+		return false
+	}
+	return true
+}
+
+// writeAccessError отвечает на отказ AccessService: кошелька нет, нет
+// доступа, не подтвержден email владельца, нужен второй фактор или
+// блокировка за перебор кодов
+func writeAccessError(c *gin.Context, err error) {
+	var stepUp *services.StepUpError
+	var throttle *services.ThrottleError
+	switch {
+	case errors.Is(err, services.ErrWalletNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrForbidden), errors.Is(err, services.ErrAPIKeyScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                       err.Error(),
+			"email_verification_required": true,
+		})
+	case errors.As(err, &stepUp):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            stepUp.Err.Error(),
+			"step_up_required": true,
+			"threshold":        stepUp.Threshold,
+		})
+	case errors.As(err, &throttle):
+		writeThrottled(c, throttle)
+	default:
+		logInternalError(c, "failed to check wallet access", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
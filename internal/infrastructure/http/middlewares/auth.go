@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/auth"
+	"walletapitest/internal/pkg/logger"
+)
+
+// AuthMiddleware требует заголовок Authorization: Bearer <token>, проверяет,
+// что сессия токена не отозвана, и кладет claims в контекст запроса.
+// Вместо JWT можно передать API-ключ (wk_...) в Authorization или X-API-Key.
+func AuthMiddleware(tokens *auth.TokenManager, denylist auth.Denylist, apiKeys auth.APIKeyVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			token = c.GetHeader("X-API-Key")
+		}
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+
+		if auth.IsAPIKey(token) {
+			authenticateAPIKey(c, apiKeys, token)
+			return
+		}
+
+		claims, err := tokens.Parse(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		revoked, err := denylist.Contains(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Error("failed to check session denylist", "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify session"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+			return
+		}
+
+		ctx := auth.WithClaims(c.Request.Context(), claims)
+		ctx = logger.WithFields(ctx, "user_id", claims.UserID, "session_id", claims.SessionID, "role", claims.Role)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, apiKeys auth.APIKeyVerifier, token string) {
+	claims, err := apiKeys.VerifyAPIKey(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		logger.FromContext(c.Request.Context()).Error("failed to verify api key", "error", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "unable to verify api key"})
+		return
+	}
+
+	ctx := auth.WithClaims(c.Request.Context(), claims)
+	ctx = logger.WithFields(ctx, "api_key_id", claims.APIKeyID)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// RequirePermission пропускает вызывающего, у которого есть хотя бы одно из
+// разрешений; ставится после AuthMiddleware. Проверка владения ресурсом
+// остается за хендлером.
+func RequirePermission(perms ...entities.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := auth.ClaimsFromContext(c.Request.Context())
+		if ok {
+			for _, perm := range perms {
+				if claims.HasPermission(string(perm)) {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+	}
+}
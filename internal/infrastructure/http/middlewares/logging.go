@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"walletapitest/internal/pkg/logger"
+)
+
+// Logging кладет в контекст запроса логгер с request_id (и trace_id, если
+// запрос трассируется) и пишет строку access-лога по завершении запроса.
+// Должен стоять после RequestID и Tracing.
+func Logging(base logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		fields := []interface{}{"request_id", GetRequestID(c)}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			fields = append(fields, "trace_id", sc.TraceID().String())
+		}
+		ctx := logger.WithContext(c.Request.Context(), base.With(fields...))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// Хендлеры могли дополнить логгер полями user_id/wallet_id
+		log := logger.FromContext(c.Request.Context())
+		status := c.Writer.Status()
+		accessFields := []interface{}{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency", time.Since(start),
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		}
+		if len(c.Errors) > 0 {
+			accessFields = append(accessFields, "errors", c.Errors.String())
+		}
+
+		switch {
+		case status >= 500:
+			log.Error("request completed", accessFields...)
+		case status >= 400:
+			log.Warn("request completed", accessFields...)
+		default:
+			log.Info("request completed", accessFields...)
+		}
+	}
+}
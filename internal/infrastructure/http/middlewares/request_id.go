@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"walletapitest/internal/pkg/requestid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	RequestIDKey    = "request_id"
+)
+
+// RequestID принимает X-Request-ID от клиента или генерирует новый
+// и возвращает его в ответе
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestid.Valid(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(requestid.WithContext(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID возвращает идентификатор текущего запроса
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
@@ -0,0 +1,124 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/pkg/health"
+)
+
+const apiDescription = `REST API of the wallet service.
+
+Amounts are integers in minor currency units. Every response carries an
+X-Request-ID header; a valid X-Request-ID sent by the client is reused.
+Authenticated routes accept a JWT access token or an API key (wk_...) in
+Authorization: Bearer, or an API key in X-API-Key. x-permissions lists the
+permissions of which the caller needs at least one; ownership of a wallet
+or user is checked by the handler on top of that.`
+
+var tags = []Tag{
+	{Name: "auth", Description: "Sign-in, sessions and tokens"},
+	{Name: "account", Description: "Email verification and password reset"},
+	{Name: "mfa", Description: "TOTP second factor"},
+	{Name: "users", Description: "User accounts"},
+	{Name: "wallets", Description: "Wallets, operations and balances"},
+	{Name: "batches", Description: "Bulk wallet operations"},
+	{Name: "schedules", Description: "Scheduled and recurring transfers"},
+	{Name: "admin", Description: "Administrative wallet actions and reconciliation"},
+	{Name: "api-keys", Description: "API keys for service integrations"},
+	{Name: "audit", Description: "Tamper-evident audit log"},
+	{Name: "reports", Description: "Reconciliation reports"},
+	{Name: "health", Description: "Liveness, readiness and dependency health"},
+	{Name: "docs", Description: "This specification and its UI"},
+}
+
+var securitySchemes = map[string]*SecurityScheme{
+	"bearerAuth": {
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Access token from /api/v1/login, or an API key (wk_...)",
+	},
+	"apiKeyAuth": {
+		Type: "apiKey",
+		Name: "X-API-Key",
+		In:   "header",
+	},
+}
+
+// enums - допустимые значения строковых типов сущностей
+func enums() map[reflect.Type][]string {
+	out := make(map[reflect.Type][]string)
+	add := func(t reflect.Type, values []string) {
+		out[t] = values
+	}
+	add(enumOf(entities.OperationTypeDeposit, entities.OperationTypeWithdraw))
+	add(enumOf(entities.WalletStatusActive, entities.WalletStatusFrozen))
+	add(enumOf(entities.OperationRequestPending, entities.OperationRequestProcessing,
+		entities.OperationRequestCompleted, entities.OperationRequestRejected))
+	add(enumOf(entities.BatchStatusQueued, entities.BatchStatusRunning,
+		entities.BatchStatusCompleted, entities.BatchStatusFailed))
+	add(enumOf(entities.BatchItemPending, entities.BatchItemSucceeded,
+		entities.BatchItemFailed, entities.BatchItemSkipped))
+	add(enumOf(entities.ScheduleStatusActive, entities.ScheduleStatusPaused, entities.ScheduleStatusCancelled))
+	add(enumOf(entities.ScheduleRunSucceeded, entities.ScheduleRunFailed, entities.ScheduleRunRetrying))
+	add(enumOf(entities.AuditActorUser, entities.AuditActorAPIKey, entities.AuditActorSystem))
+	add(enumOf(entities.RoleUser, entities.RoleSupport, entities.RoleAdmin, entities.RoleAuditor))
+	add(enumOf(health.StatusUp, health.StatusDegraded, health.StatusDown))
+	return out
+}
+
+// errorSchema - тело ошибки; флаги подсказывают клиенту, что делать дальше
+var errorSchema = &Schema{
+	Type: "object",
+	Properties: map[string]*Schema{
+		"error":                       {Type: "string"},
+		"details":                     {Type: "string"},
+		"mfa_required":                {Type: "boolean", Description: "Repeat the login with otp"},
+		"step_up_required":            {Type: "boolean", Description: "Repeat the request with otp"},
+		"threshold":                   {Type: "integer", Format: "int64", Description: "Step-up threshold in minor units"},
+		"email_verification_required": {Type: "boolean", Description: "The wallet owner must verify their email first"},
+		"index":                       {Type: "integer", Description: "Index of the rejected batch item"},
+		"max_items":                   {Type: "integer", Description: "Maximum number of items in a batch"},
+	},
+	Required: []string{"error"},
+}
+
+// errorNames - общие ответы с ошибкой в components/responses
+var errorNames = map[int]string{
+	http.StatusBadRequest:          "BadRequest",
+	http.StatusUnauthorized:        "Unauthorized",
+	http.StatusForbidden:           "Forbidden",
+	http.StatusNotFound:            "NotFound",
+	http.StatusConflict:            "Conflict",
+	http.StatusTooManyRequests:     "TooManyRequests",
+	http.StatusInternalServerError: "InternalError",
+	http.StatusServiceUnavailable:  "ServiceUnavailable",
+}
+
+func errorResponses() map[string]*Response {
+	descriptions := map[int]string{
+		http.StatusBadRequest:          "Invalid request",
+		http.StatusUnauthorized:        "Missing, invalid or revoked credentials",
+		http.StatusForbidden:           "The caller is not allowed to perform this action",
+		http.StatusNotFound:            "Resource not found",
+		http.StatusConflict:            "The resource is in a conflicting state",
+		http.StatusTooManyRequests:     "Too many attempts",
+		http.StatusInternalServerError: "Internal server error",
+		http.StatusServiceUnavailable:  "Credentials could not be verified right now",
+	}
+	out := make(map[string]*Response, len(errorNames))
+	for code, name := range errorNames {
+		out[name] = &Response{
+			Description: descriptions[code],
+			Content: map[string]*MediaType{
+				"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+			},
+		}
+	}
+	out[errorNames[http.StatusTooManyRequests]].Headers = map[string]*Header{
+		"Retry-After": {Description: "Seconds until the next attempt is allowed", Schema: &Schema{Type: "integer"}},
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+//go:generate go run ./gen -o openapi.json
+
+//go:embed openapi.json
+var spec []byte
+
+// JSON - документ из openapi.json, который отдает /openapi.json
+func JSON() []byte {
+	return spec
+}
+
+// Render - документ Build в том виде, в каком он лежит в openapi.json
+func Render() ([]byte, error) {
+	out, err := json.MarshalIndent(Build(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,23 @@
+// Команда gen пишет спецификацию OpenAPI в файл (go generate в пакете openapi)
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"walletapitest/internal/infrastructure/http/openapi"
+)
+
+func main() {
+	out := flag.String("o", "openapi.json", "output file")
+	flag.Parse()
+
+	data, err := openapi.Render()
+	if err != nil {
+		log.Fatalf("Failed to render OpenAPI document: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+}
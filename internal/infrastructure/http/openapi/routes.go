@@ -0,0 +1,614 @@
+package openapi
+
+import (
+	"net/http"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/http/handlers"
+	"walletapitest/internal/pkg/health"
+)
+
+// catalog - все маршруты App.initRouter. Новый маршрут без записи здесь
+// (или запись без маршрута) ломает тест спецификации.
+func catalog() []route {
+	pointInTime := requiredQuery("at", "RFC 3339 timestamp, or a YYYY-MM-DD date meaning the end of that day (UTC)",
+		&Schema{Type: "string"})
+	walletOperation := object{props: map[string]interface{}{
+		"message":       "",
+		"walletId":      uuidSchema(),
+		"operationType": entities.OperationType(""),
+		"amount":        int64(0),
+	}}
+
+	return []route{
+		// Public routes
+		{
+			method: http.MethodPost, path: "/api/v1/users", handler: (*handlers.UserHandler).CreateUser,
+			id: "createUser", tag: "users", public: true,
+			summary:     "Register a user",
+			description: "Sends an email verification link; withdrawals stay blocked until the address is verified.",
+			body:        handlers.CreateUserRequest{},
+			responses:   []response{created(handlers.UserResponse{})},
+			errors:      []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/login", handler: (*handlers.UserHandler).Login,
+			id: "login", tag: "auth", public: true,
+			summary:     "Sign in",
+			description: "Returns 401 with mfa_required when the user has TOTP enabled and otp is missing or wrong. Repeated failures lock the account or IP for a while (429).",
+			body:        handlers.LoginRequest{},
+			responses: []response{ok(object{props: map[string]interface{}{
+				"token":              "",
+				"expires_at":         dateTime(),
+				"refresh_token":      "",
+				"refresh_expires_at": dateTime(),
+				"user":               handlers.UserResponse{},
+			}})},
+			errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/token/refresh", handler: (*handlers.SessionHandler).Refresh,
+			id: "refreshToken", tag: "auth", public: true,
+			summary:     "Exchange a refresh token for a new token pair",
+			description: "Refresh tokens are single-use; reusing one revokes the whole session.",
+			body:        handlers.RefreshTokenRequest{},
+			responses:   []response{ok(handlers.TokenResponse{})},
+			errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/email/verify", handler: (*handlers.AccountHandler).VerifyEmail,
+			id: "verifyEmail", tag: "account", public: true,
+			summary:   "Verify an email address with the token from the email",
+			body:      handlers.TokenRequest{},
+			responses: []response{ok(handlers.UserResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/password/forgot", handler: (*handlers.AccountHandler).ForgotPassword,
+			id: "forgotPassword", tag: "account", public: true,
+			summary:     "Request a password reset link",
+			description: "Always answers 202 so that registered addresses cannot be probed.",
+			body:        handlers.ForgotPasswordRequest{},
+			responses:   []response{{status: http.StatusAccepted, description: "Accepted", content: jsonBody(message)}},
+			errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/password/reset", handler: (*handlers.AccountHandler).ResetPassword,
+			id: "resetPassword", tag: "account", public: true,
+			summary:     "Set a new password with the token from the email",
+			description: "Revokes all sessions of the user.",
+			body:        handlers.ResetPasswordRequest{},
+			responses:   []response{ok(message)},
+			errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+
+		// Authenticated routes
+		{
+			method: http.MethodPost, path: "/api/v1/logout", handler: (*handlers.SessionHandler).Logout,
+			id: "logout", tag: "auth",
+			summary:   "Revoke the current session",
+			responses: []response{noContent()},
+			errors:    []int{http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/logout/all", handler: (*handlers.SessionHandler).LogoutAll,
+			id: "logoutAll", tag: "auth",
+			summary:   "Revoke all sessions of the current user",
+			responses: []response{ok(object{props: map[string]interface{}{"revoked_sessions": 0}})},
+			errors:    []int{http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/users/:id", handler: (*handlers.UserHandler).GetUser,
+			id: "getUser", tag: "users",
+			summary:     "Get a user",
+			description: "Allowed for the user themselves or a caller with users:read_all.",
+			responses:   []response{ok(handlers.UserResponse{})},
+			errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPatch, path: "/api/v1/users/:id", handler: (*handlers.UserHandler).UpdateUser,
+			id: "updateUser", tag: "users",
+			summary: "Update email or username",
+			description: "Allowed for the user themselves or a caller with users:manage. " +
+				"Changing the email requires the caller's current_password or otp; failures count toward the sign-in lockout. " +
+				"The new email has to be verified again, and pending verification and password reset links stop working.",
+			body:      handlers.UpdateUserRequest{},
+			responses: []response{ok(handlers.UserResponse{})},
+			errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+				http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodDelete, path: "/api/v1/users/:id", handler: (*handlers.UserHandler).DeleteUser,
+			id: "deleteUser", tag: "users",
+			summary:     "Delete a user",
+			description: "Allowed for the user themselves or a caller with users:manage. Fails with 409 while any wallet holds funds.",
+			responses:   []response{noContent()},
+			errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+				http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/mfa/totp/enroll", handler: (*handlers.MFAHandler).Enroll,
+			id: "enrollTOTP", tag: "mfa",
+			summary: "Start TOTP enrollment",
+			responses: []response{ok(object{props: map[string]interface{}{
+				"secret":      "",
+				"otpauth_uri": "",
+			}})},
+			errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/mfa/totp/confirm", handler: (*handlers.MFAHandler).Confirm,
+			id: "confirmTOTP", tag: "mfa",
+			summary:   "Confirm enrollment with a code and receive recovery codes",
+			body:      handlers.OTPRequest{},
+			responses: []response{ok(object{props: map[string]interface{}{"recovery_codes": []string{}}})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodDelete, path: "/api/v1/mfa/totp", handler: (*handlers.MFAHandler).Disable,
+			id: "disableTOTP", tag: "mfa",
+			summary:   "Disable the second factor",
+			body:      handlers.OTPRequest{},
+			responses: []response{noContent()},
+			errors:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/me/sign-ins", handler: (*handlers.UserHandler).RecentSignIns,
+			id: "listSignIns", tag: "auth",
+			summary: "Recent sign-in attempts of the current user",
+			query: []*Parameter{queryParam("limit", "Number of events", &Schema{
+				Type: "integer", Default: services.DefaultSignInsPageSize, Maximum: int64Ptr(services.MaxSignInsPageSize),
+			})},
+			responses: []response{ok(object{props: map[string]interface{}{"sign_ins": []*entities.LoginEvent{}}})},
+			errors:    []int{http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/email/verify/resend", handler: (*handlers.AccountHandler).ResendVerification,
+			id: "resendVerification", tag: "account",
+			summary:   "Send the verification email again",
+			responses: []response{{status: http.StatusAccepted, description: "Accepted", content: jsonBody(message)}},
+			errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests,
+				http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/users", handler: (*handlers.UserHandler).ListUsers,
+			id: "listUsers", tag: "users", perms: []entities.Permission{entities.PermUsersReadAll},
+			summary: "List users",
+			query: append(pageParams(services.DefaultUsersPageSize, services.MaxUsersPageSize),
+				queryParam("sort", "Sort field", &Schema{Type: "string", Enum: []string{"created_at", "email", "username"}, Default: "created_at"}),
+				queryParam("order", "Sort order", &Schema{Type: "string", Enum: []string{"asc", "desc"}, Default: "desc"}),
+				queryParam("email", "Email prefix", stringSchema()),
+				queryParam("username", "Username prefix", stringSchema()),
+			),
+			responses: []response{ok(handlers.ListUsersResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPut, path: "/api/v1/users/:id/role", handler: (*handlers.UserHandler).SetRole,
+			id: "setUserRole", tag: "users", perms: []entities.Permission{entities.PermUsersManage},
+			summary:     "Change a user's role",
+			description: "Revokes the user's sessions so that the new permissions apply at once.",
+			body:        handlers.SetRoleRequest{},
+			responses:   []response{ok(handlers.UserResponse{})},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPut, path: "/api/v1/users/:id/tier", handler: (*handlers.UserHandler).SetTier,
+			id: "setUserTier", tag: "users", perms: []entities.Permission{entities.PermUsersManage},
+			summary:     "Change a user's fee tier",
+			description: "Revokes the user's sessions, as a role change does.",
+			body:        handlers.SetTierRequest{},
+			responses:   []response{ok(handlers.UserResponse{})},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+
+		// Wallet routes
+		{
+			method: http.MethodPost, path: "/api/v1/wallet", handler: (*handlers.WalletHandler).ProcessOperation,
+			id: "processOperation", tag: "wallets", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary: "Deposit to or withdraw from a wallet",
+			description: "Only the wallet owner (or an API key scoped to the wallet) may operate on it. " +
+				"Withdrawals require a verified owner email, and withdrawals above the step-up threshold require otp. " +
+				"With async the operation is queued and answered with 202; poll the Location URL. " +
+				"A repeated request with the same Idempotency-Key returns the original operation " +
+				"(with async, the originally queued request) with replayed=true; " +
+				"reusing the key for a different operation is answered with 409.",
+			headers: []*Parameter{{
+				Name: handlers.IdempotencyKeyHeader, In: "header",
+				Description: "Idempotency key of the operation; with async it becomes the ID of the queued request",
+				Schema:      uuidSchema(),
+			}},
+			body: handlers.WalletOperationRequest{},
+			responses: []response{
+				ok(merge(walletOperation, map[string]interface{}{
+					"operationId": uuidSchema(),
+					"balance":     int64(0),
+					"fee":         nullable{entities.Fee{}},
+					"replayed":    false,
+				})),
+				accepted(merge(walletOperation, map[string]interface{}{
+					"operationId": uuidSchema(),
+					"status":      entities.OperationRequestStatus(""),
+					"replayed":    false,
+				})),
+			},
+			errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+				http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/wallet/create", handler: (*handlers.WalletHandler).CreateWallet,
+			id: "createWallet", tag: "wallets", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary:     "Create a wallet",
+			description: "Allowed for the user themselves or a caller with users:manage.",
+			body:        handlers.CreateWalletRequest{},
+			responses:   []response{ok(entities.Wallet{})},
+			errors:      []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/wallet/batch", handler: (*handlers.BatchHandler).Submit,
+			id: "submitBatch", tag: "batches", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary: "Submit a batch of deposits and withdrawals",
+			description: "Items come as JSON, as a CSV body (header wallet_id,operation_type,amount,reference) " +
+				"or as a CSV file in the multipart field file. For CSV the flags are taken from the query " +
+				"or the multipart form. Small synchronous batches answer 200 with item results; " +
+				"queued batches answer 202.",
+			content: map[string]interface{}{
+				"application/json": handlers.BatchRequest{},
+				"text/csv":         stringSchema(),
+				"multipart/form-data": object{
+					props: map[string]interface{}{
+						"file":   &Schema{Type: "string", ContentMediaType: "text/csv"},
+						"atomic": false,
+						"async":  false,
+						"otp":    "",
+					},
+					optional: []string{"atomic", "async", "otp"},
+				},
+			},
+			query: []*Parameter{
+				queryParam("atomic", "CSV only: run all items in one transaction", &Schema{Type: "boolean"}),
+				queryParam("async", "CSV only: queue the batch regardless of its size", &Schema{Type: "boolean"}),
+				queryParam("otp", "CSV only: second factor for withdrawals above the step-up threshold", stringSchema()),
+			},
+			responses: []response{
+				ok(handlers.BatchJobResponse{}),
+				accepted(object{props: map[string]interface{}{"job": entities.BatchJob{}}}),
+			},
+			errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/batch/:jobId", handler: (*handlers.BatchHandler).Get,
+			id: "getBatch", tag: "batches",
+			summary:     "Batch job status and item results",
+			description: "Visible to the author of the job or a caller with history:read_all.",
+			query: append(pageParams(services.DefaultBatchItemsPageSize, services.MaxBatchItemsPageSize),
+				queryParam("status", "Item status filter", enumSchema(string(entities.BatchItemPending),
+					string(entities.BatchItemSucceeded), string(entities.BatchItemFailed), string(entities.BatchItemSkipped))),
+			),
+			responses: []response{ok(handlers.BatchJobResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/operations/:id", handler: (*handlers.WalletHandler).GetOperation,
+			id: "getQueuedOperation", tag: "wallets",
+			perms:     []entities.Permission{entities.PermWalletsRead, entities.PermHistoryReadAll},
+			summary:   "Status of an asynchronous operation",
+			responses: []response{ok(entities.OperationRequest{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/:walletId", handler: (*handlers.WalletHandler).GetWallet,
+			id: "getWallet", tag: "wallets",
+			perms:     []entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll},
+			summary:   "Get a wallet",
+			responses: []response{ok(handlers.WalletResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/:walletId/operations", handler: (*handlers.WalletHandler).ListOperations,
+			id: "listOperations", tag: "wallets",
+			perms:   []entities.Permission{entities.PermWalletsRead, entities.PermHistoryReadAll},
+			summary: "Operation history of a wallet",
+			query: []*Parameter{
+				queryParam("limit", "Page size; out-of-range values are clamped", &Schema{
+					Type: "integer", Default: services.DefaultOperationsPageSize, Maximum: int64Ptr(services.MaxOperationsPageSize),
+				}),
+				queryParam("offset", "Number of operations to skip", &Schema{Type: "integer", Default: 0}),
+			},
+			responses: []response{ok(object{props: map[string]interface{}{"operations": []*entities.Operation{}}})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/:walletId/balance", handler: (*handlers.WalletHandler).BalanceAt,
+			id: "getBalanceAt", tag: "wallets",
+			perms:     []entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll, entities.PermReportsRead},
+			summary:   "Wallet balance at a point in time",
+			query:     []*Parameter{pointInTime},
+			responses: []response{ok(entities.WalletBalance{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/users/:id/balances", handler: (*handlers.WalletHandler).UserBalancesAt,
+			id: "getUserBalancesAt", tag: "wallets",
+			perms:       []entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll, entities.PermReportsRead},
+			summary:     "Balances of all wallets of a user at a point in time",
+			description: "An API key only sees the wallets in its scope.",
+			query:       []*Parameter{pointInTime},
+			responses: []response{ok(object{props: map[string]interface{}{
+				"user_id": uuidSchema(),
+				"at":      dateTime(),
+				"wallets": []*entities.WalletBalance{},
+				"total":   int64(0),
+			}})},
+			errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/:walletId/statement", handler: (*handlers.WalletHandler).Statement,
+			id: "getStatement", tag: "wallets",
+			perms:   []entities.Permission{entities.PermWalletsRead, entities.PermHistoryReadAll, entities.PermReportsRead},
+			summary: "Account statement for a period",
+			description: "Streamed as it is read; an error in the middle of the output truncates the file " +
+				"without the closing totals.",
+			query: []*Parameter{
+				requiredQuery("from", "RFC 3339 timestamp or a YYYY-MM-DD date (start of day, UTC)", stringSchema()),
+				requiredQuery("to", "RFC 3339 timestamp or a YYYY-MM-DD date (end of day, UTC)", stringSchema()),
+				queryParam("format", "Output format", &Schema{Type: "string", Enum: []string{"csv", "ndjson", "pdf"}, Default: "csv"}),
+			},
+			responses: []response{{
+				status:      http.StatusOK,
+				description: "Statement file",
+				headers: map[string]*Header{
+					"Content-Disposition": {Description: "attachment with the statement file name", Schema: stringSchema()},
+				},
+				content: map[string]interface{}{
+					"text/csv":             stringSchema(),
+					"application/x-ndjson": stringSchema(),
+					"application/pdf":      &Schema{Type: "string", ContentMediaType: "application/pdf"},
+				},
+			}},
+			errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/:walletId/operations/verify", handler: (*handlers.WalletHandler).VerifyChain,
+			id: "verifyOperationsChain", tag: "wallets", perms: []entities.Permission{entities.PermHistoryReadAll},
+			summary:   "Verify the hash chain of a wallet's operations",
+			responses: []response{ok(entities.ChainVerification{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+
+		// Scheduled transfers
+		{
+			method: http.MethodPost, path: "/api/v1/wallet/:walletId/schedules", handler: (*handlers.ScheduleHandler).Create,
+			id: "createSchedule", tag: "schedules", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary:     "Schedule a recurring transfer from the wallet",
+			description: "Exactly one of cron (UTC) and interval_seconds is required.",
+			body:        handlers.CreateScheduleRequest{},
+			responses:   []response{created(entities.Schedule{})},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/wallet/:walletId/schedules", handler: (*handlers.ScheduleHandler).List,
+			id: "listSchedules", tag: "schedules",
+			perms:     []entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll},
+			summary:   "Schedules of a wallet, except cancelled ones",
+			responses: []response{ok(object{props: map[string]interface{}{"schedules": []*entities.Schedule{}}})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/schedules/:id", handler: (*handlers.ScheduleHandler).Get,
+			id: "getSchedule", tag: "schedules",
+			perms:     []entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll},
+			summary:   "Get a schedule",
+			responses: []response{ok(entities.Schedule{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/schedules/:id/runs", handler: (*handlers.ScheduleHandler).Runs,
+			id: "listScheduleRuns", tag: "schedules",
+			perms:     []entities.Permission{entities.PermWalletsRead, entities.PermWalletsReadAll, entities.PermHistoryReadAll},
+			summary:   "Execution history of a schedule",
+			query:     pageParams(services.DefaultScheduleRunsPageSize, services.MaxScheduleRunsPageSize),
+			responses: []response{ok(handlers.ListScheduleRunsResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/schedules/:id/pause", handler: (*handlers.ScheduleHandler).Pause,
+			id: "pauseSchedule", tag: "schedules", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary:   "Pause a schedule",
+			responses: []response{ok(entities.Schedule{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/schedules/:id/resume", handler: (*handlers.ScheduleHandler).Resume,
+			id: "resumeSchedule", tag: "schedules", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary:   "Resume a schedule from its next due time",
+			responses: []response{ok(entities.Schedule{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodDelete, path: "/api/v1/schedules/:id", handler: (*handlers.ScheduleHandler).Cancel,
+			id: "cancelSchedule", tag: "schedules", perms: []entities.Permission{entities.PermWalletsOperate},
+			summary:   "Cancel a schedule",
+			responses: []response{ok(entities.Schedule{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+
+		// Admin routes
+		{
+			method: http.MethodPost, path: "/api/v1/admin/wallets/:walletId/freeze", handler: (*handlers.WalletHandler).Freeze,
+			id: "freezeWallet", tag: "admin", perms: []entities.Permission{entities.PermWalletsFreeze},
+			summary:   "Freeze a wallet; operations on it are rejected",
+			responses: []response{ok(walletStatus)},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/admin/wallets/:walletId/unfreeze", handler: (*handlers.WalletHandler).Unfreeze,
+			id: "unfreezeWallet", tag: "admin", perms: []entities.Permission{entities.PermWalletsFreeze},
+			summary:   "Return a frozen wallet to service",
+			responses: []response{ok(walletStatus)},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/admin/wallets/:walletId/adjust", handler: (*handlers.WalletHandler).Adjust,
+			id: "adjustWallet", tag: "admin", perms: []entities.Permission{entities.PermWalletsAdjust},
+			summary:   "Manual balance adjustment with a reason",
+			body:      handlers.AdjustWalletRequest{},
+			responses: []response{ok(entities.Operation{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/admin/reconciliation/run", handler: (*handlers.ReconciliationHandler).Run,
+			id: "runReconciliation", tag: "admin", perms: []entities.Permission{entities.PermWalletsAdjust},
+			summary:   "Run reconciliation now and return its report",
+			responses: []response{ok(handlers.ReconciliationReportResponse{})},
+			errors:    []int{http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/admin/api-keys", handler: (*handlers.APIKeyHandler).Create,
+			id: "createAPIKey", tag: "api-keys", perms: []entities.Permission{entities.PermAPIKeysManage},
+			summary:     "Issue an API key",
+			description: "The plain key is returned only in this response. wallets:read and wallets:operate require wallet_ids; every listed wallet must be the caller's own, or readable by the caller with wallets:read_all for a key with wallets:read only.",
+			body:        handlers.CreateAPIKeyRequest{},
+			responses:   []response{created(handlers.APIKeyResponse{})},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/admin/api-keys", handler: (*handlers.APIKeyHandler).List,
+			id: "listAPIKeys", tag: "api-keys", perms: []entities.Permission{entities.PermAPIKeysManage},
+			summary:   "List API keys",
+			responses: []response{ok(object{props: map[string]interface{}{"api_keys": []*entities.APIKey{}}})},
+			errors:    []int{http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/admin/api-keys/:id/rotate", handler: (*handlers.APIKeyHandler).Rotate,
+			id: "rotateAPIKey", tag: "api-keys", perms: []entities.Permission{entities.PermAPIKeysManage},
+			summary:      "Issue a replacement key; the old one keeps working for the grace period",
+			body:         handlers.RotateAPIKeyRequest{},
+			optionalBody: true,
+			responses:    []response{created(handlers.APIKeyResponse{})},
+			errors:       []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodDelete, path: "/api/v1/admin/api-keys/:id", handler: (*handlers.APIKeyHandler).Revoke,
+			id: "revokeAPIKey", tag: "api-keys", perms: []entities.Permission{entities.PermAPIKeysManage},
+			summary:   "Revoke an API key",
+			responses: []response{noContent()},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+
+		// Audit log
+		{
+			method: http.MethodGet, path: "/api/v1/audit", handler: (*handlers.AuditHandler).List,
+			id: "listAuditEvents", tag: "audit", perms: []entities.Permission{entities.PermAuditRead},
+			summary: "Search the audit log",
+			query: append(pageParams(services.DefaultAuditPageSize, services.MaxAuditPageSize),
+				queryParam("action", "Action, e.g. wallet.adjusted", stringSchema()),
+				queryParam("target_type", "Target type", stringSchema()),
+				queryParam("target_id", "Target ID", stringSchema()),
+				queryParam("actor_id", "Actor ID", uuidSchema()),
+				queryParam("from", "Events at or after this RFC 3339 timestamp", dateTime()),
+				queryParam("to", "Events at or before this RFC 3339 timestamp", dateTime()),
+			),
+			responses: []response{ok(handlers.ListAuditResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/audit/verify", handler: (*handlers.AuditHandler).Verify,
+			id: "verifyAuditChain", tag: "audit", perms: []entities.Permission{entities.PermAuditRead},
+			summary:   "Verify the hash chain of the whole audit log",
+			responses: []response{ok(entities.ChainVerification{})},
+			errors:    []int{http.StatusInternalServerError},
+		},
+
+		// Reconciliation reports
+		{
+			method: http.MethodGet, path: "/api/v1/reconciliation/runs", handler: (*handlers.ReconciliationHandler).ListRuns,
+			id: "listReconciliationRuns", tag: "reports", perms: []entities.Permission{entities.PermReportsRead},
+			summary:   "List reconciliation runs",
+			query:     pageParams(services.DefaultReconciliationPageSize, services.MaxReconciliationPageSize),
+			responses: []response{ok(handlers.ListReconciliationRunsResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/reconciliation/runs/:id", handler: (*handlers.ReconciliationHandler).Report,
+			id: "getReconciliationReport", tag: "reports", perms: []entities.Permission{entities.PermReportsRead},
+			summary: "Reconciliation report with discrepancies",
+			pathParams: map[string]*Schema{
+				"id": {Type: "string", Description: "Run ID, or latest for the most recent run"},
+			},
+			responses: []response{ok(handlers.ReconciliationReportResponse{})},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		},
+
+		// Health checks
+		{
+			method: http.MethodGet, path: "/livez", handler: (*handlers.HealthHandler).Livez,
+			id: "livez", tag: "health", public: true,
+			summary:   "Liveness: the process serves requests",
+			responses: []response{ok(object{props: map[string]interface{}{"status": health.Status("")}})},
+		},
+		{
+			method: http.MethodGet, path: "/readyz", handler: (*handlers.HealthHandler).Readyz,
+			id: "readyz", tag: "health", public: true,
+			summary: "Readiness: critical dependencies are up and the instance is not shutting down",
+			responses: []response{
+				ok(health.Report{}),
+				{status: http.StatusServiceUnavailable, description: "Not ready", content: jsonBody(health.Report{})},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/health", handler: (*handlers.HealthHandler).Health,
+			id: "health", tag: "health", public: true,
+			summary: "Detailed report on dependencies and background workers",
+			responses: []response{
+				ok(health.Report{}),
+				{status: http.StatusServiceUnavailable, description: "A critical dependency is down", content: jsonBody(health.Report{})},
+			},
+		},
+
+		// Documentation
+		{
+			method: http.MethodGet, path: "/openapi.json", handler: (*handlers.DocsHandler).Spec,
+			id: "getOpenAPISpec", tag: "docs", public: true,
+			summary:   "This OpenAPI document",
+			responses: []response{ok(&Schema{Type: "object"})},
+		},
+		{
+			method: http.MethodGet, path: "/docs/*filepath", handler: (*handlers.DocsHandler).UI,
+			id: "getDocsUI", tag: "docs", public: true,
+			summary:    "Swagger UI for this document; open /docs/",
+			pathParams: map[string]*Schema{"filepath": stringSchema()},
+			responses: []response{
+				{status: http.StatusOK, description: "UI page or asset", content: map[string]interface{}{"text/html": stringSchema()}},
+				{status: http.StatusNotFound, description: "No such asset"},
+			},
+		},
+	}
+}
+
+// walletStatus - ответ заморозки и разморозки
+var walletStatus = object{props: map[string]interface{}{
+	"walletId": uuidSchema(),
+	"status":   entities.WalletStatus(""),
+}}
+
+// merge - объект с дополнительными полями
+func merge(base object, props map[string]interface{}) object {
+	out := object{props: make(map[string]interface{}, len(base.props)+len(props)), optional: base.optional}
+	for k, v := range base.props {
+		out.props[k] = v
+	}
+	for k, v := range props {
+		out.props[k] = v
+	}
+	return out
+}
+
+func uuidSchema() *Schema {
+	return &Schema{Type: "string", Format: "uuid"}
+}
+
+func dateTime() *Schema {
+	return &Schema{Type: "string", Format: "date-time"}
+}
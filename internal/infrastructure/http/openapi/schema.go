@@ -0,0 +1,289 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// direction - схема описывает тело запроса или ответа. От этого зависит
+// обязательность полей: в запросе ее задает binding:"required", в ответе
+// обязательно все, что сериализуется без omitempty.
+type direction int
+
+const (
+	inbound direction = iota
+	outbound
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	uuidType      = reflect.TypeOf(uuid.UUID{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// object - тело gin.H: значения - Go-значения, *Schema или вложенные object.
+// Все поля обязательны, кроме перечисленных в optional.
+type object struct {
+	props    map[string]interface{}
+	optional []string
+}
+
+// nullable - поле, которое сериализуется как null при отсутствии значения
+type nullable struct {
+	value interface{}
+}
+
+// generator собирает компоненты схем по мере обхода маршрутов
+type generator struct {
+	enums      map[reflect.Type][]string
+	schemas    map[string]*Schema
+	types      map[string]reflect.Type
+	directions map[reflect.Type]direction
+}
+
+func newGenerator(enums map[reflect.Type][]string) *generator {
+	return &generator{
+		enums:      enums,
+		schemas:    make(map[string]*Schema),
+		types:      make(map[string]reflect.Type),
+		directions: make(map[reflect.Type]direction),
+	}
+}
+
+// schema переводит описание тела из каталога маршрутов в схему
+func (g *generator) schema(v interface{}, dir direction) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *Schema:
+		return v
+	case nullable:
+		return orNull(g.schema(v.value, dir))
+	case object:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema, len(v.props))}
+		for name, value := range v.props {
+			s.Properties[name] = g.schema(value, dir)
+		}
+		for name := range v.props {
+			if !contains(v.optional, name) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		sort.Strings(s.Required)
+		return s
+	default:
+		return g.typeSchema(reflect.TypeOf(v), dir)
+	}
+}
+
+func (g *generator) typeSchema(t reflect.Type, dir direction) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	if values, ok := g.enums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+	if t.Implements(marshalerType) {
+		// Сырой JSON (например, снимки в журнале аудита): схема любая
+		return &Schema{Description: "Arbitrary JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.typeSchema(t.Elem(), dir)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem(), dir)}
+	case reflect.Struct:
+		return g.component(t, dir)
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// component регистрирует структуру в components/schemas и возвращает ссылку
+func (g *generator) component(t reflect.Type, dir direction) *Schema {
+	name := componentName(t)
+	if prev, ok := g.types[name]; ok && prev != t {
+		panic(fmt.Sprintf("openapi: schema name %s is used by both %s and %s", name, prev, t))
+	}
+	if prev, ok := g.directions[t]; ok && prev != dir {
+		panic(fmt.Sprintf("openapi: %s is used both as a request and as a response body", t))
+	}
+
+	if _, ok := g.schemas[name]; !ok {
+		// Заглушка до обхода полей защищает от рекурсивных типов
+		g.schemas[name] = &Schema{}
+		g.types[name] = t
+		g.directions[t] = dir
+		*g.schemas[name] = *g.structSchema(t, dir)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *generator) structSchema(t reflect.Type, dir direction) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t, dir)
+	return s
+}
+
+// addFields добавляет поля структуры по правилам encoding/json: встроенные
+// структуры без имени в теге раскрываются в родителя
+func (g *generator) addFields(s *Schema, t reflect.Type, dir direction) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitempty := contains(strings.Split(opts, ","), "omitempty")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(s, embedded, dir)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := g.typeSchema(field.Type, dir)
+		required := false
+		switch dir {
+		case inbound:
+			required = applyBinding(prop, field, t)
+		case outbound:
+			required = !omitempty
+		}
+		if field.Type.Kind() == reflect.Pointer && !omitempty {
+			prop = orNull(prop)
+		}
+
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// applyBinding переносит в схему ограничения из тега binding и сообщает,
+// обязательно ли поле. Незнакомое правило - ошибка каталога: иначе
+// спецификация молча разошлась бы с проверкой gin.
+func applyBinding(s *Schema, field reflect.StructField, owner reflect.Type) (required bool) {
+	kind := field.Type.Kind()
+	if kind == reflect.Pointer {
+		kind = field.Type.Elem().Kind()
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "":
+		case "required":
+			required = true
+		case "omitempty":
+		case "dive":
+			// Правила после dive относятся к элементам, а схема элемента
+			// строится из его собственных тегов
+			return required
+		case "email":
+			s.Format = "email"
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		case "min", "max", "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: %s.%s: invalid binding %q", owner, field.Name, rule))
+			}
+			applyBound(s, kind, key, n)
+		default:
+			panic(fmt.Sprintf("openapi: %s.%s: unsupported binding %q", owner, field.Name, rule))
+		}
+	}
+	return required
+}
+
+// applyBound - min/max у строк и списков ограничивают длину, у чисел - значение
+func applyBound(s *Schema, kind reflect.Kind, key string, n int64) {
+	switch {
+	case kind == reflect.String && key == "min":
+		s.MinLength = &n
+	case kind == reflect.String && key == "max":
+		s.MaxLength = &n
+	case (kind == reflect.Slice || kind == reflect.Array) && key == "min":
+		s.MinItems = &n
+	case (kind == reflect.Slice || kind == reflect.Array) && key == "max":
+		s.MaxItems = &n
+	case key == "min", key == "gte":
+		s.Minimum = &n
+	case key == "max", key == "lte":
+		s.Maximum = &n
+	case key == "gt":
+		s.ExclusiveMinimum = &n
+	case key == "lt":
+		s.ExclusiveMaximum = &n
+	}
+}
+
+// componentName - имя схемы: имя типа, для пакетов вне handlers и entities
+// с префиксом пакета (health.Report - HealthReport)
+func componentName(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if pkg == "handlers" || pkg == "entities" {
+		return t.Name()
+	}
+	runes := []rune(pkg)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes) + t.Name()
+}
+
+// orNull допускает null вместо значения
+func orNull(s *Schema) *Schema {
+	if t, ok := s.Type.(string); ok && s.Ref == "" {
+		out := *s
+		out.Type = []string{t, "null"}
+		return &out
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
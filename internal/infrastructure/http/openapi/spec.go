@@ -0,0 +1,284 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"walletapitest/internal/domain/entities"
+)
+
+// route - маршрут каталога: путь gin, функция хендлера и описание тел
+type route struct {
+	method string
+	// path - путь в синтаксисе gin (:param, *param)
+	path string
+	// handler - выражение метода хендлера, например (*handlers.UserHandler).CreateUser
+	handler interface{}
+	id      string
+	tag     string
+	summary string
+	// description - подробности, которые не видны из схем
+	description string
+	// public - маршрут без AuthMiddleware
+	public bool
+	// perms - разрешения RequirePermission, нужно хотя бы одно
+	perms []entities.Permission
+	// pathParams - схемы параметров пути, если это не UUID
+	pathParams map[string]*Schema
+	query      []*Parameter
+	// headers - параметры запроса в заголовках
+	headers []*Parameter
+	// body - тело запроса application/json; content - если типов несколько
+	body         interface{}
+	content      map[string]interface{}
+	optionalBody bool
+	responses    []response
+	// errors - коды ошибок хендлера; 401, 403 и 503 проверки токена
+	// добавляются сами для закрытых маршрутов
+	errors []int
+}
+
+type response struct {
+	status      int
+	description string
+	headers     map[string]*Header
+	// content - тело по типам содержимого; для JSON - результат jsonBody
+	content map[string]interface{}
+}
+
+// Endpoint - маршрут спецификации: метод, путь gin и имя функции хендлера
+// в том виде, в каком его сообщает gin.RouteInfo (без суффикса -fm)
+type Endpoint struct {
+	Method  string
+	Path    string
+	Handler string
+}
+
+// Endpoints - все маршруты, описанные в спецификации
+func Endpoints() []Endpoint {
+	routes := catalog()
+	endpoints := make([]Endpoint, len(routes))
+	for i, r := range routes {
+		endpoints[i] = Endpoint{Method: r.method, Path: r.path, Handler: funcName(r.handler)}
+	}
+	return endpoints
+}
+
+// Build собирает документ из каталога маршрутов
+func Build() *Document {
+	g := newGenerator(enums())
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "Wallet API",
+			Version:     "1.0.0",
+			Description: apiDescription,
+		},
+		Tags:  tags,
+		Paths: make(map[string]*PathItem),
+		Components: Components{
+			Responses:       errorResponses(),
+			SecuritySchemes: securitySchemes,
+		},
+	}
+	// Схема ошибки описана вручную: хендлеры отвечают gin.H
+	g.schemas["Error"] = errorSchema
+
+	for _, r := range catalog() {
+		item := doc.Paths[SpecPath(r.path)]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[SpecPath(r.path)] = item
+		}
+		op := g.operation(r)
+		switch r.method {
+		case http.MethodGet:
+			item.Get = op
+		case http.MethodPut:
+			item.Put = op
+		case http.MethodPost:
+			item.Post = op
+		case http.MethodDelete:
+			item.Delete = op
+		case http.MethodPatch:
+			item.Patch = op
+		default:
+			panic(fmt.Sprintf("openapi: unsupported method %s %s", r.method, r.path))
+		}
+	}
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+func (g *generator) operation(r route) *Operation {
+	op := &Operation{
+		Tags:        []string{r.tag},
+		Summary:     r.summary,
+		Description: r.description,
+		OperationID: r.id,
+		Responses:   make(map[string]*Response),
+	}
+	for _, perm := range r.perms {
+		op.Permissions = append(op.Permissions, string(perm))
+	}
+	if !r.public {
+		op.Security = []SecurityRequirement{{"bearerAuth": {}}, {"apiKeyAuth": {}}}
+	}
+
+	for _, segment := range strings.Split(r.path, "/") {
+		if len(segment) < 2 || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		schema, ok := r.pathParams[name]
+		if !ok {
+			schema = &Schema{Type: "string", Format: "uuid"}
+		}
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	op.Parameters = append(op.Parameters, r.query...)
+	op.Parameters = append(op.Parameters, r.headers...)
+
+	content := r.content
+	if r.body != nil {
+		content = jsonBody(r.body)
+	}
+	if content != nil {
+		op.RequestBody = &RequestBody{Required: !r.optionalBody, Content: g.content(content, inbound)}
+	}
+
+	for _, resp := range r.responses {
+		op.Responses[strconv.Itoa(resp.status)] = &Response{
+			Description: resp.description,
+			Headers:     resp.headers,
+			Content:     g.content(resp.content, outbound),
+		}
+	}
+	codes := r.errors
+	if !r.public {
+		codes = append(codes, http.StatusUnauthorized, http.StatusServiceUnavailable)
+		if len(r.perms) > 0 {
+			codes = append(codes, http.StatusForbidden)
+		}
+	}
+	for _, code := range codes {
+		key := strconv.Itoa(code)
+		if _, ok := op.Responses[key]; ok {
+			continue
+		}
+		name, ok := errorNames[code]
+		if !ok {
+			panic(fmt.Sprintf("openapi: %s %s: no shared response for status %d", r.method, r.path, code))
+		}
+		op.Responses[key] = &Response{Ref: "#/components/responses/" + name}
+	}
+	return op
+}
+
+func (g *generator) content(bodies map[string]interface{}, dir direction) map[string]*MediaType {
+	if len(bodies) == 0 {
+		return nil
+	}
+	out := make(map[string]*MediaType, len(bodies))
+	for mediaType, body := range bodies {
+		out[mediaType] = &MediaType{Schema: g.schema(body, dir)}
+	}
+	return out
+}
+
+// SpecPath переводит путь gin в шаблон OpenAPI: :id и *id - {id}
+func SpecPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// funcName - имя функции, как его показывает gin для метода-значения
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func jsonBody(body interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": body}
+}
+
+func ok(body interface{}) response {
+	return response{status: http.StatusOK, description: "OK", content: jsonBody(body)}
+}
+
+func created(body interface{}) response {
+	return response{status: http.StatusCreated, description: "Created", content: jsonBody(body)}
+}
+
+func noContent() response {
+	return response{status: http.StatusNoContent, description: "No Content"}
+}
+
+// accepted - 202 со ссылкой на состояние в Location
+func accepted(body interface{}) response {
+	return response{
+		status:      http.StatusAccepted,
+		description: "Accepted",
+		content:     jsonBody(body),
+		headers: map[string]*Header{
+			"Location": {Description: "URL to poll for the result", Schema: &Schema{Type: "string"}},
+		},
+	}
+}
+
+// message - ответ вида {"message": "..."}
+var message = object{props: map[string]interface{}{"message": ""}}
+
+// pageParams - limit и offset постраничных списков
+func pageParams(def, max int) []*Parameter {
+	return []*Parameter{
+		{
+			Name: "limit", In: "query",
+			Description: "Page size",
+			Schema:      &Schema{Type: "integer", Default: def, Minimum: int64Ptr(1), Maximum: int64Ptr(int64(max))},
+		},
+		{
+			Name: "offset", In: "query",
+			Description: "Number of items to skip",
+			Schema:      &Schema{Type: "integer", Default: 0, Minimum: int64Ptr(0)},
+		},
+	}
+}
+
+func queryParam(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func requiredQuery(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Required: true, Schema: schema}
+}
+
+func stringSchema() *Schema {
+	return &Schema{Type: "string"}
+}
+
+func enumSchema(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+// enumOf - значения строкового типа сущности для enums
+func enumOf[T ~string](values ...T) (reflect.Type, []string) {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return reflect.TypeOf(values[0]), out
+}
@@ -2,20 +2,31 @@ package http
 
 import (
 	"github.com/gin-gonic/gin"
+	"walletapitest/internal/domain/entities"
 	"walletapitest/internal/infrastructure/http/handlers"
 	"walletapitest/internal/infrastructure/http/middlewares"
+	"walletapitest/internal/pkg/auth"
 )
 
 type Router struct {
 	engine         *gin.Engine
 	userHandler    *handlers.UserHandler
+	healthHandler  *handlers.HealthHandler
 	authMiddleware gin.HandlerFunc
 }
 
-func NewRouter(userHandler *handlers.UserHandler) *Router {
+func NewRouter(
+	userHandler *handlers.UserHandler,
+	healthHandler *handlers.HealthHandler,
+	tokens *auth.TokenManager,
+	denylist auth.Denylist,
+	apiKeys auth.APIKeyVerifier,
+) *Router {
 	router := &Router{
-		engine:      gin.Default(),
-		userHandler: userHandler,
+		engine:         gin.Default(),
+		userHandler:    userHandler,
+		healthHandler:  healthHandler,
+		authMiddleware: middlewares.AuthMiddleware(tokens, denylist, apiKeys),
 	}
 	
 	router.setupRoutes()
@@ -32,16 +43,19 @@ func (r *Router) setupRoutes() {
 	
 	// Protected routes
 	protected := r.engine.Group("/api/v1")
-	protected.Use(middlewares.AuthMiddleware())
+	protected.Use(r.authMiddleware)
 	{
 		protected.GET("/users/:id", r.userHandler.GetUser)
+		protected.PATCH("/users/:id", r.userHandler.UpdateUser)
+		protected.DELETE("/users/:id", r.userHandler.DeleteUser)
+		protected.GET("/users", middlewares.RequirePermission(entities.PermUsersReadAll), r.userHandler.ListUsers)
 		// другие защищенные маршруты
 	}
 	
-	// Health check
-	r.engine.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Health checks
+	r.engine.GET("/livez", r.healthHandler.Livez)
+	r.engine.GET("/readyz", r.healthHandler.Readyz)
+	r.engine.GET("/health", r.healthHandler.Health)
 }
 
 func (r *Router) Run(addr string) error {
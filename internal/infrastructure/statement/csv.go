@@ -0,0 +1,56 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+	"walletapitest/internal/domain/entities"
+)
+
+// Входящий и исходящий остатки выводятся отдельными строками таблицы,
+// чтобы файл читался как одна таблица без служебного заголовка.
+const (
+	csvOpeningBalance = "OPENING_BALANCE"
+	csvClosingBalance = "CLOSING_BALANCE"
+)
+
+var csvHeader = []string{"created_at", "operation_id", "operation_type", "amount", "running_balance", "reason"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin(statement *entities.Statement) error {
+	if err := c.w.Write(csvHeader); err != nil {
+		return err
+	}
+	return c.w.Write(balanceRow(statement.From, csvOpeningBalance, statement.OpeningBalance))
+}
+
+func (c *csvWriter) Line(line *entities.StatementLine) error {
+	return c.w.Write([]string{
+		line.CreatedAt.UTC().Format(time.RFC3339Nano),
+		line.OperationID.String(),
+		string(line.OperationType),
+		strconv.FormatInt(line.Amount, 10),
+		strconv.FormatInt(line.RunningBalance, 10),
+		line.Reason,
+	})
+}
+
+func (c *csvWriter) End(statement *entities.Statement) error {
+	if err := c.w.Write(balanceRow(statement.To, csvClosingBalance, statement.ClosingBalance)); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func balanceRow(at time.Time, kind string, balance int64) []string {
+	return []string{at.UTC().Format(time.RFC3339Nano), "", kind, "", strconv.FormatInt(balance, 10), ""}
+}
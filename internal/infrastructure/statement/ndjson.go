@@ -0,0 +1,63 @@
+package statement
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+	"walletapitest/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+// Каждая строка NDJSON - отдельный объект с полем record:
+// header, затем operation для каждой операции и summary в конце
+const (
+	recordHeader    = "header"
+	recordOperation = "operation"
+	recordSummary   = "summary"
+)
+
+type ndjsonHeader struct {
+	Record         string    `json:"record"`
+	WalletID       uuid.UUID `json:"wallet_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	GeneratedAt    time.Time `json:"generated_at"`
+	OpeningBalance int64     `json:"opening_balance"`
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+// Begin пишет заголовок без итогов: они известны только в конце выписки
+func (n *ndjsonWriter) Begin(statement *entities.Statement) error {
+	return n.enc.Encode(ndjsonHeader{
+		Record:         recordHeader,
+		WalletID:       statement.WalletID,
+		UserID:         statement.UserID,
+		From:           statement.From,
+		To:             statement.To,
+		GeneratedAt:    statement.GeneratedAt,
+		OpeningBalance: statement.OpeningBalance,
+	})
+}
+
+func (n *ndjsonWriter) Line(line *entities.StatementLine) error {
+	return n.enc.Encode(struct {
+		Record string `json:"record"`
+		*entities.StatementLine
+	}{recordOperation, line})
+}
+
+func (n *ndjsonWriter) End(statement *entities.Statement) error {
+	return n.enc.Encode(struct {
+		Record string `json:"record"`
+		*entities.Statement
+	}{recordSummary, statement})
+}
@@ -0,0 +1,223 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"walletapitest/internal/domain/entities"
+)
+
+// Простой PDF 1.4 без внешних зависимостей: A4, моноширинный Courier из
+// стандартных шрифтов. Страницы пишутся в поток по мере заполнения, а
+// дерево страниц и таблица xref - в конце, поэтому в памяти держится
+// только текущая страница.
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 40
+	pdfFontSize     = 8
+	pdfLeading      = 11
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	// pdfLineWidth - символов Courier в строке между полями
+	pdfLineWidth = 100
+
+	pdfCatalogObject = 1
+	pdfPagesObject   = 2
+	pdfFontObject    = 3
+	pdfFirstFree     = 4
+)
+
+const pdfTimeLayout = "2006-01-02 15:04:05"
+
+type pdfWriter struct {
+	w       *countingWriter
+	offsets map[int]int64
+	next    int
+	pages   []int
+	lines   []string
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	return &pdfWriter{
+		w:       &countingWriter{w: w},
+		offsets: map[int]int64{},
+		next:    pdfFirstFree,
+	}
+}
+
+func (p *pdfWriter) Begin(statement *entities.Statement) error {
+	if _, err := io.WriteString(p.w, "%PDF-1.4\n"); err != nil {
+		return err
+	}
+	if err := p.object(pdfFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"); err != nil {
+		return err
+	}
+
+	header := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		"Wallet:          " + statement.WalletID.String(),
+		"Owner:           " + statement.UserID.String(),
+		"Period:          " + statement.From.UTC().Format(pdfTimeLayout) + " - " + statement.To.UTC().Format(pdfTimeLayout) + " UTC",
+		"Generated:       " + statement.GeneratedAt.UTC().Format(pdfTimeLayout) + " UTC",
+		fmt.Sprintf("Opening balance: %d", statement.OpeningBalance),
+		"",
+		pdfRow("Date (UTC)", "Operation", "Type", "Amount", "Balance"),
+		strings.Repeat("-", pdfLineWidth),
+	}
+	for _, line := range header {
+		if err := p.add(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *pdfWriter) Line(line *entities.StatementLine) error {
+	row := pdfRow(
+		line.CreatedAt.UTC().Format(pdfTimeLayout),
+		line.OperationID.String(),
+		string(line.OperationType),
+		fmt.Sprint(line.Amount),
+		fmt.Sprint(line.RunningBalance),
+	)
+	if err := p.add(row); err != nil {
+		return err
+	}
+	if line.Reason != "" {
+		return p.add(truncate("    Reason: "+line.Reason, pdfLineWidth))
+	}
+	return nil
+}
+
+func (p *pdfWriter) End(statement *entities.Statement) error {
+	footer := []string{
+		strings.Repeat("-", pdfLineWidth),
+		fmt.Sprintf("Operations:      %d", statement.Count),
+		fmt.Sprintf("Total credits:   %d", statement.TotalCredits),
+		fmt.Sprintf("Total debits:    %d", statement.TotalDebits),
+		fmt.Sprintf("Closing balance: %d", statement.ClosingBalance),
+	}
+	for _, line := range footer {
+		if err := p.add(line); err != nil {
+			return err
+		}
+	}
+	if err := p.flushPage(); err != nil {
+		return err
+	}
+
+	kids := make([]string, len(p.pages))
+	for i, page := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	pages := fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages))
+	if err := p.object(pdfPagesObject, pages); err != nil {
+		return err
+	}
+	if err := p.object(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject)); err != nil {
+		return err
+	}
+	return p.trailer()
+}
+
+// add добавляет строку на текущую страницу и выводит страницу, когда она заполнена
+func (p *pdfWriter) add(line string) error {
+	p.lines = append(p.lines, line)
+	if len(p.lines) < pdfLinesPerPage {
+		return nil
+	}
+	return p.flushPage()
+}
+
+func (p *pdfWriter) flushPage() error {
+	if len(p.lines) == 0 && len(p.pages) > 0 {
+		return nil
+	}
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin)
+	for _, line := range p.lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	fmt.Fprintf(&content, "ET\nBT /F1 %d Tf %d %d Td (Page %d) Tj ET\n", pdfFontSize, pdfPageWidth-pdfMargin-50, pdfMargin/2, len(p.pages)+1)
+	p.lines = p.lines[:0]
+
+	contentObject, pageObject := p.next, p.next+1
+	p.next += 2
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String())
+	if err := p.object(contentObject, stream); err != nil {
+		return err
+	}
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, pdfFontObject, contentObject)
+	if err := p.object(pageObject, page); err != nil {
+		return err
+	}
+	p.pages = append(p.pages, pageObject)
+	return nil
+}
+
+func (p *pdfWriter) object(number int, body string) error {
+	p.offsets[number] = p.w.n
+	_, err := fmt.Fprintf(p.w, "%d 0 obj\n%s\nendobj\n", number, body)
+	return err
+}
+
+// trailer пишет таблицу смещений объектов; записи xref - ровно 20 байт
+func (p *pdfWriter) trailer() error {
+	size := p.next
+	start := p.w.n
+
+	var xref bytes.Buffer
+	fmt.Fprintf(&xref, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for i := 1; i < size; i++ {
+		fmt.Fprintf(&xref, "%010d 00000 n \n", p.offsets[i])
+	}
+	fmt.Fprintf(&xref, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, pdfCatalogObject, start)
+	_, err := p.w.Write(xref.Bytes())
+	return err
+}
+
+func pdfRow(date, operation, kind, amount, balance string) string {
+	return fmt.Sprintf("%-19s  %-36s  %-10s %14s %14s", date, operation, kind, amount, balance)
+}
+
+// pdfEscape экранирует строку PDF; символы вне ASCII стандартным шрифтом
+// без встраивания не выводятся и заменяются на '?'
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-3]) + "..."
+}
+
+// countingWriter считает записанные байты для смещений в xref
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,58 @@
+// Package statement выводит выписки по кошельку в CSV, NDJSON и PDF.
+// Все форматы пишут строки сразу в io.Writer и не накапливают выписку в памяти.
+package statement
+
+import (
+	"errors"
+	"io"
+	"walletapitest/internal/domain/entities"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported statement format, expected csv, ndjson or pdf")
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatPDF    Format = "pdf"
+)
+
+// Writer реализует services.StatementWriter для конкретного формата
+type Writer interface {
+	Begin(statement *entities.Statement) error
+	Line(line *entities.StatementLine) error
+	End(statement *entities.Statement) error
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatPDF:
+		return newPDFWriter(w), nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// ContentType - MIME-тип формата для ответа HTTP
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension - расширение файла для Content-Disposition
+func (f Format) Extension() string {
+	return string(f)
+}
@@ -0,0 +1,220 @@
+package statement_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"walletapitest/internal/domain/entities"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/infrastructure/statement"
+
+	"github.com/google/uuid"
+)
+
+// statementPage - размер страницы ListOperationsBetween в WriteStatement
+const statementPage = 500
+
+// period - кошелек с операциями до, во время и после периода выписки
+type period struct {
+	wallets  *services.WalletService
+	walletID uuid.UUID
+	from, to time.Time
+	opening  int64
+	closing  int64
+	inside   []*entities.Operation
+}
+
+func newPeriod(t *testing.T, operations int) *period {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	owner := entities.NewUser("statement-"+uuid.NewString()+"@example.com", "statement-"+uuid.NewString(), "secret")
+	if err := memory.NewUserRepository(store).Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	wallets := services.NewWalletService(memory.NewWalletRepository(store), nil, nil, nil)
+	wallet, err := wallets.CreateWallet(ctx, owner.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &period{wallets: wallets, walletID: wallet.ID}
+
+	process := func(operationType entities.OperationType, amount int64) *entities.Operation {
+		t.Helper()
+		result, err := wallets.ProcessOperation(ctx, wallet.ID, operationType, amount, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Operation
+	}
+	// Время операций округляется до микросекунд: границы периода отделены паузой
+	pause := func() { time.Sleep(time.Millisecond) }
+
+	process(entities.OperationTypeDeposit, 10000)
+	p.opening = process(entities.OperationTypeWithdraw, 2500).BalanceAfter
+	pause()
+	p.from = time.Now()
+	pause()
+	for i := 0; i < operations; i++ {
+		operationType, amount := entities.OperationTypeDeposit, int64(100+i%7)
+		if i%3 == 2 {
+			operationType, amount = entities.OperationTypeWithdraw, int64(150+i%5)
+		}
+		op := process(operationType, amount)
+		p.inside = append(p.inside, op)
+		p.closing = op.BalanceAfter
+	}
+	pause()
+	p.to = time.Now()
+	pause()
+	process(entities.OperationTypeDeposit, 777)
+	return p
+}
+
+func (p *period) write(t *testing.T, format statement.Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := statement.NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.wallets.WriteStatement(context.Background(), p.walletID, p.from, p.to, w); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkLines проверяет строки выписки: все операции периода по порядку, и
+// каждый остаток следует из предыдущего
+func (p *period) checkLines(t *testing.T, ids []string, amounts, running []int64, types []string) {
+	t.Helper()
+	if len(ids) != len(p.inside) {
+		t.Fatalf("%d lines, want %d", len(ids), len(p.inside))
+	}
+	balance := p.opening
+	for i, op := range p.inside {
+		if ids[i] != op.ID.String() {
+			t.Fatalf("line %d: operation %s, want %s", i, ids[i], op.ID)
+		}
+		if types[i] == string(entities.OperationTypeWithdraw) {
+			balance -= amounts[i]
+		} else {
+			balance += amounts[i]
+		}
+		if running[i] != balance {
+			t.Fatalf("line %d: running balance %d, want %d", i, running[i], balance)
+		}
+	}
+	if balance != p.closing {
+		t.Fatalf("last running balance %d, want closing %d", balance, p.closing)
+	}
+}
+
+func TestStatementBalancesAcrossPages(t *testing.T) {
+	// Ровно страница, ровно две страницы и неполная последняя
+	for _, operations := range []int{statementPage, 2 * statementPage, 2*statementPage + 7} {
+		t.Run(strconv.Itoa(operations), func(t *testing.T) {
+			p := newPeriod(t, operations)
+
+			t.Run("csv", func(t *testing.T) {
+				records, err := csv.NewReader(bytes.NewReader(p.write(t, statement.FormatCSV))).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Заголовок, входящий остаток, операции, исходящий остаток
+				if len(records) != operations+3 {
+					t.Fatalf("%d rows, want %d", len(records), operations+3)
+				}
+				opening, closing := records[1], records[len(records)-1]
+				if opening[2] != "OPENING_BALANCE" || opening[4] != strconv.FormatInt(p.opening, 10) {
+					t.Fatalf("opening row %v, want balance %d", opening, p.opening)
+				}
+				if closing[2] != "CLOSING_BALANCE" || closing[4] != strconv.FormatInt(p.closing, 10) {
+					t.Fatalf("closing row %v, want balance %d", closing, p.closing)
+				}
+				var ids, types []string
+				var amounts, running []int64
+				for _, row := range records[2 : len(records)-1] {
+					amount, _ := strconv.ParseInt(row[3], 10, 64)
+					balance, _ := strconv.ParseInt(row[4], 10, 64)
+					ids, types = append(ids, row[1]), append(types, row[2])
+					amounts, running = append(amounts, amount), append(running, balance)
+				}
+				p.checkLines(t, ids, amounts, running, types)
+			})
+
+			t.Run("ndjson", func(t *testing.T) {
+				var ids, types []string
+				var amounts, running []int64
+				var header, summary struct {
+					Record         string `json:"record"`
+					OpeningBalance int64  `json:"opening_balance"`
+					ClosingBalance int64  `json:"closing_balance"`
+					Count          int64  `json:"count"`
+				}
+				scanner := bufio.NewScanner(bytes.NewReader(p.write(t, statement.FormatNDJSON)))
+				for scanner.Scan() {
+					var record struct {
+						Record         string `json:"record"`
+						OperationID    string `json:"operation_id"`
+						OperationType  string `json:"operation_type"`
+						Amount         int64  `json:"amount"`
+						RunningBalance int64  `json:"running_balance"`
+					}
+					if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+						t.Fatal(err)
+					}
+					switch record.Record {
+					case "header":
+						_ = json.Unmarshal(scanner.Bytes(), &header)
+					case "summary":
+						_ = json.Unmarshal(scanner.Bytes(), &summary)
+					default:
+						ids, types = append(ids, record.OperationID), append(types, record.OperationType)
+						amounts, running = append(amounts, record.Amount), append(running, record.RunningBalance)
+					}
+				}
+				if header.OpeningBalance != p.opening {
+					t.Fatalf("opening balance %d, want %d", header.OpeningBalance, p.opening)
+				}
+				if summary.Record != "summary" || summary.ClosingBalance != p.closing || summary.Count != int64(operations) {
+					t.Fatalf("summary %+v, want closing %d after %d operations", summary, p.closing, operations)
+				}
+				p.checkLines(t, ids, amounts, running, types)
+			})
+
+			t.Run("pdf", func(t *testing.T) {
+				pdf := string(p.write(t, statement.FormatPDF))
+				for _, want := range []string{
+					fmt.Sprintf("(Opening balance: %d)", p.opening),
+					fmt.Sprintf("(Closing balance: %d)", p.closing),
+					fmt.Sprintf("(Operations:      %d)", operations),
+				} {
+					if !strings.Contains(pdf, want) {
+						t.Fatalf("pdf has no %q", want)
+					}
+				}
+				// Остаток после каждой операции стоит в конце ее строки
+				for i, op := range p.inside {
+					at := strings.Index(pdf, op.ID.String())
+					if at < 0 {
+						t.Fatalf("pdf has no operation %d (%s)", i, op.ID)
+					}
+					row := pdf[at : at+strings.Index(pdf[at:], ")")]
+					if !strings.HasSuffix(row, " "+strconv.FormatInt(op.BalanceAfter, 10)) {
+						t.Fatalf("pdf row %q, want running balance %d", row, op.BalanceAfter)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// APIKeyPrefix отличает API-ключи от JWT в заголовке Authorization
+const APIKeyPrefix = "wk_"
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyVerifier проверяет API-ключ и возвращает данные вызывающего
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*Claims, error)
+}
+
+// NewAPIKey генерирует ключ вида wk_<prefix>_<secret>. Возвращает сам ключ
+// (показывается один раз), открытый префикс и хеш секрета для хранения.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	idBuf := make([]byte, 8)
+	if _, err := rand.Read(idBuf); err != nil {
+		return "", "", "", err
+	}
+	secretBuf := make([]byte, 32)
+	if _, err := rand.Read(secretBuf); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(idBuf)
+	secret := base64.RawURLEncoding.EncodeToString(secretBuf)
+	return APIKeyPrefix + prefix + "_" + secret, prefix, HashToken(secret), nil
+}
+
+// IsAPIKey - токен выглядит как API-ключ, а не JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// ParseAPIKey разбирает ключ на префикс и секрет
+func ParseAPIKey(key string) (prefix, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	prefix, secret, ok = strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewAPIKeyFormat(t *testing.T) {
+	format := regexp.MustCompile(`^wk_[0-9a-f]{16}_[A-Za-z0-9_-]{43}$`)
+	seen := make(map[string]bool)
+	for range 20 {
+		key, prefix, hash, err := NewAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !format.MatchString(key) {
+			t.Fatalf("key %q does not match %s", key, format)
+		}
+		if !IsAPIKey(key) {
+			t.Fatalf("IsAPIKey(%q) = false", key)
+		}
+
+		gotPrefix, secret, ok := ParseAPIKey(key)
+		if !ok || gotPrefix != prefix {
+			t.Fatalf("ParseAPIKey(%q) = %q, ok %v; want prefix %q", key, gotPrefix, ok, prefix)
+		}
+		// Хранится только хеш секрета, префикс в него не входит
+		if HashToken(secret) != hash {
+			t.Fatalf("hash of parsed secret does not match stored hash")
+		}
+		if seen[prefix] {
+			t.Fatalf("prefix %q generated twice", prefix)
+		}
+		seen[prefix] = true
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		prefix string
+		secret string
+		ok     bool
+	}{
+		{"valid", "wk_0123abcd_s3cret", "0123abcd", "s3cret", true},
+		// Секрет в base64url может содержать "_", префикс - нет
+		{"underscore in secret", "wk_0123abcd_s3_cr_et", "0123abcd", "s3_cr_et", true},
+		{"jwt", "eyJhbGciOiJIUzI1NiJ9.e30.sig", "", "", false},
+		{"no prefix marker", "0123abcd_s3cret", "", "", false},
+		{"no secret separator", "wk_0123abcd", "", "", false},
+		{"empty prefix", "wk__s3cret", "", "", false},
+		{"empty secret", "wk_0123abcd_", "", "", false},
+		{"only marker", "wk_", "", "", false},
+		{"empty", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, secret, ok := ParseAPIKey(tt.key)
+			if prefix != tt.prefix || secret != tt.secret || ok != tt.ok {
+				t.Fatalf("ParseAPIKey(%q) = %q, %q, %v; want %q, %q, %v",
+					tt.key, prefix, secret, ok, tt.prefix, tt.secret, tt.ok)
+			}
+		})
+	}
+}
+
+func TestClaimsCoversWallet(t *testing.T) {
+	wallet, other, keyID := uuid.New(), uuid.New(), uuid.New()
+	const readAll = "wallets:read_all"
+
+	tests := []struct {
+		name   string
+		claims Claims
+		all    []string
+		want   bool
+	}{
+		// Доступ пользователя проверяется по владельцу кошелька, а не здесь
+		{"user token", Claims{UserID: uuid.New()}, nil, true},
+		{"scoped key, wallet in scope", Claims{APIKeyID: keyID, WalletIDs: []uuid.UUID{other, wallet}}, nil, true},
+		{"scoped key, wallet out of scope", Claims{APIKeyID: keyID, WalletIDs: []uuid.UUID{other}}, nil, false},
+		// Область сильнее разрешения: read_all не выводит ключ за его кошельки
+		{"scoped key with permission, wallet out of scope",
+			Claims{APIKeyID: keyID, WalletIDs: []uuid.UUID{other}, Permissions: []string{readAll}}, []string{readAll}, false},
+		{"unscoped key with permission", Claims{APIKeyID: keyID, Permissions: []string{readAll}}, []string{readAll}, true},
+		{"unscoped key, permission not accepted", Claims{APIKeyID: keyID, Permissions: []string{readAll}}, nil, false},
+		{"unscoped key without permission",
+			Claims{APIKeyID: keyID, Permissions: []string{"wallets:operate"}}, []string{readAll}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.CoversWallet(wallet, tt.all...); got != tt.want {
+				t.Fatalf("CoversWallet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsIsAuthor(t *testing.T) {
+	user, key, otherKey := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name     string
+		claims   Claims
+		userID   uuid.UUID
+		apiKeyID *uuid.UUID
+		want     bool
+	}{
+		{"same user", Claims{UserID: user}, user, nil, true},
+		{"other user", Claims{UserID: uuid.New()}, user, nil, false},
+		{"same key", Claims{APIKeyID: key}, uuid.Nil, &key, true},
+		// У всех ключей пользователь нулевой, сравнивается только ключ
+		{"other key", Claims{APIKeyID: otherKey}, uuid.Nil, &key, false},
+		{"key, resource of a user", Claims{APIKeyID: key}, user, nil, false},
+		{"key, legacy resource without key", Claims{APIKeyID: key}, uuid.Nil, nil, false},
+		{"user, resource of a key", Claims{UserID: user}, uuid.Nil, &key, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.IsAuthor(tt.userID, tt.apiKeyID); got != tt.want {
+				t.Fatalf("IsAuthor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
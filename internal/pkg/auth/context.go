@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type ctxKey struct{}
+
+// WithClaims кладет данные аутентифицированного вызывающего в контекст
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, claims)
+}
+
+// ClaimsFromContext возвращает данные вызывающего, если запрос аутентифицирован
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ctxKey{}).(*Claims)
+	return claims, ok
+}
+
+// HasPermission - у вызывающего есть разрешение perm
+func HasPermission(ctx context.Context, perm string) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	return ok && claims.HasPermission(perm)
+}
+
+// CanActOn - вызывающий является самим пользователем или имеет разрешение
+// perm на чужие ресурсы
+func CanActOn(ctx context.Context, userID uuid.UUID, perm string) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return claims.UserID == userID || claims.HasPermission(perm)
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims - содержимое access-токена
+type Claims struct {
+	UserID      uuid.UUID `json:"uid"`
+	SessionID   uuid.UUID `json:"sid"`
+	Role        string    `json:"role,omitempty"`
+	Permissions []string  `json:"perms,omitempty"`
+	// APIKeyID и WalletIDs заполняются только при аутентификации API-ключом
+	APIKeyID  uuid.UUID   `json:"-"`
+	WalletIDs []uuid.UUID `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager выпускает и проверяет access-токены (HS256)
+type TokenManager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTokenManager(secret string, ttl time.Duration) *TokenManager {
+	return &TokenManager{
+		secret: []byte(secret),
+		ttl:    ttl,
+	}
+}
+
+// TTL возвращает время жизни access-токена
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue выпускает токен для пользователя в рамках сессии; роль и разрешения
+// зашиваются в токен и действуют до его истечения
+func (m *TokenManager) Issue(userID, sessionID uuid.UUID, role string, permissions []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	claims := Claims{
+		UserID:      userID,
+		SessionID:   sessionID,
+		Role:        role,
+		Permissions: permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Parse проверяет подпись и срок действия токена
+func (m *TokenManager) Parse(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// HasPermission - токен дает разрешение perm
+func (c *Claims) HasPermission(perm string) bool {
+	for _, p := range c.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKey - вызывающий аутентифицирован API-ключом, а не сессией пользователя
+func (c *Claims) IsAPIKey() bool {
+	return c.APIKeyID != uuid.Nil
+}
+
+// CoversWallet - вызывающий может действовать на walletID. Ключ с областью
+// покрывает только свои кошельки, ключ без области - любой кошелек, только
+// если у него есть одно из разрешений all. Для пользовательских токенов
+// всегда true: их доступ проверяется по владельцу кошелька.
+func (c *Claims) CoversWallet(walletID uuid.UUID, all ...string) bool {
+	if !c.IsAPIKey() {
+		return true
+	}
+	if len(c.WalletIDs) == 0 {
+		for _, perm := range all {
+			if c.HasPermission(perm) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, id := range c.WalletIDs {
+		if id == walletID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthor - вызывающий создал ресурс, записанный за пользователем userID и
+// API-ключом apiKeyID (nil - создан сессией пользователя). У API-ключа
+// пользователя нет, поэтому ключ узнает только свои ресурсы, а пользователь -
+// только созданные им самим, не ключом.
+func (c *Claims) IsAuthor(userID uuid.UUID, apiKeyID *uuid.UUID) bool {
+	if c.IsAPIKey() {
+		return apiKeyID != nil && *apiKeyID == c.APIKeyID
+	}
+	return apiKeyID == nil && c.UserID == userID
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewRefreshToken генерирует непрозрачный refresh-токен и его хеш для хранения
+func NewRefreshToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken возвращает SHA-256 токена в hex. Токены случайные и длинные,
+// поэтому медленный хеш вроде bcrypt не нужен.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Denylist - список отозванных сессий, проверяемый на каждом запросе,
+// чтобы отзыв действовал до истечения access-токена
+type Denylist interface {
+	Add(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error
+	Contains(ctx context.Context, sessionID uuid.UUID) (bool, error)
+}
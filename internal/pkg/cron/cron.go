@@ -0,0 +1,172 @@
+// Package cron разбирает пятипольные cron-выражения (минута, час, день
+// месяца, месяц, день недели) и считает время следующего срабатывания.
+// Поддерживаются *, списки, диапазоны, шаги (*/15, 1-10/2) и сокращения
+// @hourly, @daily, @weekly, @monthly, @yearly.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidExpression = errors.New("invalid cron expression")
+
+// searchLimit - горизонт поиска срабатывания (например, для "0 0 30 2 *" его нет)
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	min, max int
+}
+
+var fieldBounds = [5]bounds{
+	{0, 59}, // минута
+	{0, 23}, // час
+	{1, 31}, // день месяца
+	{1, 12}, // месяц
+	{0, 7},  // день недели, 0 и 7 - воскресенье
+}
+
+// Schedule - разобранное выражение: множество допустимых значений каждого поля
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny/dowAny - поле задано как *; если ограничены оба дня, срабатывание
+	// наступает при совпадении любого из них, как в классическом cron
+	domAny, dowAny bool
+}
+
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidExpression, len(fields))
+	}
+
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseField(field, fieldBounds[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %d %q: %s", ErrInvalidExpression, i+1, field, err)
+		}
+		sets[i] = set
+	}
+
+	s := &Schedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+	// Воскресенье задается и 0, и 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.New("step must be a positive number")
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.New("invalid range start")
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, errors.New("invalid range end")
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, errors.New("invalid number")
+			}
+			lo, hi = n, n
+			// "5/15" - начиная с 5 до конца диапазона
+			if step > 1 {
+				hi = b.max
+			}
+		}
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("values must be within %d-%d", b.min, b.max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// Next возвращает первое срабатывание строго после t в часовом поясе t.
+// Нулевое время - срабатываний в ближайшие пять лет нет.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(searchLimit)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
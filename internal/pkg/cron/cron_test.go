@@ -0,0 +1,135 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"-1 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1- * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@every",
+	} {
+		if _, err := Parse(expr); !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("Parse(%q) = %v, want %v", expr, err, ErrInvalidExpression)
+		}
+	}
+}
+
+func TestParseField(t *testing.T) {
+	minute, dom, dow := fieldBounds[0], fieldBounds[2], fieldBounds[4]
+	tests := []struct {
+		field string
+		b     bounds
+		want  []int
+	}{
+		{"0", minute, []int{0}},
+		{"59", minute, []int{59}},
+		{"*/15", minute, []int{0, 15, 30, 45}},
+		{"1-10/3", minute, []int{1, 4, 7, 10}},
+		// Число со шагом - от него до конца диапазона
+		{"5/20", minute, []int{5, 25, 45}},
+		{"1,3,5-6", minute, []int{1, 3, 5, 6}},
+		{"10-12,11", minute, []int{10, 11, 12}},
+		{"*/10", dom, []int{1, 11, 21, 31}},
+		{"28-31", dom, []int{28, 29, 30, 31}},
+		{"1-5", dow, []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		got, err := parseField(tt.field, tt.b)
+		if err != nil {
+			t.Errorf("parseField(%q): %v", tt.field, err)
+			continue
+		}
+		var want uint64
+		for _, v := range tt.want {
+			want |= 1 << uint(v)
+		}
+		if got != want {
+			t.Errorf("parseField(%q) = %b, want %b", tt.field, got, want)
+		}
+	}
+}
+
+func TestParseSundayAsSeven(t *testing.T) {
+	s, err := Parse("0 0 * * 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.dow&1 == 0 {
+		t.Error("day of week 7 does not match Sunday (0)")
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"step within hour", "*/15 * * * *", at(2024, 1, 1, 10, 7, 0), at(2024, 1, 1, 10, 15, 0)},
+		{"strictly after a match", "0 * * * *", at(2024, 1, 1, 10, 0, 0), at(2024, 1, 1, 11, 0, 0)},
+		{"seconds ignored", "*/15 * * * *", at(2024, 1, 1, 10, 14, 59), at(2024, 1, 1, 10, 15, 0)},
+		{"next day", "0 0 * * *", at(2024, 1, 1, 23, 59, 0), at(2024, 1, 2, 0, 0, 0)},
+		{"hour range", "30 9-17/4 * * *", at(2024, 1, 1, 13, 31, 0), at(2024, 1, 1, 17, 30, 0)},
+		{"month boundary", "0 0 1 * *", at(2024, 1, 31, 12, 0, 0), at(2024, 2, 1, 0, 0, 0)},
+		{"short months skipped", "0 0 31 * *", at(2024, 4, 1, 0, 0, 0), at(2024, 5, 31, 0, 0, 0)},
+		{"year boundary", "@yearly", at(2024, 6, 1, 0, 0, 0), at(2025, 1, 1, 0, 0, 0)},
+		{"last minute of the year", "59 23 31 12 *", at(2024, 12, 31, 23, 59, 0), at(2025, 12, 31, 23, 59, 0)},
+		{"leap day", "0 0 29 2 *", at(2025, 3, 1, 0, 0, 0), at(2028, 2, 29, 0, 0, 0)},
+		{"day of week", "0 9 * * 1", at(2024, 1, 3, 0, 0, 0), at(2024, 1, 8, 9, 0, 0)},
+		{"weekdays over weekend", "0 9 * * 1-5", at(2024, 1, 5, 10, 0, 0), at(2024, 1, 8, 9, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 7, 0, 0, 0)},
+		{"weekly macro", "@weekly", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 7, 0, 0, 0)},
+		// Ограничены оба дня: срабатывает по любому из них
+		{"day of month or week, week first", "0 0 13 * 5", at(2024, 9, 1, 0, 0, 0), at(2024, 9, 6, 0, 0, 0)},
+		{"day of month or week, month first", "0 0 2 * 5", at(2024, 9, 1, 0, 0, 0), at(2024, 9, 2, 0, 0, 0)},
+		// Ограничен только день месяца: день недели не учитывается
+		{"day of month only", "0 0 13 * *", at(2024, 9, 1, 0, 0, 0), at(2024, 9, 13, 0, 0, 0)},
+		{"impossible date", "0 0 30 2 *", at(2024, 1, 1, 0, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from.Format(time.RFC3339), got.Format(time.RFC3339), tt.want.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	s, err := Parse("@daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2024, 1, 1, 12, 0, 0, 0, loc))
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
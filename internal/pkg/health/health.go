@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Check - проверка одной зависимости. Critical-проверки влияют на readiness,
+// остальные только переводят отчет в degraded.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) (details string, err error)
+}
+
+type Result struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Details   string  `json:"details,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status       Status    `json:"status"`
+	ShuttingDown bool      `json:"shutting_down"`
+	Checks       []Result  `json:"checks"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// Service собирает проверки зависимостей и состояние фоновых воркеров
+type Service struct {
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+
+	mu      sync.RWMutex
+	checks  []Check
+	workers map[string]*Worker
+}
+
+func New(timeout time.Duration) *Service {
+	return &Service{
+		timeout: timeout,
+		workers: make(map[string]*Worker),
+	}
+}
+
+// AddCheck регистрирует проверку зависимости
+func (s *Service) AddCheck(check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, check)
+}
+
+// SetShuttingDown переводит readiness в failing, чтобы балансировщик
+// успел снять трафик до остановки сервера
+func (s *Service) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+func (s *Service) ShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
+
+// Report выполняет все проверки параллельно и собирает отчет
+func (s *Service) Report(ctx context.Context) Report {
+	return s.run(ctx, false)
+}
+
+// Ready выполняет только critical-проверки
+func (s *Service) Ready(ctx context.Context) (Report, bool) {
+	report := s.run(ctx, true)
+	return report, report.Status != StatusDown
+}
+
+func (s *Service) run(ctx context.Context, criticalOnly bool) Report {
+	s.mu.RLock()
+	checks := make([]Check, 0, len(s.checks)+len(s.workers))
+	for _, c := range s.checks {
+		if !criticalOnly || c.Critical {
+			checks = append(checks, c)
+		}
+	}
+	if !criticalOnly {
+		for _, w := range s.workers {
+			checks = append(checks, w.check())
+		}
+	}
+	s.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			results[i] = s.runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{
+		Status:       StatusUp,
+		ShuttingDown: s.ShuttingDown(),
+		Checks:       results,
+		CheckedAt:    time.Now().UTC(),
+	}
+	for _, r := range results {
+		if r.Status == StatusUp {
+			continue
+		}
+		if r.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	if report.ShuttingDown {
+		report.Status = StatusDown
+	}
+
+	return report
+}
+
+func (s *Service) runCheck(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := c.Run(ctx)
+	result := Result{
+		Name:      c.Name,
+		Status:    StatusUp,
+		Critical:  c.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Worker - heartbeat фонового воркера. Воркер вызывает Beat на каждой
+// итерации; если heartbeat старше maxInterval, воркер считается зависшим.
+type Worker struct {
+	name        string
+	maxInterval time.Duration
+
+	mu       sync.Mutex
+	started  time.Time
+	lastBeat time.Time
+	lastErr  error
+}
+
+// RegisterWorker регистрирует фоновый воркер в отчете о здоровье
+func (s *Service) RegisterWorker(name string, maxInterval time.Duration) *Worker {
+	w := &Worker{name: name, maxInterval: maxInterval, started: time.Now()}
+
+	s.mu.Lock()
+	s.workers[name] = w
+	s.mu.Unlock()
+
+	return w
+}
+
+// Beat отмечает успешную итерацию
+func (w *Worker) Beat() {
+	w.mu.Lock()
+	w.lastBeat = time.Now()
+	w.lastErr = nil
+	w.mu.Unlock()
+}
+
+// Fail отмечает итерацию, завершившуюся ошибкой
+func (w *Worker) Fail(err error) {
+	w.mu.Lock()
+	w.lastBeat = time.Now()
+	w.lastErr = err
+	w.mu.Unlock()
+}
+
+func (w *Worker) check() Check {
+	return Check{
+		Name: "worker:" + w.name,
+		Run: func(context.Context) (string, error) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+
+			last := w.lastBeat
+			if last.IsZero() {
+				last = w.started
+			}
+			age := time.Since(last).Round(time.Millisecond)
+			if age > w.maxInterval {
+				return "", fmt.Errorf("no heartbeat for %s", age)
+			}
+			if w.lastErr != nil {
+				return "", fmt.Errorf("last run failed: %w", w.lastErr)
+			}
+			if w.lastBeat.IsZero() {
+				return "starting", nil
+			}
+			return fmt.Sprintf("last heartbeat %s ago", age), nil
+		},
+	}
+}
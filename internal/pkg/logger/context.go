@@ -0,0 +1,34 @@
+package logger
+
+import "context"
+
+type ctxKey struct{}
+
+// WithContext кладет логгер в контекст запроса
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext достает логгер из контекста; если его нет, возвращает логгер,
+// который ничего не пишет
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(ctxKey{}).(Logger); ok {
+		return l
+	}
+	return nop{}
+}
+
+// WithFields добавляет поля к логгеру из контекста и возвращает новый контекст
+func WithFields(ctx context.Context, fields ...interface{}) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(fields...))
+}
+
+type nop struct{}
+
+func (nop) Debug(string, ...interface{}) {}
+func (nop) Info(string, ...interface{})  {}
+func (nop) Warn(string, ...interface{})  {}
+func (nop) Error(string, ...interface{}) {}
+func (nop) Fatal(string, ...interface{}) {}
+
+func (n nop) With(...interface{}) Logger { return n }
@@ -11,6 +11,7 @@ type Logger interface {
 	Warn(msg string, fields ...interface{})
 	Error(msg string, fields ...interface{})
 	Fatal(msg string, fields ...interface{})
+	With(fields ...interface{}) Logger
 }
 
 type ZapLogger struct {
@@ -82,4 +83,8 @@ func (l *ZapLogger) Error(msg string, fields ...interface{}) {
 
 func (l *ZapLogger) Fatal(msg string, fields ...interface{}) {
 	l.logger.Fatalw(msg, fields...)
+}
+
+func (l *ZapLogger) With(fields ...interface{}) Logger {
+	return &ZapLogger{logger: l.logger.With(fields...)}
 }
\ No newline at end of file
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer дописывает письма в файл или выводит в stdout (path пуст).
+// Предназначен для локальной разработки: ссылки из писем видны без почтового сервера.
+type FileMailer struct {
+	from string
+	path string
+
+	mu sync.Mutex
+}
+
+func NewFileMailer(from, path string) *FileMailer {
+	return &FileMailer{from: from, path: path}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) (err error) {
+	if !validAddress(msg.To) {
+		return errors.New("mail: invalid address")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var w io.Writer = os.Stdout
+	if m.path != "" {
+		f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+
+	data := append([]byte("----- mail -----\r\n"), render(m.from, msg, time.Now())...)
+	_, err = w.Write(data)
+	return err
+}
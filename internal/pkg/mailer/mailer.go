@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"walletapitest/internal/config"
+)
+
+// Message - текстовое письмо
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма пользователям
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New создает отправителя по конфигурации: smtp, file или stdout
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("mail: smtp host is not configured (MAIL_SMTP_HOST)")
+		}
+		return NewSMTPMailer(cfg), nil
+	case "file":
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("mail: file path is not configured (MAIL_FILE_PATH)")
+		}
+		return NewFileMailer(cfg.From, cfg.FilePath), nil
+	case "", "stdout":
+		return NewFileMailer(cfg.From, ""), nil
+	default:
+		return nil, fmt.Errorf("mail: unknown driver %q", cfg.Driver)
+	}
+}
+
+// render собирает письмо в формате RFC 5322
+func render(from string, msg Message, now time.Time) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + msg.To + "\r\n")
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	sb.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	sb.WriteString("\r\n")
+	return []byte(sb.String())
+}
+
+// validAddress отсекает переводы строк, через которые можно внедрить заголовки
+func validAddress(addr string) bool {
+	return addr != "" && !strings.ContainsAny(addr, "\r\n")
+}
@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+
+	"walletapitest/internal/config"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer отправляет письма через SMTP-сервер. STARTTLS используется,
+// если сервер его поддерживает; авторизация - PLAIN, если задан логин.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		host:     cfg.SMTPHost,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     cfg.From,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) (err error) {
+	if !validAddress(msg.To) || !validAddress(m.from) {
+		return errors.New("mail: invalid address")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err = client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(m.from); err != nil {
+		return err
+	}
+	if err = client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(render(m.from, msg, time.Now())); err != nil {
+		w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,31 @@
+package requestid
+
+import "context"
+
+type ctxKey struct{}
+
+// WithContext кладет идентификатор запроса в контекст, чтобы сервисы могли
+// сослаться на него (например, в журнале аудита)
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса или пустую строку
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Valid - идентификатор от клиента можно принять: непустой, не длиннее
+// 128 символов, только печатный ASCII без пробелов
+func Valid(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Cipher шифрует TOTP-секреты перед записью в БД (AES-256-GCM)
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher создает шифр; ключ произвольной длины приводится к 256 битам через SHA-256
+func NewCipher(key string) (*Cipher, error) {
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Open(ciphertext string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < c.aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce, sealed := raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,124 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret - ключ из приложения B RFC 6238 для SHA-1 ("12345678901234567890")
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// Восьмизначные коды RFC 6238; при Digits = 6 остаются младшие шесть цифр
+	vectors := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", v.unix, err)
+		}
+		if got != v.want {
+			t.Errorf("Code at %d = %s, want %s", v.unix, got, v.want)
+		}
+	}
+}
+
+func TestCodeRFC4226Counters(t *testing.T) {
+	// HOTP из приложения D RFC 4226: TOTP - это HOTP от номера интервала
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for step, w := range want {
+		got, err := Code(rfcSecret, int64(step))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", step, err)
+		}
+		if got != w {
+			t.Errorf("Code(%d) = %s, want %s", step, got, w)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	upper, err := Code(rfcSecret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upper != lower {
+		t.Errorf("lowercase secret gives %s, want %s", lower, upper)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code with an invalid secret succeeded")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, err := Code(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code, now)
+		inWindow := offset >= -Skew && offset <= Skew
+		if ok != inWindow {
+			t.Errorf("code of step %+d accepted = %v, want %v", offset, ok, inWindow)
+			continue
+		}
+		if ok && step != current+offset {
+			t.Errorf("code of step %+d matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "287o82", "000000"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate(%q) accepted", code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, "287082", now); !ok {
+		t.Error("Validate rejected the RFC 6238 code at T=59")
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher("test key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Seal(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := c.Open(sealed)
+	if err != nil || opened != rfcSecret {
+		t.Fatalf("Open = %q, %v; want %q", opened, err, rfcSecret)
+	}
+
+	other, err := NewCipher("other key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("secret opened with a different key")
+	}
+}
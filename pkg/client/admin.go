@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserFilter - поиск пользователей по префиксам email и имени
+type UserFilter struct {
+	Email    string
+	Username string
+	// Limit 0 - размер страницы сервера по умолчанию
+	Limit  int
+	Offset int
+}
+
+type UserPage struct {
+	Users  []User `json:"users"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// WalletBalance - баланс кошелька на момент At
+type WalletBalance struct {
+	WalletID uuid.UUID `json:"wallet_id"`
+	Balance  int64     `json:"balance"`
+	At       time.Time `json:"at"`
+	// OperationID - операция, по которой определен баланс; nil - операций не было
+	OperationID *uuid.UUID `json:"operation_id,omitempty"`
+}
+
+type UserBalances struct {
+	UserID  uuid.UUID       `json:"user_id"`
+	At      time.Time       `json:"at"`
+	Wallets []WalletBalance `json:"wallets"`
+	Total   int64           `json:"total"`
+}
+
+type ReconciliationRun struct {
+	ID             uuid.UUID `json:"id"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	WalletsChecked int64     `json:"wallets_checked"`
+	Discrepancies  int64     `json:"discrepancies"`
+}
+
+// Discrepancy - кошелек, баланс которого не сходится с историей операций
+type Discrepancy struct {
+	WalletID         uuid.UUID `json:"wallet_id"`
+	Balance          int64     `json:"balance"`
+	LastBalanceAfter *int64    `json:"last_balance_after"`
+	OperationsSum    int64     `json:"operations_sum"`
+	OperationsCount  int64     `json:"operations_count"`
+	LastBalanceDrift int64     `json:"last_balance_drift"`
+	SumDrift         int64     `json:"sum_drift"`
+}
+
+type ReconciliationReport struct {
+	Run           ReconciliationRun `json:"run"`
+	Discrepancies []Discrepancy     `json:"discrepancies"`
+}
+
+// StatementFormat - формат выписки: csv, ndjson или pdf
+type StatementFormat string
+
+const (
+	StatementCSV    StatementFormat = "csv"
+	StatementNDJSON StatementFormat = "ndjson"
+	StatementPDF    StatementFormat = "pdf"
+)
+
+// ListUsers - страница пользователей (users:read_all)
+func (c *Client) ListUsers(ctx context.Context, filter UserFilter) (*UserPage, error) {
+	query := url.Values{}
+	if filter.Email != "" {
+		query.Set("email", filter.Email)
+	}
+	if filter.Username != "" {
+		query.Set("username", filter.Username)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set("offset", strconv.Itoa(filter.Offset))
+	}
+	var page UserPage
+	err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/v1/users",
+		query:     query,
+		retryable: true,
+	}, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// UserBalances - балансы всех кошельков пользователя на момент at
+func (c *Client) UserBalances(ctx context.Context, userID uuid.UUID, at time.Time) (*UserBalances, error) {
+	var balances UserBalances
+	err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/v1/users/" + userID.String() + "/balances",
+		query:     url.Values{"at": []string{at.UTC().Format(time.RFC3339Nano)}},
+		retryable: true,
+	}, &balances)
+	if err != nil {
+		return nil, err
+	}
+	return &balances, nil
+}
+
+// FreezeWallet запрещает операции с кошельком (wallets:freeze)
+func (c *Client) FreezeWallet(ctx context.Context, walletID uuid.UUID) error {
+	return c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/v1/admin/wallets/" + walletID.String() + "/freeze",
+		retryable: true,
+	}, nil)
+}
+
+func (c *Client) UnfreezeWallet(ctx context.Context, walletID uuid.UUID) error {
+	return c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/v1/admin/wallets/" + walletID.String() + "/unfreeze",
+		retryable: true,
+	}, nil)
+}
+
+// AdjustWallet - ручная корректировка: положительная сумма зачисляется,
+// отрицательная списывается (wallets:adjust). Ключа идемпотентности у
+// корректировок нет, поэтому после ошибки сервера запрос не повторяется.
+func (c *Client) AdjustWallet(ctx context.Context, walletID uuid.UUID, amount int64, reason string) (*Operation, error) {
+	var operation Operation
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v1/admin/wallets/" + walletID.String() + "/adjust",
+		body: map[string]interface{}{
+			"amount": amount,
+			"reason": reason,
+		},
+	}, &operation)
+	if err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// RunReconciliation запускает сверку балансов и возвращает ее отчет
+// (wallets:adjust)
+func (c *Client) RunReconciliation(ctx context.Context) (*ReconciliationReport, error) {
+	var report ReconciliationReport
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v1/admin/reconciliation/run",
+	}, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Statement пишет в w выписку по кошельку за период from..to включительно
+func (c *Client) Statement(ctx context.Context, walletID uuid.UUID, from, to time.Time, format StatementFormat, w io.Writer) error {
+	accept := "*/*"
+	switch format {
+	case StatementCSV:
+		accept = "text/csv"
+	case StatementNDJSON:
+		accept = "application/x-ndjson"
+	case StatementPDF:
+		accept = "application/pdf"
+	}
+	return c.stream(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/v1/wallet/" + walletID.String() + "/statement",
+		query: url.Values{
+			"from":   []string{from.UTC().Format(time.RFC3339Nano)},
+			"to":     []string{to.UTC().Format(time.RFC3339Nano)},
+			"format": []string{string(format)},
+		},
+		header:    http.Header{"Accept": []string{accept}},
+		retryable: true,
+	}, w)
+}
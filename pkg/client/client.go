@@ -0,0 +1,313 @@
+// Package client - Go-клиент HTTP API кошельков: типизированные методы для
+// пользователей, входа, кошельков и операций, повторы с экспоненциальной
+// задержкой и ошибки API в виде *APIError.
+//
+// Повторяются только запросы, которые безопасно выполнить еще раз: чтение,
+// операции с ключом идемпотентности (клиент создает его сам и передает во
+// всех попытках) и ответы 429. Регистрация и вход при ошибке сервера не
+// повторяются.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader - заголовок ключа идемпотентности операции
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	userAgent = "walletapi-go-client"
+)
+
+// RetryPolicy - повторы запросов при сетевых ошибках, 429 и 502-504
+type RetryPolicy struct {
+	// MaxAttempts - число попыток вместе с первой; 1 - без повторов
+	MaxAttempts int
+	// BaseDelay - задержка перед первым повтором, дальше удваивается
+	BaseDelay time.Duration
+	// MaxDelay - предел задержки; Retry-After больше предела не ждем
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy - три попытки с задержкой от 100 мс до 2 с
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retry      RetryPolicy
+
+	mu     sync.RWMutex
+	token  string
+	apiKey string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient задает http.Client (таймауты, транспорт, прокси)
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithToken - access JWT для закрытых методов
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithAPIKey - ключ API сервиса; используется, если нет JWT
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithRetry задает политику повторов
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// New создает клиент для API по адресу baseURL, например http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base url must be http or https, got %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// SetToken заменяет access JWT, например после обновления сессии
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Token - текущий access JWT
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// request - описание вызова API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	header http.Header
+	// retryable - запрос можно повторить после ответа 5xx или обрыва
+	// соединения: чтение или операция с ключом идемпотентности
+	retryable bool
+}
+
+// do выполняет запрос с повторами и разбирает ответ 2xx в out
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	return c.doFunc(ctx, req, func(body io.Reader) error {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return &transportError{err: err}
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("client: decode response: %w", err)
+		}
+		return nil
+	})
+}
+
+// stream выполняет запрос с повторами и копирует тело ответа 2xx в w.
+// Обрыв после начала копирования не повторяется: часть ответа уже в w.
+func (c *Client) stream(ctx context.Context, req request, w io.Writer) error {
+	return c.doFunc(ctx, req, func(body io.Reader) error {
+		if _, err := io.Copy(w, body); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return fmt.Errorf("client: read response: %w", err)
+		}
+		return nil
+	})
+}
+
+// doFunc выполняет запрос с повторами и передает тело ответа 2xx в handle
+func (c *Client) doFunc(ctx context.Context, req request, handle func(io.Reader) error) error {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("client: encode request: %w", err)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.send(ctx, req, payload, handle)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.retry.MaxAttempts || !c.shouldRetry(req, err) {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > 0 {
+			if retryAfter > c.retry.MaxDelay {
+				return err
+			}
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send - одна попытка; возвращает Retry-After ответа, если он был
+func (c *Client) send(ctx context.Context, req request, payload []byte, handle func(io.Reader) error) (time.Duration, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return 0, fmt.Errorf("client: build request: %w", err)
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	httpReq.Header.Set("User-Agent", userAgent)
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	c.mu.RLock()
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	}
+	c.mu.RUnlock()
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, &transportError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return 0, ctxErr
+			}
+			return 0, &transportError{err: err}
+		}
+		apiErr := newAPIError(resp, data)
+		return apiErr.RetryAfter, apiErr
+	}
+	if err := handle(resp.Body); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+func (c *Client) shouldRetry(req request, err error) bool {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests:
+			// Запрос отклонен до обработки
+			return true
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return req.retryable
+		}
+		return false
+	case errors.As(err, new(*transportError)):
+		return req.retryable
+	}
+	return false
+}
+
+// backoff - экспоненциальная задержка перед повтором attempt со случайной
+// добавкой до половины, чтобы клиенты не повторяли запросы одновременно
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.retry.BaseDelay
+	for i := 1; i < attempt && delay < c.retry.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.retry.MaxDelay {
+		delay = c.retry.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// transportError - запрос не дошел до API или ответ не прочитан
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return "client: " + e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// parseRetryAfter разбирает Retry-After в секундах или как дату HTTP
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
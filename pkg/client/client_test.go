@@ -0,0 +1,559 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"walletapitest/internal/app"
+	"walletapitest/internal/config"
+	"walletapitest/internal/domain/repositories"
+	"walletapitest/internal/domain/services"
+	"walletapitest/internal/infrastructure/database/memory"
+	"walletapitest/internal/infrastructure/database/migrations"
+	"walletapitest/internal/infrastructure/database/postgres"
+	"walletapitest/internal/pkg/logger"
+	"walletapitest/internal/pkg/totp"
+	"walletapitest/pkg/client"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	password = "secret-password"
+	mfaKey   = "test-mfa-key"
+	// stepUpThreshold - списания выше этой суммы требуют код второго фактора
+	stepUpThreshold = 1000
+	// envTestDSN - Postgres, на котором сценарии повторяются вслед за памятью
+	// (отдельная база: данные тестов остаются в ней)
+	envTestDSN = "WALLET_TEST_DSN"
+)
+
+// testAPI - настоящий роутер на хранилище в памяти или Postgres и
+// репозитории того же хранилища для подготовки данных в обход API
+type testAPI struct {
+	handler http.Handler
+	users   repositories.UserRepository
+	mfa     repositories.MFARepository
+	logins  repositories.LoginRepository
+}
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		JWT:  config.JWTConfig{SecretKey: "test-secret", ExpiresIn: 3600, RefreshExpiresIn: 86400},
+		Mail: config.MailConfig{Driver: "file", FilePath: filepath.Join(t.TempDir(), "mail.log")},
+		MFA:  config.MFAConfig{Issuer: "Wallet", EncryptionKey: mfaKey, WithdrawalThreshold: stepUpThreshold},
+	}
+}
+
+// newTestAPI - API на хранилище в памяти
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	store := memory.NewStore()
+	handler, err := app.New(testConfig(t), logger.New("error")).MemoryHandler(store)
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	return &testAPI{
+		handler: handler,
+		users:   memory.NewUserRepository(store),
+		mfa:     memory.NewMFARepository(store),
+		logins:  memory.NewLoginRepository(store),
+	}
+}
+
+// newPostgresAPI - API на Postgres из dsn с примененными миграциями
+func newPostgresAPI(t *testing.T, dsn string) *testAPI {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqlx.ConnectContext(ctx, "postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", envTestDSN, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Up(ctx, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	handler, err := app.New(testConfig(t), logger.New("error")).PostgresHandler(db)
+	if err != nil {
+		t.Fatalf("build handler: %v", err)
+	}
+	return &testAPI{
+		handler: handler,
+		users:   postgres.NewUserRepository(db),
+		mfa:     postgres.NewMFARepository(db),
+		logins:  postgres.NewLoginRepository(db),
+	}
+}
+
+// eachBackend проводит сценарий на памяти и, если задан WALLET_TEST_DSN, на
+// Postgres: хранилища должны вести себя для клиента одинаково
+func eachBackend(t *testing.T, test func(t *testing.T, api *testAPI)) {
+	t.Helper()
+	t.Run("memory", func(t *testing.T) { test(t, newTestAPI(t)) })
+	dsn := os.Getenv(envTestDSN)
+	if dsn == "" {
+		t.Logf("%s is not set, Postgres is skipped", envTestDSN)
+		return
+	}
+	t.Run("postgres", func(t *testing.T) { test(t, newPostgresAPI(t, dsn)) })
+}
+
+// uniqueName - имя пользователя, не занятое прошлыми прогонами на той же базе
+func uniqueName(name string) string {
+	return name + "-" + uuid.NewString()[:8]
+}
+
+// serve запускает сервер; wrap может подменить ответы роутера
+func (api *testAPI) serve(t *testing.T, wrap func(http.Handler) http.Handler) string {
+	t.Helper()
+	handler := api.handler
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// verifyEmail подтверждает email в обход письма
+func (api *testAPI) verifyEmail(t *testing.T, userID uuid.UUID) {
+	t.Helper()
+	user, err := api.users.FindByID(context.Background(), userID)
+	if err != nil || user == nil {
+		t.Fatalf("find user: %v", err)
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := api.users.Update(context.Background(), user, nil); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+}
+
+// enableTOTP включает пользователю второй фактор в обход API и возвращает
+// TOTP-секрет
+func (api *testAPI) enableTOTP(t *testing.T, userID uuid.UUID) string {
+	t.Helper()
+	ctx := context.Background()
+	cipher, err := totp.NewCipher(mfaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa := services.NewMFAService(api.mfa, api.users, cipher, "Wallet", nil, nil)
+	enrollment, err := mfa.Enroll(ctx, userID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfa.Confirm(ctx, userID, code); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	return enrollment.Secret
+}
+
+func newClient(t *testing.T, baseURL string, opts ...client.Option) *client.Client {
+	t.Helper()
+	opts = append([]client.Option{client.WithRetry(client.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	})}, opts...)
+	c, err := client.New(baseURL, opts...)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c
+}
+
+// signUp регистрирует пользователя, входит и создает ему кошелек
+func signUp(t *testing.T, c *client.Client, name string) (*client.User, *client.Wallet) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := c.CreateUser(ctx, name+"@example.com", name, password)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := c.Login(ctx, user.Email, password, ""); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	wallet, err := c.CreateWallet(ctx, user.ID, "")
+	if err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	return user, wallet
+}
+
+func assertIs(t *testing.T, err error, targets ...error) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	for _, target := range targets {
+		if !errors.Is(err, target) {
+			t.Errorf("errors.Is(%v, %v) = false", err, target)
+		}
+	}
+}
+
+func TestUsersAndWallets(t *testing.T) {
+	eachBackend(t, testUsersAndWallets)
+}
+
+func testUsersAndWallets(t *testing.T, api *testAPI) {
+	c := newClient(t, api.serve(t, nil))
+	ctx := context.Background()
+
+	name := uniqueName("alice")
+	user, wallet := signUp(t, c, name)
+	if c.Token() == "" {
+		t.Fatal("login did not store the token")
+	}
+
+	got, err := c.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if got.Email != name+"@example.com" || got.Username != name {
+		t.Errorf("unexpected user: %+v", got)
+	}
+	if wallet.UserID != user.ID || wallet.Currency != "USD" || wallet.Balance != 0 {
+		t.Errorf("unexpected wallet: %+v", wallet)
+	}
+
+	deposit, err := c.Deposit(ctx, wallet.ID, 1000)
+	if err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if deposit.Balance != 1000 || deposit.Replayed || deposit.IdempotencyKey == uuid.Nil {
+		t.Errorf("unexpected deposit result: %+v", deposit)
+	}
+
+	// Списания - только с подтвержденным email
+	_, err = c.Withdraw(ctx, wallet.ID, 300)
+	assertIs(t, err, client.ErrForbidden, client.ErrEmailNotVerified)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || !apiErr.EmailVerificationRequired || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected api error: %#v", err)
+	}
+
+	api.verifyEmail(t, user.ID)
+	withdraw, err := c.Withdraw(ctx, wallet.ID, 300)
+	if err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+	if withdraw.Balance != 700 {
+		t.Errorf("balance after withdraw = %d, want 700", withdraw.Balance)
+	}
+
+	got2, err := c.GetWallet(ctx, wallet.ID)
+	if err != nil {
+		t.Fatalf("get wallet: %v", err)
+	}
+	if got2.Balance != 700 {
+		t.Errorf("wallet balance = %d, want 700", got2.Balance)
+	}
+
+	ops, err := c.ListOperations(ctx, wallet.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("list operations: %v", err)
+	}
+	if len(ops) != 2 || ops[0].OperationType != client.Withdraw || ops[1].OperationType != client.Deposit {
+		t.Fatalf("unexpected history: %+v", ops)
+	}
+	if ops[0].RequestID == nil || *ops[0].RequestID != withdraw.IdempotencyKey {
+		t.Errorf("withdrawal was not stored with its idempotency key")
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	eachBackend(t, testTypedErrors)
+}
+
+func testTypedErrors(t *testing.T, api *testAPI) {
+	baseURL := api.serve(t, nil)
+	c := newClient(t, baseURL)
+	ctx := context.Background()
+
+	name := uniqueName("bob")
+	user, wallet := signUp(t, c, name)
+	api.verifyEmail(t, user.ID)
+
+	_, err := c.CreateUser(ctx, name+"@example.com", name+"2", password)
+	assertIs(t, err, client.ErrConflict)
+
+	_, err = c.Login(ctx, name+"@example.com", "wrong-password", "")
+	assertIs(t, err, client.ErrUnauthorized)
+
+	_, err = c.Withdraw(ctx, wallet.ID, 1)
+	assertIs(t, err, client.ErrValidation, client.ErrInsufficientFunds)
+
+	_, err = c.GetWallet(ctx, uuid.New())
+	assertIs(t, err, client.ErrNotFound)
+
+	_, err = c.Deposit(ctx, wallet.ID, -5)
+	assertIs(t, err, client.ErrValidation)
+
+	// Ключ идемпотентности нельзя переиспользовать для другой операции
+	key := uuid.New()
+	if _, err := c.ProcessOperation(ctx, client.OperationRequest{
+		WalletID: wallet.ID, Type: client.Deposit, Amount: 10, IdempotencyKey: key,
+	}); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	_, err = c.ProcessOperation(ctx, client.OperationRequest{
+		WalletID: wallet.ID, Type: client.Deposit, Amount: 20, IdempotencyKey: key,
+	})
+	assertIs(t, err, client.ErrConflict, client.ErrIdempotencyKeyReused)
+
+	// Чужой кошелек недоступен
+	other := newClient(t, baseURL)
+	signUp(t, other, uniqueName("carol"))
+	_, err = other.GetWallet(ctx, wallet.ID)
+	assertIs(t, err, client.ErrForbidden)
+	_, err = other.GetUser(ctx, user.ID)
+	assertIs(t, err, client.ErrForbidden)
+
+	anonymous := newClient(t, baseURL)
+	_, err = anonymous.GetWallet(ctx, wallet.ID)
+	assertIs(t, err, client.ErrUnauthorized)
+	_, err = anonymous.GetUser(ctx, user.ID)
+	assertIs(t, err, client.ErrUnauthorized)
+}
+
+// dropResponse - транспорт, который теряет ответ на первую операцию:
+// сервер ее провел, а клиент видит обрыв соединения
+type dropResponse struct {
+	next    http.RoundTripper
+	dropped atomic.Bool
+	keys    []string
+}
+
+func (d *dropResponse) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := d.next.RoundTrip(req)
+	if err != nil || req.URL.Path != "/api/v1/wallet" {
+		return resp, err
+	}
+	d.keys = append(d.keys, req.Header.Get(client.IdempotencyKeyHeader))
+	if d.dropped.CompareAndSwap(false, true) {
+		resp.Body.Close()
+		return nil, errors.New("connection reset by peer")
+	}
+	return resp, nil
+}
+
+func TestRetryReplaysOperation(t *testing.T) {
+	eachBackend(t, testRetryReplaysOperation)
+}
+
+func testRetryReplaysOperation(t *testing.T, api *testAPI) {
+	baseURL := api.serve(t, nil)
+	transport := &dropResponse{next: http.DefaultTransport}
+	c := newClient(t, baseURL, client.WithHTTPClient(&http.Client{Transport: transport}))
+	ctx := context.Background()
+
+	_, wallet := signUp(t, c, uniqueName("dave"))
+
+	result, err := c.Deposit(ctx, wallet.ID, 500)
+	if err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if !result.Replayed || result.Balance != 500 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(transport.keys) != 2 || transport.keys[0] != transport.keys[1] || transport.keys[0] != result.IdempotencyKey.String() {
+		t.Errorf("retry did not reuse the idempotency key: %v", transport.keys)
+	}
+
+	// Операция проведена один раз
+	got, err := c.GetWallet(ctx, wallet.ID)
+	if err != nil {
+		t.Fatalf("get wallet: %v", err)
+	}
+	if got.Balance != 500 {
+		t.Errorf("balance = %d, want 500", got.Balance)
+	}
+	ops, err := c.ListOperations(ctx, wallet.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("list operations: %v", err)
+	}
+	if len(ops) != 1 {
+		t.Errorf("operations = %d, want 1", len(ops))
+	}
+}
+
+func TestRetryReplaysStepUpWithdrawal(t *testing.T) {
+	eachBackend(t, testRetryReplaysStepUpWithdrawal)
+}
+
+func testRetryReplaysStepUpWithdrawal(t *testing.T, api *testAPI) {
+	baseURL := api.serve(t, nil)
+	ctx := context.Background()
+
+	owner := newClient(t, baseURL)
+	user, wallet := signUp(t, owner, uniqueName("grace"))
+	api.verifyEmail(t, user.ID)
+	if _, err := owner.Deposit(ctx, wallet.ID, 5000); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	secret := api.enableTOTP(t, user.ID)
+
+	// Повтор идет с тем же кодом, который сервер уже принял в первой попытке
+	transport := &dropResponse{next: http.DefaultTransport}
+	c := newClient(t, baseURL, client.WithHTTPClient(&http.Client{Transport: transport}), client.WithToken(owner.Token()))
+	// Код следующего интервала: код текущего уже использован при включении
+	otp, err := totp.Code(secret, totp.Step(time.Now())+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.ProcessOperation(ctx, client.OperationRequest{
+		WalletID: wallet.ID, Type: client.Withdraw, Amount: 2 * stepUpThreshold, OTP: otp,
+	})
+	if err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+	if !result.Replayed || result.Balance != 5000-2*stepUpThreshold {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(transport.keys) != 2 || transport.keys[0] != transport.keys[1] {
+		t.Errorf("retry did not reuse the idempotency key: %v", transport.keys)
+	}
+
+	ops, err := owner.ListOperations(ctx, wallet.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("list operations: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Errorf("operations = %d, want 2", len(ops))
+	}
+
+	// Повтор не засчитан как неверный код
+	lockout, err := api.logins.FindLockout(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("find lockout: %v", err)
+	}
+	if lockout != nil && lockout.FailedCount != 0 {
+		t.Errorf("failed count = %d, want 0", lockout.FailedCount)
+	}
+}
+
+// unavailable отвечает 503 на первые n запросов к path
+func unavailable(path string, n int32, retryAfter string, calls *atomic.Int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == path && calls.Add(1) <= n {
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"error":"unable to verify session"}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retries reads", func(t *testing.T) {
+		api := newTestAPI(t)
+		owner := newClient(t, api.serve(t, nil))
+		user, _ := signUp(t, owner, uniqueName("erin"))
+
+		var calls atomic.Int32
+		c := newClient(t, api.serve(t, unavailable("/api/v1/users/"+user.ID.String(), 2, "0", &calls)), client.WithToken(owner.Token()))
+		if _, err := c.GetUser(ctx, user.ID); err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("attempts = %d, want 3", calls.Load())
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		api := newTestAPI(t)
+		var calls atomic.Int32
+		c := newClient(t, api.serve(t, unavailable("/api/v1/users/"+uuid.Nil.String(), 10, "", &calls)))
+		_, err := c.GetUser(ctx, uuid.Nil)
+		assertIs(t, err, client.ErrUnavailable)
+		if calls.Load() != 3 {
+			t.Errorf("attempts = %d, want 3", calls.Load())
+		}
+	})
+
+	t.Run("does not retry registration", func(t *testing.T) {
+		api := newTestAPI(t)
+		var calls atomic.Int32
+		c := newClient(t, api.serve(t, unavailable("/api/v1/users", 1, "", &calls)))
+		_, err := c.CreateUser(ctx, "frank@example.com", "frank", password)
+		assertIs(t, err, client.ErrUnavailable)
+		if calls.Load() != 1 {
+			t.Errorf("attempts = %d, want 1", calls.Load())
+		}
+	})
+
+	t.Run("respects long Retry-After", func(t *testing.T) {
+		api := newTestAPI(t)
+		var calls atomic.Int32
+		c := newClient(t, api.serve(t, unavailable("/api/v1/users/"+uuid.Nil.String(), 10, "120", &calls)))
+		_, err := c.GetUser(ctx, uuid.Nil)
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.RetryAfter != 120*time.Second {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("attempts = %d, want 1", calls.Load())
+		}
+	})
+}
+
+func TestContextCancellation(t *testing.T) {
+	api := newTestAPI(t)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	stall := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/users/"+uuid.Nil.String() {
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	c := newClient(t, api.serve(t, stall))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.GetUser(ctx, uuid.Nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request was not cancelled in time: %v", elapsed)
+	}
+}
+
+func TestNewRejectsInvalidBaseURL(t *testing.T) {
+	for _, raw := range []string{"localhost:8080", "ftp://example.com", "://"} {
+		if _, err := client.New(raw); err == nil {
+			t.Errorf("New(%q) succeeded", raw)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Классы ошибок API для errors.Is. Ошибка может относиться к нескольким
+// классам: нехватка средств - это и ErrInsufficientFunds, и ErrValidation.
+var (
+	ErrValidation     = errors.New("client: invalid request")
+	ErrUnauthorized   = errors.New("client: unauthorized")
+	ErrForbidden      = errors.New("client: forbidden")
+	ErrNotFound       = errors.New("client: not found")
+	ErrConflict       = errors.New("client: conflict")
+	ErrRateLimited    = errors.New("client: rate limited")
+	ErrServer         = errors.New("client: server error")
+	ErrUnavailable    = errors.New("client: service unavailable")
+	ErrUnexpectedCode = errors.New("client: unexpected status code")
+
+	ErrInsufficientFunds = errors.New("client: insufficient funds")
+	ErrWalletFrozen      = errors.New("client: wallet is frozen")
+	// ErrIdempotencyKeyReused - ключ идемпотентности уже использован для
+	// другой операции
+	ErrIdempotencyKeyReused = errors.New("client: idempotency key reused for a different operation")
+	ErrMFARequired          = errors.New("client: two-factor code required")
+	ErrStepUpRequired       = errors.New("client: step-up verification required")
+	ErrEmailNotVerified     = errors.New("client: email address is not verified")
+)
+
+// Сообщения API, по которым различаются ошибки с одним кодом ответа
+var messageErrors = map[string]error{
+	"insufficient funds": ErrInsufficientFunds,
+	"wallet is frozen":   ErrWalletFrozen,
+	"request id was already used for a different operation": ErrIdempotencyKeyReused,
+}
+
+// APIError - ответ API с кодом не 2xx
+type APIError struct {
+	StatusCode int
+	// Message - поле error ответа
+	Message string
+	// MFARequired - для входа нужен второй фактор
+	MFARequired bool
+	// StepUpRequired - списание выше Threshold требует otp
+	StepUpRequired bool
+	Threshold      int64
+	// EmailVerificationRequired - списание запрещено до подтверждения email
+	EmailVerificationRequired bool
+	// RetryAfter - из заголовка Retry-After (0 - не задан)
+	RetryAfter time.Duration
+	// RequestID - X-Request-ID ответа для поиска в логах сервера
+	RequestID string
+
+	kinds []error
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("api error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("api error: %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap отдает классы ошибки для errors.Is
+func (e *APIError) Unwrap() []error {
+	return e.kinds
+}
+
+func newAPIError(resp *http.Response, data []byte) *APIError {
+	var body struct {
+		Error                     string `json:"error"`
+		MFARequired               bool   `json:"mfa_required"`
+		StepUpRequired            bool   `json:"step_up_required"`
+		Threshold                 int64  `json:"threshold"`
+		EmailVerificationRequired bool   `json:"email_verification_required"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		body.Error = strings.TrimSpace(string(data))
+	}
+
+	e := &APIError{
+		StatusCode:                resp.StatusCode,
+		Message:                   body.Error,
+		MFARequired:               body.MFARequired,
+		StepUpRequired:            body.StepUpRequired,
+		Threshold:                 body.Threshold,
+		EmailVerificationRequired: body.EmailVerificationRequired,
+		RetryAfter:                parseRetryAfter(resp.Header.Get("Retry-After")),
+		RequestID:                 resp.Header.Get("X-Request-ID"),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest, resp.StatusCode == http.StatusUnprocessableEntity:
+		e.kinds = append(e.kinds, ErrValidation)
+	case resp.StatusCode == http.StatusUnauthorized:
+		e.kinds = append(e.kinds, ErrUnauthorized)
+	case resp.StatusCode == http.StatusForbidden:
+		e.kinds = append(e.kinds, ErrForbidden)
+	case resp.StatusCode == http.StatusNotFound:
+		e.kinds = append(e.kinds, ErrNotFound)
+	case resp.StatusCode == http.StatusConflict:
+		e.kinds = append(e.kinds, ErrConflict)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.kinds = append(e.kinds, ErrRateLimited)
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		e.kinds = append(e.kinds, ErrUnavailable)
+	case resp.StatusCode >= 500:
+		e.kinds = append(e.kinds, ErrServer)
+	default:
+		e.kinds = append(e.kinds, ErrUnexpectedCode)
+	}
+	if err, ok := messageErrors[e.Message]; ok {
+		e.kinds = append(e.kinds, err)
+	}
+	if e.MFARequired {
+		e.kinds = append(e.kinds, ErrMFARequired)
+	}
+	if e.StepUpRequired {
+		e.kinds = append(e.kinds, ErrStepUpRequired)
+	}
+	if e.EmailVerificationRequired {
+		e.kinds = append(e.kinds, ErrEmailNotVerified)
+	}
+	return e
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OperationType string
+
+const (
+	Deposit  OperationType = "DEPOSIT"
+	Withdraw OperationType = "WITHDRAW"
+)
+
+type User struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	Username      string    `json:"username"`
+	Role          string    `json:"role"`
+	Tier          string    `json:"tier"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     string    `json:"created_at"`
+}
+
+// Session - результат входа; Login сохраняет Token в клиенте
+type Session struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	User             User      `json:"user"`
+}
+
+// Wallet - кошелек; суммы в минимальных единицах валюты
+type Wallet struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Balance   int64     `json:"balance"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	CreatedAt string    `json:"created_at"`
+	UpdatedAt string    `json:"updated_at"`
+}
+
+// OperationRequest - разовая операция с кошельком
+type OperationRequest struct {
+	WalletID uuid.UUID
+	Type     OperationType
+	Amount   int64
+	// OTP - второй фактор для списаний выше порога step-up
+	OTP string
+	// IdempotencyKey - ключ идемпотентности; пустой - клиент создаст новый.
+	// Задайте свой, чтобы повторить операцию после перезапуска процесса.
+	IdempotencyKey uuid.UUID
+}
+
+// Fee - комиссия, списанная вместе с операцией
+type Fee struct {
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Rule        string    `json:"rule"`
+	FeeWalletID uuid.UUID `json:"fee_wallet_id"`
+}
+
+// OperationResult - итог операции
+type OperationResult struct {
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	OperationID   uuid.UUID     `json:"operationId"`
+	// Balance - баланс после операции и комиссии
+	Balance int64 `json:"balance"`
+	Fee     *Fee  `json:"fee"`
+	// Replayed - операция уже была проведена раньше с тем же ключом
+	Replayed bool `json:"replayed"`
+	// IdempotencyKey - ключ, с которым операция проведена
+	IdempotencyKey uuid.UUID `json:"-"`
+}
+
+// Operation - запись истории кошелька
+type Operation struct {
+	ID            uuid.UUID     `json:"id"`
+	WalletID      uuid.UUID     `json:"wallet_id"`
+	OperationType OperationType `json:"operation_type"`
+	Amount        int64         `json:"amount"`
+	BalanceAfter  int64         `json:"balance_after"`
+	Reason        *string       `json:"reason,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	TransferID    *uuid.UUID    `json:"transfer_id,omitempty"`
+	RequestID     *uuid.UUID    `json:"request_id,omitempty"`
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// CreateUser регистрирует пользователя; письмо для подтверждения email
+// сервер отправляет сам
+func (c *Client) CreateUser(ctx context.Context, email, username, password string) (*User, error) {
+	var user User
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v1/users",
+		body: map[string]string{
+			"email":    email,
+			"username": username,
+			"password": password,
+		},
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+	var user User
+	err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/v1/users/" + id.String(),
+		retryable: true,
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login открывает сессию и сохраняет access JWT в клиенте. otp нужен, если
+// у пользователя включен второй фактор (иначе ошибка с ErrMFARequired).
+func (c *Client) Login(ctx context.Context, email, password, otp string) (*Session, error) {
+	var session Session
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v1/login",
+		body: map[string]string{
+			"email":    email,
+			"password": password,
+			"otp":      otp,
+		},
+	}, &session)
+	if err != nil {
+		return nil, err
+	}
+	c.SetToken(session.Token)
+	return &session, nil
+}
+
+// VerifyEmail подтверждает email токеном из письма
+func (c *Client) VerifyEmail(ctx context.Context, token string) (*User, error) {
+	var user User
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v1/email/verify",
+		body:   map[string]string{"token": token},
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// CreateWallet создает кошелек пользователя; пустая currency - USD
+func (c *Client) CreateWallet(ctx context.Context, userID uuid.UUID, currency string) (*Wallet, error) {
+	body := map[string]string{"user_id": userID.String()}
+	if currency != "" {
+		body["currency"] = currency
+	}
+	var wallet Wallet
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/v1/wallet/create",
+		body:   body,
+	}, &wallet)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (c *Client) GetWallet(ctx context.Context, id uuid.UUID) (*Wallet, error) {
+	var wallet Wallet
+	err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/v1/wallet/" + id.String(),
+		retryable: true,
+	}, &wallet)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// ProcessOperation проводит операцию синхронно. Все попытки идут с одним
+// ключом идемпотентности, поэтому повтор после обрыва соединения не
+// проведет операцию дважды: сервер вернет первую с Replayed.
+func (c *Client) ProcessOperation(ctx context.Context, op OperationRequest) (*OperationResult, error) {
+	if op.Type != Deposit && op.Type != Withdraw {
+		return nil, fmt.Errorf("client: unknown operation type %q", op.Type)
+	}
+	key := op.IdempotencyKey
+	if key == uuid.Nil {
+		key = uuid.New()
+	}
+
+	body := map[string]interface{}{
+		"walletId":      op.WalletID,
+		"operationType": op.Type,
+		"amount":        op.Amount,
+	}
+	if op.OTP != "" {
+		body["otp"] = op.OTP
+	}
+	var result OperationResult
+	err := c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/v1/wallet",
+		body:      body,
+		header:    http.Header{IdempotencyKeyHeader: []string{key.String()}},
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	result.IdempotencyKey = key
+	return &result, nil
+}
+
+func (c *Client) Deposit(ctx context.Context, walletID uuid.UUID, amount int64) (*OperationResult, error) {
+	return c.ProcessOperation(ctx, OperationRequest{WalletID: walletID, Type: Deposit, Amount: amount})
+}
+
+// Withdraw списывает amount; для сумм выше порога step-up используйте
+// ProcessOperation с OTP
+func (c *Client) Withdraw(ctx context.Context, walletID uuid.UUID, amount int64) (*OperationResult, error) {
+	return c.ProcessOperation(ctx, OperationRequest{WalletID: walletID, Type: Withdraw, Amount: amount})
+}
+
+// ListOperations - история кошелька, новые первыми; limit 0 - размер
+// страницы сервера по умолчанию
+func (c *Client) ListOperations(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]Operation, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	var page struct {
+		Operations []Operation `json:"operations"`
+	}
+	err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/v1/wallet/" + walletID.String() + "/operations",
+		query:     query,
+		retryable: true,
+	}, &page)
+	if err != nil {
+		return nil, err
+	}
+	return page.Operations, nil
+}